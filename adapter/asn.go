@@ -0,0 +1,9 @@
+package adapter
+
+import "net/netip"
+
+type ASNDatabase interface {
+	LifecycleService
+	// LookupASN returns the number of the autonomous system announcing addr, or zero if not found.
+	LookupASN(addr netip.Addr) uint32
+}
@@ -0,0 +1,9 @@
+package adapter
+
+import "net/netip"
+
+type CountryDatabase interface {
+	LifecycleService
+	// LookupCountry returns the lower case country code of addr, or "unknown" if not found.
+	LookupCountry(addr netip.Addr) string
+}
@@ -3,12 +3,14 @@ package adapter
 import (
 	"context"
 	"net/netip"
+	"time"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/common/observable"
 	"github.com/sagernet/sing/service"
 
 	"github.com/miekg/dns"
@@ -21,6 +23,7 @@ type DNSRouter interface {
 	ClearCache()
 	LookupReverseMapping(ip netip.Addr) (string, bool)
 	ResetNetwork()
+	QueryLog() DNSQueryLog
 }
 
 type DNSClient interface {
@@ -37,6 +40,11 @@ type DNSQueryOptions struct {
 	DisableCache   bool
 	RewriteTTL     *uint32
 	ClientSubnet   netip.Prefix
+	Rewriters      []DNSResponseRewriter
+}
+
+type DNSResponseRewriter interface {
+	RewriteResponse(ctx context.Context, response *dns.Msg)
 }
 
 func DNSQueryOptionsFrom(ctx context.Context, options *option.DomainResolveOptions) (*DNSQueryOptions, error) {
@@ -91,3 +99,36 @@ type DNSTransportManager interface {
 	Remove(tag string) error
 	Create(ctx context.Context, logger log.ContextLogger, tag string, outboundType string, options any) error
 }
+
+type DNSQueryLog interface {
+	Records(limit int) []DNSQueryRecord
+	DomainStatistics() []DNSQueryStatistics
+	ClientStatistics() []DNSQueryStatistics
+	Subscribe() (subscription observable.Subscription[DNSQueryRecord], done <-chan struct{}, err error)
+	UnSubscribe(subscription observable.Subscription[DNSQueryRecord])
+	Reset()
+}
+
+type DNSQueryRecord struct {
+	ID        uint64        `json:"id"`
+	Time      time.Time     `json:"time"`
+	Domain    string        `json:"domain"`
+	QueryType string        `json:"type"`
+	Inbound   string        `json:"inbound,omitempty"`
+	Client    string        `json:"client,omitempty"`
+	Rule      string        `json:"rule,omitempty"`
+	Transport string        `json:"transport,omitempty"`
+	Rcode     string        `json:"rcode,omitempty"`
+	Answers   []string      `json:"answers,omitempty"`
+	Latency   time.Duration `json:"latency"`
+	Cached    bool          `json:"cached"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type DNSQueryStatistics struct {
+	Name     string    `json:"name"`
+	Total    uint64    `json:"total"`
+	Cached   uint64    `json:"cached"`
+	Failed   uint64    `json:"failed"`
+	LastSeen time.Time `json:"last_seen"`
+}
@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -54,6 +55,17 @@ func (m *Registry) CreateOptions(outboundType string) (any, bool) {
 	return optionsConstructor(), true
 }
 
+func (m *Registry) Types() []string {
+	m.access.Lock()
+	defer m.access.Unlock()
+	types := make([]string, 0, len(m.optionsType))
+	for optionsType := range m.optionsType {
+		types = append(types, optionsType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 func (m *Registry) Create(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, outboundType string, options any) (adapter.Endpoint, error) {
 	m.access.Lock()
 	defer m.access.Unlock()
@@ -2,6 +2,7 @@ package adapter
 
 import (
 	"context"
+	"crypto/tls"
 	"net/netip"
 	"time"
 
@@ -52,12 +53,23 @@ type InboundContext struct {
 
 	// sniffer
 
-	Protocol     string
-	Domain       string
-	Client       string
-	SniffContext any
-	SnifferNames []string
-	SniffError   error
+	Protocol string
+	Domain   string
+	Client   string
+	JA3      string
+	JA4      string
+	// MQTT client ID and topic prefix
+	MQTTClientID string
+	Topic        string
+	// plain HTTP request
+	HTTPMethod    string
+	HTTPHost      string
+	HTTPPath      string
+	HTTPURL       string
+	HTTPUserAgent string
+	SniffContext  any
+	SnifferNames  []string
+	SniffError    error
 
 	// cache
 
@@ -72,6 +84,11 @@ type InboundContext struct {
 	TLSFragment               bool
 	TLSFragmentFallbackDelay  time.Duration
 	TLSRecordFragment         bool
+	MITM                      bool
+	MITMState                 *tls.ConnectionState
+	HTTPHeaderRewriters       []HTTPHeaderRewriter
+	// PROXY protocol version to send by direct outbounds
+	ProxyProtocol uint8
 
 	NetworkStrategy     *C.NetworkStrategy
 	NetworkType         []C.InterfaceType
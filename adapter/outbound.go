@@ -32,6 +32,30 @@ type DirectRouteOutbound interface {
 	NewDirectRouteConnection(metadata InboundContext, routeContext tun.DirectRouteContext, timeout time.Duration) (tun.DirectRouteDestination, error)
 }
 
+type MultiplexOutbound interface {
+	Outbound
+	// MultiplexClient returns nil if multiplex is disabled.
+	MultiplexClient() MultiplexClient
+}
+
+type MultiplexClient interface {
+	Sessions() []MultiplexSession
+	// Drain stops opening new streams on existing sessions and closes them once their streams are finished.
+	Drain()
+}
+
+type MultiplexSession struct {
+	ID           uint64    `json:"id"`
+	Protocol     string    `json:"protocol"`
+	CreatedAt    time.Time `json:"createdAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+	Streams      int       `json:"streams"`
+	TotalStreams uint64    `json:"totalStreams"`
+	Upload       int64     `json:"upload"`
+	Download     int64     `json:"download"`
+	Draining     bool      `json:"draining"`
+}
+
 type OutboundRegistry interface {
 	option.OutboundOptionsRegistry
 	CreateOutbound(ctx context.Context, router Router, logger log.ContextLogger, tag string, outboundType string, options any) (Outbound, error)
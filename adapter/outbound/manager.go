@@ -3,7 +3,9 @@ package outbound
 import (
 	"context"
 	"io"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -238,6 +240,10 @@ func (m *Manager) Remove(tag string) error {
 	if !found {
 		return os.ErrInvalid
 	}
+	dependBy := m.dependByTag[tag]
+	if len(dependBy) > 0 {
+		return E.New("outbound[", tag, "] is depended by ", strings.Join(dependBy, ", "))
+	}
 	delete(m.outboundByTag, tag)
 	index := common.Index(m.outbounds, func(it adapter.Outbound) bool {
 		return it == outbound
@@ -255,20 +261,7 @@ func (m *Manager) Remove(tag string) error {
 			m.defaultOutbound = nil
 		}
 	}
-	dependBy := m.dependByTag[tag]
-	if len(dependBy) > 0 {
-		return E.New("outbound[", tag, "] is depended by ", strings.Join(dependBy, ", "))
-	}
-	dependencies := outbound.Dependencies()
-	for _, dependency := range dependencies {
-		if len(m.dependByTag[dependency]) == 1 {
-			delete(m.dependByTag, dependency)
-		} else {
-			m.dependByTag[dependency] = common.Filter(m.dependByTag[dependency], func(it string) bool {
-				return it != tag
-			})
-		}
-	}
+	m.removeDependencies(tag, outbound)
 	if started {
 		return common.Close(outbound)
 	}
@@ -297,13 +290,21 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 	}
 	m.access.Lock()
 	defer m.access.Unlock()
-	if existsOutbound, loaded := m.outboundByTag[tag]; loaded {
-		if m.started {
-			err = common.Close(existsOutbound)
-			if err != nil {
-				return E.Cause(err, "close outbound/", existsOutbound.Type(), "[", existsOutbound.Tag(), "]")
-			}
+	existsOutbound := m.replace(tag, outbound, outboundType, options)
+	if existsOutbound != nil && m.started {
+		err = common.Close(existsOutbound)
+		if err != nil {
+			return E.Cause(err, "close outbound/", existsOutbound.Type(), "[", existsOutbound.Tag(), "]")
 		}
+	}
+	return nil
+}
+
+// replace registers the outbound and returns the outbound with the same tag it replaces.
+// The caller must hold the lock and close the replaced outbound.
+func (m *Manager) replace(tag string, outbound adapter.Outbound, outboundType string, options any) adapter.Outbound {
+	existsOutbound, loaded := m.outboundByTag[tag]
+	if loaded {
 		existsIndex := common.Index(m.outbounds, func(it adapter.Outbound) bool {
 			return it == existsOutbound
 		})
@@ -311,6 +312,7 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 			panic("invalid inbound index")
 		}
 		m.outbounds = append(m.outbounds[:existsIndex], m.outbounds[existsIndex+1:]...)
+		m.removeDependencies(tag, existsOutbound)
 	}
 	m.outbounds = append(m.outbounds, outbound)
 	m.outboundByTag[tag] = outbound
@@ -318,7 +320,7 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 	for _, dependency := range dependencies {
 		m.dependByTag[dependency] = append(m.dependByTag[dependency], tag)
 	}
-	if tag == m.defaultTag || (m.defaultTag == "" && m.defaultOutbound == nil) {
+	if tag == m.defaultTag || (m.defaultTag == "" && m.defaultOutbound == nil) || (loaded && m.defaultOutbound == existsOutbound) {
 		m.defaultOutbound = outbound
 		if m.started {
 			m.logger.Info("updated default outbound to ", outbound.Tag())
@@ -328,7 +330,20 @@ func (m *Manager) Create(ctx context.Context, router adapter.Router, logger log.
 		typ:     outboundType,
 		options: options,
 	}
-	return nil
+	return existsOutbound
+}
+
+func (m *Manager) removeDependencies(tag string, outbound adapter.Outbound) {
+	dependencies := outbound.Dependencies()
+	for _, dependency := range dependencies {
+		if len(m.dependByTag[dependency]) == 1 {
+			delete(m.dependByTag, dependency)
+		} else {
+			m.dependByTag[dependency] = common.Filter(m.dependByTag[dependency], func(it string) bool {
+				return it != tag
+			})
+		}
+	}
 }
 
 // DupOverrideDetour duplicates the outbound with the specified tag and sets the override and detour for the duplicated outbound.
@@ -358,3 +373,83 @@ func (m *Manager) DupOverrideDetour(ctx context.Context, router adapter.Router,
 	}
 	return outbound, nil
 }
+
+// Entry describes an outbound to be created by Recreate.
+type Entry struct {
+	Context context.Context
+	Logger  log.ContextLogger
+	Tag     string
+	Type    string
+	Options any
+}
+
+// Recreate creates outbounds and replaces existing outbounds with the same tags.
+// New outbounds are started in dependency order after their dependencies are replaced,
+// and replaced outbounds are closed after all new outbounds are started.
+func (m *Manager) Recreate(router adapter.Router, entries []Entry) error {
+	outbounds := make([]adapter.Outbound, 0, len(entries))
+	pending := make(map[string]bool)
+	for _, entry := range entries {
+		outbound, err := m.registry.CreateOutbound(entry.Context, router, entry.Logger, entry.Tag, entry.Type, entry.Options)
+		if err != nil {
+			for _, created := range outbounds {
+				common.Close(created)
+			}
+			return E.Cause(err, "create outbound[", entry.Tag, "]")
+		}
+		outbounds = append(outbounds, outbound)
+		pending[entry.Tag] = true
+	}
+	var (
+		replaced []adapter.Outbound
+		err      error
+	)
+	for len(pending) > 0 && err == nil {
+		canContinue := false
+	startOne:
+		for i, outbound := range outbounds {
+			if !pending[outbound.Tag()] {
+				continue
+			}
+			for _, dependency := range outbound.Dependencies() {
+				if pending[dependency] {
+					continue startOne
+				}
+			}
+			delete(pending, outbound.Tag())
+			canContinue = true
+			name := "outbound/" + outbound.Type() + "[" + outbound.Tag() + "]"
+			for _, stage := range adapter.ListStartStages {
+				m.logger.Trace(stage, " ", name)
+				startTime := time.Now()
+				err = adapter.LegacyStart(outbound, stage)
+				if err != nil {
+					err = E.Cause(err, stage, " ", name)
+					pending[outbound.Tag()] = true
+					break startOne
+				}
+				adapter.LogElapsed(m.logger, startTime, stage, " ", name)
+			}
+			m.access.Lock()
+			existsOutbound := m.replace(outbound.Tag(), outbound, entries[i].Type, entries[i].Options)
+			m.access.Unlock()
+			if existsOutbound != nil {
+				replaced = append(replaced, existsOutbound)
+			}
+		}
+		if err == nil && !canContinue {
+			err = E.New("circular outbound dependency: ", strings.Join(slices.Sorted(maps.Keys(pending)), ", "))
+		}
+	}
+	for _, outbound := range outbounds {
+		if pending[outbound.Tag()] {
+			common.Close(outbound)
+		}
+	}
+	for _, outbound := range replaced {
+		err = E.Append(err, common.Close(outbound), func(err error) error {
+			return E.Cause(err, "close outbound/", outbound.Type(), "[", outbound.Tag(), "]")
+		})
+	}
+	return err
+}
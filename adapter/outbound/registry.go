@@ -2,6 +2,7 @@ package outbound
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -71,6 +72,17 @@ func (r *Registry) CreateOptions(outboundType string) (any, bool) {
 	return optionsConstructor(), true
 }
 
+func (r *Registry) Types() []string {
+	r.access.Lock()
+	defer r.access.Unlock()
+	types := make([]string, 0, len(r.optionsType))
+	for optionsType := range r.optionsType {
+		types = append(types, optionsType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 func (r *Registry) DeriveOptions(outboundType string, tag string, options any) []option.Outbound {
 	r.access.Lock()
 	defer r.access.Unlock()
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -54,6 +55,17 @@ func (r *Registry) CreateOptions(providerType string) (any, bool) {
 	return optionsConstructor(), true
 }
 
+func (r *Registry) Types() []string {
+	r.access.Lock()
+	defer r.access.Unlock()
+	types := make([]string, 0, len(r.optionsType))
+	for optionsType := range r.optionsType {
+		types = append(types, optionsType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 func (r *Registry) CreateProvider(ctx context.Context, router adapter.Router, logFactory log.Factory, tag string, providerType string, options any) (adapter.Provider, error) {
 	r.access.Lock()
 	defer r.access.Unlock()
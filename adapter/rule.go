@@ -1,6 +1,8 @@
 package adapter
 
 import (
+	"net/http"
+
 	C "github.com/sagernet/sing-box/constant"
 )
 
@@ -29,9 +31,16 @@ type RuleAction interface {
 
 func IsFinalAction(action RuleAction) bool {
 	switch action.Type() {
-	case C.RuleActionTypeSniff, C.RuleActionTypeResolve:
+	case C.RuleActionTypeSniff, C.RuleActionTypeResolve, C.RuleActionTypeMITM, C.RuleActionTypeHTTPHeader:
 		return false
 	default:
 		return true
 	}
 }
+
+// HTTPHeaderRewriter rewrites headers of plain HTTP requests and responses
+// passing through a routed connection.
+type HTTPHeaderRewriter interface {
+	RewriteRequestHeader(header http.Header)
+	RewriteResponseHeader(header http.Header)
+}
@@ -37,6 +37,8 @@ import (
 var _ adapter.SimpleLifecycle = (*Box)(nil)
 
 type Box struct {
+	ctx             context.Context
+	options         option.Options
 	createdAt       time.Time
 	logFactory      log.Factory
 	logger          log.ContextLogger
@@ -184,6 +186,22 @@ func New(options Options) (*Box, error) {
 
 	routeOptions := common.PtrValueOrDefault(options.Route)
 	dnsOptions := common.PtrValueOrDefault(options.DNS)
+	if routeOptions.ASN != nil {
+		asnDatabase, err := route.NewASNDatabase(ctx, common.PtrValueOrDefault(routeOptions.ASN))
+		if err != nil {
+			return nil, E.Cause(err, "initialize ASN database")
+		}
+		service.MustRegister[adapter.ASNDatabase](ctx, asnDatabase)
+		internalServices = append(internalServices, asnDatabase)
+	}
+	if routeOptions.Country != nil {
+		countryDatabase, err := route.NewCountryDatabase(ctx, common.PtrValueOrDefault(routeOptions.Country))
+		if err != nil {
+			return nil, E.Cause(err, "initialize country database")
+		}
+		service.MustRegister[adapter.CountryDatabase](ctx, countryDatabase)
+		internalServices = append(internalServices, countryDatabase)
+	}
 	endpointManager := endpoint.NewManager(logFactory.NewLogger("endpoint"), endpointRegistry)
 	inboundManager := inbound.NewManager(logFactory.NewLogger("inbound"), inboundRegistry, endpointManager)
 	outboundManager := outbound.NewManager(logFactory.NewLogger("outbound"), outboundRegistry, endpointManager, routeOptions.Final)
@@ -428,6 +446,8 @@ func New(options Options) (*Box, error) {
 		internalServices = append(internalServices, adapter.NewLifecycleService(ntpService, "ntp service"))
 	}
 	return &Box{
+		ctx:             ctx,
+		options:         options.Options,
 		network:         networkManager,
 		endpoint:        endpointManager,
 		inbound:         inboundManager,
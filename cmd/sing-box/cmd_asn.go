@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/sagernet/sing-box/common/geoip"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	asnReader          *geoip.ASNReader
+	commandASNFlagFile string
+)
+
+var commandASN = &cobra.Command{
+	Use:   "asn",
+	Short: "ASN database tools",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		err := asnPreRun()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandASN.PersistentFlags().StringVarP(&commandASNFlagFile, "file", "f", "GeoLite2-ASN.mmdb", "MaxMind ASN database or ip2asn TSV file")
+	mainCommand.AddCommand(commandASN)
+}
+
+func asnPreRun() error {
+	reader, err := geoip.OpenASN(commandASNFlagFile)
+	if err != nil {
+		return E.Cause(err, "open ASN database")
+	}
+	asnReader = reader
+	return nil
+}
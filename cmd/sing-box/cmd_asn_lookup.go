@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/netip"
+	"os"
+
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/spf13/cobra"
+)
+
+var commandASNLookup = &cobra.Command{
+	Use:   "lookup <address>",
+	Short: "Lookup the autonomous system announcing an IP address",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := asnLookup(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandASN.AddCommand(commandASNLookup)
+}
+
+func asnLookup(address string) error {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return E.Cause(err, "parse address")
+	}
+	number, organization := asnReader.Lookup(addr)
+	if number == 0 {
+		os.Stdout.WriteString("unknown\n")
+		return nil
+	}
+	if organization != "" {
+		os.Stdout.WriteString(F.ToString("AS", number, " ", organization, "\n"))
+	} else {
+		os.Stdout.WriteString(F.ToString("AS", number, "\n"))
+	}
+	return nil
+}
@@ -2,9 +2,15 @@ package main
 
 import (
 	"context"
+	"os"
 
 	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/common/jsonsmerge"
+	"github.com/sagernet/sing-box/common/lint"
+	"github.com/sagernet/sing-box/common/schema"
 	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
 
 	"github.com/spf13/cobra"
 )
@@ -26,10 +32,35 @@ func init() {
 }
 
 func check() error {
+	problems, err := validateConfig()
+	if err != nil {
+		return err
+	}
 	options, err := readConfigAndMerge()
 	if err != nil {
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				log.Error(problem)
+			}
+			return E.New(len(problems), " error(s) found in configuration")
+		}
 		return err
 	}
+	for _, problem := range problems {
+		log.Warn(problem)
+	}
+	var lintErrors int
+	for _, issue := range lint.Lint(options) {
+		if issue.Severity == lint.SeverityError {
+			log.Error(issue)
+			lintErrors++
+		} else {
+			log.Warn(issue)
+		}
+	}
+	if lintErrors > 0 {
+		return E.New(lintErrors, " error(s) found in configuration")
+	}
 	ctx, cancel := context.WithCancel(globalCtx)
 	instance, err := box.New(box.Options{
 		Context: ctx,
@@ -41,3 +72,57 @@ func check() error {
 	cancel()
 	return err
 }
+
+// validateConfig validates configuration files against the schema,
+// extended configurations are validated after merging.
+func validateConfig() ([]string, error) {
+	configSchema, err := schema.Generate(globalCtx)
+	if err != nil {
+		return nil, E.Cause(err, "generate schema")
+	}
+	if configMergeExtended {
+		content, err := jsonsmerge.Files(configPaths, configDirectories)
+		if err != nil {
+			return nil, err
+		}
+		return validateContent(configSchema, "merged config", content, false)
+	}
+	configFiles, err := readConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, path := range configFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, E.Cause(err, "read config at ", path)
+		}
+		expandedContent, err := jsonsmerge.Expand(content, path)
+		if err != nil {
+			return nil, err
+		}
+		fileProblems, err := validateContent(configSchema, path, expandedContent, jsonsmerge.HasDirectives(content))
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, fileProblems...)
+	}
+	return problems, nil
+}
+
+func validateContent(configSchema *schema.Schema, path string, content []byte, expanded bool) ([]string, error) {
+	validationErrors, err := schema.Validate(configSchema, content)
+	if err != nil {
+		return nil, E.Cause(err, "decode config at ", path)
+	}
+	problems := make([]string, 0, len(validationErrors))
+	for _, validationError := range validationErrors {
+		if expanded || validationError.Line == 0 {
+			// positions of expanded configs do not match the source
+			problems = append(problems, F.ToString(path, ": ", validationError.Pointer, ": ", validationError.Message))
+		} else {
+			problems = append(problems, F.ToString(path, ":", validationError.Line, ":", validationError.Column, ": ", validationError.Pointer, ": ", validationError.Message))
+		}
+	}
+	return problems, nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/sagernet/sing-box/common/jsonsmerge"
 	"github.com/sagernet/sing-box/log"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
@@ -41,16 +42,25 @@ func format() error {
 		return err
 	}
 	for _, optionsEntry := range optionsList {
-		optionsEntry.options, err = badjson.Omitempty(globalCtx, optionsEntry.options)
-		if err != nil {
-			return err
-		}
 		buffer := new(bytes.Buffer)
-		encoder := json.NewEncoder(buffer)
-		encoder.SetIndent("", "  ")
-		err = encoder.Encode(optionsEntry.options)
-		if err != nil {
-			return E.Cause(err, "encode config")
+		if jsonsmerge.HasDirectives(optionsEntry.content) {
+			// keep includes, variables and templates, the expanded config is validated by readConfig
+			content, err := jsonsmerge.Format(optionsEntry.content, optionsEntry.path)
+			if err != nil {
+				return E.Cause(err, "format config at ", optionsEntry.path)
+			}
+			buffer.Write(content)
+		} else {
+			optionsEntry.options, err = badjson.Omitempty(globalCtx, optionsEntry.options)
+			if err != nil {
+				return err
+			}
+			encoder := json.NewEncoder(buffer)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(optionsEntry.options)
+			if err != nil {
+				return E.Cause(err, "encode config")
+			}
 		}
 		outputPath, _ := filepath.Abs(optionsEntry.path)
 		if !commandFormatFlagWrite {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/sagernet/sing-box/common/schema"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var commandGenerateSchemaFlagOutput string
+
+var commandGenerateSchema = &cobra.Command{
+	Use:   "schema",
+	Short: "Generate JSON Schema of the configuration",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := generateSchema()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandGenerateSchema.Flags().StringVarP(&commandGenerateSchemaFlagOutput, "output", "o", "", "write result to file instead of stdout")
+	commandGenerate.AddCommand(commandGenerateSchema)
+}
+
+func generateSchema() error {
+	configSchema, err := schema.Generate(globalCtx)
+	if err != nil {
+		return E.Cause(err, "generate schema")
+	}
+	content, err := json.MarshalIndent(configSchema, "", "  ")
+	if err != nil {
+		return E.Cause(err, "encode schema")
+	}
+	content = append(content, '\n')
+	if commandGenerateSchemaFlagOutput == "" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return os.WriteFile(commandGenerateSchemaFlagOutput, content, 0o644)
+}
@@ -10,7 +10,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var flagGenerateTLSKeyPairMonths int
+var (
+	flagGenerateTLSKeyPairMonths int
+	flagGenerateTLSKeyPairCA     bool
+)
 
 var commandGenerateTLSKeyPair = &cobra.Command{
 	Use:   "tls-keypair <server_name>",
@@ -26,11 +29,21 @@ var commandGenerateTLSKeyPair = &cobra.Command{
 
 func init() {
 	commandGenerateTLSKeyPair.Flags().IntVarP(&flagGenerateTLSKeyPairMonths, "months", "m", 1, "Valid months")
+	commandGenerateTLSKeyPair.Flags().BoolVar(&flagGenerateTLSKeyPairCA, "ca", false, "Generate certificate authority (use the argument as common name)")
 	commandGenerate.AddCommand(commandGenerateTLSKeyPair)
 }
 
 func generateTLSKeyPair(serverName string) error {
-	privateKeyPem, publicKeyPem, err := tls.GenerateCertificate(nil, nil, time.Now, serverName, time.Now().AddDate(0, flagGenerateTLSKeyPairMonths, 0))
+	var (
+		privateKeyPem []byte
+		publicKeyPem  []byte
+		err           error
+	)
+	if flagGenerateTLSKeyPairCA {
+		privateKeyPem, publicKeyPem, err = tls.GenerateCA(time.Now, serverName, time.Now().AddDate(0, flagGenerateTLSKeyPairMonths, 0))
+	} else {
+		privateKeyPem, publicKeyPem, err = tls.GenerateCertificate(nil, nil, time.Now, serverName, time.Now().AddDate(0, flagGenerateTLSKeyPairMonths, 0))
+	}
 	if err != nil {
 		return err
 	}
@@ -1,15 +1,16 @@
 package main
 
 import (
+	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/log"
 	E "github.com/sagernet/sing/common/exceptions"
 
-	"github.com/oschwald/maxminddb-golang"
 	"github.com/spf13/cobra"
 )
 
 var (
-	geoipReader          *maxminddb.Reader
+	geoipReader          *geoip.Reader
+	geoipCodeList        []string
 	commandGeoIPFlagFile string
 )
 
@@ -30,14 +31,11 @@ func init() {
 }
 
 func geoipPreRun() error {
-	reader, err := maxminddb.Open(commandGeoIPFlagFile)
+	reader, codeList, err := geoip.Open(commandGeoIPFlagFile)
 	if err != nil {
-		return err
-	}
-	if reader.Metadata.DatabaseType != "sing-geoip" {
-		reader.Close()
-		return E.New("incorrect database type, expected sing-geoip, got ", reader.Metadata.DatabaseType)
+		return E.Cause(err, "open geoip file")
 	}
 	geoipReader = reader
+	geoipCodeList = codeList
 	return nil
 }
@@ -1,31 +1,42 @@
 package main
 
 import (
-	"io"
-	"net"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"strings"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
-	"github.com/sagernet/sing/common/json"
 
-	"github.com/oschwald/maxminddb-golang"
 	"github.com/spf13/cobra"
 )
 
-var flagGeoipExportOutput string
+var (
+	flagGeoipExportOutput string
+	flagGeoipExportFormat string
+	flagGeoipExportAll    bool
+)
 
-const flagGeoipExportDefaultOutput = "geoip-<country>.srs"
+const flagGeoipExportDefaultOutput = "geoip-<country>.json"
 
 var commandGeoipExport = &cobra.Command{
-	Use:   "export <country>",
-	Short: "Export geoip country as rule-set",
-	Args:  cobra.ExactArgs(1),
+	Use:   "export <country>...",
+	Short: "Export geoip countries as rule-set",
+	Long: "Export geoip countries as rule-set.\n\n" +
+		"Multiple countries are merged into one rule-set.\n" +
+		"With --all, every country is exported into the output directory.",
 	Run: func(cmd *cobra.Command, args []string) {
-		err := geoipExport(args[0])
+		var err error
+		if flagGeoipExportAll {
+			err = geoipExportAll()
+		} else if len(args) == 0 {
+			err = E.New("missing country code")
+		} else {
+			err = geoipExport(args)
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -33,66 +44,62 @@ var commandGeoipExport = &cobra.Command{
 }
 
 func init() {
-	commandGeoipExport.Flags().StringVarP(&flagGeoipExportOutput, "output", "o", flagGeoipExportDefaultOutput, "Output path")
+	commandGeoipExport.Flags().StringVarP(&flagGeoipExportOutput, "output", "o", flagGeoipExportDefaultOutput, "Output path, or output directory with --all")
+	commandGeoipExport.Flags().StringVar(&flagGeoipExportFormat, "format", C.RuleSetFormatSource, "Output format, available: source, binary")
+	commandGeoipExport.Flags().BoolVar(&flagGeoipExportAll, "all", false, "Export all countries")
 	commandGeoip.AddCommand(commandGeoipExport)
 }
 
-func geoipExport(countryCode string) error {
-	networks := geoipReader.Networks(maxminddb.SkipAliasedNetworks)
-	countryMap := make(map[string][]*net.IPNet)
-	var (
-		ipNet           *net.IPNet
-		nextCountryCode string
-		err             error
-	)
-	for networks.Next() {
-		ipNet, err = networks.Network(&nextCountryCode)
-		if err != nil {
-			return err
+func geoipExport(countryCodes []string) error {
+	countryMap, err := geoipReader.ReadAll()
+	if err != nil {
+		return err
+	}
+	var prefixes []netip.Prefix
+	for i, countryCode := range countryCodes {
+		countryCode = strings.ToLower(strings.TrimPrefix(countryCode, "geoip:"))
+		countryCodes[i] = countryCode
+		countryPrefixes := countryMap[countryCode]
+		if len(countryPrefixes) == 0 {
+			return E.New("country code not found: ", countryCode)
 		}
-		countryMap[nextCountryCode] = append(countryMap[nextCountryCode], ipNet)
+		prefixes = append(prefixes, countryPrefixes...)
 	}
-	ipNets := countryMap[strings.ToLower(countryCode)]
-	if len(ipNets) == 0 {
-		return E.New("country code not found: ", countryCode)
+	outputPath := flagGeoipExportOutput
+	if outputPath == flagGeoipExportDefaultOutput {
+		outputPath = "geoip-" + strings.Join(countryCodes, "+") + ruleSetExtension(flagGeoipExportFormat)
 	}
+	return exportRuleSet(outputPath, flagGeoipExportFormat, geoipHeadlessRule(prefixes))
+}
 
-	var (
-		outputFile   *os.File
-		outputWriter io.Writer
-	)
-	if flagGeoipExportOutput == "stdout" {
-		outputWriter = os.Stdout
-	} else if flagGeoipExportOutput == flagGeoipExportDefaultOutput {
-		outputFile, err = os.Create("geoip-" + countryCode + ".json")
-		if err != nil {
-			return err
-		}
-		defer outputFile.Close()
-		outputWriter = outputFile
-	} else {
-		outputFile, err = os.Create(flagGeoipExportOutput)
+func geoipExportAll() error {
+	outputDirectory := flagGeoipExportOutput
+	if outputDirectory == flagGeoipExportDefaultOutput {
+		outputDirectory = "."
+	}
+	err := os.MkdirAll(outputDirectory, 0o755)
+	if err != nil {
+		return err
+	}
+	countryMap, err := geoipReader.ReadAll()
+	if err != nil {
+		return err
+	}
+	for countryCode, prefixes := range countryMap {
+		outputPath := filepath.Join(outputDirectory, "geoip-"+countryCode+ruleSetExtension(flagGeoipExportFormat))
+		err = exportRuleSet(outputPath, flagGeoipExportFormat, geoipHeadlessRule(prefixes))
 		if err != nil {
-			return err
+			return E.Cause(err, "export ", countryCode)
 		}
-		defer outputFile.Close()
-		outputWriter = outputFile
 	}
+	return nil
+}
 
-	encoder := json.NewEncoder(outputWriter)
-	encoder.SetIndent("", "  ")
+func geoipHeadlessRule(prefixes []netip.Prefix) option.DefaultHeadlessRule {
 	var headlessRule option.DefaultHeadlessRule
-	headlessRule.IPCIDR = make([]string, 0, len(ipNets))
-	for _, cidr := range ipNets {
-		headlessRule.IPCIDR = append(headlessRule.IPCIDR, cidr.String())
-	}
-	var plainRuleSet option.PlainRuleSetCompat
-	plainRuleSet.Version = C.RuleSetVersion2
-	plainRuleSet.Options.Rules = []option.HeadlessRule{
-		{
-			Type:           C.RuleTypeDefault,
-			DefaultOptions: headlessRule,
-		},
+	headlessRule.IPCIDR = make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		headlessRule.IPCIDR = append(headlessRule.IPCIDR, prefix.String())
 	}
-	return encoder.Encode(plainRuleSet)
+	return headlessRule
 }
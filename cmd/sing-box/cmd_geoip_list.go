@@ -24,7 +24,7 @@ func init() {
 }
 
 func listGeoip() error {
-	for _, code := range geoipReader.Metadata.Languages {
+	for _, code := range geoipCodeList {
 		os.Stdout.WriteString(code + "\n")
 	}
 	return nil
@@ -36,12 +36,6 @@ func geoipLookup(address string) error {
 		os.Stdout.WriteString("private\n")
 		return nil
 	}
-	var code string
-	_ = geoipReader.Lookup(addr.AsSlice(), &code)
-	if code != "" {
-		os.Stdout.WriteString(code + "\n")
-		return nil
-	}
-	os.Stdout.WriteString("unknown\n")
+	os.Stdout.WriteString(geoipReader.Lookup(addr) + "\n")
 	return nil
 }
@@ -3,26 +3,44 @@ package main
 import (
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/sagernet/sing-box/common/geosite"
+	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 
 	"github.com/spf13/cobra"
 )
 
-var commandGeositeExportOutput string
+var (
+	commandGeositeExportOutput string
+	commandGeositeExportFormat string
+	commandGeositeExportAll    bool
+)
 
 const commandGeositeExportDefaultOutput = "geosite-<category>.json"
 
 var commandGeositeExport = &cobra.Command{
-	Use:   "export <category>",
-	Short: "Export geosite category as rule-set",
-	Args:  cobra.ExactArgs(1),
+	Use:   "export <category>...",
+	Short: "Export geosite categories as rule-set",
+	Long: "Export geosite categories as rule-set.\n\n" +
+		"Multiple categories are merged into one rule-set. For V2Ray geosite.dat files, " +
+		"categories can be filtered by attributes, as in google@cn or google@!cn.\n" +
+		"With --all, every category is exported into the output directory.",
 	Run: func(cmd *cobra.Command, args []string) {
-		err := geositeExport(args[0])
+		var err error
+		if commandGeositeExportAll {
+			err = geositeExportAll()
+		} else if len(args) == 0 {
+			err = E.New("missing category")
+		} else {
+			err = geositeExport(args)
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -30,52 +48,106 @@ var commandGeositeExport = &cobra.Command{
 }
 
 func init() {
-	commandGeositeExport.Flags().StringVarP(&commandGeositeExportOutput, "output", "o", commandGeositeExportDefaultOutput, "Output path")
+	commandGeositeExport.Flags().StringVarP(&commandGeositeExportOutput, "output", "o", commandGeositeExportDefaultOutput, "Output path, or output directory with --all")
+	commandGeositeExport.Flags().StringVar(&commandGeositeExportFormat, "format", C.RuleSetFormatSource, "Output format, available: source, binary")
+	commandGeositeExport.Flags().BoolVar(&commandGeositeExportAll, "all", false, "Export all categories")
 	commandGeoSite.AddCommand(commandGeositeExport)
 }
 
-func geositeExport(category string) error {
-	sourceSet, err := geositeReader.Read(category)
+func geositeExport(categories []string) error {
+	var sourceSet []geosite.Item
+	for i, category := range categories {
+		category = strings.TrimPrefix(category, "geosite:")
+		categories[i] = category
+		items, err := geositeReader.Read(category)
+		if err != nil {
+			return err
+		}
+		sourceSet = append(sourceSet, items...)
+	}
+	outputPath := commandGeositeExportOutput
+	if outputPath == commandGeositeExportDefaultOutput {
+		outputPath = "geosite-" + strings.Join(categories, "+") + ruleSetExtension(commandGeositeExportFormat)
+	}
+	return exportRuleSet(outputPath, commandGeositeExportFormat, geositeHeadlessRule(sourceSet))
+}
+
+func geositeExportAll() error {
+	outputDirectory := commandGeositeExportOutput
+	if outputDirectory == commandGeositeExportDefaultOutput {
+		outputDirectory = "."
+	}
+	err := os.MkdirAll(outputDirectory, 0o755)
 	if err != nil {
 		return err
 	}
-	var (
-		outputFile   *os.File
-		outputWriter io.Writer
-	)
-	if commandGeositeExportOutput == "stdout" {
-		outputWriter = os.Stdout
-	} else if commandGeositeExportOutput == commandGeositeExportDefaultOutput {
-		outputFile, err = os.Create("geosite-" + category + ".json")
-		if err != nil {
-			return err
+	for _, code := range geositeCodeList {
+		categories := []string{code}
+		for _, attribute := range geositeReader.Attributes(code) {
+			categories = append(categories, code+"@"+attribute)
 		}
-		defer outputFile.Close()
-		outputWriter = outputFile
-	} else {
-		outputFile, err = os.Create(commandGeositeExportOutput)
-		if err != nil {
-			return err
+		for _, category := range categories {
+			sourceSet, err := geositeReader.Read(category)
+			if err != nil {
+				return err
+			}
+			outputPath := filepath.Join(outputDirectory, "geosite-"+category+ruleSetExtension(commandGeositeExportFormat))
+			err = exportRuleSet(outputPath, commandGeositeExportFormat, geositeHeadlessRule(sourceSet))
+			if err != nil {
+				return E.Cause(err, "export ", category)
+			}
 		}
-		defer outputFile.Close()
-		outputWriter = outputFile
 	}
+	return nil
+}
 
-	encoder := json.NewEncoder(outputWriter)
-	encoder.SetIndent("", "  ")
+func geositeHeadlessRule(sourceSet []geosite.Item) option.DefaultHeadlessRule {
 	var headlessRule option.DefaultHeadlessRule
 	defaultRule := geosite.Compile(sourceSet)
 	headlessRule.Domain = defaultRule.Domain
 	headlessRule.DomainSuffix = defaultRule.DomainSuffix
 	headlessRule.DomainKeyword = defaultRule.DomainKeyword
 	headlessRule.DomainRegex = defaultRule.DomainRegex
-	var plainRuleSet option.PlainRuleSetCompat
-	plainRuleSet.Version = C.RuleSetVersion2
-	plainRuleSet.Options.Rules = []option.HeadlessRule{
+	return headlessRule
+}
+
+func ruleSetExtension(format string) string {
+	if format == C.RuleSetFormatBinary {
+		return ".srs"
+	}
+	return ".json"
+}
+
+// exportRuleSet writes headlessRule as a rule-set in format to outputPath, or to stdout.
+func exportRuleSet(outputPath string, format string, headlessRule option.DefaultHeadlessRule) error {
+	if format != C.RuleSetFormatSource && format != C.RuleSetFormatBinary {
+		return E.New("unknown rule-set format: ", format)
+	}
+	var plainRuleSet option.PlainRuleSet
+	plainRuleSet.Rules = []option.HeadlessRule{
 		{
 			Type:           C.RuleTypeDefault,
 			DefaultOptions: headlessRule,
 		},
 	}
-	return encoder.Encode(plainRuleSet)
+	var outputWriter io.Writer
+	if outputPath == "stdout" {
+		outputWriter = os.Stdout
+	} else {
+		outputFile, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer outputFile.Close()
+		outputWriter = outputFile
+	}
+	if format == C.RuleSetFormatBinary {
+		return srs.Write(outputWriter, plainRuleSet, C.RuleSetVersion2)
+	}
+	encoder := json.NewEncoder(outputWriter)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(option.PlainRuleSetCompat{
+		Version: C.RuleSetVersion2,
+		Options: plainRuleSet,
+	})
 }
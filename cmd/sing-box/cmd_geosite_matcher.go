@@ -34,7 +34,7 @@ func (r *searchGeositeMatcher) Match(domain string) string {
 		return "domain=" + domain
 	}
 	for _, suffix := range r.suffixList {
-		if strings.HasSuffix(domain, suffix) {
+		if matchDomainSuffix(domain, suffix) {
 			return "domain_suffix=" + suffix
 		}
 	}
@@ -54,3 +54,10 @@ func (r *searchGeositeMatcher) Match(domain string) string {
 	}
 	return ""
 }
+
+func matchDomainSuffix(domain string, suffix string) bool {
+	if suffix == "" || !strings.HasSuffix(domain, suffix) {
+		return false
+	}
+	return len(domain) == len(suffix) || suffix[0] == '.' || domain[len(domain)-len(suffix)-1] == '.'
+}
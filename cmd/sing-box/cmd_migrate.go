@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/sagernet/sing-box/common/migrate"
+	"github.com/sagernet/sing-box/log"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var commandMigrateFlagWrite bool
+
+var commandMigrate = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate deprecated fields of configuration",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := migrateConfig()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	commandMigrate.Flags().BoolVarP(&commandMigrateFlagWrite, "write", "w", false, "write result to (source) file instead of stdout")
+	mainCommand.AddCommand(commandMigrate)
+}
+
+func migrateConfig() error {
+	configFiles, err := readConfigFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range configFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return E.Cause(err, "read config at ", path)
+		}
+		migrated, changes, err := migrate.Migrate(content)
+		if err != nil {
+			return E.Cause(err, "migrate config at ", path)
+		}
+		if len(changes) == 0 {
+			log.Info(path, ": already up-to-date")
+		}
+		for _, change := range changes {
+			if change.Manual {
+				log.Warn(path, ": ", change)
+			} else {
+				log.Info(path, ": ", change)
+			}
+		}
+		outputPath, _ := filepath.Abs(path)
+		if !commandMigrateFlagWrite {
+			if len(configFiles) > 1 {
+				os.Stdout.WriteString(outputPath + "\n")
+			}
+			os.Stdout.Write(migrated)
+			if !bytes.HasSuffix(migrated, []byte("\n")) {
+				os.Stdout.WriteString("\n")
+			}
+			continue
+		}
+		if bytes.Equal(content, migrated) {
+			continue
+		}
+		err = os.WriteFile(path, migrated, 0o644)
+		if err != nil {
+			return E.Cause(err, "write output")
+		}
+		os.Stderr.WriteString(outputPath + "\n")
+	}
+	return nil
+}
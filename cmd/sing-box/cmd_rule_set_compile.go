@@ -15,7 +15,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var flagRuleSetCompileOutput string
+var (
+	flagRuleSetCompileOutput string
+	flagRuleSetMapped        bool
+)
 
 const flagRuleSetCompileDefaultOutput = "<file_name>.srs"
 
@@ -34,6 +37,7 @@ var commandRuleSetCompile = &cobra.Command{
 func init() {
 	commandRuleSet.AddCommand(commandRuleSetCompile)
 	commandRuleSetCompile.Flags().StringVarP(&flagRuleSetCompileOutput, "output", "o", flagRuleSetCompileDefaultOutput, "Output file")
+	commandRuleSetCompile.Flags().BoolVarP(&flagRuleSetMapped, "mapped", "m", false, "Write the uncompressed memory-mapped layout (version 7)")
 }
 
 func compileRuleSet(sourcePath string) error {
@@ -82,6 +86,27 @@ func compileRuleSet(sourcePath string) error {
 }
 
 func downgradeRuleSetVersion(version uint8, options option.PlainRuleSet) uint8 {
+	if flagRuleSetMapped {
+		return C.RuleSetVersion7
+	}
+	if version == C.RuleSetVersion7 {
+		// the mapped layout is only written on request
+		version = C.RuleSetVersion6
+	}
+	if version == C.RuleSetVersion6 && !rule.HasHeadlessRule(options.Rules, func(rule option.DefaultHeadlessRule) bool {
+		return len(rule.SourceIPASN) > 0 || len(rule.IPASN) > 0 ||
+			len(rule.SourceIPCountry) > 0 || len(rule.IPCountry) > 0
+	}) {
+		version = C.RuleSetVersion5
+	}
+	if version == C.RuleSetVersion5 && !rule.HasHeadlessRule(options.Rules, func(rule option.DefaultHeadlessRule) bool {
+		return len(rule.JA3) > 0 || len(rule.JA4) > 0 ||
+			len(rule.HTTPMethod) > 0 || len(rule.HTTPHost) > 0 ||
+			len(rule.HTTPPathPrefix) > 0 || len(rule.HTTPPathRegex) > 0 ||
+			len(rule.HTTPUserAgentKeyword) > 0 || len(rule.HTTPUserAgentRegex) > 0
+	}) {
+		version = C.RuleSetVersion4
+	}
 	if version == C.RuleSetVersion4 && !rule.HasHeadlessRule(options.Rules, func(rule option.DefaultHeadlessRule) bool {
 		return rule.NetworkInterfaceAddress != nil && rule.NetworkInterfaceAddress.Size() > 0 ||
 			len(rule.DefaultInterfaceAddress) > 0
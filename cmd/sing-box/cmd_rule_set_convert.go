@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/sagernet/sing-box/common/convertor/adguard"
+	"github.com/sagernet/sing-box/common/convertor/clash"
+	"github.com/sagernet/sing-box/common/convertor/surge"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -16,13 +18,14 @@ import (
 )
 
 var (
-	flagRuleSetConvertType   string
-	flagRuleSetConvertOutput string
+	flagRuleSetConvertType     string
+	flagRuleSetConvertBehavior string
+	flagRuleSetConvertOutput   string
 )
 
 var commandRuleSetConvert = &cobra.Command{
 	Use:   "convert [source-path]",
-	Short: "Convert adguard DNS filter to rule-set",
+	Short: "Convert adguard DNS filter, clash or surge rule-set to rule-set",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		err := convertRuleSet(args[0])
@@ -34,8 +37,10 @@ var commandRuleSetConvert = &cobra.Command{
 
 func init() {
 	commandRuleSet.AddCommand(commandRuleSetConvert)
-	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertType, "type", "t", "", "Source type, available: adguard")
+	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertType, "type", "t", "", "Source type, available: adguard, clash, surge")
+	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertBehavior, "behavior", "b", clash.BehaviorClassical, "Clash rule-set behavior, available: domain, ipcidr, classical")
 	commandRuleSetConvert.Flags().StringVarP(&flagRuleSetConvertOutput, "output", "o", flagRuleSetCompileDefaultOutput, "Output file")
+	commandRuleSetConvert.Flags().BoolVarP(&flagRuleSetMapped, "mapped", "m", false, "Write the uncompressed memory-mapped layout (version 7)")
 }
 
 func convertRuleSet(sourcePath string) error {
@@ -55,6 +60,10 @@ func convertRuleSet(sourcePath string) error {
 	switch flagRuleSetConvertType {
 	case "adguard":
 		rules, err = adguard.ToOptions(reader, log.StdLogger())
+	case "clash":
+		rules, err = clash.ToOptions(reader, flagRuleSetConvertBehavior, log.StdLogger())
+	case "surge":
+		rules, err = surge.ToOptions(reader, log.StdLogger())
 	case "":
 		return E.New("source type is required")
 	default:
@@ -65,11 +74,14 @@ func convertRuleSet(sourcePath string) error {
 	}
 	var outputPath string
 	if flagRuleSetConvertOutput == flagRuleSetCompileDefaultOutput {
-		if strings.HasSuffix(sourcePath, ".txt") {
-			outputPath = sourcePath[:len(sourcePath)-4] + ".srs"
-		} else {
-			outputPath = sourcePath + ".srs"
+		outputPath = sourcePath
+		for _, extension := range []string{".txt", ".yaml", ".yml", ".list"} {
+			if strings.HasSuffix(sourcePath, extension) {
+				outputPath = sourcePath[:len(sourcePath)-len(extension)]
+				break
+			}
 		}
+		outputPath += ".srs"
 	} else {
 		outputPath = flagRuleSetConvertOutput
 	}
@@ -78,7 +90,8 @@ func convertRuleSet(sourcePath string) error {
 		return err
 	}
 	defer outputFile.Close()
-	err = srs.Write(outputFile, option.PlainRuleSet{Rules: rules}, C.RuleSetVersion2)
+	plainRuleSet := option.PlainRuleSet{Rules: rules}
+	err = srs.Write(outputFile, plainRuleSet, downgradeRuleSetVersion(C.RuleSetVersionCurrent, plainRuleSet))
 	if err != nil {
 		outputFile.Close()
 		os.Remove(outputPath)
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"io"
 	"os"
 	"strings"
 
+	"github.com/sagernet/sing-box/common/convertor/adguard"
+	"github.com/sagernet/sing-box/common/convertor/clash"
+	"github.com/sagernet/sing-box/common/convertor/surge"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
@@ -15,7 +19,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var flagRuleSetDecompileOutput string
+var (
+	flagRuleSetDecompileType     string
+	flagRuleSetDecompileBehavior string
+	flagRuleSetDecompileOutput   string
+)
 
 const flagRuleSetDecompileDefaultOutput = "<file_name>.json"
 
@@ -33,6 +41,8 @@ var commandRuleSetDecompile = &cobra.Command{
 
 func init() {
 	commandRuleSet.AddCommand(commandRuleSetDecompile)
+	commandRuleSetDecompile.Flags().StringVarP(&flagRuleSetDecompileType, "type", "t", "", "Output type, available: adguard, clash, surge (default rule-set source)")
+	commandRuleSetDecompile.Flags().StringVarP(&flagRuleSetDecompileBehavior, "behavior", "b", clash.BehaviorClassical, "Clash rule-set behavior, available: domain, ipcidr, classical")
 	commandRuleSetDecompile.Flags().StringVarP(&flagRuleSetDecompileOutput, "output", "o", flagRuleSetDecompileDefaultOutput, "Output file")
 }
 
@@ -53,17 +63,44 @@ func decompileRuleSet(sourcePath string) error {
 	if err != nil {
 		return err
 	}
-	if hasRule(ruleSet.Options.Rules, func(rule option.DefaultHeadlessRule) bool {
-		return len(rule.AdGuardDomain) > 0
-	}) {
-		return E.New("unable to decompile binary AdGuard rules to rule-set.")
+	var (
+		content   []byte
+		extension string
+	)
+	switch flagRuleSetDecompileType {
+	case "":
+		if hasRule(ruleSet.Options.Rules, func(rule option.DefaultHeadlessRule) bool {
+			return len(rule.AdGuardDomain) > 0
+		}) {
+			return E.New("unable to decompile binary AdGuard rules to rule-set.")
+		}
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(ruleSet)
+		content = buffer.Bytes()
+		extension = ".json"
+	case "adguard":
+		content, err = adguard.FromOptions(ruleSet.Options.Rules)
+		extension = ".txt"
+	case "clash":
+		content, err = clash.FromOptions(ruleSet.Options.Rules, flagRuleSetDecompileBehavior, log.StdLogger())
+		extension = ".yaml"
+	case "surge":
+		content, err = surge.FromOptions(ruleSet.Options.Rules, log.StdLogger())
+		extension = ".list"
+	default:
+		return E.New("unsupported output type: ", flagRuleSetDecompileType)
+	}
+	if err != nil {
+		return err
 	}
 	var outputPath string
 	if flagRuleSetDecompileOutput == flagRuleSetDecompileDefaultOutput {
 		if strings.HasSuffix(sourcePath, ".srs") {
-			outputPath = sourcePath[:len(sourcePath)-4] + ".json"
+			outputPath = sourcePath[:len(sourcePath)-4] + extension
 		} else {
-			outputPath = sourcePath + ".json"
+			outputPath = sourcePath + extension
 		}
 	} else {
 		outputPath = flagRuleSetDecompileOutput
@@ -72,9 +109,7 @@ func decompileRuleSet(sourcePath string) error {
 	if err != nil {
 		return err
 	}
-	encoder := json.NewEncoder(outputFile)
-	encoder.SetIndent("", "  ")
-	err = encoder.Encode(ruleSet)
+	_, err = outputFile.Write(content)
 	if err != nil {
 		outputFile.Close()
 		os.Remove(outputPath)
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box/common/srs"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+)
+
+var commandRuleSetDiff = &cobra.Command{
+	Use:   "diff <old rule-set path> <new rule-set path>",
+	Short: "Compare items of two rule-sets",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := diffRuleSet(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetDiff)
+}
+
+func diffRuleSet(fromPath string, toPath string) error {
+	from, err := readRuleSetRecovered(fromPath)
+	if err != nil {
+		return err
+	}
+	to, err := readRuleSetRecovered(toPath)
+	if err != nil {
+		return err
+	}
+	diffs, err := srs.Diff(from.Rules, to.Rules)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		os.Stdout.WriteString("no differences\n")
+		return nil
+	}
+	var buffer bytes.Buffer
+	for _, ruleDiff := range diffs {
+		buffer.WriteString(ruleDiff.Path + ":\n")
+		writeAddressItems(&buffer, "+", ruleDiff.Added)
+		writeAddressItems(&buffer, "-", ruleDiff.Removed)
+		if ruleDiff.OtherChanged {
+			buffer.WriteString("  ~ other items changed\n")
+		}
+	}
+	_, err = os.Stdout.Write(buffer.Bytes())
+	return err
+}
+
+func writeAddressItems(buffer *bytes.Buffer, prefix string, items srs.AddressItems) {
+	for _, item := range []struct {
+		name  string
+		value []string
+	}{
+		{"domain", items.Domain},
+		{"domain_suffix", items.DomainSuffix},
+		{"domain_keyword", items.DomainKeyword},
+		{"domain_regex", items.DomainRegex},
+		{"ip_cidr", items.IPCIDR},
+		{"source_ip_cidr", items.SourceIPCIDR},
+	} {
+		for _, value := range item.value {
+			buffer.WriteString(F.ToString("  ", prefix, " ", item.name, ": ", value, "\n"))
+		}
+	}
+}
+
+// readRuleSetRecovered reads a rule-set source or binary file, detected by content.
+func readRuleSetRecovered(path string) (option.PlainRuleSet, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if path == "stdin" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return option.PlainRuleSet{}, E.Cause(err, "read rule-set at ", path)
+	}
+	var ruleSet option.PlainRuleSetCompat
+	if bytes.HasPrefix(content, srs.MagicBytes[:]) {
+		ruleSet, err = srs.Read(bytes.NewReader(content), true)
+	} else {
+		ruleSet, err = json.UnmarshalExtendedContext[option.PlainRuleSetCompat](globalCtx, content)
+	}
+	if err != nil {
+		return option.PlainRuleSet{}, E.Cause(err, "decode rule-set at ", path)
+	}
+	return ruleSet.Upgrade()
+}
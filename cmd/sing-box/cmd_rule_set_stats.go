@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/sagernet/sing-box/common/srs"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common/byteformats"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/spf13/cobra"
+)
+
+var commandRuleSetStats = &cobra.Command{
+	Use:   "stats <rule-set path>",
+	Short: "Show item counts and estimated memory usage of a rule-set",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := ruleSetStats(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetStats)
+}
+
+func ruleSetStats(sourcePath string) error {
+	ruleSet, err := readRuleSetRecovered(sourcePath)
+	if err != nil {
+		return err
+	}
+	stats, err := srs.CollectStats(ruleSet.Rules)
+	if err != nil {
+		return err
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(F.ToString("rules: ", stats.Rules, " (", stats.LogicalRules, " logical)\n"))
+	for _, item := range []struct {
+		name  string
+		count int
+	}{
+		{"domain", stats.Domain},
+		{"domain_suffix", stats.DomainSuffix},
+		{"domain_keyword", stats.DomainKeyword},
+		{"domain_regex", stats.DomainRegex},
+		{"ip_cidr", stats.IPCIDR},
+		{"source_ip_cidr", stats.SourceIPCIDR},
+		{"adguard rules", stats.AdGuardDomain},
+	} {
+		if item.count > 0 {
+			buffer.WriteString(F.ToString(item.name, ": ", item.count, "\n"))
+		}
+	}
+	buffer.WriteString("estimated memory usage:\n")
+	for _, item := range []struct {
+		name string
+		size int
+	}{
+		{"domain matcher", stats.DomainMatcherSize},
+		{"domain keywords", stats.DomainKeywordSize},
+		{"domain regexes", stats.DomainRegexSize},
+		{"ip sets", stats.IPSetSize},
+		{"adguard matcher", stats.AdGuardMatcherSize},
+	} {
+		if item.size > 0 {
+			buffer.WriteString(F.ToString("  ", item.name, ": ", byteformats.FormatMemoryBytes(uint64(item.size)), "\n"))
+		}
+	}
+	buffer.WriteString(F.ToString("  total: ", byteformats.FormatMemoryBytes(uint64(stats.MemorySize())), "\n"))
+	_, err = os.Stdout.Write(buffer.Bytes())
+	return err
+}
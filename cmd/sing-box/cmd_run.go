@@ -59,7 +59,11 @@ func readConfigAt(path string) (*OptionsEntry, error) {
 	if err != nil {
 		return nil, E.Cause(err, "read config at ", path)
 	}
-	options, err := json.UnmarshalExtendedContext[option.Options](globalCtx, configContent)
+	expandedContent, err := jsonsmerge.Expand(configContent, path)
+	if err != nil {
+		return nil, err
+	}
+	options, err := json.UnmarshalExtendedContext[option.Options](globalCtx, expandedContent)
 	if err != nil {
 		return nil, E.Cause(err, "decode config at ", path)
 	}
@@ -71,17 +75,32 @@ func readConfigAt(path string) (*OptionsEntry, error) {
 }
 
 func readConfig() ([]*OptionsEntry, error) {
+	configFiles, err := readConfigFiles()
+	if err != nil {
+		return nil, err
+	}
 	var optionsList []*OptionsEntry
-	for _, path := range configPaths {
-		if !strings.HasSuffix(path, ".json") {
-			return nil, E.New("unsupported file extension: ", path)
-		}
+	for _, path := range configFiles {
 		optionsEntry, err := readConfigAt(path)
 		if err != nil {
 			return nil, err
 		}
 		optionsList = append(optionsList, optionsEntry)
 	}
+	sort.Slice(optionsList, func(i, j int) bool {
+		return optionsList[i].path < optionsList[j].path
+	})
+	return optionsList, nil
+}
+
+func readConfigFiles() ([]string, error) {
+	var configFiles []string
+	for _, path := range configPaths {
+		if !strings.HasSuffix(path, ".json") {
+			return nil, E.New("unsupported file extension: ", path)
+		}
+		configFiles = append(configFiles, path)
+	}
 	for _, directory := range configDirectories {
 		entries, err := os.ReadDir(directory)
 		if err != nil {
@@ -91,17 +110,10 @@ func readConfig() ([]*OptionsEntry, error) {
 			if !strings.HasSuffix(entry.Name(), ".json") || entry.IsDir() {
 				continue
 			}
-			optionsEntry, err := readConfigAt(filepath.Join(directory, entry.Name()))
-			if err != nil {
-				return nil, err
-			}
-			optionsList = append(optionsList, optionsEntry)
+			configFiles = append(configFiles, filepath.Join(directory, entry.Name()))
 		}
 	}
-	sort.Slice(optionsList, func(i, j int) bool {
-		return optionsList[i].path < optionsList[j].path
-	})
-	return optionsList, nil
+	return configFiles, nil
 }
 
 func readConfigAndMerge() (option.Options, error) {
@@ -150,10 +162,10 @@ func jsonsMerge() (option.Options, error) {
 	return options, nil
 }
 
-func create() (*box.Box, context.CancelFunc, error) {
+func readOptions() (option.Options, error) {
 	options, err := readConfigAndMerge()
 	if err != nil {
-		return nil, nil, err
+		return option.Options{}, err
 	}
 	if disableColor {
 		if options.Log == nil {
@@ -161,6 +173,14 @@ func create() (*box.Box, context.CancelFunc, error) {
 		}
 		options.Log.DisableColor = true
 	}
+	return options, nil
+}
+
+func create() (*box.Box, context.CancelFunc, error) {
+	options, err := readOptions()
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithCancel(globalCtx)
 	instance, err := box.New(box.Options{
 		Context: ctx,
@@ -224,6 +244,14 @@ func run() error {
 				}
 				reloadTag = true
 			}
+			if reloadTag {
+				err = reload(instance)
+				if err == nil {
+					runtimeDebug.FreeOSMemory()
+					continue
+				}
+				log.Warn(E.Cause(err, "hot reload"), ", restarting")
+			}
 			cancel()
 			closeCtx, closed := context.WithCancel(context.Background())
 			go closeMonitor(closeCtx)
@@ -240,6 +268,14 @@ func run() error {
 	}
 }
 
+func reload(instance *box.Box) error {
+	options, err := readOptions()
+	if err != nil {
+		return err
+	}
+	return instance.Reload(options)
+}
+
 func closeMonitor(ctx context.Context) {
 	time.Sleep(C.FatalStopTimeout)
 	select {
@@ -1,4 +1,4 @@
-package adguard
+package adguard_test
 
 import (
 	"context"
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor/adguard"
 	"github.com/sagernet/sing-box/route/rule"
 	"github.com/sagernet/sing/common/logger"
 
@@ -25,7 +26,7 @@ example.net^
 example.arpa
 @@|sagernet.example.org^
 `
-	rules, err := ToOptions(strings.NewReader(ruleString), logger.NOP())
+	rules, err := adguard.ToOptions(strings.NewReader(ruleString), logger.NOP())
 	require.NoError(t, err)
 	require.Len(t, rules, 1)
 	rule, err := rule.NewHeadlessRule(context.Background(), rules[0])
@@ -76,14 +77,14 @@ example.arpa
 			Domain: domain,
 		}), domain)
 	}
-	ruleFromOptions, err := FromOptions(rules)
+	ruleFromOptions, err := adguard.FromOptions(rules)
 	require.NoError(t, err)
 	require.Equal(t, ruleString, string(ruleFromOptions))
 }
 
 func TestHosts(t *testing.T) {
 	t.Parallel()
-	rules, err := ToOptions(strings.NewReader(`
+	rules, err := adguard.ToOptions(strings.NewReader(`
 127.0.0.1 localhost
 ::1 localhost #[IPv6]
 0.0.0.0 google.com
@@ -114,7 +115,7 @@ func TestHosts(t *testing.T) {
 
 func TestSimpleHosts(t *testing.T) {
 	t.Parallel()
-	rules, err := ToOptions(strings.NewReader(`
+	rules, err := adguard.ToOptions(strings.NewReader(`
 example.com
 www.example.org
 `), logger.NOP())
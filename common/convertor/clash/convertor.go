@@ -0,0 +1,179 @@
+package clash
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/convertor/internal/classical"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	BehaviorDomain    = "domain"
+	BehaviorIPCIDR    = "ipcidr"
+	BehaviorClassical = "classical"
+)
+
+type ruleProvider struct {
+	Payload []string `yaml:"payload"`
+}
+
+// ToOptions converts a Clash rule provider in YAML or text format.
+func ToOptions(reader io.Reader, behavior string, logger logger.Logger) ([]option.HeadlessRule, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := readPayload(content)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		builder      classical.Builder
+		parsedLines  int
+		ignoredLines int
+	)
+	for _, ruleLine := range payload {
+		switch behavior {
+		case BehaviorDomain:
+			err = addDomain(&builder, ruleLine)
+		case BehaviorIPCIDR:
+			err = addIPCIDR(&builder, ruleLine)
+		case BehaviorClassical, "":
+			err = builder.Add(ruleLine)
+		default:
+			return nil, E.New("unknown behavior: ", behavior)
+		}
+		if err != nil {
+			ignoredLines++
+			logger.Warn("ignored unsupported rule: ", ruleLine, ": ", err)
+			continue
+		}
+		parsedLines++
+	}
+	if parsedLines == 0 {
+		return nil, E.New("Clash rule-set is empty or all rules are unsupported")
+	}
+	if ignoredLines > 0 {
+		logger.Info("parsed rules: ", parsedLines, "/", parsedLines+ignoredLines)
+	}
+	return builder.Build(), nil
+}
+
+func readPayload(content []byte) ([]string, error) {
+	if bytes.HasPrefix(content, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		return nil, E.New("binary mrs rule-set is not supported")
+	}
+	var provider ruleProvider
+	if yaml.Unmarshal(content, &provider) == nil && provider.Payload != nil {
+		return common.Filter(common.Map(provider.Payload, strings.TrimSpace), func(it string) bool {
+			return it != ""
+		}), nil
+	}
+	var payload []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		ruleLine := strings.TrimSpace(scanner.Text())
+		if ruleLine == "" || strings.HasPrefix(ruleLine, "#") || strings.HasPrefix(ruleLine, "//") {
+			continue
+		}
+		payload = append(payload, ruleLine)
+	}
+	return payload, scanner.Err()
+}
+
+// addDomain adds a line of the domain behavior:
+// `+.` matches the domain and all subdomains, `.` matches all subdomains,
+// and `*` matches a single label.
+func addDomain(builder *classical.Builder, ruleLine string) error {
+	var rule option.DefaultHeadlessRule
+	switch {
+	case strings.HasPrefix(ruleLine, "+."):
+		rule.DomainSuffix = []string{ruleLine[2:]}
+	case strings.Contains(ruleLine, "*"):
+		rule.DomainRegex = []string{"^" + strings.ReplaceAll(strings.ReplaceAll(ruleLine, ".", "\\."), "*", "[^.]+") + "$"}
+	case strings.HasPrefix(ruleLine, "."):
+		rule.DomainSuffix = []string{ruleLine}
+	default:
+		rule.Domain = []string{ruleLine}
+	}
+	builder.AddDefault("", rule)
+	return nil
+}
+
+func addIPCIDR(builder *classical.Builder, ruleLine string) error {
+	prefix, err := classical.ParsePrefix(ruleLine)
+	if err != nil {
+		return err
+	}
+	builder.AddDefault("", option.DefaultHeadlessRule{IPCIDR: []string{prefix.String()}})
+	return nil
+}
+
+// FromOptions converts rules to a Clash rule provider in YAML format.
+//
+// The domain and ipcidr behaviors require rules to only contain items of that behavior,
+// rules that can not be converted are skipped with a warning.
+func FromOptions(rules []option.HeadlessRule, behavior string, logger logger.Logger) ([]byte, error) {
+	var payload []string
+	switch behavior {
+	case BehaviorDomain, BehaviorIPCIDR:
+		for index, rule := range rules {
+			ruleLines, err := formatBehavior(rule, behavior)
+			if err != nil {
+				logger.Warn("ignored rules[", index, "]: ", err)
+				continue
+			}
+			payload = append(payload, ruleLines...)
+		}
+		if len(payload) == 0 {
+			return nil, E.New("rule-set is empty or all rules are unsupported")
+		}
+	case BehaviorClassical, "":
+		var err error
+		payload, err = classical.Format(classical.DialectClash, rules, logger)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, E.New("unknown behavior: ", behavior)
+	}
+	return yaml.Marshal(ruleProvider{Payload: payload})
+}
+
+func formatBehavior(rule option.HeadlessRule, behavior string) ([]string, error) {
+	if rule.Type != C.RuleTypeDefault || rule.DefaultOptions.Invert {
+		return nil, E.New("logical or inverted rules are not supported by ", behavior, " behavior")
+	}
+	options := rule.DefaultOptions
+	var ruleLines []string
+	if behavior == BehaviorDomain {
+		options.Domain = nil
+		options.DomainSuffix = nil
+		options.DomainMatcher = nil
+		ruleLines = append(ruleLines, rule.DefaultOptions.Domain...)
+		for _, domainSuffix := range rule.DefaultOptions.DomainSuffix {
+			if strings.HasPrefix(domainSuffix, ".") {
+				ruleLines = append(ruleLines, domainSuffix)
+			} else {
+				ruleLines = append(ruleLines, "+."+domainSuffix)
+			}
+		}
+	} else {
+		options.IPCIDR = nil
+		options.IPSet = nil
+		ruleLines = append(ruleLines, rule.DefaultOptions.IPCIDR...)
+	}
+	if len(ruleLines) == 0 || !classical.IsEmpty(options) {
+		return nil, E.New("rule contains items not supported by ", behavior, " behavior")
+	}
+	return ruleLines, nil
+}
@@ -0,0 +1,151 @@
+package clash_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor/clash"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRule(t *testing.T, rules []option.HeadlessRule) adapter.HeadlessRule {
+	rule, err := rule.NewHeadlessRule(context.Background(), option.HeadlessRule{
+		Type: C.RuleTypeLogical,
+		LogicalOptions: option.LogicalHeadlessRule{
+			Mode:  C.LogicalTypeOr,
+			Rules: rules,
+		},
+	})
+	require.NoError(t, err)
+	return rule
+}
+
+func TestDomainBehavior(t *testing.T) {
+	t.Parallel()
+	ruleString := `payload:
+  - 'example.com'
+  - '+.example.org'
+  - '.example.net'
+  - '*.example.edu'
+`
+	rules, err := clash.ToOptions(strings.NewReader(ruleString), clash.BehaviorDomain, logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	headlessRule := newRule(t, rules)
+	matchDomain := []string{
+		"example.com",
+		"example.org",
+		"www.example.org",
+		"a.www.example.org",
+		"www.example.net",
+		"www.example.edu",
+	}
+	notMatchDomain := []string{
+		"www.example.com",
+		"notexample.org",
+		"example.net",
+		"example.edu",
+		"a.www.example.edu",
+	}
+	for _, domain := range matchDomain {
+		require.True(t, headlessRule.Match(&adapter.InboundContext{
+			Domain: domain,
+		}), domain)
+	}
+	for _, domain := range notMatchDomain {
+		require.False(t, headlessRule.Match(&adapter.InboundContext{
+			Domain: domain,
+		}), domain)
+	}
+	rules[0].DefaultOptions.DomainRegex = nil
+	content, err := clash.FromOptions(rules, clash.BehaviorDomain, logger.NOP())
+	require.NoError(t, err)
+	require.Equal(t, "payload:\n- example.com\n- +.example.org\n- .example.net\n", string(content))
+}
+
+func TestIPCIDRBehavior(t *testing.T) {
+	t.Parallel()
+	rules, err := clash.ToOptions(strings.NewReader(`# comment
+10.0.0.0/8
+2001:db8::/32
+1.1.1.1
+`), clash.BehaviorIPCIDR, logger.NOP())
+	require.NoError(t, err)
+	headlessRule := newRule(t, rules)
+	for _, address := range []string{"10.1.2.3", "2001:db8::1", "1.1.1.1"} {
+		require.True(t, headlessRule.Match(&adapter.InboundContext{
+			Destination: M.ParseSocksaddrHostPort(address, 443),
+		}), address)
+	}
+	for _, address := range []string{"11.1.2.3", "2001:db9::1", "1.1.1.2"} {
+		require.False(t, headlessRule.Match(&adapter.InboundContext{
+			Destination: M.ParseSocksaddrHostPort(address, 443),
+		}), address)
+	}
+	content, err := clash.FromOptions(rules, clash.BehaviorIPCIDR, logger.NOP())
+	require.NoError(t, err)
+	require.Equal(t, "payload:\n- 10.0.0.0/8\n- 2001:db8::/32\n- 1.1.1.1/32\n", string(content))
+}
+
+func TestClassicalBehavior(t *testing.T) {
+	t.Parallel()
+	ruleString := `payload:
+  - DOMAIN,example.com
+  - DOMAIN-SUFFIX,example.org
+  - DOMAIN-KEYWORD,sagernet
+  - IP-CIDR,10.0.0.0/8,no-resolve
+  - IP-CIDR6,2001:db8::/32
+  - DST-PORT,853/5000-6000
+  - AND,((DOMAIN-SUFFIX,example.net),(NETWORK,UDP))
+  - AND,((DOMAIN-SUFFIX,example.edu),(NOT,((DOMAIN,www.example.edu))))
+  - GEOIP,CN
+  - MATCH
+`
+	rules, err := clash.ToOptions(strings.NewReader(ruleString), clash.BehaviorClassical, logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	headlessRule := newRule(t, rules)
+	matchMetadata := []adapter.InboundContext{
+		{Domain: "example.com"},
+		{Domain: "www.example.org"},
+		{Domain: "sagernet.org"},
+		{Destination: M.ParseSocksaddr("10.0.0.1:443")},
+		{Destination: M.ParseSocksaddr("[2001:db8::1]:443")},
+		{Destination: M.ParseSocksaddr("1.1.1.1:853")},
+		{Destination: M.ParseSocksaddr("1.1.1.1:5353")},
+		{Domain: "www.example.net", Network: "udp", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+		{Domain: "example.edu", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+	}
+	notMatchMetadata := []adapter.InboundContext{
+		{Domain: "www.example.com", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+		{Domain: "www.example.net", Network: "tcp", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+		{Domain: "www.example.edu", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+	}
+	for _, metadata := range matchMetadata {
+		require.True(t, headlessRule.Match(&metadata), metadata)
+	}
+	for _, metadata := range notMatchMetadata {
+		require.False(t, headlessRule.Match(&metadata), metadata)
+	}
+	content, err := clash.FromOptions(rules, clash.BehaviorClassical, logger.NOP())
+	require.NoError(t, err)
+	require.Equal(t, `payload:
+- DOMAIN,example.com
+- DOMAIN-SUFFIX,example.org
+- DOMAIN-KEYWORD,sagernet
+- IP-CIDR,10.0.0.0/8
+- IP-CIDR6,2001:db8::/32
+- DST-PORT,853
+- DST-PORT,5000-6000
+- AND,((DOMAIN-SUFFIX,example.net),(NETWORK,UDP))
+- AND,((DOMAIN-SUFFIX,example.edu),(NOT,((DOMAIN,www.example.edu))))
+`, string(content))
+}
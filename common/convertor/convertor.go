@@ -0,0 +1,45 @@
+package convertor
+
+import (
+	"io"
+
+	"github.com/sagernet/sing-box/common/convertor/adguard"
+	"github.com/sagernet/sing-box/common/convertor/clash"
+	"github.com/sagernet/sing-box/common/convertor/hosts"
+	"github.com/sagernet/sing-box/common/convertor/surge"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+)
+
+// IsForeignFormat reports whether format is a rule-set format of other projects.
+func IsForeignFormat(format string) bool {
+	switch format {
+	case C.RuleSetFormatAdGuard, C.RuleSetFormatClashDomain, C.RuleSetFormatClashIPCIDR, C.RuleSetFormatClashClassical,
+		C.RuleSetFormatSurgeList, C.RuleSetFormatHosts:
+		return true
+	default:
+		return false
+	}
+}
+
+// ToOptions converts a rule-set in a foreign format.
+func ToOptions(format string, reader io.Reader, logger logger.Logger) ([]option.HeadlessRule, error) {
+	switch format {
+	case C.RuleSetFormatAdGuard:
+		return adguard.ToOptions(reader, logger)
+	case C.RuleSetFormatClashDomain:
+		return clash.ToOptions(reader, clash.BehaviorDomain, logger)
+	case C.RuleSetFormatClashIPCIDR:
+		return clash.ToOptions(reader, clash.BehaviorIPCIDR, logger)
+	case C.RuleSetFormatClashClassical:
+		return clash.ToOptions(reader, clash.BehaviorClassical, logger)
+	case C.RuleSetFormatSurgeList:
+		return surge.ToOptions(reader, logger)
+	case C.RuleSetFormatHosts:
+		return hosts.ToOptions(reader, logger)
+	default:
+		return nil, E.New("unknown rule-set format: ", format)
+	}
+}
@@ -0,0 +1,86 @@
+package hosts
+
+import (
+	"bufio"
+	"io"
+	"net/netip"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// localNames are common entries of hosts files that are not meant to be matched.
+var localNames = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+}
+
+// ToOptions converts a hosts file to a rule that matches all host names in it,
+// regardless of their addresses.
+func ToOptions(reader io.Reader, logger logger.Logger) ([]option.HeadlessRule, error) {
+	scanner := bufio.NewScanner(reader)
+	var (
+		domains      []string
+		domainMap    = make(map[string]bool)
+		ignoredLines int
+	)
+	for scanner.Scan() {
+		ruleLine := scanner.Text()
+		if index := strings.IndexByte(ruleLine, '#'); index >= 0 {
+			ruleLine = ruleLine[:index]
+		}
+		fields := strings.Fields(ruleLine)
+		if len(fields) == 0 {
+			continue
+		}
+		_, err := netip.ParseAddr(common.SubstringBefore(fields[0], "%"))
+		if err != nil || len(fields) < 2 {
+			ignoredLines++
+			logger.Debug("ignored invalid hosts line: ", ruleLine)
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = strings.ToLower(name)
+			if localNames[name] || domainMap[name] {
+				continue
+			}
+			if common.Error(netip.ParseAddr(name)) == nil || !M.IsDomainName(name) {
+				logger.Debug("ignored invalid host name: ", name)
+				continue
+			}
+			domainMap[name] = true
+			domains = append(domains, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(domains) == 0 {
+		return nil, E.New("hosts file is empty or all lines are invalid")
+	}
+	if ignoredLines > 0 {
+		logger.Info("ignored invalid lines: ", ignoredLines)
+	}
+	return []option.HeadlessRule{
+		{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain: domains,
+			},
+		},
+	}, nil
+}
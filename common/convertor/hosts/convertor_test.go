@@ -0,0 +1,27 @@
+package hosts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter(t *testing.T) {
+	t.Parallel()
+	rules, err := ToOptions(strings.NewReader(`# hosts
+127.0.0.1 localhost
+::1 localhost ip6-localhost
+fe80::1%lo0 localhost
+0.0.0.0 0.0.0.0
+0.0.0.0 ads.example.com tracker.example.com # comment
+10.0.0.1 NAS.example.org
+0.0.0.0 ads.example.com
+invalid.example.com
+`), logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, []string{"ads.example.com", "tracker.example.com", "nas.example.org"}, []string(rules[0].DefaultOptions.Domain))
+}
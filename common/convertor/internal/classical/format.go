@@ -0,0 +1,297 @@
+package classical
+
+import (
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+)
+
+type Dialect int
+
+const (
+	DialectClash Dialect = iota
+	DialectSurge
+)
+
+// Format formats rules into classical rule lines,
+// items of a rule are combined into a logical line when they can not be expressed by separate lines.
+// Rules with items that have no equivalent are skipped with a warning.
+func Format(dialect Dialect, rules []option.HeadlessRule, logger logger.Logger) ([]string, error) {
+	var lines []string
+	for index, rule := range rules {
+		ruleLines, err := formatTopLevelRule(dialect, rule)
+		if err != nil {
+			logger.Warn("ignored rules[", index, "]: ", err)
+			continue
+		}
+		lines = append(lines, ruleLines...)
+	}
+	if len(lines) == 0 {
+		return nil, E.New("rule-set is empty or all rules are unsupported")
+	}
+	return lines, nil
+}
+
+func formatTopLevelRule(dialect Dialect, rule option.HeadlessRule) ([]string, error) {
+	if rule.Type == C.RuleTypeDefault && !rule.DefaultOptions.Invert {
+		groups, err := formatGroups(dialect, rule.DefaultOptions)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 1 {
+			return groups[0], nil
+		}
+	}
+	line, err := formatRule(dialect, rule)
+	if err != nil {
+		return nil, err
+	}
+	return []string{line}, nil
+}
+
+func formatRule(dialect Dialect, rule option.HeadlessRule) (string, error) {
+	var (
+		line   string
+		invert bool
+	)
+	switch rule.Type {
+	case C.RuleTypeDefault:
+		groups, err := formatGroups(dialect, rule.DefaultOptions)
+		if err != nil {
+			return "", err
+		}
+		if len(groups) == 0 {
+			return "", E.New("empty rule")
+		}
+		line = formatLogical(TypeAnd, common.Map(groups, func(it []string) string {
+			return formatLogical(TypeOr, it)
+		}))
+		invert = rule.DefaultOptions.Invert
+	case C.RuleTypeLogical:
+		subLines := make([]string, 0, len(rule.LogicalOptions.Rules))
+		for _, subRule := range rule.LogicalOptions.Rules {
+			subLine, err := formatRule(dialect, subRule)
+			if err != nil {
+				return "", err
+			}
+			subLines = append(subLines, subLine)
+		}
+		if len(subLines) == 0 {
+			return "", E.New("empty logical rule")
+		}
+		if rule.LogicalOptions.Mode == C.LogicalTypeAnd {
+			line = formatLogical(TypeAnd, subLines)
+		} else {
+			line = formatLogical(TypeOr, subLines)
+		}
+		invert = rule.LogicalOptions.Invert
+	default:
+		return "", E.New("unknown rule type: ", rule.Type)
+	}
+	if invert {
+		line = TypeNot + ",((" + line + "))"
+	}
+	return line, nil
+}
+
+// formatLogical returns the only line as is, or joins lines with ruleType.
+func formatLogical(ruleType string, lines []string) string {
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	return ruleType + ",(" + strings.Join(common.Map(lines, func(it string) string {
+		return "(" + it + ")"
+	}), ",") + ")"
+}
+
+// formatGroups returns lines of each group of items,
+// lines in a group match if any matches, and all groups must match.
+func formatGroups(dialect Dialect, rule option.DefaultHeadlessRule) ([][]string, error) {
+	err := checkUnsupported(dialect, rule)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		groups  [][]string
+		address []string
+	)
+	address = append(address, common.Map(rule.Domain, func(it string) string {
+		return line(TypeDomain, it)
+	})...)
+	for _, domainSuffix := range rule.DomainSuffix {
+		if strings.HasPrefix(domainSuffix, ".") {
+			if dialect == DialectSurge {
+				address = append(address, line(TypeDomainWildcard, "*"+domainSuffix))
+			} else {
+				address = append(address, line(TypeDomainRegex, "^.+"+strings.ReplaceAll(domainSuffix, ".", "\\.")+"$"))
+			}
+		} else {
+			address = append(address, line(TypeDomainSuffix, domainSuffix))
+		}
+	}
+	address = append(address, common.Map(rule.DomainKeyword, func(it string) string {
+		return line(TypeDomainKeyword, it)
+	})...)
+	for _, domainRegex := range rule.DomainRegex {
+		if dialect == DialectSurge {
+			wildcard, loaded := RegexToWildcard(domainRegex)
+			if !loaded {
+				return nil, E.New("unsupported domain_regex: ", domainRegex)
+			}
+			address = append(address, line(TypeDomainWildcard, wildcard))
+		} else {
+			address = append(address, line(TypeDomainRegex, domainRegex))
+		}
+	}
+	for _, prefixString := range rule.IPCIDR {
+		prefix, err := ParsePrefix(prefixString)
+		if err != nil {
+			return nil, err
+		}
+		if prefix.Addr().Is4() {
+			address = append(address, line(TypeIPCIDR, prefix.String()))
+		} else {
+			address = append(address, line(TypeIPCIDR6, prefix.String()))
+		}
+	}
+	groups = appendGroup(groups, address)
+	sourceIPCIDRType := TypeSourceIPCIDR
+	if dialect == DialectSurge {
+		sourceIPCIDRType = "SRC-IP"
+	}
+	groups = appendGroup(groups, common.Map(rule.SourceIPCIDR, func(it string) string {
+		return line(sourceIPCIDRType, it)
+	}))
+	destinationPortType := TypeDestinationPort
+	if dialect == DialectSurge {
+		destinationPortType = "DEST-PORT"
+	}
+	ports, err := formatPorts(destinationPortType, rule.Port, rule.PortRange)
+	if err != nil {
+		return nil, err
+	}
+	groups = appendGroup(groups, ports)
+	sourcePorts, err := formatPorts(TypeSourcePort, rule.SourcePort, rule.SourcePortRange)
+	if err != nil {
+		return nil, err
+	}
+	groups = appendGroup(groups, sourcePorts)
+	groups = appendGroup(groups, common.Map(rule.Network, func(it string) string {
+		if dialect == DialectSurge {
+			return line("PROTOCOL", strings.ToUpper(it))
+		}
+		return line(TypeNetwork, strings.ToUpper(it))
+	}))
+	groups = appendGroup(groups, common.Map(rule.ProcessName, func(it string) string {
+		return line(TypeProcessName, it)
+	}))
+	groups = appendGroup(groups, common.Map(rule.ProcessPath, func(it string) string {
+		if dialect == DialectSurge {
+			return line(TypeProcessName, it)
+		}
+		return line(TypeProcessPath, it)
+	}))
+	groups = appendGroup(groups, common.Map(rule.ProcessPathRegex, func(it string) string {
+		return line(TypeProcessPathRegex, it)
+	}))
+	groups = appendGroup(groups, common.Map(rule.HTTPUserAgentKeyword, func(it string) string {
+		return line(TypeUserAgent, "*"+it+"*")
+	}))
+	var userAgentRegex []string
+	for _, expression := range rule.HTTPUserAgentRegex {
+		wildcard, loaded := RegexToWildcard(expression)
+		if !loaded {
+			return nil, E.New("unsupported http_user_agent_regex: ", expression)
+		}
+		userAgentRegex = append(userAgentRegex, line(TypeUserAgent, wildcard))
+	}
+	groups = appendGroup(groups, userAgentRegex)
+	return groups, nil
+}
+
+// IsEmpty reports whether rule has no items.
+func IsEmpty(rule option.DefaultHeadlessRule) bool {
+	if checkUnsupported(DialectClash, rule) != nil {
+		return false
+	}
+	groups, err := formatGroups(DialectClash, rule)
+	return err == nil && len(groups) == 0
+}
+
+func appendGroup(groups [][]string, group []string) [][]string {
+	if len(group) == 0 {
+		return groups
+	}
+	return append(groups, group)
+}
+
+func line(ruleType string, value string) string {
+	return ruleType + "," + value
+}
+
+func formatPorts(ruleType string, ports []uint16, portRanges []string) ([]string, error) {
+	lines := common.Map(ports, func(it uint16) string {
+		return line(ruleType, strconv.Itoa(int(it)))
+	})
+	for _, portRange := range portRanges {
+		startString, endString, loaded := strings.Cut(portRange, ":")
+		if !loaded {
+			return nil, E.New("bad port range: ", portRange)
+		}
+		if startString == "" {
+			startString = "0"
+		}
+		if endString == "" {
+			endString = "65535"
+		}
+		lines = append(lines, line(ruleType, startString+"-"+endString))
+	}
+	return lines, nil
+}
+
+func checkUnsupported(dialect Dialect, rule option.DefaultHeadlessRule) error {
+	var items []string
+	if len(rule.QueryType) > 0 {
+		items = append(items, "query_type")
+	}
+	if len(rule.PackageName) > 0 {
+		items = append(items, "package_name")
+	}
+	if len(rule.NetworkType) > 0 || rule.NetworkIsExpensive || rule.NetworkIsConstrained {
+		items = append(items, "network_type")
+	}
+	if len(rule.WIFISSID) > 0 || len(rule.WIFIBSSID) > 0 {
+		items = append(items, "wifi_ssid")
+	}
+	if rule.NetworkInterfaceAddress != nil && rule.NetworkInterfaceAddress.Size() > 0 || len(rule.DefaultInterfaceAddress) > 0 {
+		items = append(items, "network_interface_address")
+	}
+	if len(rule.JA3) > 0 || len(rule.JA4) > 0 {
+		items = append(items, "ja3")
+	}
+	if len(rule.HTTPMethod) > 0 || len(rule.HTTPHost) > 0 || len(rule.HTTPPathPrefix) > 0 || len(rule.HTTPPathRegex) > 0 {
+		items = append(items, "http")
+	}
+	if len(rule.AdGuardDomain) > 0 {
+		items = append(items, "adguard_domain")
+	}
+	switch dialect {
+	case DialectClash:
+		if len(rule.HTTPUserAgentKeyword) > 0 || len(rule.HTTPUserAgentRegex) > 0 {
+			items = append(items, "http_user_agent")
+		}
+	case DialectSurge:
+		if len(rule.ProcessPathRegex) > 0 {
+			items = append(items, "process_path_regex")
+		}
+	}
+	if len(items) > 0 {
+		return E.New("unsupported rule items: ", strings.Join(items, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package classical
+
+import (
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	TypeDomain           = "DOMAIN"
+	TypeDomainSuffix     = "DOMAIN-SUFFIX"
+	TypeDomainKeyword    = "DOMAIN-KEYWORD"
+	TypeDomainRegex      = "DOMAIN-REGEX"
+	TypeDomainWildcard   = "DOMAIN-WILDCARD"
+	TypeIPCIDR           = "IP-CIDR"
+	TypeIPCIDR6          = "IP-CIDR6"
+	TypeSourceIPCIDR     = "SRC-IP-CIDR"
+	TypeDestinationPort  = "DST-PORT"
+	TypeSourcePort       = "SRC-PORT"
+	TypeNetwork          = "NETWORK"
+	TypeProcessName      = "PROCESS-NAME"
+	TypeProcessPath      = "PROCESS-PATH"
+	TypeProcessPathRegex = "PROCESS-PATH-REGEX"
+	TypeUserAgent        = "USER-AGENT"
+	TypeAnd              = "AND"
+	TypeOr               = "OR"
+	TypeNot              = "NOT"
+)
+
+// typeAliases maps rule types of Clash, Surge and Quantumult X to the Clash names.
+var typeAliases = map[string]string{
+	"DOMAIN":             TypeDomain,
+	"HOST":               TypeDomain,
+	"DOMAIN-SUFFIX":      TypeDomainSuffix,
+	"HOST-SUFFIX":        TypeDomainSuffix,
+	"DOMAIN-KEYWORD":     TypeDomainKeyword,
+	"HOST-KEYWORD":       TypeDomainKeyword,
+	"DOMAIN-REGEX":       TypeDomainRegex,
+	"DOMAIN-WILDCARD":    TypeDomainWildcard,
+	"HOST-WILDCARD":      TypeDomainWildcard,
+	"IP-CIDR":            TypeIPCIDR,
+	"IP-CIDR6":           TypeIPCIDR,
+	"IP6-CIDR":           TypeIPCIDR,
+	"SRC-IP-CIDR":        TypeSourceIPCIDR,
+	"SRC-IP":             TypeSourceIPCIDR,
+	"DST-PORT":           TypeDestinationPort,
+	"DEST-PORT":          TypeDestinationPort,
+	"SRC-PORT":           TypeSourcePort,
+	"NETWORK":            TypeNetwork,
+	"PROTOCOL":           TypeNetwork,
+	"PROCESS-NAME":       TypeProcessName,
+	"PROCESS-PATH":       TypeProcessPath,
+	"PROCESS-PATH-REGEX": TypeProcessPathRegex,
+	"USER-AGENT":         TypeUserAgent,
+	"AND":                TypeAnd,
+	"OR":                 TypeOr,
+	"NOT":                TypeNot,
+}
+
+// Builder collects rule lines and merges items of the same kind,
+// so that the resulting rule-set stays small.
+type Builder struct {
+	address    option.DefaultHeadlessRule
+	hasAddress bool
+	keys       []string
+	rules      map[string]*option.DefaultHeadlessRule
+	logical    []option.HeadlessRule
+}
+
+// Add parses a classical rule line like `DOMAIN-SUFFIX,example.com`.
+// Policies and options like `no-resolve` after the value are ignored.
+func (b *Builder) Add(line string) error {
+	key, rule, err := parseRule(line)
+	if err != nil {
+		return err
+	}
+	if rule.Type == C.RuleTypeLogical || rule.DefaultOptions.Invert {
+		b.logical = append(b.logical, rule)
+	} else {
+		b.AddDefault(key, rule.DefaultOptions)
+	}
+	return nil
+}
+
+// AddDefault merges rule into the rule of the same key,
+// an empty key stands for destination address items.
+func (b *Builder) AddDefault(key string, rule option.DefaultHeadlessRule) {
+	if key == "" {
+		mergeRule(&b.address, rule)
+		b.hasAddress = true
+		return
+	}
+	if b.rules == nil {
+		b.rules = make(map[string]*option.DefaultHeadlessRule)
+	}
+	current, loaded := b.rules[key]
+	if !loaded {
+		current = new(option.DefaultHeadlessRule)
+		b.rules[key] = current
+		b.keys = append(b.keys, key)
+	}
+	mergeRule(current, rule)
+}
+
+func (b *Builder) Build() []option.HeadlessRule {
+	var rules []option.HeadlessRule
+	if b.hasAddress {
+		rules = append(rules, option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: b.address})
+	}
+	for _, key := range b.keys {
+		rules = append(rules, option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: *b.rules[key]})
+	}
+	return append(rules, b.logical...)
+}
+
+func mergeRule(destination *option.DefaultHeadlessRule, source option.DefaultHeadlessRule) {
+	destination.Domain = append(destination.Domain, source.Domain...)
+	destination.DomainSuffix = append(destination.DomainSuffix, source.DomainSuffix...)
+	destination.DomainKeyword = append(destination.DomainKeyword, source.DomainKeyword...)
+	destination.DomainRegex = append(destination.DomainRegex, source.DomainRegex...)
+	destination.IPCIDR = append(destination.IPCIDR, source.IPCIDR...)
+	destination.SourceIPCIDR = append(destination.SourceIPCIDR, source.SourceIPCIDR...)
+	destination.Port = append(destination.Port, source.Port...)
+	destination.PortRange = append(destination.PortRange, source.PortRange...)
+	destination.SourcePort = append(destination.SourcePort, source.SourcePort...)
+	destination.SourcePortRange = append(destination.SourcePortRange, source.SourcePortRange...)
+	destination.Network = append(destination.Network, source.Network...)
+	destination.ProcessName = append(destination.ProcessName, source.ProcessName...)
+	destination.ProcessPath = append(destination.ProcessPath, source.ProcessPath...)
+	destination.ProcessPathRegex = append(destination.ProcessPathRegex, source.ProcessPathRegex...)
+	destination.HTTPUserAgentKeyword = append(destination.HTTPUserAgentKeyword, source.HTTPUserAgentKeyword...)
+	destination.HTTPUserAgentRegex = append(destination.HTTPUserAgentRegex, source.HTTPUserAgentRegex...)
+}
+
+// parseRule returns the rule of the line and the key of items it can be merged with.
+func parseRule(line string) (string, option.HeadlessRule, error) {
+	ruleType, payload, _ := strings.Cut(line, ",")
+	ruleType = strings.ToUpper(strings.TrimSpace(ruleType))
+	canonicalType, loaded := typeAliases[ruleType]
+	if !loaded {
+		return "", option.HeadlessRule{}, E.New("unsupported rule type: ", ruleType)
+	}
+	switch canonicalType {
+	case TypeAnd, TypeOr, TypeNot:
+		rule, err := parseLogicalRule(canonicalType, payload)
+		if err != nil {
+			return "", option.HeadlessRule{}, E.Cause(err, "parse ", ruleType, " rule")
+		}
+		return "", rule, nil
+	}
+	value, _, _ := strings.Cut(payload, ",")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", option.HeadlessRule{}, E.New("missing value for rule type: ", ruleType)
+	}
+	var (
+		key  string
+		rule option.DefaultHeadlessRule
+	)
+	switch canonicalType {
+	case TypeDomain:
+		rule.Domain = []string{value}
+	case TypeDomainSuffix:
+		rule.DomainSuffix = []string{value}
+	case TypeDomainKeyword:
+		rule.DomainKeyword = []string{value}
+	case TypeDomainRegex:
+		_, err := regexp.Compile(value)
+		if err != nil {
+			return "", option.HeadlessRule{}, err
+		}
+		rule.DomainRegex = []string{value}
+	case TypeDomainWildcard:
+		rule.DomainRegex = []string{WildcardToRegex(value)}
+	case TypeIPCIDR:
+		prefix, err := ParsePrefix(value)
+		if err != nil {
+			return "", option.HeadlessRule{}, err
+		}
+		rule.IPCIDR = []string{prefix.String()}
+	case TypeSourceIPCIDR:
+		prefix, err := ParsePrefix(value)
+		if err != nil {
+			return "", option.HeadlessRule{}, err
+		}
+		key = canonicalType
+		rule.SourceIPCIDR = []string{prefix.String()}
+	case TypeDestinationPort, TypeSourcePort:
+		ports, portRanges, err := parsePorts(value)
+		if err != nil {
+			return "", option.HeadlessRule{}, err
+		}
+		key = canonicalType
+		if canonicalType == TypeDestinationPort {
+			rule.Port = ports
+			rule.PortRange = portRanges
+		} else {
+			rule.SourcePort = ports
+			rule.SourcePortRange = portRanges
+		}
+	case TypeNetwork:
+		network := strings.ToLower(value)
+		if network != "tcp" && network != "udp" {
+			return "", option.HeadlessRule{}, E.New("unsupported network: ", value)
+		}
+		key = canonicalType
+		rule.Network = []string{network}
+	case TypeProcessName:
+		key = canonicalType
+		rule.ProcessName = []string{value}
+	case TypeProcessPath:
+		key = canonicalType
+		rule.ProcessPath = []string{value}
+	case TypeProcessPathRegex:
+		_, err := regexp.Compile(value)
+		if err != nil {
+			return "", option.HeadlessRule{}, err
+		}
+		key = canonicalType
+		rule.ProcessPathRegex = []string{value}
+	case TypeUserAgent:
+		keyword := strings.TrimSuffix(strings.TrimPrefix(value, "*"), "*")
+		if len(keyword)+2 == len(value) && !strings.ContainsAny(keyword, "*?") {
+			key = "USER-AGENT-KEYWORD"
+			rule.HTTPUserAgentKeyword = []string{keyword}
+		} else {
+			key = "USER-AGENT-REGEX"
+			rule.HTTPUserAgentRegex = []string{WildcardToRegex(value)}
+		}
+	}
+	return key, option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: rule}, nil
+}
+
+func parseLogicalRule(ruleType string, payload string) (option.HeadlessRule, error) {
+	fields, err := splitTopLevel(strings.TrimSpace(payload))
+	if err != nil {
+		return option.HeadlessRule{}, err
+	}
+	if len(fields) == 0 {
+		return option.HeadlessRule{}, E.New("missing sub-rules")
+	}
+	subRuleList, err := unwrap(fields[0])
+	if err != nil {
+		return option.HeadlessRule{}, err
+	}
+	subRuleLines, err := splitTopLevel(subRuleList)
+	if err != nil {
+		return option.HeadlessRule{}, err
+	}
+	var subRules []option.HeadlessRule
+	for _, subRuleLine := range subRuleLines {
+		subRuleLine, err = unwrap(subRuleLine)
+		if err != nil {
+			return option.HeadlessRule{}, err
+		}
+		_, subRule, err := parseRule(subRuleLine)
+		if err != nil {
+			return option.HeadlessRule{}, err
+		}
+		subRules = append(subRules, subRule)
+	}
+	switch ruleType {
+	case TypeNot:
+		if len(subRules) != 1 {
+			return option.HeadlessRule{}, E.New("NOT rule requires exactly one sub-rule")
+		}
+		rule := subRules[0]
+		if rule.Type == C.RuleTypeLogical {
+			rule.LogicalOptions.Invert = !rule.LogicalOptions.Invert
+		} else {
+			rule.DefaultOptions.Invert = !rule.DefaultOptions.Invert
+		}
+		return rule, nil
+	case TypeAnd:
+		return option.HeadlessRule{
+			Type:           C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{Mode: C.LogicalTypeAnd, Rules: subRules},
+		}, nil
+	default:
+		return option.HeadlessRule{
+			Type:           C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{Mode: C.LogicalTypeOr, Rules: subRules},
+		}, nil
+	}
+}
+
+// splitTopLevel splits s by commas outside parentheses.
+func splitTopLevel(s string) ([]string, error) {
+	var (
+		fields []string
+		depth  int
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, E.New("unbalanced parentheses: ", s)
+			}
+		case ',':
+			if depth == 0 {
+				fields = append(fields, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, E.New("unbalanced parentheses: ", s)
+	}
+	if start < len(s) {
+		fields = append(fields, strings.TrimSpace(s[start:]))
+	}
+	return fields, nil
+}
+
+func unwrap(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return "", E.New("expected parenthesized expression: ", s)
+	}
+	return strings.TrimSpace(s[1 : len(s)-1]), nil
+}
+
+func parsePorts(value string) ([]uint16, []string, error) {
+	var (
+		ports      []uint16
+		portRanges []string
+	)
+	for _, portString := range strings.Split(value, "/") {
+		portString = strings.TrimSpace(portString)
+		startString, endString, isRange := strings.Cut(portString, "-")
+		start, err := strconv.ParseUint(startString, 10, 16)
+		if err != nil {
+			return nil, nil, E.Cause(err, "parse port: ", portString)
+		}
+		if !isRange {
+			ports = append(ports, uint16(start))
+			continue
+		}
+		end, err := strconv.ParseUint(endString, 10, 16)
+		if err != nil {
+			return nil, nil, E.Cause(err, "parse port range: ", portString)
+		}
+		if start > end {
+			return nil, nil, E.New("bad port range: ", portString)
+		}
+		portRanges = append(portRanges, startString+":"+endString)
+	}
+	return ports, portRanges, nil
+}
+
+// ParsePrefix parses an IP prefix or a single address.
+func ParsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// WildcardToRegex converts a pattern where `*` matches any characters
+// and `?` matches a single character to a regular expression.
+func WildcardToRegex(pattern string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, char := range pattern {
+		switch char {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// RegexToWildcard converts a regular expression generated by WildcardToRegex back.
+func RegexToWildcard(expression string) (string, bool) {
+	if !strings.HasPrefix(expression, "^") || !strings.HasSuffix(expression, "$") {
+		return "", false
+	}
+	expression = expression[1 : len(expression)-1]
+	var builder strings.Builder
+	for i := 0; i < len(expression); i++ {
+		char := expression[i]
+		switch {
+		case char == '.' && i+1 < len(expression) && expression[i+1] == '*':
+			builder.WriteByte('*')
+			i++
+		case char == '.':
+			builder.WriteByte('?')
+		case char == '\\' && i+1 < len(expression):
+			i++
+			if expression[i] == '*' || expression[i] == '?' || !strings.ContainsRune(regexpMetaCharacters, rune(expression[i])) {
+				return "", false
+			}
+			builder.WriteByte(expression[i])
+		case strings.ContainsRune(regexpMetaCharacters, rune(char)):
+			return "", false
+		default:
+			builder.WriteByte(char)
+		}
+	}
+	return builder.String(), true
+}
+
+const regexpMetaCharacters = `\.+*?()|[]{}^$`
@@ -0,0 +1,73 @@
+package surge
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/convertor/internal/classical"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+)
+
+// ToOptions converts a Surge or Quantumult X rule list.
+//
+// Lines without a rule type are treated as Surge domain set lines,
+// where domains starting with `.` match the domain and all subdomains.
+func ToOptions(reader io.Reader, logger logger.Logger) ([]option.HeadlessRule, error) {
+	scanner := bufio.NewScanner(reader)
+	var (
+		builder      classical.Builder
+		parsedLines  int
+		ignoredLines int
+	)
+	for scanner.Scan() {
+		ruleLine := strings.TrimSpace(scanner.Text())
+		if ruleLine == "" || strings.HasPrefix(ruleLine, "#") || strings.HasPrefix(ruleLine, "//") || strings.HasPrefix(ruleLine, ";") {
+			continue
+		}
+		if !strings.Contains(ruleLine, ",") {
+			if strings.HasPrefix(ruleLine, ".") {
+				builder.AddDefault("", option.DefaultHeadlessRule{DomainSuffix: []string{ruleLine[1:]}})
+			} else {
+				builder.AddDefault("", option.DefaultHeadlessRule{Domain: []string{ruleLine}})
+			}
+			parsedLines++
+			continue
+		}
+		err := builder.Add(ruleLine)
+		if err != nil {
+			ignoredLines++
+			logger.Warn("ignored unsupported rule: ", ruleLine, ": ", err)
+			continue
+		}
+		parsedLines++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if parsedLines == 0 {
+		return nil, E.New("Surge rule-set is empty or all rules are unsupported")
+	}
+	if ignoredLines > 0 {
+		logger.Info("parsed rules: ", parsedLines, "/", parsedLines+ignoredLines)
+	}
+	return builder.Build(), nil
+}
+
+// FromOptions converts rules to a Surge rule list,
+// rules that can not be converted are skipped with a warning.
+func FromOptions(rules []option.HeadlessRule, logger logger.Logger) ([]byte, error) {
+	ruleLines, err := classical.Format(classical.DialectSurge, rules, logger)
+	if err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	for _, ruleLine := range ruleLines {
+		output.WriteString(ruleLine)
+		output.WriteString("\n")
+	}
+	return output.Bytes(), nil
+}
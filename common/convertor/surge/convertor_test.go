@@ -0,0 +1,102 @@
+package surge_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor/surge"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConverter(t *testing.T) {
+	t.Parallel()
+	ruleString := `# Surge
+DOMAIN,example.com
+DOMAIN-SUFFIX,example.org
+DOMAIN-WILDCARD,*.example.net
+IP-CIDR,10.0.0.0/8,no-resolve
+DEST-PORT,853
+USER-AGENT,*Instagram*
+AND,((DOMAIN-SUFFIX,example.edu), (PROTOCOL,UDP))
+URL-REGEX,^http://example\.gov
+; Quantumult X
+host-suffix, sagernet.org, proxy
+ip6-cidr, 2001:db8::/32, direct
+`
+	rules, err := surge.ToOptions(strings.NewReader(ruleString), logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	headlessRule, err := rule.NewHeadlessRule(context.Background(), option.HeadlessRule{
+		Type: C.RuleTypeLogical,
+		LogicalOptions: option.LogicalHeadlessRule{
+			Mode:  C.LogicalTypeOr,
+			Rules: rules,
+		},
+	})
+	require.NoError(t, err)
+	matchMetadata := []adapter.InboundContext{
+		{Domain: "example.com"},
+		{Domain: "www.example.org"},
+		{Domain: "www.example.net"},
+		{Domain: "www.sagernet.org"},
+		{Destination: M.ParseSocksaddr("10.0.0.1:443")},
+		{Destination: M.ParseSocksaddr("[2001:db8::1]:443")},
+		{Destination: M.ParseSocksaddr("1.1.1.1:853")},
+		{Destination: M.ParseSocksaddr("1.1.1.1:80"), HTTPUserAgent: "Instagram 1.0"},
+		{Domain: "www.example.edu", Network: "udp", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+	}
+	notMatchMetadata := []adapter.InboundContext{
+		{Domain: "www.example.com", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+		{Domain: "example.net", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+		{Domain: "www.example.edu", Network: "tcp", Destination: M.ParseSocksaddr("1.1.1.1:443")},
+		{Destination: M.ParseSocksaddr("1.1.1.1:80"), HTTPUserAgent: "curl/8.0"},
+	}
+	for _, metadata := range matchMetadata {
+		require.True(t, headlessRule.Match(&metadata), metadata)
+	}
+	for _, metadata := range notMatchMetadata {
+		require.False(t, headlessRule.Match(&metadata), metadata)
+	}
+	content, err := surge.FromOptions(rules, logger.NOP())
+	require.NoError(t, err)
+	require.Equal(t, `DOMAIN,example.com
+DOMAIN-SUFFIX,example.org
+DOMAIN-SUFFIX,sagernet.org
+DOMAIN-WILDCARD,*.example.net
+IP-CIDR,10.0.0.0/8
+IP-CIDR6,2001:db8::/32
+DEST-PORT,853
+USER-AGENT,*Instagram*
+AND,((DOMAIN-SUFFIX,example.edu),(PROTOCOL,UDP))
+`, string(content))
+}
+
+func TestDomainSet(t *testing.T) {
+	t.Parallel()
+	rules, err := surge.ToOptions(strings.NewReader(`
+example.com
+.example.org
+`), logger.NOP())
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	headlessRule, err := rule.NewHeadlessRule(context.Background(), rules[0])
+	require.NoError(t, err)
+	for _, domain := range []string{"example.com", "example.org", "www.example.org"} {
+		require.True(t, headlessRule.Match(&adapter.InboundContext{
+			Domain: domain,
+		}), domain)
+	}
+	for _, domain := range []string{"www.example.com", "notexample.org"} {
+		require.False(t, headlessRule.Match(&adapter.InboundContext{
+			Domain: domain,
+		}), domain)
+	}
+}
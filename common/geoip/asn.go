@@ -0,0 +1,187 @@
+package geoip
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// ASNReader reads a MaxMind ASN database, such as GeoLite2-ASN,
+// or an ip2asn TSV file, optionally gzip compressed.
+type ASNReader struct {
+	reader       *maxminddb.Reader
+	ipv4Ranges   []asnRange
+	ipv6Ranges   []asnRange
+	organization []string
+}
+
+type asnRange struct {
+	from         netip.Addr
+	to           netip.Addr
+	number       uint32
+	organization int
+}
+
+type asnRecord struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+type asnNumberRecord struct {
+	Number uint32 `maxminddb:"autonomous_system_number"`
+}
+
+func OpenASN(path string) (*ASNReader, error) {
+	database, err := maxminddb.Open(path)
+	if err == nil {
+		if !strings.Contains(database.Metadata.DatabaseType, "ASN") {
+			database.Close()
+			return nil, E.New("incorrect database type, expected ASN, got ", database.Metadata.DatabaseType)
+		}
+		return &ASNReader{reader: database}, nil
+	}
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+	return NewTSVASNReader(file)
+}
+
+// NewTSVASNReader reads an ip2asn TSV file, with lines of
+// range start, range end, AS number, country code and AS description.
+func NewTSVASNReader(reader io.Reader) (*ASNReader, error) {
+	bufferedReader := bufio.NewReader(reader)
+	header, err := bufferedReader.Peek(2)
+	if err == nil && bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		bufferedReader = bufio.NewReader(gzipReader)
+	}
+	asnReader := &ASNReader{}
+	organizationIndex := make(map[string]int)
+	scanner := bufio.NewScanner(bufferedReader)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return nil, E.New("invalid ip2asn line ", lineNumber, ": ", line)
+		}
+		from, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, E.Cause(err, "invalid ip2asn line ", lineNumber)
+		}
+		to, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, E.Cause(err, "invalid ip2asn line ", lineNumber)
+		}
+		if from.Is4() != to.Is4() || to.Less(from) {
+			return nil, E.New("invalid ip2asn line ", lineNumber, ": invalid range")
+		}
+		number, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, E.Cause(err, "invalid ip2asn line ", lineNumber)
+		}
+		// AS0 marks ranges that are not routed
+		if number == 0 {
+			continue
+		}
+		ipRange := asnRange{from: from, to: to, number: uint32(number), organization: -1}
+		if len(fields) >= 5 {
+			index, loaded := organizationIndex[fields[4]]
+			if !loaded {
+				index = len(asnReader.organization)
+				asnReader.organization = append(asnReader.organization, fields[4])
+				organizationIndex[fields[4]] = index
+			}
+			ipRange.organization = index
+		}
+		if from.Is4() {
+			asnReader.ipv4Ranges = append(asnReader.ipv4Ranges, ipRange)
+		} else {
+			asnReader.ipv6Ranges = append(asnReader.ipv6Ranges, ipRange)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, ranges := range [][]asnRange{asnReader.ipv4Ranges, asnReader.ipv6Ranges} {
+		sort.Slice(ranges, func(i, j int) bool {
+			return ranges[i].from.Less(ranges[j].from)
+		})
+	}
+	return asnReader, nil
+}
+
+// LookupASN returns the number of the autonomous system announcing addr, or zero if not found.
+func (r *ASNReader) LookupASN(addr netip.Addr) uint32 {
+	addr = addr.Unmap().WithZone("")
+	if r.reader != nil {
+		var record asnNumberRecord
+		_ = r.reader.Lookup(addr.AsSlice(), &record)
+		return record.Number
+	}
+	ipRange := r.lookupRange(addr)
+	if ipRange == nil {
+		return 0
+	}
+	return ipRange.number
+}
+
+// Lookup returns the number and the organization of the autonomous system announcing addr.
+func (r *ASNReader) Lookup(addr netip.Addr) (number uint32, organization string) {
+	addr = addr.Unmap().WithZone("")
+	if r.reader != nil {
+		var record asnRecord
+		_ = r.reader.Lookup(addr.AsSlice(), &record)
+		return record.Number, record.Organization
+	}
+	ipRange := r.lookupRange(addr)
+	if ipRange == nil {
+		return 0, ""
+	}
+	if ipRange.organization >= 0 {
+		organization = r.organization[ipRange.organization]
+	}
+	return ipRange.number, organization
+}
+
+func (r *ASNReader) lookupRange(addr netip.Addr) *asnRange {
+	ranges := r.ipv6Ranges
+	if addr.Is4() {
+		ranges = r.ipv4Ranges
+	}
+	// find the first range starting after addr
+	index := sort.Search(len(ranges), func(i int) bool {
+		return addr.Less(ranges[i].from)
+	})
+	if index == 0 || ranges[index-1].to.Less(addr) {
+		return nil
+	}
+	return &ranges[index-1]
+}
+
+func (r *ASNReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
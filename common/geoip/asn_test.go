@@ -0,0 +1,53 @@
+package geoip_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/geoip"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testIP2ASN = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"1.0.4.0\t1.0.7.255\t38803\tAU\tWPL-AS-AP Wirefreebroadband Pty Ltd\n" +
+	"2606:4700::\t2606:4700:ffff:ffff:ffff:ffff:ffff:ffff\t13335\tUS\tCLOUDFLARENET\n"
+
+func TestASNReaderTSV(t *testing.T) {
+	t.Parallel()
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err := gzipWriter.Write([]byte(testIP2ASN))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+	for name, content := range map[string][]byte{
+		"plain": []byte(testIP2ASN),
+		"gzip":  compressed.Bytes(),
+	} {
+		reader, err := geoip.NewTSVASNReader(bytes.NewReader(content))
+		require.NoError(t, err, name)
+		for address, number := range map[string]uint32{
+			"1.0.0.1":          13335,
+			"::ffff:1.0.0.255": 13335,
+			"1.0.2.1":          0,
+			"1.0.7.255":        38803,
+			"1.0.8.0":          0,
+			"0.0.0.1":          0,
+			"2606:4700::1111":  13335,
+			"fe80::1%eth0":     0,
+		} {
+			require.Equal(t, number, reader.LookupASN(netip.MustParseAddr(address)), address)
+		}
+		number, organization := reader.Lookup(netip.MustParseAddr("1.0.5.1"))
+		require.Equal(t, uint32(38803), number)
+		require.Equal(t, "WPL-AS-AP Wirefreebroadband Pty Ltd", organization)
+	}
+	_, err = geoip.NewTSVASNReader(strings.NewReader("1.0.0.0\t::1\t13335\n"))
+	require.Error(t, err)
+	_, err = geoip.NewTSVASNReader(strings.NewReader("1.0.0.0\t1.0.0.255\tAS13335\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package geoip
+
+import (
+	"net/netip"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"go4.org/netipx"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// readDat reads a V2Ray geoip.dat file, which is a GeoIPList protobuf message.
+func readDat(content []byte) (map[string]*netipx.IPSet, error) {
+	builders := make(map[string]*netipx.IPSetBuilder)
+	err := consumeMessage(content, func(number protowire.Number, value []byte) error {
+		if number != 1 {
+			return nil
+		}
+		return readDatGeoIP(value, builders)
+	})
+	if err != nil {
+		return nil, E.Cause(err, "read geoip.dat")
+	}
+	codes := make(map[string]*netipx.IPSet, len(builders))
+	for code, builder := range builders {
+		codes[code], err = builder.IPSet()
+		if err != nil {
+			return nil, E.Cause(err, "read geoip.dat: ", code)
+		}
+	}
+	return codes, nil
+}
+
+func readDatGeoIP(content []byte, builders map[string]*netipx.IPSetBuilder) error {
+	var (
+		code         string
+		prefixes     []netip.Prefix
+		reverseMatch bool
+	)
+	err := consumeMessage(content, func(number protowire.Number, value []byte) error {
+		switch number {
+		case 1:
+			code = strings.ToLower(string(value))
+		case 2:
+			prefix, err := readDatCIDR(value)
+			if err != nil {
+				return err
+			}
+			prefixes = append(prefixes, prefix)
+		case 3:
+			flag, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			reverseMatch = flag != 0
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if code == "" {
+		return E.New("missing country code")
+	}
+	var builder netipx.IPSetBuilder
+	for _, prefix := range prefixes {
+		builder.AddPrefix(prefix)
+	}
+	if reverseMatch {
+		builder.Complement()
+	}
+	if existing := builders[code]; existing != nil {
+		ipSet, err := builder.IPSet()
+		if err != nil {
+			return err
+		}
+		existing.AddSet(ipSet)
+	} else {
+		builders[code] = &builder
+	}
+	return nil
+}
+
+func readDatCIDR(content []byte) (prefix netip.Prefix, err error) {
+	var (
+		addr netip.Addr
+		bits uint64
+	)
+	err = consumeMessage(content, func(number protowire.Number, value []byte) error {
+		switch number {
+		case 1:
+			var ok bool
+			addr, ok = netip.AddrFromSlice(value)
+			if !ok {
+				return E.New("invalid address length: ", len(value))
+			}
+		case 2:
+			var n int
+			bits, n = protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	if bits > uint64(addr.BitLen()) {
+		return prefix, E.New("invalid prefix length: ", addr, "/", bits)
+	}
+	return netip.PrefixFrom(addr, int(bits)).Masked(), nil
+}
+
+// consumeMessage calls f with the number and the value of each field in content.
+// Varint values are passed encoded, other values without their length prefix.
+func consumeMessage(content []byte, f func(number protowire.Number, value []byte) error) error {
+	for len(content) > 0 {
+		number, wireType, n := protowire.ConsumeTag(content)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		content = content[n:]
+		var value []byte
+		if wireType == protowire.BytesType {
+			value, n = protowire.ConsumeBytes(content)
+		} else {
+			n = protowire.ConsumeFieldValue(number, wireType, content)
+			if n >= 0 {
+				value = content[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		content = content[n:]
+		err := f(number, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
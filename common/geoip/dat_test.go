@@ -0,0 +1,75 @@
+package geoip_test
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/geoip"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendDatGeoIP(content []byte, code string, reverseMatch bool, prefixes ...string) []byte {
+	var geoIP []byte
+	geoIP = protowire.AppendTag(geoIP, 1, protowire.BytesType)
+	geoIP = protowire.AppendString(geoIP, code)
+	for _, prefixString := range prefixes {
+		prefix := netip.MustParsePrefix(prefixString)
+		var cidr []byte
+		cidr = protowire.AppendTag(cidr, 1, protowire.BytesType)
+		cidr = protowire.AppendBytes(cidr, prefix.Addr().AsSlice())
+		cidr = protowire.AppendTag(cidr, 2, protowire.VarintType)
+		cidr = protowire.AppendVarint(cidr, uint64(prefix.Bits()))
+		geoIP = protowire.AppendTag(geoIP, 2, protowire.BytesType)
+		geoIP = protowire.AppendBytes(geoIP, cidr)
+	}
+	if reverseMatch {
+		geoIP = protowire.AppendTag(geoIP, 3, protowire.VarintType)
+		geoIP = protowire.AppendVarint(geoIP, 1)
+	}
+	content = protowire.AppendTag(content, 1, protowire.BytesType)
+	return protowire.AppendBytes(content, geoIP)
+}
+
+func TestGeoIPDat(t *testing.T) {
+	t.Parallel()
+	var content []byte
+	content = appendDatGeoIP(content, "CN", false, "1.0.1.0/24", "1.0.2.0/23", "2001:db8::/32")
+	content = appendDatGeoIP(content, "PRIVATE", false, "10.0.0.0/8")
+	content = appendDatGeoIP(content, "NOT-PRIVATE", true, "10.0.0.0/8", "::/1")
+	content = appendDatGeoIP(content, "HK", false, "1.0.2.0/24", "1.0.4.0/24")
+	path := filepath.Join(t.TempDir(), "geoip.dat")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	reader, codes, err := geoip.Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, []string{"cn", "hk", "not-private", "private"}, codes)
+	prefixes, err := reader.Read("cn")
+	require.NoError(t, err)
+	require.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("1.0.1.0/24"),
+		netip.MustParsePrefix("1.0.2.0/23"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, prefixes)
+	// overlapping ranges belong to the first country code
+	require.Equal(t, "cn", reader.Lookup(netip.MustParseAddr("1.0.2.1")))
+	require.Equal(t, "cn", reader.Lookup(netip.MustParseAddr("2001:db8::1")))
+	require.Equal(t, "hk", reader.Lookup(netip.MustParseAddr("1.0.4.1")))
+	require.Equal(t, "unknown", reader.Lookup(netip.MustParseAddr("1.0.5.1")))
+	// codes other than countries are not returned
+	require.Equal(t, "unknown", reader.Lookup(netip.MustParseAddr("10.1.1.1")))
+	require.Equal(t, "unknown", reader.Lookup(netip.MustParseAddr("8.8.8.8")))
+	require.Equal(t, "unknown", reader.Lookup(netip.MustParseAddr("::1")))
+	all, err := reader.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	_, err = reader.Read("us")
+	require.Error(t, err)
+
+	_, _, err = geoip.NewDatReader(content[:len(content)-1])
+	require.Error(t, err)
+}
@@ -1,30 +1,115 @@
 package geoip
 
 import (
+	"net"
 	"net/netip"
+	"os"
+	"sort"
+	"strings"
 
 	E "github.com/sagernet/sing/common/exceptions"
 
 	"github.com/oschwald/maxminddb-golang"
+	"go4.org/netipx"
 )
 
+// Reader reads a sing-geoip MaxMind database or a V2Ray geoip.dat file.
 type Reader struct {
-	reader *maxminddb.Reader
+	reader       *maxminddb.Reader
+	datCodes     map[string]*netipx.IPSet
+	datCountries []countryRange
+	codes        []string
+}
+
+type countryRange struct {
+	netipx.IPRange
+	code string
 }
 
 func Open(path string) (*Reader, []string, error) {
 	database, err := maxminddb.Open(path)
 	if err != nil {
-		return nil, nil, err
+		content, readErr := os.ReadFile(path)
+		if readErr != nil || len(content) == 0 || content[0] != 0x0a {
+			return nil, nil, err
+		}
+		return NewDatReader(content)
 	}
 	if database.Metadata.DatabaseType != "sing-geoip" {
 		database.Close()
 		return nil, nil, E.New("incorrect database type, expected sing-geoip, got ", database.Metadata.DatabaseType)
 	}
-	return &Reader{database}, database.Metadata.Languages, nil
+	return &Reader{reader: database, codes: database.Metadata.Languages}, database.Metadata.Languages, nil
+}
+
+// NewDatReader creates a reader from the content of a V2Ray geoip.dat file.
+func NewDatReader(content []byte) (*Reader, []string, error) {
+	datCodes, err := readDat(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	codes := make([]string, 0, len(datCodes))
+	for code := range datCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	datCountries, err := buildCountryRanges(datCodes, codes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Reader{datCodes: datCodes, datCountries: datCountries, codes: codes}, codes, nil
+}
+
+// buildCountryRanges builds a sorted table of non-overlapping ranges of country codes,
+// skipping other codes of geoip.dat files such as private or cloudflare.
+// Overlapping ranges are assigned to the first code in order.
+func buildCountryRanges(datCodes map[string]*netipx.IPSet, codes []string) ([]countryRange, error) {
+	var (
+		covered netipx.IPSetBuilder
+		ranges  []countryRange
+	)
+	for _, code := range codes {
+		if !isCountryCode(code) {
+			continue
+		}
+		var builder netipx.IPSetBuilder
+		builder.AddSet(datCodes[code])
+		coveredSet, err := covered.IPSet()
+		if err != nil {
+			return nil, err
+		}
+		builder.RemoveSet(coveredSet)
+		ipSet, err := builder.IPSet()
+		if err != nil {
+			return nil, err
+		}
+		for _, ipRange := range ipSet.Ranges() {
+			ranges = append(ranges, countryRange{ipRange, code})
+		}
+		covered.AddSet(ipSet)
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].From().Less(ranges[j].From())
+	})
+	return ranges, nil
 }
 
+func isCountryCode(code string) bool {
+	return len(code) == 2 && code[0] >= 'a' && code[0] <= 'z' && code[1] >= 'a' && code[1] <= 'z'
+}
+
+// Lookup returns the country code of addr, or unknown.
+// Only two-letter country codes of geoip.dat files are considered.
 func (r *Reader) Lookup(addr netip.Addr) string {
+	if r.datCodes != nil {
+		index := sort.Search(len(r.datCountries), func(i int) bool {
+			return !r.datCountries[i].To().Less(addr)
+		})
+		if index < len(r.datCountries) && r.datCountries[index].Contains(addr) {
+			return r.datCountries[index].code
+		}
+		return "unknown"
+	}
 	var code string
 	_ = r.reader.Lookup(addr.AsSlice(), &code)
 	if code != "" {
@@ -33,6 +118,59 @@ func (r *Reader) Lookup(addr netip.Addr) string {
 	return "unknown"
 }
 
+// Read returns the prefixes of code.
+func (r *Reader) Read(code string) ([]netip.Prefix, error) {
+	code = strings.ToLower(code)
+	if r.datCodes != nil {
+		ipSet, loaded := r.datCodes[code]
+		if !loaded {
+			return nil, E.New("code ", code, " not exists!")
+		}
+		return ipSet.Prefixes(), nil
+	}
+	codes, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	prefixes, loaded := codes[code]
+	if !loaded {
+		return nil, E.New("code ", code, " not exists!")
+	}
+	return prefixes, nil
+}
+
+// ReadAll returns the prefixes of all codes.
+func (r *Reader) ReadAll() (map[string][]netip.Prefix, error) {
+	codes := make(map[string][]netip.Prefix)
+	if r.datCodes != nil {
+		for code, ipSet := range r.datCodes {
+			codes[code] = ipSet.Prefixes()
+		}
+		return codes, nil
+	}
+	networks := r.reader.Networks(maxminddb.SkipAliasedNetworks)
+	var (
+		ipNet *net.IPNet
+		code  string
+		err   error
+	)
+	for networks.Next() {
+		ipNet, err = networks.Network(&code)
+		if err != nil {
+			return nil, err
+		}
+		prefix, ok := netipx.FromStdIPNet(ipNet)
+		if !ok {
+			return nil, E.New("invalid network: ", ipNet)
+		}
+		codes[code] = append(codes[code], prefix)
+	}
+	return codes, networks.Err()
+}
+
 func (r *Reader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
 	return r.reader.Close()
 }
@@ -0,0 +1,199 @@
+package geosite
+
+import (
+	"sort"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// datItem is a domain of a V2Ray geosite.dat file with its attributes.
+type datItem struct {
+	Item
+	attributes []string
+}
+
+// V2Ray domain types
+const (
+	datTypePlain  = 0
+	datTypeRegex  = 1
+	datTypeDomain = 2
+	datTypeFull   = 3
+)
+
+// readDat reads a V2Ray geosite.dat file, which is a GeoSiteList protobuf message.
+func readDat(content []byte) (map[string][]datItem, error) {
+	codes := make(map[string][]datItem)
+	err := consumeMessage(content, func(number protowire.Number, value []byte) error {
+		if number != 1 {
+			return nil
+		}
+		code, items, err := readDatGeoSite(value)
+		if err != nil {
+			return err
+		}
+		codes[code] = append(codes[code], items...)
+		return nil
+	})
+	if err != nil {
+		return nil, E.Cause(err, "read geosite.dat")
+	}
+	return codes, nil
+}
+
+func readDatGeoSite(content []byte) (code string, items []datItem, err error) {
+	err = consumeMessage(content, func(number protowire.Number, value []byte) error {
+		switch number {
+		case 1:
+			code = strings.ToLower(string(value))
+		case 2:
+			item, err := readDatDomain(value)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err == nil && code == "" {
+		err = E.New("missing country code")
+	}
+	return
+}
+
+func readDatDomain(content []byte) (item datItem, err error) {
+	var domainType uint64
+	err = consumeMessage(content, func(number protowire.Number, value []byte) error {
+		switch number {
+		case 1:
+			var n int
+			domainType, n = protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+		case 2:
+			item.Value = string(value)
+		case 3:
+			return consumeMessage(value, func(number protowire.Number, value []byte) error {
+				if number == 1 {
+					item.attributes = append(item.attributes, strings.ToLower(string(value)))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	switch domainType {
+	case datTypePlain:
+		item.Type = RuleTypeDomainKeyword
+	case datTypeRegex:
+		item.Type = RuleTypeDomainRegex
+	case datTypeDomain:
+		item.Type = RuleTypeDomainSuffix
+	case datTypeFull:
+		item.Type = RuleTypeDomain
+	default:
+		err = E.New("unknown domain type: ", domainType)
+	}
+	return
+}
+
+// consumeMessage calls f with the number and the value of each field in content.
+// Varint values are passed encoded, other values without their length prefix.
+func consumeMessage(content []byte, f func(number protowire.Number, value []byte) error) error {
+	for len(content) > 0 {
+		number, wireType, n := protowire.ConsumeTag(content)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		content = content[n:]
+		var value []byte
+		if wireType == protowire.BytesType {
+			value, n = protowire.ConsumeBytes(content)
+		} else {
+			n = protowire.ConsumeFieldValue(number, wireType, content)
+			if n >= 0 {
+				value = content[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		content = content[n:]
+		err := f(number, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type attributeFilter struct {
+	name   string
+	negate bool
+}
+
+// parseCode splits code and attribute filters, as in `google@cn` or `google@!cn`.
+func parseCode(selector string) (code string, filters []attributeFilter, err error) {
+	parts := strings.Split(strings.ToLower(selector), "@")
+	code = parts[0]
+	for _, part := range parts[1:] {
+		var filter attributeFilter
+		if strings.HasPrefix(part, "!") {
+			filter.negate = true
+			part = part[1:]
+		}
+		if part == "" {
+			return "", nil, E.New("invalid attribute in ", selector)
+		}
+		filter.name = part
+		filters = append(filters, filter)
+	}
+	return
+}
+
+func filterItems(items []datItem, filters []attributeFilter) []Item {
+	result := make([]Item, 0, len(items))
+	for _, item := range items {
+		matched := true
+		for _, filter := range filters {
+			if hasAttribute(item.attributes, filter.name) == filter.negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, item.Item)
+		}
+	}
+	return result
+}
+
+func hasAttribute(attributes []string, name string) bool {
+	for _, attribute := range attributes {
+		if attribute == name {
+			return true
+		}
+	}
+	return false
+}
+
+func datAttributes(items []datItem) []string {
+	attributeMap := make(map[string]bool)
+	for _, item := range items {
+		for _, attribute := range item.attributes {
+			attributeMap[attribute] = true
+		}
+	}
+	attributes := make([]string, 0, len(attributeMap))
+	for attribute := range attributeMap {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
@@ -0,0 +1,90 @@
+package geosite_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/geosite"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func appendDatDomain(content []byte, domainType uint64, value string, attributes ...string) []byte {
+	var domain []byte
+	domain = protowire.AppendTag(domain, 1, protowire.VarintType)
+	domain = protowire.AppendVarint(domain, domainType)
+	domain = protowire.AppendTag(domain, 2, protowire.BytesType)
+	domain = protowire.AppendString(domain, value)
+	for _, attribute := range attributes {
+		var message []byte
+		message = protowire.AppendTag(message, 1, protowire.BytesType)
+		message = protowire.AppendString(message, attribute)
+		message = protowire.AppendTag(message, 2, protowire.VarintType)
+		message = protowire.AppendVarint(message, 1)
+		domain = protowire.AppendTag(domain, 3, protowire.BytesType)
+		domain = protowire.AppendBytes(domain, message)
+	}
+	content = protowire.AppendTag(content, 2, protowire.BytesType)
+	return protowire.AppendBytes(content, domain)
+}
+
+func TestGeositeDat(t *testing.T) {
+	t.Parallel()
+	var site []byte
+	site = protowire.AppendTag(site, 1, protowire.BytesType)
+	site = protowire.AppendString(site, "GOOGLE")
+	site = appendDatDomain(site, 0, "google")
+	site = appendDatDomain(site, 1, `^google\.com\.[a-z]+$`)
+	site = appendDatDomain(site, 2, "google.com")
+	site = appendDatDomain(site, 2, "google.cn", "cn")
+	site = appendDatDomain(site, 3, "ads.google.com", "ads", "cn")
+	var content []byte
+	content = protowire.AppendTag(content, 1, protowire.BytesType)
+	content = protowire.AppendBytes(content, site)
+
+	reader, codes, err := geosite.NewReader(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, []string{"google"}, codes)
+	require.Equal(t, []string{"ads", "cn"}, reader.Attributes("google"))
+	items, err := reader.Read("google")
+	require.NoError(t, err)
+	require.Equal(t, []geosite.Item{
+		{Type: geosite.RuleTypeDomainKeyword, Value: "google"},
+		{Type: geosite.RuleTypeDomainRegex, Value: `^google\.com\.[a-z]+$`},
+		{Type: geosite.RuleTypeDomainSuffix, Value: "google.com"},
+		{Type: geosite.RuleTypeDomainSuffix, Value: "google.cn"},
+		{Type: geosite.RuleTypeDomain, Value: "ads.google.com"},
+	}, items)
+	items, err = reader.Read("google@cn")
+	require.NoError(t, err)
+	require.Equal(t, []geosite.Item{
+		{Type: geosite.RuleTypeDomainSuffix, Value: "google.cn"},
+		{Type: geosite.RuleTypeDomain, Value: "ads.google.com"},
+	}, items)
+	items, err = reader.Read("google@cn@!ads")
+	require.NoError(t, err)
+	require.Equal(t, []geosite.Item{{Type: geosite.RuleTypeDomainSuffix, Value: "google.cn"}}, items)
+	items, err = reader.Read("GOOGLE@!cn")
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	_, err = reader.Read("google@")
+	require.Error(t, err)
+	_, err = reader.Read("facebook")
+	require.Error(t, err)
+	_, _, err = geosite.NewReader(bytes.NewReader(content[:len(content)-1]))
+	require.Error(t, err)
+}
+
+func TestGeositeAttributeUnsupported(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	require.NoError(t, geosite.Write(&buffer, map[string][]geosite.Item{
+		"test": {{Type: geosite.RuleTypeDomain, Value: "example.org"}},
+	}))
+	reader, _, err := geosite.NewReader(bytes.NewReader(buffer.Bytes()))
+	require.NoError(t, err)
+	_, err = reader.Read("test@cn")
+	require.Error(t, err)
+	require.Empty(t, reader.Attributes("test"))
+}
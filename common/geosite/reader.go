@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"io"
 	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -18,6 +19,7 @@ type Reader struct {
 	metadataIndex  int64
 	domainIndex    map[string]int
 	domainLength   map[string]int
+	datItems       map[string][]datItem
 }
 
 func Open(path string) (*Reader, []string, error) {
@@ -37,13 +39,39 @@ func NewReader(readSeeker io.ReadSeeker) (*Reader, []string, error) {
 	reader := &Reader{
 		reader: readSeeker,
 	}
-	err := reader.readMetadata()
+	var header [1]byte
+	_, err := io.ReadFull(readSeeker, header[:])
 	if err != nil {
 		return nil, nil, err
 	}
-	codes := make([]string, 0, len(reader.domainIndex))
-	for code := range reader.domainIndex {
-		codes = append(codes, code)
+	_, err = readSeeker.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	var codes []string
+	// a V2Ray geosite.dat file starts with the tag of its first entry
+	if header[0] == 0x0a {
+		content, err := io.ReadAll(readSeeker)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader.datItems, err = readDat(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = make([]string, 0, len(reader.datItems))
+		for code := range reader.datItems {
+			codes = append(codes, code)
+		}
+	} else {
+		err = reader.readMetadata()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = make([]string, 0, len(reader.domainIndex))
+		for code := range reader.domainIndex {
+			codes = append(codes, code)
+		}
 	}
 	return reader, codes, nil
 }
@@ -100,7 +128,25 @@ func (r *Reader) readMetadata() error {
 	return nil
 }
 
+// Read reads the items of code.
+//
+// For V2Ray geosite.dat files, code may select items by attribute,
+// as in `google@cn` (having attribute cn) or `google@!cn` (not having it).
 func (r *Reader) Read(code string) ([]Item, error) {
+	if r.datItems != nil {
+		code, filters, err := parseCode(code)
+		if err != nil {
+			return nil, err
+		}
+		items, exists := r.datItems[code]
+		if !exists {
+			return nil, E.New("code ", code, " not exists!")
+		}
+		return filterItems(items, filters), nil
+	}
+	if strings.Contains(code, "@") {
+		return nil, E.New("attributes are only available in V2Ray geosite.dat files: ", code)
+	}
 	index, exists := r.domainIndex[code]
 	if !exists {
 		return nil, E.New("code ", code, " not exists!")
@@ -125,6 +171,11 @@ func (r *Reader) Read(code string) ([]Item, error) {
 	return itemList, nil
 }
 
+// Attributes returns the attributes used in code, which are always empty for sing-box geosite.db files.
+func (r *Reader) Attributes(code string) []string {
+	return datAttributes(r.datItems[code])
+}
+
 func (r *Reader) Upstream() any {
 	return r.reader
 }
@@ -0,0 +1,129 @@
+package ja3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	JA4ProtocolTCP  = 't'
+	JA4ProtocolQUIC = 'q'
+)
+
+// JA4 returns the JA4 fingerprint (JA4_a_JA4_b_JA4_c),
+// see https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+func (j *ClientHello) JA4(protocol byte) string {
+	var builder strings.Builder
+	builder.WriteByte(protocol)
+	builder.WriteString(ja4Version(j.maxVersion()))
+	if j.ServerName != "" {
+		builder.WriteByte('d')
+	} else {
+		builder.WriteByte('i')
+	}
+	cipherSuites := filterGrease(j.CipherSuites)
+	extensions := filterGrease(j.Extensions)
+	builder.WriteString(ja4Count(len(cipherSuites)))
+	builder.WriteString(ja4Count(len(extensions)))
+	builder.WriteString(ja4ALPN(j.ALPNProtocols))
+	builder.WriteByte('_')
+	slices.Sort(cipherSuites)
+	builder.WriteString(ja4Hash(ja4HexList(cipherSuites)))
+	builder.WriteByte('_')
+	extensions = slices.DeleteFunc(extensions, func(it uint16) bool {
+		return it == sniExtensionType || it == alpnExtensionType
+	})
+	slices.Sort(extensions)
+	extensionList := ja4HexList(extensions)
+	if len(extensions) > 0 && len(j.SignatureAlgorithms) > 0 {
+		extensionList += "_" + ja4HexList(filterGrease(j.SignatureAlgorithms))
+	}
+	builder.WriteString(ja4Hash(extensionList))
+	return builder.String()
+}
+
+func (j *ClientHello) maxVersion() uint16 {
+	var version uint16
+	for _, it := range j.Versions {
+		if !isGrease(it) && it > version {
+			version = it
+		}
+	}
+	if version == 0 {
+		version = j.Version
+	}
+	return version
+}
+
+func ja4Version(version uint16) string {
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+func ja4Count(count int) string {
+	if count > 99 {
+		count = 99
+	}
+	if count < 10 {
+		return "0" + strconv.Itoa(count)
+	}
+	return strconv.Itoa(count)
+}
+
+func ja4ALPN(protocols []string) string {
+	if len(protocols) == 0 || protocols[0] == "" {
+		return "00"
+	}
+	alpn := protocols[0]
+	first, last := alpn[0], alpn[len(alpn)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	return hex.EncodeToString([]byte{first})[:1] + hex.EncodeToString([]byte{last})[1:]
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func ja4HexList(values []uint16) string {
+	hexValues := make([]string, 0, len(values))
+	for _, value := range values {
+		hexValues = append(hexValues, hex.EncodeToString([]byte{byte(value >> 8), byte(value)}))
+	}
+	return strings.Join(hexValues, ",")
+}
+
+func ja4Hash(value string) string {
+	if value == "" {
+		return "000000000000"
+	}
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+func filterGrease(values []uint16) []uint16 {
+	result := make([]uint16, 0, len(values))
+	for _, value := range values {
+		if !isGrease(value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -34,6 +34,8 @@ const (
 	ecpfExtensionType                     uint16 = 11
 	versionExtensionType                  uint16 = 43
 	signatureAlgorithmsExtensionType      uint16 = 13
+	alpnExtensionType                     uint16 = 16
+	alpnExtensionHeaderLen                int    = 2
 
 	// Versions
 	// The bitmask covers the versions SSL3.0 to TLS1.2
@@ -176,6 +178,7 @@ func (j *ClientHello) parseExtensions(exs []byte) error {
 	var ellipticCurvePF []uint8
 	var versions []uint16
 	var signatureAlgorithms []uint16
+	var alpnProtocols []string
 	for len(exs) > 0 {
 
 		// Check if we can decode the next fields
@@ -275,6 +278,23 @@ func (j *ClientHello) parseExtensions(exs []byte) error {
 			for i := 0; i < int(ssaLen); i += 2 {
 				signatureAlgorithms = append(signatureAlgorithms, binary.BigEndian.Uint16(sex[2:][i:]))
 			}
+		case alpnExtensionType:
+			if len(sex) < alpnExtensionHeaderLen {
+				return &ParseError{LengthErr, 21}
+			}
+			alpnLen := int(binary.BigEndian.Uint16(sex))
+			sex = sex[alpnExtensionHeaderLen:]
+			if len(sex) != alpnLen {
+				return &ParseError{LengthErr, 22}
+			}
+			for len(sex) > 0 {
+				protocolLen := int(sex[0])
+				if len(sex) < 1+protocolLen {
+					return &ParseError{LengthErr, 23}
+				}
+				alpnProtocols = append(alpnProtocols, string(sex[1:1+protocolLen]))
+				sex = sex[1+protocolLen:]
+			}
 		}
 		exs = exs[4+exLen:]
 	}
@@ -284,6 +304,7 @@ func (j *ClientHello) parseExtensions(exs []byte) error {
 	j.EllipticCurvePF = ellipticCurvePF
 	j.Versions = versions
 	j.SignatureAlgorithms = signatureAlgorithms
+	j.ALPNProtocols = alpnProtocols
 	return nil
 }
 
@@ -298,60 +319,33 @@ func (j *ClientHello) marshalJA3() {
 	byteString = strconv.AppendUint(byteString, uint64(j.Version), 10)
 	byteString = append(byteString, commaByte)
 
-	// Cipher Suites
-	if len(j.CipherSuites) != 0 {
-		for _, val := range j.CipherSuites {
-			if val&GreaseBitmask != 0x0A0A {
-				continue
-			}
-			byteString = strconv.AppendUint(byteString, uint64(val), 10)
-			byteString = append(byteString, dashByte)
-		}
-		// Replace last dash with a comma
-		byteString[len(byteString)-1] = commaByte
-	} else {
-		byteString = append(byteString, commaByte)
-	}
-
-	// Extensions
-	if len(j.Extensions) != 0 {
-		for _, val := range j.Extensions {
-			if val&GreaseBitmask != 0x0A0A {
+	// Cipher Suites, Extensions and Elliptic curves, ignoring GREASE values
+	for _, values := range [][]uint16{j.CipherSuites, j.Extensions, j.EllipticCurves} {
+		var appended bool
+		for _, val := range values {
+			if isGrease(val) {
 				continue
 			}
-			byteString = strconv.AppendUint(byteString, uint64(val), 10)
-			byteString = append(byteString, dashByte)
-		}
-		// Replace last dash with a comma
-		byteString[len(byteString)-1] = commaByte
-	} else {
-		byteString = append(byteString, commaByte)
-	}
-
-	// Elliptic curves
-	if len(j.EllipticCurves) != 0 {
-		for _, val := range j.EllipticCurves {
-			if val&GreaseBitmask != 0x0A0A {
-				continue
+			if appended {
+				byteString = append(byteString, dashByte)
 			}
 			byteString = strconv.AppendUint(byteString, uint64(val), 10)
-			byteString = append(byteString, dashByte)
+			appended = true
 		}
-		// Replace last dash with a comma
-		byteString[len(byteString)-1] = commaByte
-	} else {
 		byteString = append(byteString, commaByte)
 	}
 
 	// ECPF
-	if len(j.EllipticCurvePF) != 0 {
-		for _, val := range j.EllipticCurvePF {
-			byteString = strconv.AppendUint(byteString, uint64(val), 10)
+	for i, val := range j.EllipticCurvePF {
+		if i > 0 {
 			byteString = append(byteString, dashByte)
 		}
-		// Remove last dash
-		byteString = byteString[:len(byteString)-1]
+		byteString = strconv.AppendUint(byteString, uint64(val), 10)
 	}
 
 	j.ja3ByteString = byteString
 }
+
+func isGrease(value uint16) bool {
+	return value&GreaseBitmask == 0x0A0A && value>>8 == value&0xFF
+}
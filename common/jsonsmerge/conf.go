@@ -30,8 +30,29 @@ var (
 )
 
 // Files merges files into a single json.
+// Files using `$include`, `$variables` or `$template` are expanded before merging.
 func Files(files, dirs []string) ([]byte, error) {
-	return merger.Merge(allFiles(files, dirs))
+	all, err := allFiles(files, dirs)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]interface{}, 0, len(all))
+	for _, file := range all {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, E.Cause(err, "read config at ", file)
+		}
+		if !HasDirectives(content) {
+			inputs = append(inputs, file)
+			continue
+		}
+		content, err = Expand(content, file)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, content)
+	}
+	return merger.Merge(inputs...)
 }
 
 // Contents merges files content into a single json.
@@ -0,0 +1,491 @@
+package jsonsmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	myjson "github.com/sagernet/sing/common/json"
+
+	"github.com/qjebbs/go-jsons"
+)
+
+// Configuration directives.
+const (
+	keyInclude   = "$include"
+	keyVariables = "$variables"
+	keyTemplates = "$templates"
+	keyTemplate  = "$template"
+)
+
+var (
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	variablePattern     = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)}`)
+)
+
+// HasDirectives reports whether content may use `$include`, `$variables` or `$template`.
+func HasDirectives(content []byte) bool {
+	return bytes.Contains(content, []byte(keyInclude)) ||
+		bytes.Contains(content, []byte(keyVariables)) ||
+		bytes.Contains(content, []byte(keyTemplate))
+}
+
+// Expand resolves includes, templates and variables of a configuration document
+// and returns it as plain JSON.
+// The format of the document is detected by the extension of path,
+// content without directives is returned as is.
+func Expand(content []byte, path string) ([]byte, error) {
+	if !HasDirectives(content) {
+		return content, nil
+	}
+	value, err := decodeDocument(content, path)
+	if err != nil {
+		return nil, E.Cause(err, "decode config at ", path)
+	}
+	e := &expander{including: map[string]bool{}}
+	if absPath, err := filepath.Abs(path); err == nil {
+		e.including[absPath] = true
+	}
+	value, err = e.include(value, filepath.Dir(path))
+	if err != nil {
+		return nil, E.Cause(err, "expand config at ", path)
+	}
+	value, err = e.expandRoot(value)
+	if err != nil {
+		return nil, E.Cause(err, "expand config at ", path)
+	}
+	return encodeDocument(value)
+}
+
+// Format re-indents a configuration document as JSON without expanding its directives.
+func Format(content []byte, path string) ([]byte, error) {
+	value, err := decodeDocument(content, path)
+	if err != nil {
+		return nil, err
+	}
+	return encodeDocument(value)
+}
+
+func encodeDocument(value any) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+type expander struct {
+	including map[string]bool
+	variables map[string]any
+	templates map[string]*jsons.OrderedMap
+	resolving map[string]bool
+}
+
+// include replaces every `$include` directive in value, relative paths are resolved against dir.
+func (e *expander) include(value any, dir string) (any, error) {
+	switch typedValue := value.(type) {
+	case *jsons.OrderedMap:
+		var included any
+		if paths, loaded := typedValue.Values[keyInclude]; loaded {
+			typedValue.Remove(keyInclude)
+			pathList, err := stringList(keyInclude, paths)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range pathList {
+				document, err := e.includeFile(path, dir)
+				if err != nil {
+					return nil, err
+				}
+				if included == nil {
+					included = document
+					continue
+				}
+				includedObject, isObject := included.(*jsons.OrderedMap)
+				documentObject, isDocumentObject := document.(*jsons.OrderedMap)
+				if isObject && isDocumentObject {
+					mergeObject(includedObject, documentObject)
+				} else {
+					includedArray, isArray := included.([]any)
+					documentArray, isDocumentArray := document.([]any)
+					if !isArray || !isDocumentArray {
+						return nil, E.New("cannot combine included documents of different types: ", path)
+					}
+					included = append(includedArray, documentArray...)
+				}
+			}
+		}
+		for _, key := range typedValue.Keys {
+			newValue, err := e.include(typedValue.Values[key], dir)
+			if err != nil {
+				return nil, E.Cause(err, key)
+			}
+			typedValue.Values[key] = newValue
+		}
+		if included == nil {
+			return typedValue, nil
+		}
+		if len(typedValue.Keys) == 0 {
+			return included, nil
+		}
+		includedObject, isObject := included.(*jsons.OrderedMap)
+		if !isObject {
+			return nil, E.New("cannot merge fields into an included array")
+		}
+		mergeObject(includedObject, typedValue)
+		return includedObject, nil
+	case []any:
+		var result []any
+		for index, element := range typedValue {
+			_, isInclude := isIncludeOnly(element)
+			newElement, err := e.include(element, dir)
+			if err != nil {
+				return nil, E.Cause(err, "[", index, "]")
+			}
+			if elements, isArray := newElement.([]any); isInclude && isArray {
+				result = append(result, elements...)
+			} else {
+				result = append(result, newElement)
+			}
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+func (e *expander) includeFile(path string, dir string) (any, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if e.including[absPath] {
+		return nil, E.New("include cycle detected at ", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, E.Cause(err, "read included file")
+	}
+	document, err := decodeDocument(content, path)
+	if err != nil {
+		return nil, E.Cause(err, "decode included file ", path)
+	}
+	e.including[absPath] = true
+	defer delete(e.including, absPath)
+	document, err = e.include(document, filepath.Dir(path))
+	if err != nil {
+		return nil, E.Cause(err, "include ", path)
+	}
+	return document, nil
+}
+
+// expandRoot applies `$templates` and `$variables` declared at the top level of value.
+func (e *expander) expandRoot(value any) (any, error) {
+	root, isObject := value.(*jsons.OrderedMap)
+	if !isObject {
+		return nil, E.New("configuration must be an object")
+	}
+	e.variables = make(map[string]any)
+	if rawVariables, loaded := root.Values[keyVariables]; loaded {
+		root.Remove(keyVariables)
+		variables, isObject := rawVariables.(*jsons.OrderedMap)
+		if !isObject {
+			return nil, E.New(keyVariables, ": expected object")
+		}
+		for _, name := range variables.Keys {
+			if !variableNamePattern.MatchString(name) {
+				return nil, E.New(keyVariables, ": invalid variable name: ", name)
+			}
+			e.variables[name] = variables.Values[name]
+		}
+	}
+	e.templates = make(map[string]*jsons.OrderedMap)
+	e.resolving = make(map[string]bool)
+	if rawTemplates, loaded := root.Values[keyTemplates]; loaded {
+		root.Remove(keyTemplates)
+		templates, isObject := rawTemplates.(*jsons.OrderedMap)
+		if !isObject {
+			return nil, E.New(keyTemplates, ": expected object")
+		}
+		for _, name := range templates.Keys {
+			template, isObject := templates.Values[name].(*jsons.OrderedMap)
+			if !isObject {
+				return nil, E.New(keyTemplates, ": template ", name, ": expected object")
+			}
+			e.templates[name] = template
+		}
+	}
+	value, err := e.applyTemplates(root)
+	if err != nil {
+		return nil, err
+	}
+	return e.substitute(value)
+}
+
+func (e *expander) applyTemplates(value any) (any, error) {
+	switch typedValue := value.(type) {
+	case *jsons.OrderedMap:
+		for _, key := range typedValue.Keys {
+			newValue, err := e.applyTemplates(typedValue.Values[key])
+			if err != nil {
+				return nil, E.Cause(err, key)
+			}
+			typedValue.Values[key] = newValue
+		}
+		rawNames, loaded := typedValue.Values[keyTemplate]
+		if !loaded {
+			return typedValue, nil
+		}
+		typedValue.Remove(keyTemplate)
+		names, err := stringList(keyTemplate, rawNames)
+		if err != nil {
+			return nil, err
+		}
+		result := jsons.NewOrderedMap()
+		for _, name := range names {
+			template, err := e.resolveTemplate(name)
+			if err != nil {
+				return nil, err
+			}
+			mergeObject(result, template)
+		}
+		mergeObject(result, typedValue)
+		return result, nil
+	case []any:
+		for index, element := range typedValue {
+			newElement, err := e.applyTemplates(element)
+			if err != nil {
+				return nil, E.Cause(err, "[", index, "]")
+			}
+			typedValue[index] = newElement
+		}
+		return typedValue, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolveTemplate returns a copy of the named template with its own `$template` references applied.
+func (e *expander) resolveTemplate(name string) (*jsons.OrderedMap, error) {
+	template, loaded := e.templates[name]
+	if !loaded {
+		return nil, E.New("template not found: ", name)
+	}
+	if e.resolving[name] {
+		return nil, E.New("template cycle detected at ", name)
+	}
+	e.resolving[name] = true
+	defer delete(e.resolving, name)
+	resolved, err := e.applyTemplates(cloneValue(template))
+	if err != nil {
+		return nil, E.Cause(err, "template ", name)
+	}
+	return resolved.(*jsons.OrderedMap), nil
+}
+
+func (e *expander) substitute(value any) (any, error) {
+	switch typedValue := value.(type) {
+	case *jsons.OrderedMap:
+		for _, key := range typedValue.Keys {
+			newValue, err := e.substitute(typedValue.Values[key])
+			if err != nil {
+				return nil, E.Cause(err, key)
+			}
+			typedValue.Values[key] = newValue
+		}
+		return typedValue, nil
+	case []any:
+		for index, element := range typedValue {
+			newElement, err := e.substitute(element)
+			if err != nil {
+				return nil, E.Cause(err, "[", index, "]")
+			}
+			typedValue[index] = newElement
+		}
+		return typedValue, nil
+	case string:
+		if match := variablePattern.FindStringSubmatch(typedValue); match != nil && match[0] == typedValue {
+			if variable, loaded := e.variables[match[1]]; loaded {
+				return cloneValue(variable), nil
+			}
+			return typedValue, nil
+		}
+		var err error
+		result := variablePattern.ReplaceAllStringFunc(typedValue, func(reference string) string {
+			variable, loaded := e.variables[reference[2:len(reference)-1]]
+			if !loaded {
+				return reference
+			}
+			switch typedVariable := variable.(type) {
+			case string:
+				return typedVariable
+			case json.Number:
+				return typedVariable.String()
+			case bool:
+				if typedVariable {
+					return "true"
+				}
+				return "false"
+			default:
+				err = E.New("variable ", reference, " cannot be interpolated into a string")
+				return reference
+			}
+		})
+		return result, err
+	default:
+		return value, nil
+	}
+}
+
+func isIncludeOnly(value any) (*jsons.OrderedMap, bool) {
+	object, isObject := value.(*jsons.OrderedMap)
+	if !isObject || len(object.Keys) != 1 || object.Keys[0] != keyInclude {
+		return nil, false
+	}
+	return object, true
+}
+
+func stringList(key string, value any) ([]string, error) {
+	switch typedValue := value.(type) {
+	case string:
+		return []string{typedValue}, nil
+	case []any:
+		list := make([]string, 0, len(typedValue))
+		for _, element := range typedValue {
+			stringValue, isString := element.(string)
+			if !isString {
+				return nil, E.New(key, ": expected string or string array")
+			}
+			list = append(list, stringValue)
+		}
+		return list, nil
+	default:
+		return nil, E.New(key, ": expected string or string array")
+	}
+}
+
+// mergeObject merges source into target recursively, values of source take precedence.
+func mergeObject(target *jsons.OrderedMap, source *jsons.OrderedMap) {
+	for _, key := range source.Keys {
+		sourceValue := source.Values[key]
+		if sourceObject, isObject := sourceValue.(*jsons.OrderedMap); isObject {
+			if targetObject, isTargetObject := target.Values[key].(*jsons.OrderedMap); isTargetObject {
+				mergeObject(targetObject, sourceObject)
+				continue
+			}
+		}
+		target.Set(key, cloneValue(sourceValue))
+	}
+}
+
+func cloneValue(value any) any {
+	switch typedValue := value.(type) {
+	case *jsons.OrderedMap:
+		object := jsons.NewOrderedMap()
+		for _, key := range typedValue.Keys {
+			object.Set(key, cloneValue(typedValue.Values[key]))
+		}
+		return object
+	case []any:
+		array := make([]any, len(typedValue))
+		for index, element := range typedValue {
+			array[index] = cloneValue(element)
+		}
+		return array
+	default:
+		return value
+	}
+}
+
+// decodeDocument decodes a JSON, YAML or TOML document, objects keep the order of their keys.
+func decodeDocument(content []byte, path string) (any, error) {
+	extension := filepath.Ext(path)
+	switch {
+	case common.Contains(extYAML, extension):
+		var err error
+		content, err = yaml.YAMLToJSON(content)
+		if err != nil {
+			return nil, err
+		}
+	case common.Contains(extTOML, extension):
+		m := make(map[string]any)
+		err := toml.Unmarshal(content, &m)
+		if err != nil {
+			return nil, err
+		}
+		content, err = json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+	}
+	decoder := json.NewDecoder(myjson.NewCommentFilter(bytes.NewReader(content)))
+	decoder.UseNumber()
+	value, err := decodeValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, E.New("unexpected content after document")
+	}
+	return value, nil
+}
+
+func decodeValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, isDelim := token.(json.Delim)
+	if !isDelim {
+		return token, nil
+	}
+	switch delim {
+	case '{':
+		object := jsons.NewOrderedMap()
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, isString := keyToken.(string)
+			if !isString {
+				return nil, E.New("unexpected object key: ", keyToken)
+			}
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.Remove(key)
+			object.Set(key, value)
+		}
+		_, err = decoder.Token()
+		return object, err
+	case '[':
+		array := make([]any, 0)
+		for decoder.More() {
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	default:
+		return nil, E.New("unexpected token: ", strings.TrimSpace(delim.String()))
+	}
+}
@@ -0,0 +1,138 @@
+package jsonsmerge_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/jsonsmerge"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestExpandWithoutDirectives(t *testing.T) {
+	t.Parallel()
+	content := []byte(`{"log": {"level": "info"}}`)
+	expanded, err := jsonsmerge.Expand(content, "config.json")
+	require.NoError(t, err)
+	require.Equal(t, content, expanded)
+}
+
+func TestExpandInclude(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile(t, dir, "log.yaml", "level: debug\ntimestamp: true\n")
+	writeFile(t, dir, "outbounds/direct.json", `[{"type": "direct", "tag": "direct"}]`)
+	writeFile(t, dir, "outbounds/block.json", `{"type": "block", "tag": "block"}`)
+	path := writeFile(t, dir, "config.json", `{
+  "log": {"$include": "log.yaml", "level": "warn"},
+  "outbounds": [
+    {"$include": "outbounds/direct.json"},
+    {"$include": "outbounds/block.json"}
+  ]
+}`)
+	expanded, err := jsonsmerge.Expand(must(os.ReadFile(path)), path)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "log": {"level": "warn", "timestamp": true},
+  "outbounds": [
+    {"type": "direct", "tag": "direct"},
+    {"type": "block", "tag": "block"}
+  ]
+}`, string(expanded))
+}
+
+func TestExpandIncludeCycle(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile(t, dir, "a.json", `{"$include": "b.json"}`)
+	path := writeFile(t, dir, "b.json", `{"$include": "a.json"}`)
+	_, err := jsonsmerge.Expand(must(os.ReadFile(path)), path)
+	require.ErrorContains(t, err, "include cycle")
+}
+
+func TestExpandTemplatesAndVariables(t *testing.T) {
+	t.Parallel()
+	expanded, err := jsonsmerge.Expand([]byte(`{
+  "$variables": {
+    "server": "example.com",
+    "port": 443,
+    "password": "${PASSWORD}"
+  },
+  "$templates": {
+    "tls": {"tls": {"enabled": true, "server_name": "${server}"}},
+    "trojan": {
+      "$template": "tls",
+      "type": "trojan",
+      "server": "${server}",
+      "server_port": "${port}",
+      "password": "${password}"
+    }
+  },
+  "outbounds": [
+    {"$template": "trojan", "tag": "trojan-1", "tls": {"utls": {"enabled": true}}},
+    {"$template": "trojan", "tag": "trojan-2", "server": "2.${server}"}
+  ]
+}`), "config.json")
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "outbounds": [
+    {
+      "tls": {"enabled": true, "server_name": "example.com", "utls": {"enabled": true}},
+      "type": "trojan",
+      "server": "example.com",
+      "server_port": 443,
+      "password": "${PASSWORD}",
+      "tag": "trojan-1"
+    },
+    {
+      "tls": {"enabled": true, "server_name": "example.com"},
+      "type": "trojan",
+      "server": "2.example.com",
+      "server_port": 443,
+      "password": "${PASSWORD}",
+      "tag": "trojan-2"
+    }
+  ]
+}`, string(expanded))
+}
+
+func TestExpandTemplateErrors(t *testing.T) {
+	t.Parallel()
+	_, err := jsonsmerge.Expand([]byte(`{"outbounds": [{"$template": "missing"}]}`), "config.json")
+	require.ErrorContains(t, err, "template not found: missing")
+	_, err = jsonsmerge.Expand([]byte(`{
+  "$templates": {"a": {"$template": "b"}, "b": {"$template": "a"}},
+  "outbounds": [{"$template": "a"}]
+}`), "config.json")
+	require.ErrorContains(t, err, "template cycle")
+	_, err = jsonsmerge.Expand([]byte(`{
+  "$variables": {"list": [1, 2]},
+  "log": {"output": "${list}.log"}
+}`), "config.json")
+	require.ErrorContains(t, err, "cannot be interpolated")
+}
+
+func TestFilesExpand(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile(t, dir, "direct.yaml", "- type: direct\n  tag: direct\n")
+	path := writeFile(t, dir, "01-base.json", `{"outbounds": [{"$include": "direct.yaml"}]}`)
+	merged, err := jsonsmerge.Files([]string{path}, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"outbounds": [{"type": "direct", "tag": "direct"}]}`, string(merged))
+}
+
+func must[T any](value T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
@@ -1,10 +1,12 @@
 package link
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
@@ -185,10 +187,57 @@ func (v *Xray) transportOption() *option.V2RayTransportOptions {
 			Host: v.Host,
 			Path: v.Path,
 		}
+	case C.V2RayTransportTypeKCP:
+		topt.Type = C.V2RayTransportTypeKCP
+		topt.KCPOptions = option.V2RayKCPOptions{
+			HeaderType: v.HeaderType,
+			Seed:       v.Seed,
+		}
+	case C.V2RayTransportTypeXHTTP:
+		topt.Type = C.V2RayTransportTypeXHTTP
+		topt.XHTTPOptions = option.V2RayXHTTPOptions{
+			Host: v.Host,
+			Path: v.Path,
+			Mode: v.Mode,
+		}
+		if v.Extra != "" {
+			var extra xhttpExtra
+			if json.Unmarshal([]byte(v.Extra), &extra) == nil {
+				if len(extra.Headers) > 0 {
+					topt.XHTTPOptions.Headers = make(badoption.HTTPHeader)
+					for key, value := range extra.Headers {
+						topt.XHTTPOptions.Headers[key] = []string{value}
+					}
+				}
+				topt.XHTTPOptions.XPaddingBytes = string(extra.XPaddingBytes)
+				topt.XHTTPOptions.MaxEachPostBytes = uint32(extra.ScMaxEachPostBytes)
+				topt.XHTTPOptions.MinPostsInterval = badoption.Duration(time.Duration(extra.ScMinPostsIntervalMs) * time.Millisecond)
+			}
+		}
 	}
 	return topt
 }
 
+// xhttpExtra is the subset of the xray xhttp extra object that maps to sing-box options
+type xhttpExtra struct {
+	Headers              map[string]string `json:"headers,omitempty"`
+	XPaddingBytes        xhttpRange        `json:"xPaddingBytes,omitempty"`
+	ScMaxEachPostBytes   int               `json:"scMaxEachPostBytes,omitempty"`
+	ScMinPostsIntervalMs int               `json:"scMinPostsIntervalMs,omitempty"`
+}
+
+// xhttpRange accepts both number and "from-to" string forms
+type xhttpRange string
+
+func (r *xhttpRange) UnmarshalJSON(content []byte) error {
+	var value int
+	if json.Unmarshal(content, &value) == nil {
+		*r = xhttpRange(strconv.Itoa(value))
+		return nil
+	}
+	return json.Unmarshal(content, (*string)(r))
+}
+
 // URL implements Link
 func (v *Xray) URL() (string, error) {
 	if err := v.check(); err != nil {
@@ -411,7 +460,7 @@ func (v *Xray) check() error {
 			}
 		case "xhttp":
 			switch v.Mode {
-			case "auto", "packet-up", "stream-up":
+			case "auto", "packet-up", "stream-up", "stream-one":
 			default:
 				return E.New("unknown xhttp mode: ", v.Mode)
 			}
@@ -439,7 +488,7 @@ func (v *Xray) compatiblily() error {
 		return err
 	}
 	switch v.TransportType {
-	case "", "tcp", "http", "ws", "httpupgrade", "grpc":
+	case "", "tcp", "kcp", "http", "ws", "httpupgrade", "grpc", "xhttp":
 	default:
 		return E.New("unsupported transport: ", v.TransportType)
 	}
@@ -70,5 +70,22 @@ func TestXray(t *testing.T) {
 				Seed:          "中文",
 			},
 		},
+		{
+			Link: "vless://b831381d-6324-4d53-ad4f-8cda48b30811@qv2ray.net:443?type=xhttp&security=tls&sni=qv2ray.net&host=cdn.qv2ray.net&path=%2Fxhttp&mode=packet-up&extra=%7B%22xPaddingBytes%22%3A%22100-1000%22%7D#VLESSXHTTPTLS",
+			Want: &link.Xray{
+				Scheme:        "vless",
+				UUID:          "b831381d-6324-4d53-ad4f-8cda48b30811",
+				Server:        "qv2ray.net",
+				Port:          443,
+				TransportType: "xhttp",
+				Security:      "tls",
+				SNI:           "qv2ray.net",
+				Host:          "cdn.qv2ray.net",
+				Path:          "/xhttp",
+				Mode:          "packet-up",
+				Extra:         `{"xPaddingBytes":"100-1000"}`,
+				Tag:           "VLESSXHTTPTLS",
+			},
+		},
 	})
 }
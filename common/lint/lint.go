@@ -0,0 +1,315 @@
+package lint
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json/badoption"
+	N "github.com/sagernet/sing/common/network"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+// Issue is a semantic problem of the configuration at a JSON pointer.
+type Issue struct {
+	Severity Severity
+	Pointer  string
+	Message  string
+}
+
+func (i Issue) String() string {
+	return i.Pointer + ": " + i.Message
+}
+
+type linter struct {
+	options   option.Options
+	issues    []Issue
+	outbounds map[string]string
+	derived   map[string]bool
+	servers   map[string]bool
+	used      map[string]bool
+	dynamic   bool
+}
+
+// Lint checks references between outbounds, DNS servers and rules,
+// unused outbounds and rules that can never be reached.
+func Lint(options option.Options) []Issue {
+	l := &linter{
+		options:   options,
+		outbounds: make(map[string]string),
+		derived:   make(map[string]bool),
+		servers:   make(map[string]bool),
+		used:      make(map[string]bool),
+		dynamic:   len(options.Providers) > 0,
+	}
+	for index, outbound := range options.Outbounds {
+		l.outbounds[outbound.Tag] = F.ToString("/outbounds/", index)
+		if loadBalanceOptions, isLoadBalance := outbound.Options.(*option.LoadBalanceOutboundOptions); isLoadBalance {
+			for _, profile := range loadBalanceOptions.Profiles {
+				l.derived[profile.Tag] = true
+			}
+		}
+	}
+	for index, endpoint := range options.Endpoints {
+		l.outbounds[endpoint.Tag] = F.ToString("/endpoints/", index)
+	}
+	if options.DNS != nil {
+		for _, server := range options.DNS.Servers {
+			l.servers[server.Tag] = true
+		}
+	}
+	l.lintOutbounds()
+	l.lintRoute()
+	l.lintDNS()
+	l.walk(reflect.ValueOf(options), "")
+	l.lintUnused()
+	return l.issues
+}
+
+func (l *linter) report(severity Severity, pointer string, message ...any) {
+	l.issues = append(l.issues, Issue{Severity: severity, Pointer: pointer, Message: F.ToString(message...)})
+}
+
+// useOutbound marks tag as used and reports it if no such outbound exists.
+func (l *linter) useOutbound(tag string, pointer string) {
+	if tag == "" {
+		return
+	}
+	l.used[tag] = true
+	if _, loaded := l.outbounds[tag]; loaded || l.derived[tag] {
+		return
+	}
+	if l.dynamic {
+		l.report(SeverityWarning, pointer, "outbound not found: ", tag, ", unless provided by a provider")
+	} else {
+		l.report(SeverityError, pointer, "outbound not found: ", tag)
+	}
+}
+
+func (l *linter) lintOutbounds() {
+	for index, outbound := range l.options.Outbounds {
+		pointer := F.ToString("/outbounds/", index)
+		var (
+			outbounds  []string
+			defaultTag string
+		)
+		switch outboundOptions := outbound.Options.(type) {
+		case *option.SelectorOutboundOptions:
+			outbounds, defaultTag = outboundOptions.Outbounds, outboundOptions.Default
+		case *option.URLTestOutboundOptions:
+			outbounds = outboundOptions.Outbounds
+		case *option.ProviderSelectorOptions:
+			outbounds, defaultTag = outboundOptions.Outbounds, outboundOptions.Default
+		case *option.ProviderURLTestOptions:
+			outbounds = outboundOptions.Outbounds
+		case *option.LoadBalanceOutboundOptions:
+			outbounds = outboundOptions.Outbounds
+		case *option.ChainOptions:
+			outbounds = outboundOptions.Outbounds
+		case *option.LoadBalanceProfileOutboundOptions:
+			l.useOutbound(outboundOptions.LoadBalanceTag, pointer+"/loadbalance_tag")
+		}
+		for outboundIndex, tag := range outbounds {
+			l.useOutbound(tag, F.ToString(pointer, "/outbounds/", outboundIndex))
+		}
+		l.useOutbound(defaultTag, pointer+"/default")
+	}
+}
+
+func (l *linter) lintRoute() {
+	route := l.options.Route
+	if route == nil {
+		return
+	}
+	l.useOutbound(route.Final, "/route/final")
+	catchAll := -1
+	for index, rule := range route.Rules {
+		pointer := F.ToString("/route/rules/", index)
+		if catchAll >= 0 {
+			l.report(SeverityWarning, pointer, "rule is unreachable after the catch-all rule /route/rules/", catchAll)
+		}
+		var action option.RuleAction
+		switch rule.Type {
+		case C.RuleTypeDefault:
+			action = rule.DefaultOptions.RuleAction
+		case C.RuleTypeLogical:
+			action = rule.LogicalOptions.RuleAction
+		}
+		if action.Action == C.RuleActionTypeRoute {
+			l.useOutbound(action.RouteOptions.Outbound, pointer+"/outbound")
+		}
+		if catchAll < 0 && isFinalAction(action.Action) && l.isCatchAll(rule) {
+			catchAll = index
+		}
+	}
+}
+
+func (l *linter) lintDNS() {
+	dns := l.options.DNS
+	if dns == nil {
+		return
+	}
+	l.useServer(dns.Final, "/dns/final")
+	catchAll := -1
+	for index, rule := range dns.Rules {
+		pointer := F.ToString("/dns/rules/", index)
+		if catchAll >= 0 {
+			l.report(SeverityWarning, pointer, "rule is unreachable after the catch-all rule /dns/rules/", catchAll)
+		}
+		var action option.DNSRuleAction
+		switch rule.Type {
+		case C.RuleTypeDefault:
+			action = rule.DefaultOptions.DNSRuleAction
+		case C.RuleTypeLogical:
+			action = rule.LogicalOptions.DNSRuleAction
+		}
+		switch action.Action {
+		case C.RuleActionTypeRoute:
+			l.useServer(action.RouteOptions.Server, pointer+"/server")
+		}
+		if catchAll < 0 && rule.Type == C.RuleTypeDefault && common.Contains([]string{C.RuleActionTypeRoute, C.RuleActionTypeReject, C.RuleActionTypePredefined}, action.Action) &&
+			l.matchesAll(rule.DefaultOptions.RawDefaultDNSRule) {
+			catchAll = index
+		}
+	}
+}
+
+func (l *linter) useServer(tag string, pointer string) {
+	if tag == "" || l.servers[tag] {
+		return
+	}
+	l.report(SeverityError, pointer, "DNS server not found: ", tag)
+}
+
+func isFinalAction(action string) bool {
+	switch action {
+	case C.RuleActionTypeRoute, C.RuleActionTypeDirect, C.RuleActionTypeBypass, C.RuleActionTypeReject, C.RuleActionTypeHijackDNS,
+		C.RuleActionTypeHTTPRedirect, C.RuleActionTypeHTTPReject, C.RuleActionTypeHTTPMock:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCatchAll reports whether a rule matches every connection,
+// which is a rule that only matches all networks and/or all inbounds.
+func (l *linter) isCatchAll(rule option.Rule) bool {
+	return rule.Type == C.RuleTypeDefault && l.matchesAll(rule.DefaultOptions.RawDefaultRule)
+}
+
+// matchesAll reports whether raw rule options only have network and inbound conditions which match everything.
+func (l *linter) matchesAll(raw any) bool {
+	value := reflect.ValueOf(raw)
+	var network, inbound badoption.Listable[string]
+	for i := 0; i < value.NumField(); i++ {
+		switch value.Type().Field(i).Name {
+		case "Network":
+			network = value.Field(i).Interface().(badoption.Listable[string])
+		case "Inbound":
+			inbound = value.Field(i).Interface().(badoption.Listable[string])
+		default:
+			if !value.Field(i).IsZero() {
+				return false
+			}
+		}
+	}
+	if len(network) == 0 && len(inbound) == 0 {
+		return false
+	}
+	if len(network) > 0 && !(common.Contains(network, N.NetworkTCP) && common.Contains(network, N.NetworkUDP)) {
+		return false
+	}
+	if len(inbound) > 0 {
+		for _, it := range l.options.Inbounds {
+			if !common.Contains(inbound, it.Tag) {
+				return false
+			}
+		}
+		for _, it := range l.options.Endpoints {
+			if !common.Contains(inbound, it.Tag) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var (
+	dialerOptionsType        = reflect.TypeOf(option.DialerOptions{})
+	domainResolveOptionsType = reflect.TypeOf(option.DomainResolveOptions{})
+)
+
+// walk finds detours of dialer options, download detours and domain resolvers.
+func (l *linter) walk(value reflect.Value, pointer string) {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			l.walk(value.Elem(), pointer)
+		}
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < value.Len(); i++ {
+			l.walk(value.Index(i), pointer+"/"+strconv.Itoa(i))
+		}
+	case reflect.Struct:
+		switch value.Type() {
+		case dialerOptionsType:
+			l.useOutbound(value.Interface().(option.DialerOptions).Detour, pointer+"/detour")
+		case domainResolveOptionsType:
+			l.useServer(value.Interface().(option.DomainResolveOptions).Server, pointer)
+			return
+		}
+		valueType := value.Type()
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			switch {
+			case name == "-":
+				switch field.Type.Kind() {
+				case reflect.Interface, reflect.Pointer, reflect.Struct:
+					l.walk(value.Field(i), pointer)
+				}
+			case field.Anonymous && name == "":
+				l.walk(value.Field(i), pointer)
+			case field.Type.Kind() == reflect.String && strings.HasSuffix(name, "download_detour"):
+				l.useOutbound(value.Field(i).String(), pointer+"/"+name)
+			default:
+				if name == "" {
+					name = field.Name
+				}
+				l.walk(value.Field(i), pointer+"/"+name)
+			}
+		}
+	}
+}
+
+func (l *linter) lintUnused() {
+	if len(l.options.Outbounds) == 0 {
+		return
+	}
+	defaultTag := l.options.Outbounds[0].Tag
+	if l.options.Route != nil && l.options.Route.Final != "" {
+		defaultTag = l.options.Route.Final
+	}
+	for _, outbound := range l.options.Outbounds {
+		if outbound.Tag == defaultTag || l.used[outbound.Tag] {
+			continue
+		}
+		l.report(SeverityWarning, l.outbounds[outbound.Tag], "outbound is not used: ", outbound.Tag)
+	}
+}
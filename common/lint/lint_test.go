@@ -0,0 +1,84 @@
+package lint_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/lint"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/stretchr/testify/require"
+)
+
+func lintContent(t *testing.T, content string) []string {
+	options, err := json.UnmarshalExtendedContext[option.Options](include.Context(context.Background()), []byte(content))
+	require.NoError(t, err)
+	var issues []string
+	for _, issue := range lint.Lint(options) {
+		var severity string
+		if issue.Severity == lint.SeverityError {
+			severity = "error: "
+		} else {
+			severity = "warning: "
+		}
+		issues = append(issues, severity+issue.String())
+	}
+	return issues
+}
+
+func TestLintReferences(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, []string{
+		"error: /outbounds/0/outbounds/1: outbound not found: missing",
+		"error: /route/final: outbound not found: none",
+		"error: /route/rules/0/outbound: outbound not found: missing",
+		"error: /dns/rules/0/server: DNS server not found: missing",
+		"error: /outbounds/1/domain_resolver: DNS server not found: nothing",
+		"error: /outbounds/2/detour: outbound not found: gone",
+		"error: /route/rule_set/0/download_detour: outbound not found: missing",
+		"warning: /outbounds/0: outbound is not used: select",
+		"warning: /outbounds/1: outbound is not used: direct",
+	}, lintContent(t, `{
+  "dns": {
+    "servers": [{"type": "local", "tag": "local"}],
+    "rules": [{"domain": "a.com", "server": "missing"}]
+  },
+  "outbounds": [
+    {"type": "selector", "tag": "select", "outbounds": ["block", "missing"]},
+    {"type": "direct", "tag": "direct", "domain_resolver": "nothing"},
+    {"type": "direct", "tag": "block", "detour": "gone"}
+  ],
+  "route": {
+    "rules": [{"domain": "a.com", "outbound": "missing"}],
+    "rule_set": [{"type": "remote", "tag": "remote", "url": "https://example.com/a.srs", "download_detour": "missing"}],
+    "final": "none"
+  }
+}`))
+}
+
+func TestLintUnreachableRules(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, []string{
+		"warning: /route/rules/2: rule is unreachable after the catch-all rule /route/rules/1",
+		"warning: /dns/rules/1: rule is unreachable after the catch-all rule /dns/rules/0",
+	}, lintContent(t, `{
+  "dns": {
+    "servers": [{"type": "local", "tag": "local"}],
+    "rules": [
+      {"network": ["tcp", "udp"], "action": "reject"},
+      {"domain": "a.com", "server": "local"}
+    ]
+  },
+  "inbounds": [{"type": "mixed", "tag": "mixed-in"}],
+  "outbounds": [{"type": "direct", "tag": "direct"}],
+  "route": {
+    "rules": [
+      {"inbound": "mixed-in", "action": "sniff"},
+      {"inbound": ["mixed-in"], "outbound": "direct"},
+      {"domain": "a.com", "outbound": "direct"}
+    ]
+  }
+}`))
+}
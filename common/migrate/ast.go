@@ -0,0 +1,372 @@
+package migrate
+
+import (
+	"bytes"
+	"strings"
+
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/tailscale/hujson"
+)
+
+// fields is an ordered object created by migrations.
+type fields []field
+
+type field struct {
+	name  string
+	value any
+}
+
+// document is a configuration syntax tree, comments and formatting of unchanged values are kept.
+type document struct {
+	root    hujson.Value
+	unit    string
+	changes []Change
+}
+
+func (d *document) report(pointer string, message ...any) {
+	d.changes = append(d.changes, Change{Pointer: pointer, Message: F.ToString(message...)})
+}
+
+func (d *document) manual(pointer string, message ...any) {
+	d.changes = append(d.changes, Change{Pointer: pointer, Message: F.ToString(message...), Manual: true})
+}
+
+// detectIndent returns the indentation unit of the document, empty for single-line documents.
+func detectIndent(root *hujson.Object) string {
+	if root == nil || len(root.Members) == 0 {
+		return "  "
+	}
+	before := root.Members[0].Name.BeforeExtra
+	index := bytes.LastIndexByte(before, '\n')
+	if index < 0 {
+		return ""
+	}
+	unit := string(before[index+1:])
+	if unit == "" {
+		return "  "
+	}
+	return unit
+}
+
+func (d *document) indent(depth int) []byte {
+	return []byte("\n" + strings.Repeat(d.unit, depth))
+}
+
+func objectOf(value *hujson.Value) *hujson.Object {
+	if value == nil {
+		return nil
+	}
+	object, _ := value.Value.(*hujson.Object)
+	return object
+}
+
+func arrayOf(value *hujson.Value) *hujson.Array {
+	if value == nil {
+		return nil
+	}
+	array, _ := value.Value.(*hujson.Array)
+	return array
+}
+
+func indexOf(object *hujson.Object, name string) int {
+	if object == nil {
+		return -1
+	}
+	for i := range object.Members {
+		if literal, isLiteral := object.Members[i].Name.Value.(hujson.Literal); isLiteral && literal.String() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func lookup(object *hujson.Object, name string) *hujson.Value {
+	index := indexOf(object, name)
+	if index < 0 {
+		return nil
+	}
+	return &object.Members[index].Value
+}
+
+func stringOf(value *hujson.Value) string {
+	if value == nil {
+		return ""
+	}
+	literal, isLiteral := value.Value.(hujson.Literal)
+	if !isLiteral || literal.Kind() != '"' {
+		return ""
+	}
+	return literal.String()
+}
+
+func boolOf(value *hujson.Value) bool {
+	if value == nil {
+		return false
+	}
+	literal, isLiteral := value.Value.(hujson.Literal)
+	return isLiteral && literal.Bool()
+}
+
+// stringsOf reads a string or an array of strings.
+func stringsOf(value *hujson.Value) []string {
+	if value == nil {
+		return nil
+	}
+	if array := arrayOf(value); array != nil {
+		var values []string
+		for i := range array.Elements {
+			if element := stringOf(&array.Elements[i]); element != "" {
+				values = append(values, element)
+			}
+		}
+		return values
+	}
+	if element := stringOf(value); element != "" {
+		return []string{element}
+	}
+	return nil
+}
+
+// isMultiline reports whether the members of object are placed on separate lines.
+func (d *document) isMultiline(object *hujson.Object) bool {
+	if d.unit == "" {
+		return false
+	}
+	if len(object.Members) == 0 {
+		return bytes.IndexByte(object.AfterExtra, '\n') >= 0
+	}
+	return bytes.IndexByte(object.Members[0].Name.BeforeExtra, '\n') >= 0
+}
+
+func (d *document) isMultilineArray(array *hujson.Array) bool {
+	if d.unit == "" {
+		return false
+	}
+	if len(array.Elements) == 0 {
+		return bytes.IndexByte(array.AfterExtra, '\n') >= 0
+	}
+	return bytes.IndexByte(array.Elements[0].BeforeExtra, '\n') >= 0
+}
+
+// set replaces the value of a member or appends a new member, depth is the depth of object.
+func (d *document) set(object *hujson.Object, depth int, name string, value any) {
+	if index := indexOf(object, name); index >= 0 {
+		object.Members[index].Value.Value = d.build(value, depth+1, d.isMultiline(object))
+		return
+	}
+	d.insert(object, depth, len(object.Members), name, value)
+}
+
+// setAt replaces the value of a member or inserts a new member at index,
+// and returns the index after the member.
+func (d *document) setAt(object *hujson.Object, depth int, index int, name string, value any) int {
+	if memberIndex := indexOf(object, name); memberIndex >= 0 {
+		object.Members[memberIndex].Value.Value = d.build(value, depth+1, d.isMultiline(object))
+		return max(index, memberIndex+1)
+	}
+	index = min(index, len(object.Members))
+	d.insert(object, depth, index, name, value)
+	return index + 1
+}
+
+// insert inserts a new member at index, depth is the depth of object.
+func (d *document) insert(object *hujson.Object, depth int, index int, name string, value any) {
+	multiline := d.isMultiline(object)
+	trailingComma := len(object.Members) > 0 && object.Members[len(object.Members)-1].Value.AfterExtra != nil
+	member := hujson.ObjectMember{
+		Name:  hujson.Value{Value: hujson.String(name)},
+		Value: hujson.Value{BeforeExtra: []byte(" "), Value: d.build(value, depth+1, multiline)},
+	}
+	if multiline {
+		member.Name.BeforeExtra = d.indent(depth + 1)
+		if len(object.Members) == 0 {
+			object.AfterExtra = d.indent(depth)
+		}
+	} else if index > 0 {
+		member.Name.BeforeExtra = []byte(" ")
+	} else if len(object.Members) > 0 {
+		object.Members[0].Name.BeforeExtra = []byte(" ")
+	}
+	object.Members = append(object.Members, hujson.ObjectMember{})
+	copy(object.Members[index+1:], object.Members[index:])
+	object.Members[index] = member
+	fixTrailingComma(&object.Members[len(object.Members)-1].Value, &object.AfterExtra, trailingComma)
+	for i := 0; i < len(object.Members)-1; i++ {
+		if object.Members[i].Value.AfterExtra == nil {
+			object.Members[i].Value.AfterExtra = []byte{}
+		}
+	}
+}
+
+// rename renames a member in place and optionally replaces its value.
+func (d *document) rename(object *hujson.Object, depth int, name string, newName string, value any) {
+	index := indexOf(object, name)
+	if index < 0 {
+		return
+	}
+	object.Members[index].Name.Value = hujson.String(newName)
+	if value != nil {
+		object.Members[index].Value.Value = d.build(value, depth+1, d.isMultiline(object))
+	}
+}
+
+// remove removes a member and returns its value, comments before the member are kept.
+func remove(object *hujson.Object, name string) *hujson.Value {
+	index := indexOf(object, name)
+	if index < 0 {
+		return nil
+	}
+	trailingComma := object.Members[len(object.Members)-1].Value.AfterExtra != nil
+	member := object.Members[index]
+	object.Members = append(object.Members[:index], object.Members[index+1:]...)
+	if index < len(object.Members) {
+		keepComment(member.Name.BeforeExtra, &object.Members[index].Name.BeforeExtra)
+		if index == 0 && string(object.Members[0].Name.BeforeExtra) == " " {
+			object.Members[0].Name.BeforeExtra = nil
+		}
+	} else {
+		keepComment(member.Name.BeforeExtra, &object.AfterExtra)
+	}
+	if len(object.Members) > 0 {
+		fixTrailingComma(&object.Members[len(object.Members)-1].Value, &object.AfterExtra, trailingComma)
+	}
+	return &member.Value
+}
+
+// insertElement inserts a new element at index, depth is the depth of array.
+func (d *document) insertElement(array *hujson.Array, depth int, index int, value any) {
+	multiline := d.isMultilineArray(array)
+	trailingComma := len(array.Elements) > 0 && array.Elements[len(array.Elements)-1].AfterExtra != nil
+	element := hujson.Value{Value: d.build(value, depth+1, multiline)}
+	if multiline {
+		element.BeforeExtra = d.indent(depth + 1)
+		if len(array.Elements) == 0 {
+			array.AfterExtra = d.indent(depth)
+		}
+	} else if index > 0 {
+		element.BeforeExtra = []byte(" ")
+	} else if len(array.Elements) > 0 {
+		array.Elements[0].BeforeExtra = []byte(" ")
+	}
+	array.Elements = append(array.Elements, hujson.Value{})
+	copy(array.Elements[index+1:], array.Elements[index:])
+	array.Elements[index] = element
+	fixTrailingComma(&array.Elements[len(array.Elements)-1], &array.AfterExtra, trailingComma)
+	for i := 0; i < len(array.Elements)-1; i++ {
+		if array.Elements[i].AfterExtra == nil {
+			array.Elements[i].AfterExtra = []byte{}
+		}
+	}
+}
+
+func removeElement(array *hujson.Array, index int) {
+	trailingComma := array.Elements[len(array.Elements)-1].AfterExtra != nil
+	element := array.Elements[index]
+	array.Elements = append(array.Elements[:index], array.Elements[index+1:]...)
+	if index < len(array.Elements) {
+		keepComment(element.BeforeExtra, &array.Elements[index].BeforeExtra)
+		if index == 0 && string(array.Elements[0].BeforeExtra) == " " {
+			array.Elements[0].BeforeExtra = nil
+		}
+	} else {
+		keepComment(element.BeforeExtra, &array.AfterExtra)
+	}
+	if len(array.Elements) > 0 {
+		fixTrailingComma(&array.Elements[len(array.Elements)-1], &array.AfterExtra, trailingComma)
+	}
+}
+
+// keepComment moves comments of a removed value to the whitespace before the next value.
+func keepComment(removed hujson.Extra, next *hujson.Extra) {
+	if !hasComment(removed) {
+		return
+	}
+	if index := bytes.LastIndexByte(removed, '\n'); index >= 0 {
+		removed = removed[:index]
+	}
+	*next = append(append(hujson.Extra{}, removed...), *next...)
+}
+
+// fixTrailingComma keeps the trailing comma of a composite value unchanged after the last value changed.
+func fixTrailingComma(last *hujson.Value, afterExtra *hujson.Extra, trailingComma bool) {
+	switch {
+	case trailingComma && last.AfterExtra == nil:
+		last.AfterExtra = []byte{}
+	case !trailingComma && last.AfterExtra != nil:
+		*afterExtra = append(last.AfterExtra, *afterExtra...)
+		last.AfterExtra = nil
+	}
+}
+
+func hasComment(extra hujson.Extra) bool {
+	return bytes.Contains(extra, []byte("//")) || bytes.Contains(extra, []byte("/*"))
+}
+
+// build converts a value created by migrations to a syntax tree, depth is the depth of the value.
+func (d *document) build(value any, depth int, multiline bool) hujson.ValueTrimmed {
+	switch typedValue := value.(type) {
+	case hujson.ValueTrimmed:
+		return typedValue
+	case string:
+		return hujson.String(typedValue)
+	case bool:
+		return hujson.Bool(typedValue)
+	case int:
+		return hujson.Int(int64(typedValue))
+	case uint16:
+		return hujson.Uint(uint64(typedValue))
+	case []string:
+		array := &hujson.Array{}
+		for i, element := range typedValue {
+			array.Elements = append(array.Elements, hujson.Value{
+				BeforeExtra: d.separator(i, depth+1, multiline),
+				Value:       hujson.String(element),
+				AfterExtra:  []byte{},
+			})
+		}
+		if len(array.Elements) > 0 {
+			array.Elements[len(array.Elements)-1].AfterExtra = nil
+		}
+		if multiline {
+			array.AfterExtra = d.indent(depth)
+		}
+		return array
+	case fields:
+		object := &hujson.Object{}
+		for i, member := range typedValue {
+			object.Members = append(object.Members, hujson.ObjectMember{
+				Name: hujson.Value{
+					BeforeExtra: d.separator(i, depth+1, multiline),
+					Value:       hujson.String(member.name),
+				},
+				Value: hujson.Value{
+					BeforeExtra: []byte(" "),
+					Value:       d.build(member.value, depth+1, multiline),
+					AfterExtra:  []byte{},
+				},
+			})
+		}
+		if len(object.Members) > 0 {
+			object.Members[len(object.Members)-1].Value.AfterExtra = nil
+		}
+		if multiline {
+			object.AfterExtra = d.indent(depth)
+		}
+		return object
+	default:
+		panic(F.ToString("unsupported value: ", value))
+	}
+}
+
+// separator is the whitespace before the index-th value of a new composite value.
+func (d *document) separator(index int, depth int, multiline bool) hujson.Extra {
+	switch {
+	case multiline:
+		return d.indent(depth)
+	case index > 0:
+		return []byte(" ")
+	default:
+		return nil
+	}
+}
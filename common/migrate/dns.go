@@ -0,0 +1,205 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/miekg/dns"
+	"github.com/tailscale/hujson"
+)
+
+// migrateDNSServers converts legacy DNS servers to typed servers.
+func (d *document) migrateDNSServers() {
+	dnsOptions := d.child(d.rootObject(), 0, "dns", false)
+	servers := d.childArray(dnsOptions, 1, "servers", false)
+	if servers == nil {
+		return
+	}
+	fakeIPOptions := objectOf(lookup(dnsOptions, "fakeip"))
+	defaultTag := stringOf(lookup(dnsOptions, "final"))
+	if defaultTag == "" && len(servers.Elements) > 0 {
+		defaultTag = stringOf(lookup(objectOf(&servers.Elements[0]), "tag"))
+	}
+	var removed []int
+	for i := range servers.Elements {
+		server := objectOf(&servers.Elements[i])
+		pointer := F.ToString("/dns/servers/", i)
+		if server == nil {
+			continue
+		}
+		serverType := stringOf(lookup(server, "type"))
+		if serverType != "" && serverType != C.DNSTypeLegacy {
+			continue
+		}
+		address := stringOf(lookup(server, "address"))
+		if address == "" {
+			d.manual(pointer, "legacy DNS server without address")
+			continue
+		}
+		tag := stringOf(lookup(server, "tag"))
+		isDefault := i == 0
+		if defaultTag != "" {
+			isDefault = tag == defaultTag
+		}
+		serverOptions := option.DNSServerOptions{
+			Type:    C.DNSTypeLegacy,
+			Options: &option.LegacyDNSServerOptions{Address: address},
+		}
+		err := serverOptions.Upgrade(context.Background())
+		if err != nil {
+			d.manual(pointer, "migrate legacy DNS server ", address, ": ", err)
+			continue
+		}
+		if serverOptions.Type == C.DNSTypeLegacyRcode {
+			if d.migrateRcodeServer(dnsOptions, tag, isDefault, serverOptions.Options.(int), pointer) {
+				removed = append(removed, i)
+			}
+			continue
+		}
+		remove(server, "type")
+		remove(server, "address")
+		d.insert(server, 3, 0, "type", serverOptions.Type)
+		index := indexOf(server, "tag") + 1
+		if index == 0 {
+			index = 1
+		}
+		for _, member := range serverFields(serverOptions.Options, fakeIPOptions) {
+			d.insert(server, 3, index, member.name, member.value)
+			index++
+		}
+		d.migrateServerResolver(server, pointer)
+		isOnly := len(servers.Elements) == 1
+		d.moveServerOption(dnsOptions, server, tag, isDefault, isOnly, "strategy", pointer)
+		d.moveServerOption(dnsOptions, server, tag, isDefault, isOnly, "client_subnet", pointer)
+		d.report(pointer, "migrated legacy DNS server ", address, " to ", serverOptions.Type, " server")
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		removeElement(servers, removed[i])
+	}
+	if remove(dnsOptions, "fakeip") != nil {
+		d.report("/dns/fakeip", "moved legacy fakeip options to fakeip servers")
+	}
+}
+
+// serverFields returns the address fields of an upgraded DNS server.
+func serverFields(options any, fakeIPOptions *hujson.Object) fields {
+	var (
+		address option.DNSServerAddressOptions
+		path    string
+		result  fields
+	)
+	switch serverOptions := options.(type) {
+	case *option.RemoteDNSServerOptions:
+		address = serverOptions.DNSServerAddressOptions
+	case *option.RemoteTLSDNSServerOptions:
+		address = serverOptions.DNSServerAddressOptions
+	case *option.RemoteHTTPSDNSServerOptions:
+		address = serverOptions.DNSServerAddressOptions
+		path = serverOptions.Path
+	case *option.DHCPDNSServerOptions:
+		if serverOptions.Interface != "" {
+			result = append(result, field{"interface", serverOptions.Interface})
+		}
+		return result
+	case *option.FakeIPDNSServerOptions:
+		for _, name := range []string{"inet4_range", "inet6_range"} {
+			if value := lookup(fakeIPOptions, name); value != nil {
+				result = append(result, field{name, value.Clone().Value})
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+	result = append(result, field{"server", address.Server})
+	if address.ServerPort != 0 {
+		result = append(result, field{"server_port", address.ServerPort})
+	}
+	if path != "" {
+		result = append(result, field{"path", path})
+	}
+	return result
+}
+
+// migrateServerResolver converts legacy address resolver options to the domain resolver.
+func (d *document) migrateServerResolver(server *hujson.Object, pointer string) {
+	resolver := stringOf(lookup(server, "address_resolver"))
+	strategy := lookup(server, "address_strategy")
+	switch {
+	case resolver != "" && strategy != nil:
+		d.rename(server, 3, "address_resolver", "domain_resolver", fields{
+			{"server", resolver},
+			{"strategy", strategy.Clone().Value},
+		})
+		remove(server, "address_strategy")
+	case resolver != "":
+		d.rename(server, 3, "address_resolver", "domain_resolver", nil)
+	case strategy != nil:
+		remove(server, "address_strategy")
+		d.manual(pointer, "removed address_strategy without address_resolver, set domain_resolver manually")
+	}
+	d.rename(server, 3, "address_fallback_delay", "fallback_delay", nil)
+}
+
+// moveServerOption moves a legacy per-server option to the DNS rules using the server,
+// or to the DNS options if the server is the only one, so that other servers do not inherit it.
+func (d *document) moveServerOption(dnsOptions *hujson.Object, server *hujson.Object, tag string, isDefault bool, isOnly bool, name string, pointer string) {
+	value := remove(server, name)
+	if value == nil {
+		return
+	}
+	if isOnly {
+		if existing := lookup(dnsOptions, name); existing != nil {
+			if !bytes.Equal(hujson.Value{Value: existing.Value}.Pack(), hujson.Value{Value: value.Value}.Pack()) {
+				d.manual(pointer, "removed ", name, " conflicting with dns.", name)
+			}
+			return
+		}
+		d.set(dnsOptions, 1, name, value.Clone().Value)
+		d.report(pointer, "moved ", name, " to dns.", name)
+		return
+	}
+	var moved int
+	forEachRule(d.childArray(dnsOptions, 1, "rules", false), "/dns/rules", 2, false, func(rule *hujson.Object, rulePointer string, depth int) {
+		if !isRouteAction(rule) || stringOf(lookup(rule, "server")) != tag || lookup(rule, name) != nil {
+			return
+		}
+		d.set(rule, depth, name, value.Clone().Value)
+		moved++
+	})
+	if moved > 0 {
+		d.report(pointer, "moved ", name, " to ", moved, " DNS rule(s)")
+	}
+	if isDefault {
+		d.manual(pointer, "removed ", name, " of the default server for queries not matching DNS rules, dns.", name, " would also apply to other servers")
+	} else if moved == 0 {
+		d.manual(pointer, "removed ", name, " of a server not used by DNS rules")
+	}
+}
+
+// migrateRcodeServer replaces routes to a legacy rcode server with predefined actions,
+// and reports whether the server can be removed.
+func (d *document) migrateRcodeServer(dnsOptions *hujson.Object, tag string, isDefault bool, rcode int, pointer string) bool {
+	rcodeName, loaded := dns.RcodeToString[rcode]
+	if !loaded {
+		rcodeName = F.ToString(rcode)
+	}
+	forEachRule(d.childArray(dnsOptions, 1, "rules", false), "/dns/rules", 2, false, func(rule *hujson.Object, rulePointer string, depth int) {
+		if tag == "" || !isRouteAction(rule) || stringOf(lookup(rule, "server")) != tag {
+			return
+		}
+		d.setAction(rule, depth, "server", C.RuleActionTypePredefined)
+		d.insert(rule, depth, indexOf(rule, "action")+1, "rcode", rcodeName)
+		d.report(rulePointer, "replaced rcode server ", tag, " with predefined action")
+	})
+	if isDefault {
+		d.manual(pointer, "rcode server is the default DNS server, add a final rule with the predefined action manually")
+		return false
+	}
+	d.report(pointer, "removed rcode server")
+	return true
+}
@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+
+	"github.com/tailscale/hujson"
+)
+
+const (
+	geoIPRuleSetURL   = "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/"
+	geositeRuleSetURL = "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/"
+)
+
+// migrateGeoResources replaces geosite and geoip rule items with remote rule-sets.
+func (d *document) migrateGeoResources() {
+	root := d.rootObject()
+	routeOptions := d.child(root, 0, "route", false)
+	dnsOptions := d.child(root, 0, "dns", false)
+	var tags []string
+	migrateRule := func(rule *hujson.Object, pointer string, depth int) {
+		tags = append(tags, d.migrateGeoRule(rule, pointer, depth)...)
+	}
+	forEachRule(d.childArray(routeOptions, 1, "rules", false), "/route/rules", 2, true, migrateRule)
+	forEachRule(d.childArray(dnsOptions, 1, "rules", false), "/dns/rules", 2, true, migrateRule)
+	downloadDetours := make(map[string]string)
+	for _, name := range []string{"geoip", "geosite"} {
+		databaseOptions := objectOf(remove(routeOptions, name))
+		if databaseOptions == nil {
+			continue
+		}
+		if lookup(databaseOptions, "path") != nil || lookup(databaseOptions, "download_url") != nil {
+			d.manual("/route/"+name, "removed custom ", name, " database, convert it to rule-sets with `sing-box ", name, " export`")
+		} else {
+			d.report("/route/"+name, "removed ", name, " database options")
+		}
+		downloadDetours[name] = stringOf(lookup(databaseOptions, "download_detour"))
+	}
+	tags = common.Uniq(tags)
+	if len(tags) == 0 {
+		return
+	}
+	routeOptions = d.child(root, 0, "route", true)
+	ruleSets := d.childArray(routeOptions, 1, "rule_set", true)
+	existingTags := make(map[string]bool)
+	for i := range ruleSets.Elements {
+		existingTags[stringOf(lookup(objectOf(&ruleSets.Elements[i]), "tag"))] = true
+	}
+	for _, tag := range tags {
+		if existingTags[tag] {
+			continue
+		}
+		database, _, _ := strings.Cut(tag, "-")
+		baseURL := geositeRuleSetURL
+		if database == "geoip" {
+			baseURL = geoIPRuleSetURL
+		}
+		ruleSet := fields{
+			{"tag", tag},
+			{"type", C.RuleSetTypeRemote},
+			{"format", C.RuleSetFormatBinary},
+			{"url", baseURL + tag + ".srs"},
+		}
+		if detour := downloadDetours[database]; detour != "" {
+			ruleSet = append(ruleSet, field{"download_detour", detour})
+		}
+		d.insertElement(ruleSets, 2, len(ruleSets.Elements), ruleSet)
+		d.report("/route/rule_set", "added remote rule-set ", tag)
+	}
+}
+
+// migrateGeoRule replaces geosite and geoip items of a rule and returns the tags of required rule-sets.
+func (d *document) migrateGeoRule(rule *hujson.Object, pointer string, depth int) []string {
+	geosite := stringsOf(lookup(rule, "geosite"))
+	geoIP := stringsOf(lookup(rule, "geoip"))
+	sourceGeoIP := stringsOf(lookup(rule, "source_geoip"))
+	if len(geosite) == 0 && len(geoIP) == 0 && len(sourceGeoIP) == 0 {
+		return nil
+	}
+	var (
+		ruleSets       []string
+		sourceRuleSets []string
+		private        bool
+		sourcePrivate  bool
+	)
+	for _, code := range geosite {
+		ruleSets = append(ruleSets, "geosite-"+code)
+	}
+	for _, code := range geoIP {
+		if code == "private" {
+			private = true
+		} else {
+			ruleSets = append(ruleSets, "geoip-"+code)
+		}
+	}
+	for _, code := range sourceGeoIP {
+		if code == "private" {
+			sourcePrivate = true
+		} else {
+			sourceRuleSets = append(sourceRuleSets, "geoip-"+code)
+		}
+	}
+	existingRuleSets := stringsOf(lookup(rule, "rule_set"))
+	if len(sourceRuleSets) > 0 && (len(ruleSets) > 0 || len(existingRuleSets) > 0) {
+		d.manual(pointer, "source_geoip can not be combined with other rule-sets in a rule, split the rule manually")
+		return nil
+	}
+	index := len(rule.Members)
+	for _, name := range []string{"geosite", "geoip", "source_geoip"} {
+		if memberIndex := indexOf(rule, name); memberIndex >= 0 {
+			index = min(index, memberIndex)
+		}
+	}
+	remove(rule, "geosite")
+	remove(rule, "geoip")
+	remove(rule, "source_geoip")
+	if private {
+		index = d.setAt(rule, depth, index, "ip_is_private", true)
+	}
+	if sourcePrivate {
+		index = d.setAt(rule, depth, index, "source_ip_is_private", true)
+	}
+	newRuleSets := append(ruleSets, sourceRuleSets...)
+	allRuleSets := common.Uniq(append(existingRuleSets, newRuleSets...))
+	switch len(allRuleSets) {
+	case 0:
+	case 1:
+		index = d.setAt(rule, depth, index, "rule_set", allRuleSets[0])
+	default:
+		index = d.setAt(rule, depth, index, "rule_set", allRuleSets)
+	}
+	if len(sourceRuleSets) > 0 {
+		d.setAt(rule, depth, index, "rule_set_ip_cidr_match_source", true)
+	}
+	d.report(pointer, "replaced geosite and geoip items with rule-sets")
+	return newRuleSets
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	C "github.com/sagernet/sing-box/constant"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/tailscale/hujson"
+)
+
+var legacyInboundFields = []string{"sniff", "sniff_override_destination", "sniff_timeout", "domain_strategy", "udp_disable_domain_unmapping"}
+
+// migrateInboundFields replaces legacy inbound fields with rule actions added before existing route rules.
+func (d *document) migrateInboundFields() {
+	root := d.rootObject()
+	inbounds := d.childArray(root, 0, "inbounds", false)
+	if inbounds == nil {
+		return
+	}
+	tags := make(map[string]bool)
+	for i := range inbounds.Elements {
+		tags[stringOf(lookup(objectOf(&inbounds.Elements[i]), "tag"))] = true
+	}
+	var rules []fields
+	for i := range inbounds.Elements {
+		inbound := objectOf(&inbounds.Elements[i])
+		if inbound == nil || !hasAnyMember(inbound, legacyInboundFields) {
+			continue
+		}
+		pointer := F.ToString("/inbounds/", i)
+		tag := stringOf(lookup(inbound, "tag"))
+		if tag == "" {
+			tag = stringOf(lookup(inbound, "type")) + "-in"
+			for index := 1; tags[tag]; index++ {
+				tag = F.ToString(stringOf(lookup(inbound, "type")), "-in-", index)
+			}
+			tags[tag] = true
+			d.setAt(inbound, 2, indexOf(inbound, "type")+1, "tag", tag)
+			d.report(pointer, "added tag ", tag, " to match the inbound in rules")
+		}
+		sniff := boolOf(remove(inbound, "sniff"))
+		sniffOverrideDestination := boolOf(remove(inbound, "sniff_override_destination"))
+		sniffTimeout := remove(inbound, "sniff_timeout")
+		domainStrategy := remove(inbound, "domain_strategy")
+		udpDisableDomainUnmapping := boolOf(remove(inbound, "udp_disable_domain_unmapping"))
+		if sniff {
+			rule := fields{{"inbound", tag}, {"action", C.RuleActionTypeSniff}}
+			if sniffTimeout != nil {
+				rule = append(rule, field{"timeout", sniffTimeout.Clone().Value})
+			}
+			rules = append(rules, rule)
+		}
+		if sniffOverrideDestination {
+			d.manual(pointer, "removed sniff_override_destination, which has no equivalent rule action")
+		}
+		if stringOf(domainStrategy) != "" {
+			rules = append(rules, fields{{"inbound", tag}, {"action", C.RuleActionTypeResolve}, {"strategy", domainStrategy.Clone().Value}})
+		}
+		if udpDisableDomainUnmapping {
+			rules = append(rules, fields{{"inbound", tag}, {"action", C.RuleActionTypeRouteOptions}, {"udp_disable_domain_unmapping", true}})
+		}
+		d.report(pointer, "moved legacy inbound fields to rule actions")
+	}
+	if len(rules) == 0 {
+		return
+	}
+	routeRules := d.childArray(d.child(root, 0, "route", true), 1, "rules", true)
+	for i, rule := range rules {
+		d.insertElement(routeRules, 2, i, rule)
+	}
+	d.report("/route/rules", "added ", len(rules), " rule(s) before existing rules")
+}
+
+func hasAnyMember(object *hujson.Object, names []string) bool {
+	for _, name := range names {
+		if indexOf(object, name) >= 0 {
+			return true
+		}
+	}
+	return false
+}
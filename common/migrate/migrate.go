@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/tailscale/hujson"
+)
+
+// Change is a change made to the configuration, or a problem which needs to be migrated manually.
+type Change struct {
+	Pointer string
+	Message string
+	Manual  bool
+}
+
+func (c Change) String() string {
+	if c.Pointer == "" {
+		return c.Message
+	}
+	return c.Pointer + ": " + c.Message
+}
+
+// Migrate rewrites deprecated options of a configuration to the current format.
+// Comments and formatting are kept except for rewritten values, pointers of changes refer to the original configuration.
+func Migrate(content []byte) ([]byte, []Change, error) {
+	root, err := hujson.Parse(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootObject := objectOf(&root)
+	if rootObject == nil {
+		return nil, nil, E.New("configuration is not an object")
+	}
+	d := &document{
+		root: root,
+		unit: detectIndent(rootObject),
+	}
+	d.migrateDNSServers()
+	d.migrateGeoResources()
+	d.migrateSpecialOutbounds()
+	d.migrateInboundFields()
+	return d.root.Pack(), d.changes, nil
+}
+
+func (d *document) rootObject() *hujson.Object {
+	return objectOf(&d.root)
+}
+
+// child returns the object member of parent, it is created if create is set, depth is the depth of parent.
+func (d *document) child(parent *hujson.Object, depth int, name string, create bool) *hujson.Object {
+	if parent == nil {
+		return nil
+	}
+	if value := lookup(parent, name); value != nil || !create {
+		return objectOf(value)
+	}
+	d.insert(parent, depth, len(parent.Members), name, fields{})
+	return objectOf(lookup(parent, name))
+}
+
+// childArray returns the array member of parent, it is created if create is set, depth is the depth of parent.
+func (d *document) childArray(parent *hujson.Object, depth int, name string, create bool) *hujson.Array {
+	if parent == nil {
+		return nil
+	}
+	if value := lookup(parent, name); value != nil || !create {
+		return arrayOf(value)
+	}
+	d.insert(parent, depth, len(parent.Members), name, []string{})
+	return arrayOf(lookup(parent, name))
+}
+
+// forEachRule calls f with each rule of rules, nested rules of logical rules are included if nested is set.
+// depth is the depth of rules.
+func forEachRule(rules *hujson.Array, pointer string, depth int, nested bool, f func(rule *hujson.Object, pointer string, depth int)) {
+	if rules == nil {
+		return
+	}
+	for i := range rules.Elements {
+		rule := objectOf(&rules.Elements[i])
+		if rule == nil {
+			continue
+		}
+		rulePointer := F.ToString(pointer, "/", i)
+		f(rule, rulePointer, depth+1)
+		if nested && stringOf(lookup(rule, "type")) == C.RuleTypeLogical {
+			forEachRule(arrayOf(lookup(rule, "rules")), rulePointer+"/rules", depth+2, true, f)
+		}
+	}
+}
+
+// isRouteAction reports whether the action of a rule is route.
+func isRouteAction(rule *hujson.Object) bool {
+	action := stringOf(lookup(rule, "action"))
+	return action == "" || action == C.RuleActionTypeRoute
+}
+
+// setAction replaces the route target of a rule with an action.
+func (d *document) setAction(rule *hujson.Object, depth int, target string, action string) {
+	remove(rule, "action")
+	d.rename(rule, depth, target, "action", action)
+}
@@ -0,0 +1,308 @@
+package migrate_test
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/common/migrate"
+
+	"github.com/stretchr/testify/require"
+)
+
+func migrateContent(t *testing.T, content string) (string, []string) {
+	migrated, changes, err := migrate.Migrate([]byte(content))
+	require.NoError(t, err)
+	var messages []string
+	for _, change := range changes {
+		if change.Manual {
+			messages = append(messages, "manual: "+change.String())
+		} else {
+			messages = append(messages, change.String())
+		}
+	}
+	return string(migrated), messages
+}
+
+func TestMigrateUpToDate(t *testing.T) {
+	t.Parallel()
+	content := `{
+  // comment
+  "dns": {"servers": [{"type": "local", "tag": "local"}]},
+  "outbounds": [{"type": "direct", "tag": "direct"}]
+}`
+	migrated, changes := migrateContent(t, content)
+	require.Equal(t, content, migrated)
+	require.Empty(t, changes)
+}
+
+func TestMigrateDNSServers(t *testing.T) {
+	t.Parallel()
+	migrated, changes := migrateContent(t, `{
+  "dns": {
+    "servers": [
+      {
+        "tag": "google", // remote server
+        "address": "https://dns.google/dns-query",
+        "address_resolver": "local",
+        "strategy": "ipv4_only"
+      },
+      {"tag": "local", "address": "local", "client_subnet": "1.1.1.1"},
+      {"tag": "refused", "address": "rcode://refused"},
+      {"tag": "fakeip", "address": "fakeip"}
+    ],
+    "rules": [
+      {"domain": "a.com", "server": "refused"},
+      {"domain": "b.com", "server": "local"},
+      {"query_type": ["A", "AAAA"], "server": "fakeip"}
+    ],
+    "fakeip": {"enabled": true, "inet4_range": "198.18.0.0/15"}
+  }
+}`)
+	require.Equal(t, `{
+  "dns": {
+    "servers": [
+      {
+        "type": "https",
+        "tag": "google",
+        "server": "dns.google", // remote server
+        "domain_resolver": "local"
+      },
+      {"type": "local", "tag": "local"},
+      {"type": "fakeip", "tag": "fakeip", "inet4_range": "198.18.0.0/15"}
+    ],
+    "rules": [
+      {"domain": "a.com", "action": "predefined", "rcode": "REFUSED"},
+      {"domain": "b.com", "server": "local", "client_subnet": "1.1.1.1"},
+      {"query_type": ["A", "AAAA"], "server": "fakeip"}
+    ]
+  }
+}`, migrated)
+	require.Equal(t, []string{
+		"manual: /dns/servers/0: removed strategy of the default server for queries not matching DNS rules, dns.strategy would also apply to other servers",
+		"/dns/servers/0: migrated legacy DNS server https://dns.google/dns-query to https server",
+		"/dns/servers/1: moved client_subnet to 1 DNS rule(s)",
+		"/dns/servers/1: migrated legacy DNS server local to local server",
+		"/dns/rules/0: replaced rcode server refused with predefined action",
+		"/dns/servers/2: removed rcode server",
+		"/dns/servers/3: migrated legacy DNS server fakeip to fakeip server",
+		"/dns/fakeip: moved legacy fakeip options to fakeip servers",
+	}, changes)
+}
+
+func TestMigrateDNSDefaultServerOptions(t *testing.T) {
+	t.Parallel()
+	migrated, changes := migrateContent(t, `{
+  "dns": {
+    "servers": [
+      {"tag": "local", "address": "local", "strategy": "ipv4_only"}
+    ]
+  }
+}`)
+	require.Equal(t, `{
+  "dns": {
+    "servers": [
+      {"type": "local", "tag": "local"}
+    ],
+    "strategy": "ipv4_only"
+  }
+}`, migrated)
+	require.Equal(t, []string{
+		"/dns/servers/0: moved strategy to dns.strategy",
+		"/dns/servers/0: migrated legacy DNS server local to local server",
+	}, changes)
+
+	// the default server's options are not inherited by other servers
+	migrated, changes = migrateContent(t, `{
+  "dns": {
+    "servers": [
+      {"tag": "google", "address": "tls://8.8.8.8"},
+      {"tag": "local", "address": "local", "strategy": "ipv4_only"}
+    ],
+    "rules": [
+      {"domain": "a.com", "server": "local"}
+    ],
+    "final": "local"
+  }
+}`)
+	require.Equal(t, `{
+  "dns": {
+    "servers": [
+      {"type": "tls", "tag": "google", "server": "8.8.8.8"},
+      {"type": "local", "tag": "local"}
+    ],
+    "rules": [
+      {"domain": "a.com", "server": "local", "strategy": "ipv4_only"}
+    ],
+    "final": "local"
+  }
+}`, migrated)
+	require.Equal(t, []string{
+		"/dns/servers/0: migrated legacy DNS server tls://8.8.8.8 to tls server",
+		"/dns/servers/1: moved strategy to 1 DNS rule(s)",
+		"manual: /dns/servers/1: removed strategy of the default server for queries not matching DNS rules, dns.strategy would also apply to other servers",
+		"/dns/servers/1: migrated legacy DNS server local to local server",
+	}, changes)
+}
+
+func TestMigrateGeoResources(t *testing.T) {
+	t.Parallel()
+	migrated, changes := migrateContent(t, `{
+  "route": {
+    "rules": [
+      {"geoip": ["private", "cn"], "outbound": "direct"},
+      {"geosite": "cn", "rule_set": "custom", "outbound": "direct"},
+      {"source_geoip": "cn", "outbound": "direct"},
+      {"source_geoip": "us", "geoip": "cn", "outbound": "direct"}
+    ],
+    "geosite": {"download_detour": "proxy"}
+  }
+}`)
+	require.Equal(t, `{
+  "route": {
+    "rules": [
+      {"ip_is_private": true, "rule_set": "geoip-cn", "outbound": "direct"},
+      {"rule_set": ["custom", "geosite-cn"], "outbound": "direct"},
+      {"rule_set": "geoip-cn", "rule_set_ip_cidr_match_source": true, "outbound": "direct"},
+      {"source_geoip": "us", "geoip": "cn", "outbound": "direct"}
+    ],
+    "rule_set": [
+      {
+        "tag": "geoip-cn",
+        "type": "remote",
+        "format": "binary",
+        "url": "https://raw.githubusercontent.com/SagerNet/sing-geoip/rule-set/geoip-cn.srs"
+      },
+      {
+        "tag": "geosite-cn",
+        "type": "remote",
+        "format": "binary",
+        "url": "https://raw.githubusercontent.com/SagerNet/sing-geosite/rule-set/geosite-cn.srs",
+        "download_detour": "proxy"
+      }
+    ]
+  }
+}`, migrated)
+	require.Equal(t, []string{
+		"/route/rules/0: replaced geosite and geoip items with rule-sets",
+		"/route/rules/1: replaced geosite and geoip items with rule-sets",
+		"/route/rules/2: replaced geosite and geoip items with rule-sets",
+		"manual: /route/rules/3: source_geoip can not be combined with other rule-sets in a rule, split the rule manually",
+		"/route/geosite: removed geosite database options",
+		"/route/rule_set: added remote rule-set geoip-cn",
+		"/route/rule_set: added remote rule-set geosite-cn",
+	}, changes)
+}
+
+func TestMigrateSpecialOutbounds(t *testing.T) {
+	t.Parallel()
+	migrated, changes := migrateContent(t, `{
+  "outbounds": [
+    {"type": "direct", "tag": "direct"},
+    {"type": "block", "tag": "block"},
+    {"type": "dns", "tag": "dns-out"},
+    {"type": "selector", "tag": "select", "outbounds": ["direct", "block"]}
+  ],
+  "route": {
+    "rules": [
+      {"protocol": "dns", "outbound": "dns-out"},
+      {"domain": "ads.com", "outbound": "block"}
+    ]
+  }
+}`)
+	require.Equal(t, `{
+  "outbounds": [
+    {"type": "direct", "tag": "direct"},
+    {"type": "block", "tag": "block"},
+    {"type": "selector", "tag": "select", "outbounds": ["direct", "block"]}
+  ],
+  "route": {
+    "rules": [
+      {"protocol": "dns", "action": "hijack-dns"},
+      {"domain": "ads.com", "action": "reject"}
+    ]
+  }
+}`, migrated)
+	require.Equal(t, []string{
+		"/route/rules/1: replaced block outbound block with reject action",
+		"manual: /outbounds/1: block outbound is still referenced, replace the references manually",
+		"/route/rules/0: replaced dns outbound dns-out with hijack-dns action",
+		"manual: /route/rules/0: matching protocol requires a sniff action before this rule",
+		"/outbounds/2: removed dns outbound",
+	}, changes)
+}
+
+func TestMigrateInboundFields(t *testing.T) {
+	t.Parallel()
+	migrated, changes := migrateContent(t, `{
+  "inbounds": [
+    {
+      "type": "mixed",
+      "listen_port": 2080,
+      "sniff": true,
+      "sniff_override_destination": true,
+      "sniff_timeout": "1s",
+      "domain_strategy": "prefer_ipv4"
+    },
+    {"type": "tun", "tag": "tun-in", "udp_disable_domain_unmapping": true}
+  ],
+  "route": {
+    "rules": [
+      {"domain": "a.com", "outbound": "direct"}
+    ]
+  }
+}`)
+	require.Equal(t, `{
+  "inbounds": [
+    {
+      "type": "mixed",
+      "tag": "mixed-in",
+      "listen_port": 2080
+    },
+    {"type": "tun", "tag": "tun-in"}
+  ],
+  "route": {
+    "rules": [
+      {
+        "inbound": "mixed-in",
+        "action": "sniff",
+        "timeout": "1s"
+      },
+      {
+        "inbound": "mixed-in",
+        "action": "resolve",
+        "strategy": "prefer_ipv4"
+      },
+      {
+        "inbound": "tun-in",
+        "action": "route-options",
+        "udp_disable_domain_unmapping": true
+      },
+      {"domain": "a.com", "outbound": "direct"}
+    ]
+  }
+}`, migrated)
+	require.Equal(t, []string{
+		"/inbounds/0: added tag mixed-in to match the inbound in rules",
+		"manual: /inbounds/0: removed sniff_override_destination, which has no equivalent rule action",
+		"/inbounds/0: moved legacy inbound fields to rule actions",
+		"/inbounds/1: moved legacy inbound fields to rule actions",
+		"/route/rules: added 3 rule(s) before existing rules",
+	}, changes)
+}
+
+func TestMigrateCreatesRoute(t *testing.T) {
+	t.Parallel()
+	migrated, _ := migrateContent(t, `{
+	"inbounds": [{"type": "mixed", "tag": "in", "sniff": true}]
+}`)
+	require.Equal(t, `{
+	"inbounds": [{"type": "mixed", "tag": "in"}],
+	"route": {
+		"rules": [
+			{
+				"inbound": "in",
+				"action": "sniff"
+			}
+		]
+	}
+}`, migrated)
+}
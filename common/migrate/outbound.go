@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+
+	"github.com/tailscale/hujson"
+)
+
+// outboundReferences are the names of options referencing outbounds by tag.
+var outboundReferences = []string{"outbound", "outbounds", "default", "detour", "download_detour"}
+
+// migrateSpecialOutbounds replaces routes to block and dns outbounds with rule actions.
+func (d *document) migrateSpecialOutbounds() {
+	root := d.rootObject()
+	outbounds := d.childArray(root, 0, "outbounds", false)
+	if outbounds == nil {
+		return
+	}
+	routeOptions := d.child(root, 0, "route", false)
+	rules := d.childArray(routeOptions, 1, "rules", false)
+	defaultTag := stringOf(lookup(routeOptions, "final"))
+	var removed []int
+	for i := range outbounds.Elements {
+		outbound := objectOf(&outbounds.Elements[i])
+		outboundType := stringOf(lookup(outbound, "type"))
+		var action string
+		switch outboundType {
+		case C.TypeBlock:
+			action = C.RuleActionTypeReject
+		case C.TypeDNS:
+			action = C.RuleActionTypeHijackDNS
+		default:
+			continue
+		}
+		pointer := F.ToString("/outbounds/", i)
+		tag := stringOf(lookup(outbound, "tag"))
+		if tag != "" {
+			forEachRule(rules, "/route/rules", 2, false, func(rule *hujson.Object, rulePointer string, depth int) {
+				if !isRouteAction(rule) || stringOf(lookup(rule, "outbound")) != tag {
+					return
+				}
+				d.setAction(rule, depth, "outbound", action)
+				d.report(rulePointer, "replaced ", outboundType, " outbound ", tag, " with ", action, " action")
+				if action == C.RuleActionTypeHijackDNS && lookup(rule, "protocol") != nil && !d.hasSniff() {
+					d.manual(rulePointer, "matching protocol requires a sniff action before this rule")
+				}
+			})
+		}
+		if tag == defaultTag || defaultTag == "" && i == 0 {
+			d.manual(pointer, outboundType, " outbound is the default outbound, replace it with a final rule manually")
+			continue
+		}
+		if tag != "" && d.isReferenced(&d.root, tag) {
+			d.manual(pointer, outboundType, " outbound is still referenced, replace the references manually")
+			continue
+		}
+		removed = append(removed, i)
+		d.report(pointer, "removed ", outboundType, " outbound")
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		removeElement(outbounds, removed[i])
+	}
+}
+
+// isReferenced reports whether tag is used by an option referencing outbounds.
+func (d *document) isReferenced(value *hujson.Value, tag string) bool {
+	switch typedValue := value.Value.(type) {
+	case *hujson.Object:
+		for i := range typedValue.Members {
+			member := &typedValue.Members[i]
+			if common.Contains(outboundReferences, stringOf(&member.Name)) && common.Contains(stringsOf(&member.Value), tag) {
+				return true
+			}
+			if d.isReferenced(&member.Value, tag) {
+				return true
+			}
+		}
+	case *hujson.Array:
+		for i := range typedValue.Elements {
+			if d.isReferenced(&typedValue.Elements[i], tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasSniff reports whether sniffing is enabled by a rule action or a legacy inbound field.
+func (d *document) hasSniff() bool {
+	root := d.rootObject()
+	var enabled bool
+	forEachRule(d.childArray(d.child(root, 0, "route", false), 1, "rules", false), "", 2, false, func(rule *hujson.Object, pointer string, depth int) {
+		enabled = enabled || stringOf(lookup(rule, "action")) == C.RuleActionTypeSniff
+	})
+	inbounds := d.childArray(root, 0, "inbounds", false)
+	for i := 0; inbounds != nil && i < len(inbounds.Elements); i++ {
+		enabled = enabled || boolOf(lookup(objectOf(&inbounds.Elements[i]), "sniff"))
+	}
+	return enabled
+}
@@ -0,0 +1,196 @@
+package mitm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	boxTLS "github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/ntp"
+	"github.com/sagernet/sing/common/rw"
+	"github.com/sagernet/sing/contrab/freelru"
+	"github.com/sagernet/sing/contrab/maphash"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+const (
+	certificateCacheSize     = 1024
+	certificateCacheLifetime = 30 * time.Minute
+	caCommonName             = "sing-box MITM CA"
+	caValidYears             = 10
+)
+
+// Engine terminates TLS for intercepted connections with leaf certificates
+// issued by a local CA, and re-originates TLS to the real server.
+type Engine struct {
+	ctx         context.Context
+	timeFunc    func() time.Time
+	certificate *x509.Certificate
+	privateKey  any
+	cache       freelru.Cache[string, *tls.Certificate]
+}
+
+func NewEngine(ctx context.Context, logger log.ContextLogger, options option.MITMOptions) (*Engine, error) {
+	timeFunc := ntp.TimeFuncFromContext(ctx)
+	if timeFunc == nil {
+		timeFunc = time.Now
+	}
+	certificatePem, keyPem, err := loadCA(ctx, logger, timeFunc, options)
+	if err != nil {
+		return nil, err
+	}
+	keyPair, err := tls.X509KeyPair(certificatePem, keyPem)
+	if err != nil {
+		return nil, E.Cause(err, "parse CA key pair")
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, E.Cause(err, "parse CA certificate")
+	}
+	if !certificate.IsCA {
+		return nil, E.New("certificate is not a CA: ", certificate.Subject.CommonName)
+	}
+	cache := common.Must1(freelru.NewSharded[string, *tls.Certificate](certificateCacheSize, maphash.NewHasher[string]().Hash32))
+	cache.SetLifetime(certificateCacheLifetime)
+	return &Engine{
+		ctx:         ctx,
+		timeFunc:    timeFunc,
+		certificate: certificate,
+		privateKey:  keyPair.PrivateKey,
+		cache:       cache,
+	}, nil
+}
+
+func loadCA(ctx context.Context, logger log.ContextLogger, timeFunc func() time.Time, options option.MITMOptions) (certificatePem []byte, keyPem []byte, err error) {
+	if len(options.Certificate) > 0 {
+		certificatePem = []byte(strings.Join(options.Certificate, "\n"))
+	}
+	if len(options.Key) > 0 {
+		keyPem = []byte(strings.Join(options.Key, "\n"))
+	}
+	var certificatePath, keyPath string
+	if options.CertificatePath != "" {
+		certificatePath = filemanager.BasePath(ctx, options.CertificatePath)
+	}
+	if options.KeyPath != "" {
+		keyPath = filemanager.BasePath(ctx, options.KeyPath)
+	}
+	if certificatePem == nil && keyPem == nil && certificatePath != "" && keyPath != "" && !rw.IsFile(certificatePath) && !rw.IsFile(keyPath) {
+		keyPem, certificatePem, err = boxTLS.GenerateCA(timeFunc, caCommonName, timeFunc().AddDate(caValidYears, 0, 0))
+		if err != nil {
+			return nil, nil, E.Cause(err, "generate CA")
+		}
+		err = writeFile(ctx, options.CertificatePath, certificatePem, 0o644)
+		if err != nil {
+			return nil, nil, E.Cause(err, "write CA certificate")
+		}
+		err = writeFile(ctx, options.KeyPath, keyPem, 0o600)
+		if err != nil {
+			return nil, nil, E.Cause(err, "write CA key")
+		}
+		logger.Info("generated MITM CA certificate at ", certificatePath)
+		return
+	}
+	if certificatePem == nil {
+		if certificatePath == "" {
+			return nil, nil, E.New("missing CA certificate")
+		}
+		certificatePem, err = os.ReadFile(certificatePath)
+		if err != nil {
+			return nil, nil, E.Cause(err, "read CA certificate")
+		}
+	}
+	if keyPem == nil {
+		if keyPath == "" {
+			return nil, nil, E.New("missing CA key")
+		}
+		keyPem, err = os.ReadFile(keyPath)
+		if err != nil {
+			return nil, nil, E.Cause(err, "read CA key")
+		}
+	}
+	return
+}
+
+func writeFile(ctx context.Context, path string, content []byte, perm os.FileMode) error {
+	err := filemanager.MkdirAll(ctx, filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := filemanager.OpenFile(ctx, path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return E.Append(err, file.Close(), func(err error) error {
+		return E.Cause(err, "close file")
+	})
+}
+
+func (e *Engine) issue(serverName string) (*tls.Certificate, error) {
+	serverName = strings.ToLower(serverName)
+	if certificate, loaded := e.cache.Get(serverName); loaded {
+		return certificate, nil
+	}
+	certificate, err := boxTLS.GenerateKeyPair(e.certificate, e.privateKey, e.timeFunc, serverName)
+	if err != nil {
+		return nil, E.Cause(err, "issue certificate for ", serverName)
+	}
+	e.cache.Add(serverName, certificate)
+	return certificate, nil
+}
+
+// Server terminates the client TLS connection, presenting a certificate for serverName
+// if the client hello carries no server name.
+//
+// HTTP/1.1 is preferred over other application protocols so that requests can be inspected.
+func (e *Engine) Server(conn net.Conn, serverName string) *tls.Conn {
+	return tls.Server(conn, &tls.Config{
+		Time: e.timeFunc,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			certificateName := hello.ServerName
+			if certificateName == "" {
+				certificateName = serverName
+			}
+			certificate, err := e.issue(certificateName)
+			if err != nil {
+				return nil, err
+			}
+			var nextProtos []string
+			if common.Contains(hello.SupportedProtos, "http/1.1") {
+				nextProtos = []string{"http/1.1"}
+			} else if len(hello.SupportedProtos) > 0 {
+				nextProtos = hello.SupportedProtos[:1]
+			}
+			return &tls.Config{
+				Time:         e.timeFunc,
+				Certificates: []tls.Certificate{*certificate},
+				NextProtos:   nextProtos,
+			}, nil
+		},
+	})
+}
+
+// Client re-originates TLS to the real server over conn, verifying its certificate
+// and offering the application protocol negotiated with the client.
+func (e *Engine) Client(conn net.Conn, serverName string, negotiatedProtocol string) *tls.Conn {
+	config := &tls.Config{
+		Time:       e.timeFunc,
+		ServerName: serverName,
+		RootCAs:    adapter.RootPoolFromContext(e.ctx),
+	}
+	if negotiatedProtocol != "" {
+		config.NextProtos = []string{negotiatedProtocol}
+	}
+	return tls.Client(conn, config)
+}
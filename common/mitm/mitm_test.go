@@ -0,0 +1,52 @@
+package mitm_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/mitm"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineGenerateAndIssue(t *testing.T) {
+	t.Parallel()
+	directory := t.TempDir()
+	options := option.MITMOptions{
+		CertificatePath: filepath.Join(directory, "ca.crt"),
+		KeyPath:         filepath.Join(directory, "ca.key"),
+	}
+	engine, err := mitm.NewEngine(context.Background(), log.NewNOPFactory().Logger(), options)
+	require.NoError(t, err)
+	caPem, err := os.ReadFile(options.CertificatePath)
+	require.NoError(t, err)
+	rootCAs := x509.NewCertPool()
+	require.True(t, rootCAs.AppendCertsFromPEM(caPem))
+
+	_, err = mitm.NewEngine(context.Background(), log.NewNOPFactory().Logger(), options)
+	require.NoError(t, err)
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	serverErr := make(chan error, 1)
+	server := engine.Server(serverConn, "example.com")
+	go func() {
+		serverErr <- server.Handshake()
+	}()
+	client := tls.Client(clientConn, &tls.Config{
+		ServerName: "example.com",
+		RootCAs:    rootCAs,
+		NextProtos: []string{"h2", "http/1.1"},
+	})
+	require.NoError(t, client.Handshake())
+	require.NoError(t, <-serverErr)
+	require.Equal(t, "http/1.1", client.ConnectionState().NegotiatedProtocol)
+}
@@ -3,18 +3,57 @@ package mux
 import (
 	"context"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-mux"
+	"github.com/sagernet/sing/common"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 )
 
-type Client = mux.Client
+var _ adapter.MultiplexClient = (*Client)(nil)
+
+// Client keeps one sing-mux client per underlying connection,
+// so that sessions can be inspected, expired and drained individually.
+type Client struct {
+	dialer         N.Dialer
+	logger         logger.Logger
+	protocol       string
+	maxConnections int
+	minStreams     int
+	maxStreams     int
+	padding        bool
+	brutal         mux.BrutalOptions
+	idleTimeout    time.Duration
+	maxLifetime    time.Duration
+	access         sync.Mutex
+	sessions       []*session
+	nextID         uint64
+	closed         bool
+}
+
+type session struct {
+	id           uint64
+	client       *mux.Client
+	createdAt    time.Time
+	lastActive   atomic.Int64
+	upload       atomic.Int64
+	download     atomic.Int64
+	dialed       atomic.Bool
+	exhausted    atomic.Bool
+	closed       atomic.Bool
+	streams      int
+	totalStreams uint64
+	draining     bool
+	idleTimer    *time.Timer
+}
 
 func NewClientWithOptions(dialer N.Dialer, logger logger.Logger, options option.OutboundMultiplexOptions) (*Client, error) {
 	if !options.Enabled {
@@ -34,26 +73,306 @@ func NewClientWithOptions(dialer N.Dialer, logger logger.Logger, options option.
 			return nil, E.New("brutal: invalid download speed")
 		}
 	}
-	return mux.NewClient(mux.Options{
-		Dialer:         &clientDialer{dialer},
-		Logger:         logger,
-		Protocol:       options.Protocol,
-		MaxConnections: options.MaxConnections,
-		MinStreams:     options.MinStreams,
-		MaxStreams:     options.MaxStreams,
-		Padding:        options.Padding,
-		Brutal:         brutalOptions,
+	protocol := options.Protocol
+	if protocol == "" {
+		protocol = "h2mux"
+	}
+	_, err := mux.NewClient(mux.Options{Protocol: protocol})
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		dialer:         dialer,
+		logger:         logger,
+		protocol:       protocol,
+		maxConnections: options.MaxConnections,
+		minStreams:     options.MinStreams,
+		maxStreams:     options.MaxStreams,
+		padding:        options.Padding,
+		brutal:         brutalOptions,
+		idleTimeout:    time.Duration(options.IdleTimeout),
+		maxLifetime:    time.Duration(options.MaxLifetime),
+	}
+	if client.maxStreams == 0 && client.maxConnections == 0 {
+		client.minStreams = 8
+	}
+	return client, nil
+}
+
+func (c *Client) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	var (
+		currentSession *session
+		conn           net.Conn
+		err            error
+	)
+	for attempts := 0; attempts < 2; attempts++ {
+		currentSession, err = c.offer()
+		if err != nil {
+			return nil, err
+		}
+		conn, err = currentSession.client.DialContext(ctx, network, destination)
+		if err == nil {
+			break
+		}
+		c.release(currentSession)
+	}
+	if err != nil {
+		return nil, err
+	}
+	release := c.releaseFunc(currentSession)
+	if packetConn, isPacketConn := conn.(N.BindPacketConn); isPacketConn {
+		return &bindPacketStream{BindPacketConn: packetConn, release: release}, nil
+	}
+	return &stream{Conn: conn, release: release}, nil
+}
+
+func (c *Client) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	var (
+		currentSession *session
+		conn           net.PacketConn
+		err            error
+	)
+	for attempts := 0; attempts < 2; attempts++ {
+		currentSession, err = c.offer()
+		if err != nil {
+			return nil, err
+		}
+		conn, err = currentSession.client.ListenPacket(ctx, destination)
+		if err == nil {
+			break
+		}
+		c.release(currentSession)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &packetStream{NetPacketConn: conn.(N.NetPacketConn), release: c.releaseFunc(currentSession)}, nil
+}
+
+// offer selects a session for a new stream with the same strategy as sing-mux, and reserves the stream on it.
+func (c *Client) offer() (*session, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	if c.closed {
+		return nil, net.ErrClosed
+	}
+	now := time.Now()
+	var available []*session
+	for _, currentSession := range c.sessions {
+		if currentSession.closed.Load() {
+			continue
+		}
+		if c.maxLifetime > 0 && !currentSession.draining && now.Sub(currentSession.createdAt) >= c.maxLifetime {
+			c.logger.Debug("multiplex session ", currentSession.id, " reached max lifetime, draining")
+			c.drainSessionLocked(currentSession)
+		}
+		if !currentSession.draining && currentSession.exhausted.Load() {
+			c.logger.Debug("multiplex session ", currentSession.id, " can not take new streams, draining")
+			c.drainSessionLocked(currentSession)
+		}
+		if !currentSession.draining {
+			available = append(available, currentSession)
+		}
+	}
+	c.sessions = common.Filter(c.sessions, func(it *session) bool {
+		if it.closed.Load() {
+			go it.client.Close()
+			return false
+		}
+		return true
 	})
+	selected := c.selectLocked(available)
+	if selected == nil {
+		var err error
+		selected, err = c.newSessionLocked(now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if selected.idleTimer != nil {
+		selected.idleTimer.Stop()
+		selected.idleTimer = nil
+	}
+	selected.streams++
+	selected.totalStreams++
+	selected.lastActive.Store(now.UnixNano())
+	return selected, nil
 }
 
-type clientDialer struct {
-	N.Dialer
+func (c *Client) selectLocked(sessions []*session) *session {
+	if c.brutal.Enabled {
+		if len(sessions) > 0 {
+			return sessions[0]
+		}
+		return nil
+	}
+	selected := common.MinBy(sessions, func(it *session) int {
+		return it.streams
+	})
+	if selected == nil {
+		return nil
+	}
+	if selected.streams == 0 {
+		return selected
+	}
+	if c.maxConnections > 0 {
+		if len(sessions) >= c.maxConnections || selected.streams < c.minStreams {
+			return selected
+		}
+	} else if c.maxStreams > 0 && selected.streams < c.maxStreams {
+		return selected
+	}
+	return nil
+}
+
+func (c *Client) newSessionLocked(now time.Time) (*session, error) {
+	c.nextID++
+	newSession := &session{
+		id:        c.nextID,
+		createdAt: now,
+	}
+	client, err := mux.NewClient(mux.Options{
+		Dialer:         &sessionDialer{dialer: c.dialer, session: newSession},
+		Logger:         c.logger,
+		Protocol:       c.protocol,
+		MaxConnections: 1,
+		Padding:        c.padding,
+		Brutal:         c.brutal,
+	})
+	if err != nil {
+		return nil, err
+	}
+	newSession.client = client
+	c.sessions = append(c.sessions, newSession)
+	return newSession, nil
+}
+
+func (c *Client) releaseFunc(currentSession *session) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.release(currentSession)
+		})
+	}
+}
+
+func (c *Client) release(currentSession *session) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	currentSession.streams--
+	currentSession.lastActive.Store(time.Now().UnixNano())
+	if currentSession.streams > 0 || currentSession.closed.Load() {
+		return
+	}
+	if currentSession.draining {
+		c.closeSessionLocked(currentSession)
+	} else if c.idleTimeout > 0 {
+		currentSession.idleTimer = time.AfterFunc(c.idleTimeout, func() {
+			c.access.Lock()
+			defer c.access.Unlock()
+			if currentSession.streams == 0 && !currentSession.closed.Load() {
+				c.logger.Debug("multiplex session ", currentSession.id, " idle timeout")
+				c.closeSessionLocked(currentSession)
+			}
+		})
+	}
+}
+
+func (c *Client) drainSessionLocked(currentSession *session) {
+	currentSession.draining = true
+	if currentSession.streams == 0 {
+		c.closeSessionLocked(currentSession)
+	}
+}
+
+func (c *Client) closeSessionLocked(currentSession *session) {
+	if currentSession.closed.Swap(true) {
+		return
+	}
+	if currentSession.idleTimer != nil {
+		currentSession.idleTimer.Stop()
+		currentSession.idleTimer = nil
+	}
+	c.sessions = common.Filter(c.sessions, func(it *session) bool {
+		return it != currentSession
+	})
+	go currentSession.client.Close()
+}
+
+func (c *Client) Sessions() []adapter.MultiplexSession {
+	c.access.Lock()
+	defer c.access.Unlock()
+	var sessions []adapter.MultiplexSession
+	for _, currentSession := range c.sessions {
+		if currentSession.closed.Load() {
+			continue
+		}
+		sessions = append(sessions, adapter.MultiplexSession{
+			ID:           currentSession.id,
+			Protocol:     c.protocol,
+			CreatedAt:    currentSession.createdAt,
+			LastActiveAt: time.Unix(0, currentSession.lastActive.Load()),
+			Streams:      currentSession.streams,
+			TotalStreams: currentSession.totalStreams,
+			Upload:       currentSession.upload.Load(),
+			Download:     currentSession.download.Load(),
+			Draining:     currentSession.draining,
+		})
+	}
+	return sessions
 }
 
-func (d *clientDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
-	return d.Dialer.DialContext(adapter.OverrideContext(ctx), network, destination)
+func (c *Client) Drain() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	for _, currentSession := range c.sessions {
+		c.drainSessionLocked(currentSession)
+	}
+}
+
+// Reset closes all sessions immediately, used when the network changes.
+func (c *Client) Reset() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	for _, currentSession := range c.sessions {
+		c.closeSessionLocked(currentSession)
+	}
+}
+
+// Close stops opening new streams and closes each session once its streams are finished,
+// so that live connections of a removed outbound are not broken. Use Reset to close sessions immediately.
+func (c *Client) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.closed = true
+	for _, currentSession := range c.sessions {
+		c.drainSessionLocked(currentSession)
+	}
+	return nil
+}
+
+type sessionDialer struct {
+	dialer  N.Dialer
+	session *session
+}
+
+func (d *sessionDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	// each session owns exactly one connection, reconnecting is done by creating a new session.
+	// sing-mux also dials again when the connection can not take new streams (e.g. after GOAWAY),
+	// so only the new stream fails here, and the session is drained to keep its live streams.
+	if d.session.dialed.Swap(true) {
+		d.session.exhausted.Store(true)
+		return nil, net.ErrClosed
+	}
+	conn, err := d.dialer.DialContext(adapter.OverrideContext(ctx), network, destination)
+	if err != nil {
+		d.session.closed.Store(true)
+		return nil, err
+	}
+	d.session.lastActive.Store(time.Now().UnixNano())
+	return &sessionConn{Conn: conn, session: d.session}, nil
 }
 
-func (d *clientDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
-	return d.Dialer.ListenPacket(adapter.OverrideContext(ctx), destination)
+func (d *sessionDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return d.dialer.ListenPacket(adapter.OverrideContext(ctx), destination)
 }
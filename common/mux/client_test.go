@@ -0,0 +1,199 @@
+package mux
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-mux"
+	"github.com/sagernet/sing/common/json/badoption"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type echoHandler struct{}
+
+func (h *echoHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	io.Copy(conn, conn)
+	conn.Close()
+}
+
+func (h *echoHandler) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	conn.Close()
+}
+
+type pipeDialer struct {
+	service *mux.Service
+	dials   atomic.Int32
+}
+
+func newPipeDialer(t *testing.T) *pipeDialer {
+	service, err := mux.NewService(mux.ServiceOptions{
+		NewStreamContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return ctx
+		},
+		Logger:    logger.NOP(),
+		HandlerEx: &echoHandler{},
+	})
+	require.NoError(t, err)
+	return &pipeDialer{service: service}
+}
+
+func (d *pipeDialer) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	d.dials.Add(1)
+	clientConn, serverConn := net.Pipe()
+	go d.service.NewConnectionEx(context.Background(), serverConn, M.Socksaddr{}, M.Socksaddr{}, nil)
+	return clientConn, nil
+}
+
+func (d *pipeDialer) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, net.ErrClosed
+}
+
+func newTestClient(t *testing.T, dialer N.Dialer, options option.OutboundMultiplexOptions) *Client {
+	options.Enabled = true
+	options.Protocol = "smux"
+	client, err := NewClientWithOptions(dialer, logger.NOP(), options)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		client.Reset()
+	})
+	return client
+}
+
+func dialEcho(t *testing.T, client *Client) net.Conn {
+	conn, err := client.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("example.com:80"))
+	require.NoError(t, err)
+	requireEcho(t, conn)
+	return conn
+}
+
+func requireEcho(t *testing.T, conn net.Conn) {
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	buffer := make([]byte, 4)
+	_, err = io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buffer))
+}
+
+func TestClientReuseSession(t *testing.T) {
+	t.Parallel()
+	dialer := newPipeDialer(t)
+	client := newTestClient(t, dialer, option.OutboundMultiplexOptions{MaxStreams: 2})
+	conn1 := dialEcho(t, client)
+	conn2 := dialEcho(t, client)
+	require.EqualValues(t, 1, dialer.dials.Load())
+	sessions := client.Sessions()
+	require.Len(t, sessions, 1)
+	require.Equal(t, 2, sessions[0].Streams)
+	conn3 := dialEcho(t, client)
+	require.EqualValues(t, 2, dialer.dials.Load())
+	require.Len(t, client.Sessions(), 2)
+	conn1.Close()
+	conn2.Close()
+	conn3.Close()
+	for _, session := range client.Sessions() {
+		require.Zero(t, session.Streams)
+	}
+}
+
+func TestClientExhaustedSession(t *testing.T) {
+	t.Parallel()
+	dialer := newPipeDialer(t)
+	client := newTestClient(t, dialer, option.OutboundMultiplexOptions{MaxStreams: 8})
+	conn1 := dialEcho(t, client)
+	firstSession := client.sessions[0]
+	// sing-mux dials again when the connection can not take new streams
+	_, err := (&sessionDialer{dialer: dialer, session: firstSession}).DialContext(context.Background(), N.NetworkTCP, M.Socksaddr{})
+	require.ErrorIs(t, err, net.ErrClosed)
+	require.False(t, firstSession.closed.Load())
+	conn2 := dialEcho(t, client)
+	require.EqualValues(t, 2, dialer.dials.Load())
+	require.True(t, firstSession.draining)
+	requireEcho(t, conn1)
+	conn1.Close()
+	require.True(t, firstSession.closed.Load())
+	requireEcho(t, conn2)
+	conn2.Close()
+	require.Len(t, client.Sessions(), 1)
+}
+
+func TestClientDrain(t *testing.T) {
+	t.Parallel()
+	dialer := newPipeDialer(t)
+	client := newTestClient(t, dialer, option.OutboundMultiplexOptions{MaxStreams: 8})
+	conn1 := dialEcho(t, client)
+	client.Drain()
+	sessions := client.Sessions()
+	require.Len(t, sessions, 1)
+	require.True(t, sessions[0].Draining)
+	conn2 := dialEcho(t, client)
+	require.EqualValues(t, 2, dialer.dials.Load())
+	requireEcho(t, conn1)
+	conn1.Close()
+	conn2.Close()
+	sessions = client.Sessions()
+	require.Len(t, sessions, 1)
+	require.False(t, sessions[0].Draining)
+}
+
+func TestClientIdleTimeout(t *testing.T) {
+	t.Parallel()
+	dialer := newPipeDialer(t)
+	client := newTestClient(t, dialer, option.OutboundMultiplexOptions{
+		MaxStreams:  8,
+		IdleTimeout: badoption.Duration(100 * time.Millisecond),
+	})
+	dialEcho(t, client).Close()
+	require.Len(t, client.Sessions(), 1)
+	require.Eventually(t, func() bool {
+		return len(client.Sessions()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClientClose(t *testing.T) {
+	t.Parallel()
+	dialer := newPipeDialer(t)
+	client := newTestClient(t, dialer, option.OutboundMultiplexOptions{MaxStreams: 8})
+	conn := dialEcho(t, client)
+	require.NoError(t, client.Close())
+	sessions := client.Sessions()
+	require.Len(t, sessions, 1)
+	require.True(t, sessions[0].Draining)
+	requireEcho(t, conn)
+	_, err := client.DialContext(context.Background(), N.NetworkTCP, M.ParseSocksaddr("example.com:80"))
+	require.ErrorIs(t, err, net.ErrClosed)
+	conn.Close()
+	require.Eventually(t, func() bool {
+		return len(client.Sessions()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestClientReset(t *testing.T) {
+	t.Parallel()
+	dialer := newPipeDialer(t)
+	client := newTestClient(t, dialer, option.OutboundMultiplexOptions{MaxStreams: 8})
+	conn := dialEcho(t, client)
+	client.Reset()
+	require.Empty(t, client.Sessions())
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		readErr <- err
+	}()
+	select {
+	case err := <-readErr:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed with the session")
+	}
+}
@@ -0,0 +1,112 @@
+package mux
+
+import (
+	"net"
+	"time"
+
+	N "github.com/sagernet/sing/common/network"
+)
+
+type sessionConn struct {
+	net.Conn
+	session *session
+}
+
+func (c *sessionConn) Read(b []byte) (n int, err error) {
+	n, err = c.Conn.Read(b)
+	if n > 0 {
+		c.session.download.Add(int64(n))
+		c.session.lastActive.Store(time.Now().UnixNano())
+	}
+	if err != nil {
+		c.session.closed.Store(true)
+	}
+	return
+}
+
+func (c *sessionConn) Write(b []byte) (n int, err error) {
+	n, err = c.Conn.Write(b)
+	if n > 0 {
+		c.session.upload.Add(int64(n))
+		c.session.lastActive.Store(time.Now().UnixNano())
+	}
+	if err != nil {
+		c.session.closed.Store(true)
+	}
+	return
+}
+
+func (c *sessionConn) Close() error {
+	c.session.closed.Store(true)
+	return c.Conn.Close()
+}
+
+func (c *sessionConn) Upstream() any {
+	return c.Conn
+}
+
+type stream struct {
+	net.Conn
+	release func()
+}
+
+func (c *stream) Close() error {
+	defer c.release()
+	return c.Conn.Close()
+}
+
+func (c *stream) Upstream() any {
+	return c.Conn
+}
+
+func (c *stream) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *stream) WriterReplaceable() bool {
+	return true
+}
+
+type bindPacketStream struct {
+	N.BindPacketConn
+	release func()
+}
+
+func (c *bindPacketStream) Close() error {
+	defer c.release()
+	return c.BindPacketConn.Close()
+}
+
+func (c *bindPacketStream) Upstream() any {
+	return c.BindPacketConn
+}
+
+func (c *bindPacketStream) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *bindPacketStream) WriterReplaceable() bool {
+	return true
+}
+
+type packetStream struct {
+	N.NetPacketConn
+	release func()
+}
+
+func (c *packetStream) Close() error {
+	defer c.release()
+	return c.NetPacketConn.Close()
+}
+
+func (c *packetStream) Upstream() any {
+	return c.NetPacketConn
+}
+
+func (c *packetStream) ReaderReplaceable() bool {
+	return true
+}
+
+func (c *packetStream) WriterReplaceable() bool {
+	return true
+}
@@ -0,0 +1,101 @@
+package mux_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func startEchoServer(t *testing.T) M.Socksaddr {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return M.SocksaddrFromNet(listener.Addr())
+}
+
+func requireEcho(t *testing.T, conn net.Conn) {
+	_, err := conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	response := make([]byte, 4)
+	_, err = io.ReadFull(conn, response)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(response))
+}
+
+func TestRemoveOutboundKeepsStreams(t *testing.T) {
+	t.Parallel()
+	service := startEchoServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	ctx, cancel := context.WithCancel(include.Context(context.Background()))
+	defer cancel()
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(`{
+  "log": {"disabled": true},
+  "inbounds": [
+    {
+      "type": "shadowsocks",
+      "tag": "ss-in",
+      "listen": "127.0.0.1",
+      "listen_port": `+port+`,
+      "method": "aes-128-gcm",
+      "password": "password",
+      "multiplex": {"enabled": true}
+    }
+  ],
+  "outbounds": [
+    {"type": "direct", "tag": "direct"},
+    {
+      "type": "shadowsocks",
+      "tag": "ss-out",
+      "server": "127.0.0.1",
+      "server_port": `+port+`,
+      "method": "aes-128-gcm",
+      "password": "password",
+      "multiplex": {"enabled": true, "protocol": "smux"}
+    }
+  ]
+}`))
+	require.NoError(t, err)
+	instance, err := box.New(box.Options{Context: ctx, Options: options})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	outbound, loaded := instance.Outbound().Outbound("ss-out")
+	require.True(t, loaded)
+	conn, err := outbound.DialContext(ctx, N.NetworkTCP, service)
+	require.NoError(t, err)
+	defer conn.Close()
+	requireEcho(t, conn)
+	// the same path a provider takes for nodes removed by an update
+	require.NoError(t, instance.Outbound().Remove("ss-out"))
+	requireEcho(t, conn)
+	_, err = outbound.DialContext(ctx, N.NetworkTCP, service)
+	require.Error(t, err)
+}
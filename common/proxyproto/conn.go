@@ -0,0 +1,26 @@
+package proxyproto
+
+import (
+	"net"
+)
+
+// PacketConn prepends a PROXY protocol header to every written datagram.
+type PacketConn struct {
+	net.PacketConn
+	header []byte
+}
+
+func NewPacketConn(conn net.PacketConn, header []byte) *PacketConn {
+	return &PacketConn{PacketConn: conn, header: header}
+}
+
+func (c *PacketConn) WriteTo(p []byte, addr net.Addr) (n int, err error) {
+	packet := make([]byte, 0, len(c.header)+len(p))
+	packet = append(packet, c.header...)
+	packet = append(packet, p...)
+	_, err = c.PacketConn.WriteTo(packet, addr)
+	if err != nil {
+		return
+	}
+	return len(p), nil
+}
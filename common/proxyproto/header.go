@@ -0,0 +1,82 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const (
+	Version1 = 1
+	Version2 = 2
+)
+
+var v2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	v2CommandProxy = 0x21
+	v2FamilyInet   = 0x10
+	v2FamilyInet6  = 0x20
+	v2ProtocolTCP  = 0x01
+	v2ProtocolUDP  = 0x02
+)
+
+// Header returns the PROXY protocol header describing a connection from source to destination.
+//
+// Addresses of different families are both encoded as IPv6,
+// and the connection is described as unknown if either address is not an IP address.
+func Header(version int, network string, source M.Socksaddr, destination M.Socksaddr) ([]byte, error) {
+	sourceAddr := source.Addr.Unmap()
+	destinationAddr := destination.Addr.Unmap()
+	known := sourceAddr.IsValid() && destinationAddr.IsValid()
+	if known && sourceAddr.Is4() != destinationAddr.Is4() {
+		sourceAddr = netip.AddrFrom16(sourceAddr.As16())
+		destinationAddr = netip.AddrFrom16(destinationAddr.As16())
+	}
+	switch version {
+	case Version1:
+		if !known || N.NetworkName(network) != N.NetworkTCP {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		family := "TCP4"
+		if sourceAddr.Is6() {
+			family = "TCP6"
+		}
+		return []byte("PROXY " + family + " " + sourceAddr.String() + " " + destinationAddr.String() + " " +
+			strconv.Itoa(int(source.Port)) + " " + strconv.Itoa(int(destination.Port)) + "\r\n"), nil
+	case Version2:
+		header := make([]byte, 16, 16+36)
+		copy(header, v2Signature)
+		header[12] = v2CommandProxy
+		if known {
+			if sourceAddr.Is4() {
+				header[13] = v2FamilyInet
+			} else {
+				header[13] = v2FamilyInet6
+			}
+			switch N.NetworkName(network) {
+			case N.NetworkTCP:
+				header[13] |= v2ProtocolTCP
+			case N.NetworkUDP:
+				header[13] |= v2ProtocolUDP
+			default:
+				header[13] = 0
+			}
+		}
+		if header[13] == 0 {
+			return header, nil
+		}
+		header = append(header, sourceAddr.AsSlice()...)
+		header = append(header, destinationAddr.AsSlice()...)
+		header = binary.BigEndian.AppendUint16(header, source.Port)
+		header = binary.BigEndian.AppendUint16(header, destination.Port)
+		binary.BigEndian.PutUint16(header[14:], uint16(len(header)-16))
+		return header, nil
+	default:
+		return nil, E.New("unknown PROXY protocol version: ", version)
+	}
+}
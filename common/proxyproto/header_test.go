@@ -0,0 +1,38 @@
+package proxyproto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderV1(t *testing.T) {
+	t.Parallel()
+	header, err := Header(Version1, N.NetworkTCP, M.ParseSocksaddr("192.168.1.2:50000"), M.ParseSocksaddr("10.0.0.1:443"))
+	require.NoError(t, err)
+	require.Equal(t, "PROXY TCP4 192.168.1.2 10.0.0.1 50000 443\r\n", string(header))
+	header, err = Header(Version1, N.NetworkTCP, M.ParseSocksaddr("[2001:db8::1]:50000"), M.ParseSocksaddr("10.0.0.1:443"))
+	require.NoError(t, err)
+	require.Equal(t, "PROXY TCP6 2001:db8::1 ::ffff:10.0.0.1 50000 443\r\n", string(header))
+	header, err = Header(Version1, N.NetworkTCP, M.ParseSocksaddr("example.com:50000"), M.ParseSocksaddr("10.0.0.1:443"))
+	require.NoError(t, err)
+	require.Equal(t, "PROXY UNKNOWN\r\n", string(header))
+}
+
+func TestHeaderV2(t *testing.T) {
+	t.Parallel()
+	header, err := Header(Version2, N.NetworkTCP, M.ParseSocksaddr("192.168.1.2:50000"), M.ParseSocksaddr("10.0.0.1:443"))
+	require.NoError(t, err)
+	require.Equal(t, "0d0a0d0a000d0a515549540a"+"2111000c"+"c0a80102"+"0a000001"+"c350"+"01bb", hex.EncodeToString(header))
+	header, err = Header(Version2, N.NetworkUDP, M.ParseSocksaddr("[2001:db8::1]:53"), M.ParseSocksaddr("[2001:db8::2]:53"))
+	require.NoError(t, err)
+	require.Len(t, header, 16+36)
+	require.Equal(t, byte(0x22), header[13])
+	header, err = Header(Version2, N.NetworkTCP, M.Socksaddr{}, M.ParseSocksaddr("10.0.0.1:443"))
+	require.NoError(t, err)
+	require.Equal(t, "0d0a0d0a000d0a515549540a"+"21000000", hex.EncodeToString(header))
+}
@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// locate returns the offset of every JSON pointer in content,
+// members of objects are located at their keys.
+func locate(content []byte) (map[string]int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	positions := make(map[string]int)
+	var walk func(pointer string) error
+	walk = func(pointer string) error {
+		if _, loaded := positions[pointer]; !loaded {
+			positions[pointer] = skipSeparators(content, int(decoder.InputOffset()))
+		}
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				offset := skipSeparators(content, int(decoder.InputOffset()))
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				childPointer := pointer + "/" + escapePointer(keyToken.(string))
+				positions[childPointer] = offset
+				err = walk(childPointer)
+				if err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		case json.Delim('['):
+			for index := 0; decoder.More(); index++ {
+				err = walk(pointer + "/" + strconv.Itoa(index))
+				if err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+		}
+		return err
+	}
+	return positions, walk("")
+}
+
+func skipSeparators(content []byte, offset int) int {
+	for offset < len(content) {
+		switch content[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// position converts an offset of content to a line and a column.
+func position(content []byte, offset int) (line int, column int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	prefix := content[:offset]
+	line = bytes.Count(prefix, []byte{'\n'}) + 1
+	column = offset - bytes.LastIndexByte(prefix, '\n')
+	return
+}
+
+// stripComments replaces comments with spaces, so offsets and lines are kept.
+func stripComments(content []byte) []byte {
+	if !bytes.ContainsAny(content, "/#") {
+		return content
+	}
+	result := make([]byte, len(content))
+	copy(result, content)
+	var inString, escaped bool
+	for i := 0; i < len(result); i++ {
+		c := result[i]
+		if inString {
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+		case c == '#' || c == '/' && i+1 < len(result) && result[i+1] == '/':
+			for ; i < len(result) && result[i] != '\n'; i++ {
+				result[i] = ' '
+			}
+		case c == '/' && i+1 < len(result) && result[i+1] == '*':
+			for ; i < len(result); i++ {
+				if result[i] == '*' && i+1 < len(result) && result[i+1] == '/' {
+					result[i], result[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if result[i] != '\n' {
+					result[i] = ' '
+				}
+			}
+		}
+	}
+	return result
+}
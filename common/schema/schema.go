@@ -0,0 +1,318 @@
+package schema
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document or subschema.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Const                any                `json:"const,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+
+	// discriminator and variants select the OneOf branch during validation.
+	discriminator string
+	variants      []string
+	defaultIndex  int
+}
+
+// Generate generates the JSON Schema of option.Options,
+// polymorphic options are read from the registries in ctx.
+func Generate(ctx context.Context) (*Schema, error) {
+	g := &generator{
+		ctx:   ctx,
+		defs:  make(map[string]*Schema),
+		names: make(map[reflect.Type]string),
+	}
+	root, err := g.generate(reflect.TypeOf(option.Options{}))
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{
+		Schema: Draft,
+		Ref:    root.Ref,
+		Defs:   g.defs,
+	}, nil
+}
+
+type generator struct {
+	ctx   context.Context
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+type property struct {
+	name   string
+	schema *Schema
+}
+
+// shape is the set of fields of an object, optionally continued by a discriminated union.
+type shape struct {
+	properties []property
+	union      *shapeUnion
+}
+
+type shapeUnion struct {
+	key          string
+	defaultValue string
+	values       []string
+	shapes       []*shape
+}
+
+func (g *generator) generate(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if override, loaded := overrides[t]; loaded {
+		return override(), nil
+	}
+	custom := hasCustomUnmarshaler(t)
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if custom {
+			return &Schema{Type: []string{"integer", "string"}}, nil
+		}
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string"}, nil
+		}
+		items, err := g.generate(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if custom {
+			return listable(items), nil
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.generate(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		if valueType := typedMapValue(t); valueType != nil {
+			values, err := g.generate(valueType)
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{Type: "object", AdditionalProperties: values}, nil
+		}
+		if custom && !hasJSONFields(t) {
+			return &Schema{Type: "string"}, nil
+		}
+		return g.generateStruct(t)
+	default:
+		return nil, E.New("unsupported type: ", t)
+	}
+}
+
+func (g *generator) generateStruct(t reflect.Type) (*Schema, error) {
+	name, loaded := g.names[t]
+	if !loaded {
+		name = definitionName(t)
+		g.names[t] = name
+		s, err := g.shape(t)
+		if err != nil {
+			return nil, err
+		}
+		definition := g.shapeSchema(s)
+		if alternative, loaded := shorthands[t]; loaded {
+			definition = &Schema{AnyOf: []*Schema{{Type: alternative}, definition}}
+		}
+		g.defs[name] = definition
+	}
+	return &Schema{Ref: "#/$defs/" + name}, nil
+}
+
+// shape collects the fields of a struct, embedded structs are flattened.
+func (g *generator) shape(t reflect.Type) (*shape, error) {
+	s := &shape{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded, err := g.shape(fieldType)
+			if err != nil {
+				return nil, E.Cause(err, t.Name(), ".", field.Name)
+			}
+			s.properties = append(s.properties, embedded.properties...)
+			if embedded.union != nil {
+				if s.union != nil {
+					return nil, E.New(t.Name(), ": multiple unions")
+				}
+				s.union = embedded.union
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fieldSchema, err := g.generate(field.Type)
+		if err != nil {
+			return nil, E.Cause(err, t.Name(), ".", field.Name)
+		}
+		s.properties = append(s.properties, property{name, fieldSchema})
+	}
+	union, loaded := unions[t]
+	if !loaded {
+		return s, nil
+	}
+	values, types, err := union.variants(g.ctx, t)
+	if err != nil {
+		return nil, E.Cause(err, t.Name())
+	}
+	s.union = &shapeUnion{
+		key:          union.key,
+		defaultValue: union.defaultValue,
+		values:       values,
+		shapes:       make([]*shape, len(values)),
+	}
+	for i, variantType := range types {
+		if variantType == nil {
+			s.union.shapes[i] = &shape{}
+			continue
+		}
+		for variantType.Kind() == reflect.Pointer {
+			variantType = variantType.Elem()
+		}
+		variantShape, err := g.shape(variantType)
+		if err != nil {
+			return nil, E.Cause(err, t.Name(), "[", values[i], "]")
+		}
+		s.union.shapes[i] = variantShape
+	}
+	return s, nil
+}
+
+func (g *generator) shapeSchema(s *shape) *Schema {
+	if s.union == nil {
+		schema := &Schema{
+			Type:                 "object",
+			Properties:           make(map[string]*Schema, len(s.properties)),
+			AdditionalProperties: false,
+		}
+		for _, p := range s.properties {
+			schema.Properties[p.name] = p.schema
+		}
+		return schema
+	}
+	schema := &Schema{
+		discriminator: s.union.key,
+		variants:      s.union.values,
+		defaultIndex:  -1,
+	}
+	for i, value := range s.union.values {
+		variant := &shape{}
+		for _, p := range s.properties {
+			if p.name != s.union.key {
+				variant.properties = append(variant.properties, p)
+			}
+		}
+		variant.properties = append(variant.properties, property{s.union.key, &Schema{Type: "string", Const: value}})
+		variant.properties = append(variant.properties, s.union.shapes[i].properties...)
+		variant.union = s.union.shapes[i].union
+		variantSchema := g.shapeSchema(variant)
+		if value == s.union.defaultValue {
+			schema.defaultIndex = i
+		} else {
+			variantSchema.Required = append(variantSchema.Required, s.union.key)
+		}
+		schema.OneOf = append(schema.OneOf, variantSchema)
+	}
+	return schema
+}
+
+func listable(items *Schema) *Schema {
+	return &Schema{AnyOf: []*Schema{items, {Type: "array", Items: items}}}
+}
+
+func definitionName(t reflect.Type) string {
+	name := t.Name()
+	if index := strings.IndexByte(name, '['); index >= 0 {
+		name = name[:index]
+	}
+	name = strings.TrimPrefix(name, "_")
+	pkgPath := t.PkgPath()
+	if pkgPath != "" && !strings.HasSuffix(pkgPath, "/option") {
+		name = pkgPath[strings.LastIndexByte(pkgPath, '/')+1:] + "." + name
+	}
+	return name
+}
+
+var (
+	unmarshalerType        = reflect.TypeOf((*stdjson.Unmarshaler)(nil)).Elem()
+	contextUnmarshalerType = reflect.TypeOf((*json.ContextUnmarshaler)(nil)).Elem()
+	textUnmarshalerType    = reflect.TypeOf((*interface{ UnmarshalText([]byte) error })(nil)).Elem()
+)
+
+func hasCustomUnmarshaler(t reflect.Type) bool {
+	pointerType := reflect.PointerTo(t)
+	return pointerType.Implements(unmarshalerType) ||
+		pointerType.Implements(contextUnmarshalerType) ||
+		pointerType.Implements(textUnmarshalerType)
+}
+
+func hasJSONFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if hasJSONFields(field.Type) {
+				return true
+			}
+		} else if field.IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// typedMapValue returns the value type of badjson.TypedMap, which is an ordered JSON object.
+func typedMapValue(t reflect.Type) reflect.Type {
+	if t.PkgPath() != "github.com/sagernet/sing/common/json/badjson" || !strings.HasPrefix(t.Name(), "TypedMap[") {
+		return nil
+	}
+	method, loaded := t.MethodByName("Get")
+	if !loaded || method.Type.NumOut() != 2 {
+		return nil
+	}
+	return method.Type.Out(0)
+}
@@ -0,0 +1,91 @@
+package schema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/schema"
+	"github.com/sagernet/sing-box/include"
+
+	"github.com/stretchr/testify/require"
+)
+
+func generate(t *testing.T) *schema.Schema {
+	configSchema, err := schema.Generate(include.Context(context.Background()))
+	require.NoError(t, err)
+	return configSchema
+}
+
+func TestValidateValid(t *testing.T) {
+	t.Parallel()
+	errors, err := schema.Validate(generate(t), []byte(`{
+  // comment
+  "log": {"level": "info"},
+  "dns": {
+    "servers": [
+      {"type": "udp", "tag": "google", "server": "8.8.8.8"},
+      {"tag": "legacy", "address": "tls://1.1.1.1"}
+    ],
+    "rules": [{"type": "logical", "mode": "or", "rules": [{"domain": "a.com"}], "action": "reject"}]
+  },
+  "inbounds": [{"type": "mixed", "tag": "mixed-in", "listen_port": 2080}],
+  "outbounds": [
+    {"type": "vmess", "tag": "vmess", "server": "a.com", "server_port": 443, "uuid": "bf000d23-0752-40b4-affe-68f7707a9661", "transport": {"type": "ws", "path": "/"}},
+    {"type": "direct", "tag": "direct", "domain_resolver": "google"}
+  ],
+  "route": {
+    "rules": [
+      {"action": "sniff"},
+      {"port": [80, 443], "network": "tcp", "outbound": "vmess"}
+    ],
+    "rule_set": [{"tag": "inline", "rules": [{"domain_suffix": ".cn"}]}]
+  }
+}`))
+	require.NoError(t, err)
+	require.Empty(t, errors)
+}
+
+func TestValidateHashComments(t *testing.T) {
+	t.Parallel()
+	errors, err := schema.Validate(generate(t), []byte(`{ # comment
+  "log": {"level": "info"}, # trailing comment
+  "inbounds": [{"type": "mixed", "tag": "mixed-in", "listen_port": "2080"}]
+}`))
+	require.NoError(t, err)
+	require.Len(t, errors, 1)
+	require.Equal(t, "3:53: /inbounds/0/listen_port: expected integer, got string", errors[0].Error())
+}
+
+func TestValidateErrors(t *testing.T) {
+	t.Parallel()
+	errors, err := schema.Validate(generate(t), []byte(`{
+  "inbounds": [
+    {"type": "mixed", "listen_port": "abc", "unknown": 1}
+  ],
+  "outbounds": [
+    {"type": "nope"},
+    {"type": "vmess", "transport": {}}
+  ],
+  "route": {
+    "rules": [{"action": "bad"}]
+  }
+}`))
+	require.NoError(t, err)
+	var messages []string
+	for _, validationError := range errors {
+		messages = append(messages, validationError.Error())
+	}
+	require.Equal(t, []string{
+		"3:23: /inbounds/0/listen_port: expected integer, got string",
+		"3:45: /inbounds/0/unknown: unknown field unknown",
+		"6:6: /outbounds/0/type: unknown type: nope",
+		"7:23: /outbounds/1/transport: missing field type",
+		"10:16: /route/rules/0/action: unknown action: bad",
+	}, messages)
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	t.Parallel()
+	_, err := schema.Validate(generate(t), []byte("{\n  \"log\": {,}\n}"))
+	require.ErrorContains(t, err, "row 2, column 11")
+}
@@ -0,0 +1,199 @@
+package schema
+
+import (
+	"context"
+	"reflect"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/byteformats"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badoption"
+	"github.com/sagernet/sing/service"
+)
+
+// union describes options whose fields depend on the value of a key.
+type union struct {
+	key          string
+	defaultValue string
+	variants     func(ctx context.Context, t reflect.Type) ([]string, []reflect.Type, error)
+}
+
+// fieldVariants maps each value to the type of a `json:"-"` field of the options.
+func fieldVariants(pairs ...string) func(ctx context.Context, t reflect.Type) ([]string, []reflect.Type, error) {
+	return func(ctx context.Context, t reflect.Type) ([]string, []reflect.Type, error) {
+		var (
+			values []string
+			types  []reflect.Type
+		)
+		for i := 0; i < len(pairs); i += 2 {
+			values = append(values, pairs[i])
+			if pairs[i+1] == "" {
+				types = append(types, nil)
+				continue
+			}
+			field, loaded := t.FieldByName(pairs[i+1])
+			if !loaded {
+				return nil, nil, E.New("missing field ", pairs[i+1])
+			}
+			types = append(types, field.Type)
+		}
+		return values, types, nil
+	}
+}
+
+type typeRegistry interface {
+	Types() []string
+	CreateOptions(optionsType string) (any, bool)
+}
+
+// registryVariants reads the variants from an options registry in the context,
+// extraValue is accepted with extraOptions in addition to the registered types.
+func registryVariants[T any](extraValue string, extraOptions any) func(ctx context.Context, t reflect.Type) ([]string, []reflect.Type, error) {
+	return func(ctx context.Context, t reflect.Type) ([]string, []reflect.Type, error) {
+		registry, isTypeRegistry := any(service.FromContext[T](ctx)).(typeRegistry)
+		if !isTypeRegistry {
+			return nil, nil, E.New("missing options registry in context")
+		}
+		var (
+			values []string
+			types  []reflect.Type
+		)
+		if extraValue != "" {
+			values = append(values, extraValue)
+			types = append(types, reflect.TypeOf(extraOptions))
+		}
+		for _, optionsType := range registry.Types() {
+			options, _ := registry.CreateOptions(optionsType)
+			values = append(values, optionsType)
+			types = append(types, reflect.TypeOf(options))
+		}
+		return values, types, nil
+	}
+}
+
+var unions = map[reflect.Type]union{
+	reflect.TypeOf(option.Inbound{}):  {key: "type", variants: registryVariants[option.InboundOptionsRegistry]("", nil)},
+	reflect.TypeOf(option.Outbound{}): {key: "type", variants: registryVariants[option.OutboundOptionsRegistry]("", nil)},
+	reflect.TypeOf(option.Endpoint{}): {key: "type", variants: registryVariants[option.EndpointOptionsRegistry]("", nil)},
+	reflect.TypeOf(option.Provider{}): {key: "type", variants: registryVariants[option.ProviderOptionsRegistry]("", nil)},
+	reflect.TypeOf(option.Service{}):  {key: "type", variants: registryVariants[option.ServiceOptionsRegistry]("", nil)},
+	reflect.TypeOf(option.DNSServerOptions{}): {
+		key: "type", defaultValue: C.DNSTypeLegacy,
+		variants: registryVariants[option.DNSTransportOptionsRegistry](C.DNSTypeLegacy, option.LegacyDNSServerOptions{}),
+	},
+	reflect.TypeOf(option.Rule{}): {
+		key: "type", defaultValue: C.RuleTypeDefault,
+		variants: fieldVariants(C.RuleTypeDefault, "DefaultOptions", C.RuleTypeLogical, "LogicalOptions"),
+	},
+	reflect.TypeOf(option.DNSRule{}): {
+		key: "type", defaultValue: C.RuleTypeDefault,
+		variants: fieldVariants(C.RuleTypeDefault, "DefaultOptions", C.RuleTypeLogical, "LogicalOptions"),
+	},
+	reflect.TypeOf(option.HeadlessRule{}): {
+		key: "type", defaultValue: C.RuleTypeDefault,
+		variants: fieldVariants(C.RuleTypeDefault, "DefaultOptions", C.RuleTypeLogical, "LogicalOptions"),
+	},
+	reflect.TypeOf(option.RuleSet{}): {
+		key: "type", defaultValue: C.RuleSetTypeInline,
+		variants: fieldVariants(
+			C.RuleSetTypeInline, "InlineOptions",
+			C.RuleSetTypeLocal, "LocalOptions",
+			C.RuleSetTypeRemote, "RemoteOptions",
+		),
+	},
+	reflect.TypeOf(option.RuleAction{}): {
+		key: "action", defaultValue: C.RuleActionTypeRoute,
+		variants: fieldVariants(
+			C.RuleActionTypeRoute, "RouteOptions",
+			C.RuleActionTypeRouteOptions, "RouteOptionsOptions",
+			C.RuleActionTypeDirect, "DirectOptions",
+			C.RuleActionTypeBypass, "BypassOptions",
+			C.RuleActionTypeReject, "RejectOptions",
+			C.RuleActionTypeHijackDNS, "",
+			C.RuleActionTypeMITM, "",
+			C.RuleActionTypeSniff, "SniffOptions",
+			C.RuleActionTypeResolve, "ResolveOptions",
+			C.RuleActionTypeHTTPRedirect, "HTTPRedirectOptions",
+			C.RuleActionTypeHTTPReject, "HTTPRejectOptions",
+			C.RuleActionTypeHTTPMock, "HTTPMockOptions",
+			C.RuleActionTypeHTTPHeader, "HTTPHeaderOptions",
+		),
+	},
+	reflect.TypeOf(option.DNSRuleAction{}): {
+		key: "action", defaultValue: C.RuleActionTypeRoute,
+		variants: fieldVariants(
+			C.RuleActionTypeRoute, "RouteOptions",
+			C.RuleActionTypeRouteOptions, "RouteOptionsOptions",
+			C.RuleActionTypeReject, "RejectOptions",
+			C.RuleActionTypePredefined, "PredefinedOptions",
+			C.RuleActionTypeRewrite, "RewriteOptions",
+		),
+	},
+	reflect.TypeOf(option.V2RayTransportOptions{}): {
+		key: "type",
+		variants: fieldVariants(
+			C.V2RayTransportTypeHTTP, "HTTPOptions",
+			C.V2RayTransportTypeWebsocket, "WebsocketOptions",
+			C.V2RayTransportTypeQUIC, "QUICOptions",
+			C.V2RayTransportTypeGRPC, "GRPCOptions",
+			C.V2RayTransportTypeHTTPUpgrade, "HTTPUpgradeOptions",
+			C.V2RayTransportTypeXHTTP, "XHTTPOptions",
+			C.V2RayTransportTypeKCP, "KCPOptions",
+		),
+	},
+	reflect.TypeOf(option.ACMEDNS01ChallengeOptions{}): {
+		key: "provider",
+		variants: fieldVariants(
+			C.DNSProviderAliDNS, "AliDNSOptions",
+			C.DNSProviderCloudflare, "CloudflareOptions",
+			C.DNSProviderACMEDNS, "ACMEDNSOptions",
+		),
+	},
+	reflect.TypeOf(option.Hysteria2Masquerade{}): {
+		key: "type",
+		variants: fieldVariants(
+			C.Hysterai2MasqueradeTypeFile, "FileOptions",
+			C.Hysterai2MasqueradeTypeProxy, "ProxyOptions",
+			C.Hysterai2MasqueradeTypeString, "StringOptions",
+		),
+	},
+}
+
+// shorthands are the JSON types accepted in place of the object form of options.
+var shorthands = map[reflect.Type]string{
+	reflect.TypeOf(option.UDPOverTCPOptions{}):          "boolean",
+	reflect.TypeOf(option.DomainResolveOptions{}):       "string",
+	reflect.TypeOf(option.DERPVerifyClientURLOptions{}): "string",
+	reflect.TypeOf(option.DERPSTUNListenOptions{}):      "integer",
+	reflect.TypeOf(option.Hysteria2Masquerade{}):        "string",
+}
+
+// overrides are types whose JSON form is not derived from their Go type.
+var overrides = map[reflect.Type]func() *Schema{
+	reflect.TypeOf(badoption.Duration(0)): func() *Schema {
+		return &Schema{Type: "string"}
+	},
+	reflect.TypeOf(json.RawMessage(nil)): func() *Schema {
+		return &Schema{}
+	},
+	reflect.TypeOf(option.Port(0)): func() *Schema {
+		return &Schema{Type: "integer"}
+	},
+	reflect.TypeOf(option.NetworkList("")): func() *Schema {
+		return listable(&Schema{Type: "string"})
+	},
+	reflect.TypeOf(option.DNSRecordOptions{}): func() *Schema {
+		return &Schema{Type: "string"}
+	},
+	reflect.TypeOf(byteformats.MemoryBytes{}): func() *Schema {
+		return &Schema{Type: []string{"integer", "string"}}
+	},
+	reflect.TypeOf(byteformats.NetworkBytes{}): func() *Schema {
+		return &Schema{Type: []string{"integer", "string"}}
+	},
+	reflect.TypeOf(byteformats.NetworkBytesCompat{}): func() *Schema {
+		return &Schema{Type: []string{"integer", "string"}}
+	},
+}
@@ -0,0 +1,288 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+// Error is a validation error at a JSON pointer of the document.
+type Error struct {
+	Pointer string
+	Message string
+	Line    int
+	Column  int
+	offset  int
+}
+
+func (e Error) Error() string {
+	var builder strings.Builder
+	if e.Line > 0 {
+		builder.WriteString(F.ToString(e.Line, ":", e.Column, ": "))
+	}
+	if e.Pointer != "" {
+		builder.WriteString(e.Pointer)
+		builder.WriteString(": ")
+	}
+	builder.WriteString(e.Message)
+	return builder.String()
+}
+
+// Validate validates a JSON document against the schema and returns all errors in document order.
+// Comments are allowed in content, lines and columns are counted from 1.
+func Validate(schema *Schema, content []byte) ([]Error, error) {
+	content = stripComments(content)
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			// Offset is counted after the invalid byte
+			line, column := position(content, max(int(syntaxError.Offset)-1, 0))
+			return nil, E.Extend(err, "row ", line, ", column ", column)
+		}
+		return nil, err
+	}
+	positions, err := locate(content)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{root: schema}
+	v.validate(schema, value, "")
+	for i := range v.errors {
+		validationError := &v.errors[i]
+		pointer := validationError.Pointer
+		for {
+			offset, loaded := positions[pointer]
+			if loaded {
+				validationError.offset = offset
+				validationError.Line, validationError.Column = position(content, offset)
+				break
+			}
+			if pointer == "" {
+				break
+			}
+			pointer = pointer[:strings.LastIndexByte(pointer, '/')]
+		}
+	}
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].offset < v.errors[j].offset
+	})
+	return v.errors, nil
+}
+
+type validator struct {
+	root   *Schema
+	errors []Error
+}
+
+func (v *validator) report(pointer string, message ...any) {
+	v.errors = append(v.errors, Error{Pointer: pointer, Message: F.ToString(message...)})
+}
+
+func (v *validator) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/$defs/")
+		definition, loaded := v.root.Defs[name]
+		if !loaded {
+			return &Schema{}
+		}
+		schema = definition
+	}
+	return schema
+}
+
+func (v *validator) validate(schema *Schema, value any, pointer string) {
+	schema = v.resolve(schema)
+	if value == nil {
+		return
+	}
+	if schema.discriminator != "" {
+		v.validateUnion(schema, value, pointer)
+		return
+	}
+	if len(schema.AnyOf) > 0 {
+		v.validateAnyOf(schema, value, pointer)
+		return
+	}
+	kind := valueKind(value)
+	if schema.Type != nil && !kindAllowed(schemaKinds(schema), kind) {
+		v.report(pointer, "expected ", describeKinds(schemaKinds(schema)), ", got ", kind)
+		return
+	}
+	if schema.Const != nil && value != schema.Const {
+		v.report(pointer, "expected ", schema.Const, ", got ", value)
+		return
+	}
+	switch typedValue := value.(type) {
+	case map[string]any:
+		for _, key := range schema.Required {
+			if _, loaded := typedValue[key]; !loaded {
+				v.report(pointer, "missing field ", key)
+			}
+		}
+		keys := make([]string, 0, len(typedValue))
+		for key := range typedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPointer := pointer + "/" + escapePointer(key)
+			if propertySchema := lookupProperty(schema.Properties, key); propertySchema != nil {
+				v.validate(propertySchema, typedValue[key], childPointer)
+				continue
+			}
+			switch additional := schema.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					v.report(childPointer, "unknown field ", key)
+				}
+			case *Schema:
+				v.validate(additional, typedValue[key], childPointer)
+			}
+		}
+	case []any:
+		if schema.Items != nil {
+			for index, element := range typedValue {
+				v.validate(schema.Items, element, F.ToString(pointer, "/", index))
+			}
+		}
+	}
+}
+
+func (v *validator) validateUnion(schema *Schema, value any, pointer string) {
+	object, isObject := value.(map[string]any)
+	if !isObject {
+		v.report(pointer, "expected object, got ", valueKind(value))
+		return
+	}
+	index := schema.defaultIndex
+	if rawValue, loaded := object[schema.discriminator]; loaded && rawValue != nil {
+		discriminator, isString := rawValue.(string)
+		keyPointer := pointer + "/" + escapePointer(schema.discriminator)
+		if !isString {
+			v.report(keyPointer, "expected string, got ", valueKind(rawValue))
+			return
+		}
+		index = -1
+		for i, variant := range schema.variants {
+			if variant == discriminator {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			v.report(keyPointer, "unknown ", schema.discriminator, ": ", discriminator)
+			return
+		}
+	}
+	if index == -1 {
+		v.report(pointer, "missing field ", schema.discriminator)
+		return
+	}
+	v.validate(schema.OneOf[index], value, pointer)
+}
+
+func (v *validator) validateAnyOf(schema *Schema, value any, pointer string) {
+	kind := valueKind(value)
+	var kinds []string
+	for _, branch := range schema.AnyOf {
+		branchKinds := schemaKinds(v.resolve(branch))
+		if kindAllowed(branchKinds, kind) {
+			v.validate(branch, value, pointer)
+			return
+		}
+		kinds = append(kinds, branchKinds...)
+	}
+	v.report(pointer, "expected ", describeKinds(kinds), ", got ", kind)
+}
+
+// lookupProperty matches keys case-insensitively as a fallback, like encoding/json.
+func lookupProperty(properties map[string]*Schema, key string) *Schema {
+	if propertySchema, loaded := properties[key]; loaded {
+		return propertySchema
+	}
+	for name, propertySchema := range properties {
+		if strings.EqualFold(name, key) {
+			return propertySchema
+		}
+	}
+	return nil
+}
+
+// schemaKinds returns the JSON types accepted by schema, nil for any type.
+func schemaKinds(schema *Schema) []string {
+	switch typeValue := schema.Type.(type) {
+	case string:
+		return []string{typeValue}
+	case []string:
+		return typeValue
+	}
+	if schema.discriminator != "" || schema.Properties != nil {
+		return []string{"object"}
+	}
+	if len(schema.AnyOf) > 0 {
+		var kinds []string
+		for _, branch := range schema.AnyOf {
+			branchKinds := schemaKinds(branch)
+			if branchKinds == nil {
+				return nil
+			}
+			kinds = append(kinds, branchKinds...)
+		}
+		return kinds
+	}
+	return nil
+}
+
+func kindAllowed(kinds []string, kind string) bool {
+	if kinds == nil {
+		return true
+	}
+	for _, allowed := range kinds {
+		if allowed == kind || allowed == "number" && kind == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+func describeKinds(kinds []string) string {
+	return strings.Join(kinds, " or ")
+}
+
+func valueKind(value any) string {
+	switch typedValue := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := typedValue.Int64(); err == nil {
+			return "integer"
+		}
+		if !strings.ContainsAny(typedValue.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
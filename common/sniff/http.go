@@ -5,24 +5,84 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
+	std_http "net/http"
+	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
 	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
 	"github.com/sagernet/sing/protocol/http"
 )
 
 func HTTPHost(_ context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
+	request, err := readHTTPRequest(reader)
+	if err != nil {
+		return err
+	}
+	metadata.Protocol = C.ProtocolHTTP
+	metadata.Domain = M.ParseSocksaddr(request.Host).AddrString()
+	setHTTPRequest(metadata, request)
+	return nil
+}
+
+// HTTPRequest records the request fields of plain HTTP requests
+// without setting the sniffed protocol and domain.
+func HTTPRequest(_ context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
+	request, err := readHTTPRequest(reader)
+	if err != nil {
+		return err
+	}
+	setHTTPRequest(metadata, request)
+	return nil
+}
+
+// HTTPProxyRequest reads the plain HTTP request that HTTP proxy servers
+// forward over in-memory pipes, without marking the connection as sniffed.
+func HTTPProxyRequest(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn) net.Conn {
+	if conn.LocalAddr().Network() != "pipe" {
+		return conn
+	}
+	buffer := buf.NewPacket()
+	_ = PeekStream(ctx, metadata, conn, nil, buffer, 0, HTTPRequest)
+	if buffer.IsEmpty() {
+		buffer.Release()
+		return conn
+	}
+	return bufio.NewCachedConn(conn, buffer)
+}
+
+func readHTTPRequest(reader io.Reader) (*std_http.Request, error) {
 	request, err := http.ReadRequest(std_bufio.NewReader(reader))
 	if err != nil {
 		if errors.Is(err, io.ErrUnexpectedEOF) {
-			return E.Cause1(ErrNeedMoreData, err)
+			return nil, E.Cause1(ErrNeedMoreData, err)
 		} else {
-			return err
+			return nil, err
 		}
 	}
-	metadata.Protocol = C.ProtocolHTTP
-	metadata.Domain = M.ParseSocksaddr(request.Host).AddrString()
-	return nil
+	return request, nil
+}
+
+func setHTTPRequest(metadata *adapter.InboundContext, request *std_http.Request) {
+	metadata.HTTPMethod = request.Method
+	metadata.HTTPHost = strings.ToLower(M.ParseSocksaddr(request.Host).AddrString())
+	if request.URL != nil {
+		metadata.HTTPPath = request.URL.Path
+		metadata.HTTPURL = httpRequestURL(request, metadata.MITM)
+	}
+	metadata.HTTPUserAgent = request.UserAgent()
+}
+
+// httpRequestURL returns the full URL of a plain HTTP request,
+// requests decrypted by MITM use the https scheme.
+func httpRequestURL(request *std_http.Request, mitm bool) string {
+	scheme := "http"
+	if mitm {
+		scheme = "https"
+	}
+	return scheme + "://" + request.Host + request.URL.RequestURI()
 }
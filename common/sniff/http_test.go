@@ -2,11 +2,13 @@ package sniff_test
 
 import (
 	"context"
+	"io"
 	"strings"
 	"testing"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/sniff"
+	"github.com/sagernet/sing/common/pipe"
 
 	"github.com/stretchr/testify/require"
 )
@@ -28,3 +30,35 @@ func TestSniffHTTP1WithPort(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, metadata.Domain, "www.gov.cn")
 }
+
+func TestSniffHTTPRequest(t *testing.T) {
+	t.Parallel()
+	pkt := "POST /simple/requests/?page=1 HTTP/1.1\r\nHost: PyPI.org:8080\r\nUser-Agent: pip/24.0\r\nContent-Length: 0\r\n\r\n"
+	var metadata adapter.InboundContext
+	err := sniff.HTTPHost(context.Background(), &metadata, strings.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, "POST", metadata.HTTPMethod)
+	require.Equal(t, "pypi.org", metadata.HTTPHost)
+	require.Equal(t, "/simple/requests/", metadata.HTTPPath)
+	require.Equal(t, "http://PyPI.org:8080/simple/requests/?page=1", metadata.HTTPURL)
+	require.Equal(t, "pip/24.0", metadata.HTTPUserAgent)
+}
+
+func TestSniffHTTPProxyRequest(t *testing.T) {
+	t.Parallel()
+	pkt := "GET /debian/dists/stable/Release HTTP/1.1\r\nHost: deb.debian.org\r\nUser-Agent: Debian APT-HTTP/1.3 (2.6.1)\r\n\r\n"
+	serverConn, clientConn := pipe.Pipe()
+	defer serverConn.Close()
+	go clientConn.Write([]byte(pkt))
+	var metadata adapter.InboundContext
+	conn := sniff.HTTPProxyRequest(context.Background(), &metadata, serverConn)
+	require.Empty(t, metadata.Protocol)
+	require.Equal(t, "GET", metadata.HTTPMethod)
+	require.Equal(t, "deb.debian.org", metadata.HTTPHost)
+	require.Equal(t, "/debian/dists/stable/Release", metadata.HTTPPath)
+	require.Equal(t, "Debian APT-HTTP/1.3 (2.6.1)", metadata.HTTPUserAgent)
+	buffer := make([]byte, len(pkt))
+	_, err := io.ReadFull(conn, buffer)
+	require.NoError(t, err)
+	require.Equal(t, pkt, string(buffer))
+}
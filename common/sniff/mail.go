@@ -0,0 +1,474 @@
+package sniff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const mailMaxLineLength = 1024
+
+// SMTP sniffs SMTP connections to 25, 465 and 587.
+// For STARTTLS, the greeting is emulated until the TLS client hello is received,
+// and then replayed to the real server, whose replies are discarded.
+func SMTP(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration) (net.Conn, error) {
+	switch metadata.Destination.Port {
+	case 465:
+		return sniffImplicitTLS(ctx, metadata, conn, timeout, C.ProtocolSMTP)
+	case 25, 587:
+		return sniffSTARTTLS(ctx, metadata, conn, timeout, &smtpDialogue{host: mailHost(metadata)})
+	default:
+		return conn, os.ErrInvalid
+	}
+}
+
+// IMAP sniffs IMAP connections to 143 and 993.
+func IMAP(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration) (net.Conn, error) {
+	switch metadata.Destination.Port {
+	case 993:
+		return sniffImplicitTLS(ctx, metadata, conn, timeout, C.ProtocolIMAP)
+	case 143:
+		return sniffSTARTTLS(ctx, metadata, conn, timeout, &imapDialogue{})
+	default:
+		return conn, os.ErrInvalid
+	}
+}
+
+// POP3 sniffs POP3 connections to 110 and 995.
+func POP3(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration) (net.Conn, error) {
+	switch metadata.Destination.Port {
+	case 995:
+		return sniffImplicitTLS(ctx, metadata, conn, timeout, C.ProtocolPOP3)
+	case 110:
+		return sniffSTARTTLS(ctx, metadata, conn, timeout, &pop3Dialogue{})
+	default:
+		return conn, os.ErrInvalid
+	}
+}
+
+func mailHost(metadata *adapter.InboundContext) string {
+	if metadata.Destination.IsFqdn() {
+		return metadata.Destination.Fqdn
+	}
+	return "localhost"
+}
+
+func sniffImplicitTLS(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration, protocol string) (net.Conn, error) {
+	buffer := buf.NewPacket()
+	err := PeekStream(ctx, metadata, conn, nil, buffer, timeout, TLSClientHello)
+	if !buffer.IsEmpty() {
+		conn = bufio.NewCachedConn(conn, buffer)
+	} else {
+		buffer.Release()
+	}
+	if err != nil {
+		return conn, err
+	}
+	metadata.Protocol = protocol
+	return conn, nil
+}
+
+type mailState int
+
+const (
+	// the command is answered by the emulated server
+	mailStateContinue mailState = iota
+	// the command is answered, and the TLS handshake follows
+	mailStateStartTLS
+	// the command belongs to the protocol but can not be answered
+	mailStateStop
+	mailStateInvalid
+)
+
+type mailDialogue interface {
+	Protocol() string
+	Greeting() string
+	Command(line string) (reply string, state mailState)
+	// Reply reports whether the server reply to the command is complete, the greeting has an empty command.
+	Reply(command string, line string) (done bool, err error)
+}
+
+func sniffSTARTTLS(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration, dialogue mailDialogue) (net.Conn, error) {
+	if _, isMailConn := conn.(*mailConn); isMailConn {
+		return conn, os.ErrInvalid
+	}
+	_, err := conn.Write([]byte(dialogue.Greeting()))
+	if err != nil {
+		return conn, err
+	}
+	wrapper := newMailConn(conn, dialogue)
+	var (
+		pending    []byte
+		identified bool
+		readBuffer = make([]byte, mailMaxLineLength)
+	)
+	readMore := func() error {
+		err := conn.SetReadDeadline(time.Now().Add(timeout))
+		if err != nil {
+			return err
+		}
+		n, err := conn.Read(readBuffer)
+		_ = conn.SetReadDeadline(time.Time{})
+		pending = append(pending, readBuffer[:n]...)
+		return err
+	}
+	for {
+		index := bytes.IndexByte(pending, '\n')
+		if index == -1 {
+			if len(pending) > mailMaxLineLength {
+				err = os.ErrInvalid
+				break
+			}
+			err = readMore()
+			if err != nil {
+				break
+			}
+			continue
+		}
+		line := pending[:index+1]
+		reply, state := dialogue.Command(string(line))
+		if state == mailStateInvalid {
+			if !identified {
+				err = os.ErrInvalid
+			}
+			break
+		}
+		identified = true
+		if state == mailStateStop {
+			break
+		}
+		wrapper.commands = append(wrapper.commands, line)
+		pending = pending[index+1:]
+		_, err = conn.Write([]byte(reply))
+		if err != nil {
+			break
+		}
+		if state == mailStateStartTLS {
+			err = sniffMailClientHello(ctx, metadata, &pending, readMore)
+			break
+		}
+	}
+	wrapper.tail = pending
+	if !identified {
+		return wrapper, E.Cause(err, "sniff ", dialogue.Protocol())
+	}
+	metadata.Protocol = dialogue.Protocol()
+	return wrapper, nil
+}
+
+func sniffMailClientHello(ctx context.Context, metadata *adapter.InboundContext, pending *[]byte, readMore func() error) error {
+	var tlsMetadata adapter.InboundContext
+	for {
+		if len(*pending) == 0 {
+			err := readMore()
+			if err != nil {
+				return err
+			}
+		}
+		err := TLSClientHello(ctx, &tlsMetadata, bytes.NewReader(*pending))
+		if err == nil {
+			metadata.Domain = tlsMetadata.Domain
+			metadata.JA3 = tlsMetadata.JA3
+			metadata.JA4 = tlsMetadata.JA4
+			return nil
+		} else if !errors.Is(err, ErrNeedMoreData) {
+			return err
+		}
+		err = readMore()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// mailConn replays the emulated dialogue to the real server, one command after each server reply,
+// and discards the replies since the client has received the emulated ones.
+type mailConn struct {
+	net.Conn
+	dialogue mailDialogue
+	commands [][]byte
+	tail     []byte
+	access   sync.Mutex
+	replies  int
+	notify   chan struct{}
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// accessed by the reader only
+	sent   int
+	offset int
+
+	// accessed by the writer only
+	lineBuffer []byte
+	writeDone  bool
+}
+
+func newMailConn(conn net.Conn, dialogue mailDialogue) *mailConn {
+	return &mailConn{
+		Conn:     conn,
+		dialogue: dialogue,
+		notify:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (c *mailConn) Read(p []byte) (n int, err error) {
+	for c.sent <= len(c.commands) {
+		err = c.waitReplies(c.sent + 1)
+		if err != nil {
+			return
+		}
+		var segment []byte
+		if c.sent < len(c.commands) {
+			segment = c.commands[c.sent]
+		} else {
+			segment = c.tail
+		}
+		n = copy(p, segment[c.offset:])
+		c.offset += n
+		if c.offset == len(segment) {
+			c.sent++
+			c.offset = 0
+		}
+		if n > 0 {
+			return
+		}
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *mailConn) waitReplies(count int) error {
+	for {
+		c.access.Lock()
+		replies := c.replies
+		notify := c.notify
+		c.access.Unlock()
+		if replies >= count {
+			return nil
+		}
+		select {
+		case <-notify:
+		case <-c.done:
+			return net.ErrClosed
+		}
+	}
+}
+
+func (c *mailConn) Write(p []byte) (n int, err error) {
+	if c.writeDone {
+		return c.Conn.Write(p)
+	}
+	c.lineBuffer = append(c.lineBuffer, p...)
+	for {
+		c.access.Lock()
+		replies := c.replies
+		c.access.Unlock()
+		if replies > len(c.commands) {
+			break
+		}
+		index := bytes.IndexByte(c.lineBuffer, '\n')
+		if index == -1 {
+			if len(c.lineBuffer) > mailMaxLineLength {
+				return 0, E.New(c.dialogue.Protocol(), ": server reply too long")
+			}
+			return len(p), nil
+		}
+		line := string(c.lineBuffer[:index+1])
+		c.lineBuffer = c.lineBuffer[index+1:]
+		var command string
+		if replies > 0 {
+			command = string(c.commands[replies-1])
+		}
+		done, err := c.dialogue.Reply(command, line)
+		if err != nil {
+			return 0, err
+		}
+		if done {
+			c.access.Lock()
+			c.replies++
+			close(c.notify)
+			c.notify = make(chan struct{})
+			c.access.Unlock()
+		}
+	}
+	c.writeDone = true
+	if len(c.lineBuffer) > 0 {
+		_, err = c.Conn.Write(c.lineBuffer)
+		c.lineBuffer = nil
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (c *mailConn) Close() error {
+	c.doneOnce.Do(func() {
+		close(c.done)
+	})
+	return c.Conn.Close()
+}
+
+func (c *mailConn) Upstream() any {
+	return c.Conn
+}
+
+func mailVerb(line string) string {
+	verb, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	return strings.ToUpper(verb)
+}
+
+type smtpDialogue struct {
+	host string
+}
+
+func (d *smtpDialogue) Protocol() string {
+	return C.ProtocolSMTP
+}
+
+func (d *smtpDialogue) Greeting() string {
+	return "220 " + d.host + " ESMTP\r\n"
+}
+
+func (d *smtpDialogue) Command(line string) (string, mailState) {
+	switch mailVerb(line) {
+	case "EHLO":
+		return "250-" + d.host + "\r\n250 STARTTLS\r\n", mailStateContinue
+	case "HELO":
+		return "250 " + d.host + "\r\n", mailStateContinue
+	case "NOOP", "RSET":
+		return "250 OK\r\n", mailStateContinue
+	case "STARTTLS":
+		return "220 Ready to start TLS\r\n", mailStateStartTLS
+	case "AUTH", "MAIL", "VRFY", "HELP", "QUIT":
+		return "", mailStateStop
+	default:
+		return "", mailStateInvalid
+	}
+}
+
+func (d *smtpDialogue) Reply(command string, line string) (bool, error) {
+	if len(line) < 4 || !isDigits(line[:3]) {
+		return false, E.New("smtp: invalid server reply")
+	}
+	if line[3] == '-' {
+		return false, nil
+	}
+	if line[0] != '2' {
+		return false, E.New("smtp: server rejected ", strings.TrimSpace(command), ": ", strings.TrimSpace(line))
+	}
+	return true, nil
+}
+
+type imapDialogue struct{}
+
+func (d *imapDialogue) Protocol() string {
+	return C.ProtocolIMAP
+}
+
+func (d *imapDialogue) Greeting() string {
+	return "* OK [CAPABILITY IMAP4rev1 STARTTLS LOGINDISABLED] ready\r\n"
+}
+
+func (d *imapDialogue) Command(line string) (string, mailState) {
+	tag, command, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if tag == "" || strings.ContainsAny(tag, "(){%*\"\\]+") {
+		return "", mailStateInvalid
+	}
+	switch mailVerb(command) {
+	case "CAPABILITY":
+		return "* CAPABILITY IMAP4rev1 STARTTLS LOGINDISABLED\r\n" + tag + " OK CAPABILITY completed\r\n", mailStateContinue
+	case "NOOP":
+		return tag + " OK NOOP completed\r\n", mailStateContinue
+	case "STARTTLS":
+		return tag + " OK Begin TLS negotiation now\r\n", mailStateStartTLS
+	case "LOGIN", "AUTHENTICATE", "ID", "ENABLE", "LOGOUT":
+		return "", mailStateStop
+	default:
+		return "", mailStateInvalid
+	}
+}
+
+func (d *imapDialogue) Reply(command string, line string) (bool, error) {
+	if command == "" {
+		if !strings.HasPrefix(line, "* OK") {
+			return false, E.New("imap: unexpected greeting: ", strings.TrimSpace(line))
+		}
+		return true, nil
+	}
+	tag, _, _ := strings.Cut(strings.TrimSpace(command), " ")
+	if !strings.HasPrefix(line, tag+" ") {
+		return false, nil
+	}
+	if mailVerb(line[len(tag)+1:]) != "OK" {
+		return false, E.New("imap: server rejected ", strings.TrimSpace(command), ": ", strings.TrimSpace(line))
+	}
+	return true, nil
+}
+
+type pop3Dialogue struct {
+	multiline bool
+}
+
+func (d *pop3Dialogue) Protocol() string {
+	return C.ProtocolPOP3
+}
+
+func (d *pop3Dialogue) Greeting() string {
+	return "+OK POP3 ready\r\n"
+}
+
+func (d *pop3Dialogue) Command(line string) (string, mailState) {
+	switch mailVerb(line) {
+	case "CAPA":
+		return "+OK\r\nSTLS\r\nUSER\r\n.\r\n", mailStateContinue
+	case "STLS":
+		return "+OK Begin TLS negotiation\r\n", mailStateStartTLS
+	case "USER", "APOP", "AUTH", "QUIT":
+		return "", mailStateStop
+	default:
+		return "", mailStateInvalid
+	}
+}
+
+func (d *pop3Dialogue) Reply(command string, line string) (bool, error) {
+	if d.multiline {
+		if strings.TrimSpace(line) == "." {
+			d.multiline = false
+			return true, nil
+		}
+		return false, nil
+	}
+	if mailVerb(command) == "CAPA" {
+		if strings.HasPrefix(line, "+OK") {
+			d.multiline = true
+			return false, nil
+		}
+		// the client has received the emulated capabilities
+		return true, nil
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return false, E.New("pop3: server rejected ", strings.TrimSpace(command), ": ", strings.TrimSpace(line))
+	}
+	return true, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
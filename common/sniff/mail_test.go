@@ -0,0 +1,156 @@
+package sniff_test
+
+import (
+	std_bufio "bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mailExchange struct {
+	command string
+	// the reply of the emulated server ends with this line
+	replyEnd string
+	// the reply of the real server
+	serverReply string
+}
+
+func testSniffSTARTTLS(t *testing.T, sniffer sniff.ServerFirstSniffer, destination string, protocol string, serverGreeting string, exchanges []mailExchange) {
+	clientConn, sniffConn := net.Pipe()
+	defer clientConn.Close()
+	defer sniffConn.Close()
+	go func() {
+		reader := std_bufio.NewReader(clientConn)
+		_, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		for _, exchange := range exchanges {
+			_, err = clientConn.Write([]byte(exchange.command))
+			if err != nil {
+				return
+			}
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.HasPrefix(line, exchange.replyEnd) {
+					break
+				}
+			}
+		}
+		tls.Client(clientConn, &tls.Config{ServerName: "mail.example.com"}).Handshake()
+	}()
+	metadata := adapter.InboundContext{Destination: M.ParseSocksaddr(destination)}
+	conn, err := sniffer(context.Background(), &metadata, sniffConn, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, protocol, metadata.Protocol)
+	require.Equal(t, "mail.example.com", metadata.Domain)
+
+	// replay to the real server
+	_, err = conn.Write([]byte(serverGreeting))
+	require.NoError(t, err)
+	reader := std_bufio.NewReader(conn)
+	for _, exchange := range exchanges {
+		command, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, exchange.command, command)
+		_, err = conn.Write([]byte(exchange.serverReply))
+		require.NoError(t, err)
+	}
+	var recordHeader [5]byte
+	_, err = io.ReadFull(reader, recordHeader[:])
+	require.NoError(t, err)
+	require.Equal(t, byte(0x16), recordHeader[0])
+}
+
+func TestSniffSMTPSTARTTLS(t *testing.T) {
+	t.Parallel()
+	testSniffSTARTTLS(t, sniff.SMTP, "mail.example.com:587", C.ProtocolSMTP, "220-mx.example.com ESMTP\r\n220 ready\r\n", []mailExchange{
+		{"EHLO client.example.com\r\n", "250 ", "250-mx.example.com\r\n250-PIPELINING\r\n250-SIZE 10240000\r\n250 STARTTLS\r\n"},
+		{"STARTTLS\r\n", "220 ", "220 2.0.0 Ready to start TLS\r\n"},
+	})
+}
+
+func TestSniffIMAPSTARTTLS(t *testing.T) {
+	t.Parallel()
+	testSniffSTARTTLS(t, sniff.IMAP, "mail.example.com:143", C.ProtocolIMAP, "* OK [CAPABILITY IMAP4rev1 STARTTLS] Dovecot ready.\r\n", []mailExchange{
+		{"a1 CAPABILITY\r\n", "a1 ", "* CAPABILITY IMAP4rev1 SASL-IR STARTTLS LOGINDISABLED\r\na1 OK Pre-login capabilities listed.\r\n"},
+		{"a2 STARTTLS\r\n", "a2 ", "a2 OK Begin TLS negotiation now.\r\n"},
+	})
+}
+
+func TestSniffPOP3STARTTLS(t *testing.T) {
+	t.Parallel()
+	testSniffSTARTTLS(t, sniff.POP3, "mail.example.com:110", C.ProtocolPOP3, "+OK Dovecot ready.\r\n", []mailExchange{
+		{"CAPA\r\n", ".", "+OK\r\nCAPA\r\nTOP\r\nUIDL\r\nSTLS\r\n.\r\n"},
+		{"STLS\r\n", "+OK", "+OK Begin TLS negotiation now.\r\n"},
+	})
+}
+
+func TestSniffSMTPRejected(t *testing.T) {
+	t.Parallel()
+	clientConn, sniffConn := net.Pipe()
+	defer clientConn.Close()
+	defer sniffConn.Close()
+	go func() {
+		reader := std_bufio.NewReader(clientConn)
+		reader.ReadString('\n')
+		clientConn.Write([]byte("EHLO client.example.com\r\n"))
+		reader.ReadString('\n')
+		reader.ReadString('\n')
+		clientConn.Write([]byte("STARTTLS\r\n"))
+		reader.ReadString('\n')
+		tls.Client(clientConn, &tls.Config{ServerName: "mail.example.com"}).Handshake()
+	}()
+	metadata := adapter.InboundContext{Destination: M.ParseSocksaddr("mail.example.com:25")}
+	conn, err := sniff.SMTP(context.Background(), &metadata, sniffConn, time.Second)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("554 5.7.1 rejected\r\n"))
+	require.Error(t, err)
+}
+
+func TestSniffNotSMTP(t *testing.T) {
+	t.Parallel()
+	clientConn, sniffConn := net.Pipe()
+	defer clientConn.Close()
+	defer sniffConn.Close()
+	go func() {
+		reader := std_bufio.NewReader(clientConn)
+		reader.ReadString('\n')
+		clientConn.Write([]byte("GET / HTTP/1.1\r\n"))
+	}()
+	metadata := adapter.InboundContext{Destination: M.ParseSocksaddr("1.1.1.1:25")}
+	conn, err := sniff.SMTP(context.Background(), &metadata, sniffConn, time.Second)
+	require.Error(t, err)
+	require.Empty(t, metadata.Protocol)
+
+	// the consumed data is replayed after the greeting of the real server
+	_, err = conn.Write([]byte("220 mx.example.com ESMTP\r\n"))
+	require.NoError(t, err)
+	line, err := std_bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "GET / HTTP/1.1\r\n", line)
+}
+
+func TestSniffSMTPOtherPort(t *testing.T) {
+	t.Parallel()
+	_, sniffConn := net.Pipe()
+	defer sniffConn.Close()
+	metadata := adapter.InboundContext{Destination: M.ParseSocksaddr("1.1.1.1:2525")}
+	conn, err := sniff.SMTP(context.Background(), &metadata, sniffConn, time.Second)
+	require.Error(t, err)
+	require.Equal(t, sniffConn, conn)
+}
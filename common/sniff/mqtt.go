@@ -0,0 +1,201 @@
+package sniff
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+const (
+	mqttPacketConnect   = 1
+	mqttPacketPublish   = 3
+	mqttPacketSubscribe = 8
+)
+
+func MQTT(_ context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return ErrNeedMoreData
+	}
+	if content[0] != mqttPacketConnect<<4 {
+		return os.ErrInvalid
+	}
+	_, payload, remaining, err := mqttReadPacket(content)
+	if err != nil {
+		return err
+	}
+	parser := mqttParser{content: payload}
+	protocolName, err := parser.readString()
+	if err != nil {
+		return err
+	}
+	level, err := parser.readByte()
+	if err != nil {
+		return err
+	}
+	switch {
+	case protocolName == "MQTT" && (level == 4 || level == 5):
+	case protocolName == "MQIsdp" && level == 3:
+	default:
+		return os.ErrInvalid
+	}
+	flags, err := parser.readByte()
+	if err != nil {
+		return err
+	}
+	if flags&0x01 != 0 {
+		return os.ErrInvalid
+	}
+	err = parser.skip(2)
+	if err != nil {
+		return err
+	}
+	if level == 5 {
+		err = parser.skipProperties()
+		if err != nil {
+			return err
+		}
+	}
+	clientID, err := parser.readString()
+	if err != nil {
+		return err
+	}
+	var topic string
+	if flags&0x04 != 0 {
+		if level == 5 {
+			err = parser.skipProperties()
+			if err != nil {
+				return err
+			}
+		}
+		topic, err = parser.readString()
+		if err != nil {
+			return err
+		}
+	}
+	if topic == "" && len(remaining) > 0 {
+		// clients may send the first PUBLISH or SUBSCRIBE without waiting for CONNACK
+		topic = mqttReadTopic(remaining, level)
+	}
+	metadata.Protocol = C.ProtocolMQTT
+	metadata.MQTTClientID = clientID
+	if topic != "" {
+		metadata.Topic, _, _ = strings.Cut(topic, "/")
+	}
+	return nil
+}
+
+func mqttReadPacket(content []byte) (packetType byte, payload []byte, remaining []byte, err error) {
+	if len(content) < 2 {
+		return 0, nil, nil, ErrNeedMoreData
+	}
+	packetType = content[0]
+	var length, multiplier int
+	multiplier = 1
+	index := 1
+	for {
+		if index == len(content) {
+			return 0, nil, nil, ErrNeedMoreData
+		}
+		if index > 4 {
+			return 0, nil, nil, os.ErrInvalid
+		}
+		encodedByte := content[index]
+		index++
+		length += int(encodedByte&0x7F) * multiplier
+		multiplier *= 128
+		if encodedByte&0x80 == 0 {
+			break
+		}
+	}
+	if len(content) < index+length {
+		return 0, nil, nil, ErrNeedMoreData
+	}
+	return packetType, content[index : index+length], content[index+length:], nil
+}
+
+func mqttReadTopic(content []byte, level byte) string {
+	packetType, payload, _, err := mqttReadPacket(content)
+	if err != nil {
+		return ""
+	}
+	parser := mqttParser{content: payload}
+	switch packetType >> 4 {
+	case mqttPacketPublish:
+		topic, _ := parser.readString()
+		return topic
+	case mqttPacketSubscribe:
+		if parser.skip(2) != nil {
+			return ""
+		}
+		if level == 5 && parser.skipProperties() != nil {
+			return ""
+		}
+		topic, _ := parser.readString()
+		return topic
+	default:
+		return ""
+	}
+}
+
+type mqttParser struct {
+	content []byte
+}
+
+func (p *mqttParser) readByte() (byte, error) {
+	if len(p.content) < 1 {
+		return 0, os.ErrInvalid
+	}
+	value := p.content[0]
+	p.content = p.content[1:]
+	return value, nil
+}
+
+func (p *mqttParser) skip(n int) error {
+	if len(p.content) < n {
+		return os.ErrInvalid
+	}
+	p.content = p.content[n:]
+	return nil
+}
+
+func (p *mqttParser) readString() (string, error) {
+	if len(p.content) < 2 {
+		return "", os.ErrInvalid
+	}
+	length := int(binary.BigEndian.Uint16(p.content))
+	if len(p.content) < 2+length {
+		return "", os.ErrInvalid
+	}
+	value := string(p.content[2 : 2+length])
+	p.content = p.content[2+length:]
+	return value, nil
+}
+
+func (p *mqttParser) skipProperties() error {
+	var length, multiplier int
+	multiplier = 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return os.ErrInvalid
+		}
+		encodedByte, err := p.readByte()
+		if err != nil {
+			return err
+		}
+		length += int(encodedByte&0x7F) * multiplier
+		multiplier *= 128
+		if encodedByte&0x80 == 0 {
+			break
+		}
+	}
+	return p.skip(length)
+}
@@ -0,0 +1,75 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffMQTT(t *testing.T) {
+	t.Parallel()
+
+	// MQTT 3.1.1 CONNECT followed by a PUBLISH sent before CONNACK
+	pkt, err := hex.DecodeString("101500044d5154540402003c000973656e736f722d3031301c0016646576696365732f73656e736f722d30312f74656d7032312e35")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.MQTT(context.TODO(), &metadata, bytes.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolMQTT, metadata.Protocol)
+	require.Equal(t, "sensor-01", metadata.MQTTClientID)
+	require.Equal(t, "devices", metadata.Topic)
+}
+
+func TestSniffMQTT5Will(t *testing.T) {
+	t.Parallel()
+
+	pkt, err := hex.DecodeString("103700044d5154540506003c05110000003c000973656e736f722d30320000107374617475732f73656e736f722d303200076f66666c696e65")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.MQTT(context.TODO(), &metadata, bytes.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolMQTT, metadata.Protocol)
+	require.Equal(t, "sensor-02", metadata.MQTTClientID)
+	require.Equal(t, "status", metadata.Topic)
+}
+
+func TestSniffMQTT31(t *testing.T) {
+	t.Parallel()
+
+	pkt, err := hex.DecodeString("101400064d51497364700302003c00066c6567616379")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.MQTT(context.TODO(), &metadata, bytes.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolMQTT, metadata.Protocol)
+	require.Equal(t, "legacy", metadata.MQTTClientID)
+	require.Empty(t, metadata.Topic)
+}
+
+func TestSniffIncompleteMQTT(t *testing.T) {
+	t.Parallel()
+
+	pkt, err := hex.DecodeString("101500044d515454")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.MQTT(context.TODO(), &metadata, bytes.NewReader(pkt))
+	require.ErrorIs(t, err, sniff.ErrNeedMoreData)
+}
+
+func TestSniffNotMQTT(t *testing.T) {
+	t.Parallel()
+
+	pkt, err := hex.DecodeString("101500044d5154540902003c000973656e736f722d3031")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.MQTT(context.TODO(), &metadata, bytes.NewReader(pkt))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, sniff.ErrNeedMoreData)
+}
@@ -0,0 +1,133 @@
+package sniff
+
+import (
+	std_bufio "bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/protocol/http"
+)
+
+// SOCKS sniffs SOCKS4/4a requests and SOCKS5 greetings sent to a nested proxy.
+func SOCKS(_ context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if len(content) == 0 {
+		return ErrNeedMoreData
+	}
+	switch content[0] {
+	case 4:
+		err = sniffSOCKS4(content)
+	case 5:
+		err = sniffSOCKS5(content)
+	default:
+		return os.ErrInvalid
+	}
+	if err != nil {
+		return err
+	}
+	metadata.Protocol = C.ProtocolSOCKS
+	return nil
+}
+
+func sniffSOCKS4(content []byte) error {
+	if len(content) < 2 {
+		return ErrNeedMoreData
+	}
+	// CONNECT or BIND
+	if content[1] != 1 && content[1] != 2 {
+		return os.ErrInvalid
+	}
+	if len(content) < 9 {
+		return ErrNeedMoreData
+	}
+	const maxFieldLength = 255
+	userID := content[8:]
+	userIDEnd := indexByte(userID, 0, maxFieldLength)
+	if userIDEnd == -1 {
+		return ErrNeedMoreData
+	} else if userIDEnd == -2 {
+		return os.ErrInvalid
+	}
+	remaining := userID[userIDEnd+1:]
+	// SOCKS4a: 0.0.0.x with x != 0, followed by the domain
+	if content[4] == 0 && content[5] == 0 && content[6] == 0 && content[7] != 0 {
+		domainEnd := indexByte(remaining, 0, maxFieldLength)
+		if domainEnd == -1 {
+			return ErrNeedMoreData
+		} else if domainEnd <= 0 {
+			return os.ErrInvalid
+		}
+		remaining = remaining[domainEnd+1:]
+	}
+	// the client must wait for the reply
+	if len(remaining) > 0 {
+		return os.ErrInvalid
+	}
+	return nil
+}
+
+func sniffSOCKS5(content []byte) error {
+	if len(content) < 2 {
+		return ErrNeedMoreData
+	}
+	methodCount := int(content[1])
+	if methodCount == 0 {
+		return os.ErrInvalid
+	}
+	if len(content) < 2+methodCount {
+		return ErrNeedMoreData
+	}
+	// the client must wait for the method selection
+	if len(content) > 2+methodCount {
+		return os.ErrInvalid
+	}
+	for _, method := range content[2:] {
+		// assigned by IANA, or reserved for private methods
+		if method > 0x09 && method < 0x80 || method == 0xFF {
+			return os.ErrInvalid
+		}
+	}
+	return nil
+}
+
+// indexByte returns -1 if more data is needed, or -2 if the field is too long.
+func indexByte(content []byte, value byte, limit int) int {
+	for i, b := range content {
+		if i > limit {
+			return -2
+		}
+		if b == value {
+			return i
+		}
+	}
+	if len(content) > limit {
+		return -2
+	}
+	return -1
+}
+
+// HTTPConnect sniffs HTTP CONNECT requests sent to a nested proxy.
+// The target of the tunnel is not the destination of the connection, so no domain is set.
+func HTTPConnect(_ context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
+	request, err := http.ReadRequest(std_bufio.NewReader(reader))
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return E.Cause1(ErrNeedMoreData, err)
+		} else {
+			return err
+		}
+	}
+	if request.Method != "CONNECT" {
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolHTTPConnect
+	return nil
+}
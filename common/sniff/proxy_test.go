@@ -0,0 +1,82 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffSOCKS(t *testing.T) {
+	t.Parallel()
+
+	for _, fixture := range []string{
+		// SOCKS5 greeting with no authentication
+		"050100",
+		// SOCKS5 greeting with no authentication and username/password
+		"05020002",
+		// SOCKS4 CONNECT 1.1.1.1:80
+		"040100500101010100",
+		// SOCKS4a CONNECT example.com:443
+		"040101bb000000017573657200" + hex.EncodeToString([]byte("example.com")) + "00",
+	} {
+		pkt, err := hex.DecodeString(fixture)
+		require.NoError(t, err)
+		var metadata adapter.InboundContext
+		err = sniff.SOCKS(context.TODO(), &metadata, bytes.NewReader(pkt))
+		require.NoError(t, err, fixture)
+		require.Equal(t, C.ProtocolSOCKS, metadata.Protocol)
+	}
+}
+
+func TestSniffIncompleteSOCKS(t *testing.T) {
+	t.Parallel()
+
+	for _, fixture := range []string{"05", "050200", "040101bb00000001757365"} {
+		pkt, err := hex.DecodeString(fixture)
+		require.NoError(t, err)
+		var metadata adapter.InboundContext
+		err = sniff.SOCKS(context.TODO(), &metadata, bytes.NewReader(pkt))
+		require.ErrorIs(t, err, sniff.ErrNeedMoreData, fixture)
+	}
+}
+
+func TestSniffNotSOCKS(t *testing.T) {
+	t.Parallel()
+
+	for _, fixture := range []string{"050100ff", "050150", "0500", "040300500101010100"} {
+		pkt, err := hex.DecodeString(fixture)
+		require.NoError(t, err)
+		var metadata adapter.InboundContext
+		err = sniff.SOCKS(context.TODO(), &metadata, bytes.NewReader(pkt))
+		require.Error(t, err, fixture)
+		require.NotErrorIs(t, err, sniff.ErrNeedMoreData, fixture)
+	}
+}
+
+func TestSniffHTTPConnect(t *testing.T) {
+	t.Parallel()
+
+	pkt := "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\nProxy-Connection: keep-alive\r\n\r\n"
+	var metadata adapter.InboundContext
+	err := sniff.HTTPConnect(context.TODO(), &metadata, strings.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolHTTPConnect, metadata.Protocol)
+	require.Empty(t, metadata.Domain)
+}
+
+func TestSniffNotHTTPConnect(t *testing.T) {
+	t.Parallel()
+
+	pkt := "GET / HTTP/1.1\r\nHost: www.google.com\r\nAccept: */*\r\n\r\n"
+	var metadata adapter.InboundContext
+	err := sniff.HTTPConnect(context.TODO(), &metadata, strings.NewReader(pkt))
+	require.Error(t, err)
+}
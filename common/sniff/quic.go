@@ -307,6 +307,8 @@ find:
 		return E.Cause1(ErrNeedMoreData, err)
 	}
 	metadata.Domain = fingerprint.ServerName
+	metadata.JA3 = fingerprint.Hash()
+	metadata.JA4 = fingerprint.JA4(ja3.JA4ProtocolQUIC)
 	for metadata.Client == "" {
 		if len(frameTypeList) == 1 {
 			metadata.Client = C.ClientFirefox
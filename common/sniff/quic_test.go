@@ -30,6 +30,8 @@ func TestSniffQUICChromeNew(t *testing.T) {
 	err = sniff.QUICClientHello(context.Background(), &metadata, pkt)
 	require.NoError(t, err)
 	require.Equal(t, "www.google.com", metadata.Domain)
+	require.Equal(t, "9b6a31ccd6b280c5e0f6922bf133c0dd", metadata.JA3)
+	require.Equal(t, "q13d0313h3_55b375c5d22e_c7319ce65786", metadata.JA4)
 }
 
 func TestSniffQUICChromium(t *testing.T) {
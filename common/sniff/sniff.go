@@ -18,6 +18,9 @@ import (
 type (
 	StreamSniffer = func(ctx context.Context, metadata *adapter.InboundContext, reader io.Reader) error
 	PacketSniffer = func(ctx context.Context, metadata *adapter.InboundContext, packet []byte) error
+	// ServerFirstSniffer returns the input conn if it is not touched,
+	// otherwise the returned conn must be used even if sniffing fails.
+	ServerFirstSniffer = func(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration) (net.Conn, error)
 )
 
 var ErrNeedMoreData = E.New("need more data")
@@ -75,6 +78,21 @@ func PeekStream(ctx context.Context, metadata *adapter.InboundContext, conn net.
 	return sniffError
 }
 
+func PeekServerFirst(ctx context.Context, metadata *adapter.InboundContext, conn net.Conn, timeout time.Duration, sniffers ...ServerFirstSniffer) (net.Conn, error) {
+	if timeout == 0 {
+		timeout = C.ReadPayloadTimeout
+	}
+	var sniffError error
+	for _, sniffer := range sniffers {
+		newConn, err := sniffer(ctx, metadata, conn, timeout)
+		if err == nil || newConn != conn {
+			return newConn, err
+		}
+		sniffError = E.Errors(sniffError, err)
+	}
+	return conn, sniffError
+}
+
 func PeekPacket(ctx context.Context, metadata *adapter.InboundContext, packet []byte, sniffers ...PacketSniffer) error {
 	var sniffError []error
 	for _, sniffer := range sniffers {
@@ -1,20 +1,25 @@
 package sniff
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
 	"io"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ja3"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common/bufio"
 	E "github.com/sagernet/sing/common/exceptions"
 )
 
 func TLSClientHello(ctx context.Context, metadata *adapter.InboundContext, reader io.Reader) error {
-	var clientHello *tls.ClientHelloInfo
-	err := tls.Server(bufio.NewReadOnlyConn(reader), &tls.Config{
+	var (
+		clientHello *tls.ClientHelloInfo
+		record      bytes.Buffer
+	)
+	err := tls.Server(bufio.NewReadOnlyConn(io.TeeReader(reader, &record)), &tls.Config{
 		GetConfigForClient: func(argHello *tls.ClientHelloInfo) (*tls.Config, error) {
 			clientHello = argHello
 			return nil, nil
@@ -23,6 +28,11 @@ func TLSClientHello(ctx context.Context, metadata *adapter.InboundContext, reade
 	if clientHello != nil {
 		metadata.Protocol = C.ProtocolTLS
 		metadata.Domain = clientHello.ServerName
+		fingerprint, err := ja3.Compute(record.Bytes())
+		if err == nil {
+			metadata.JA3 = fingerprint.Hash()
+			metadata.JA4 = fingerprint.JA4(ja3.JA4ProtocolTCP)
+		}
 		return nil
 	}
 	if errors.Is(err, io.ErrUnexpectedEOF) {
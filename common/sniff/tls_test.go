@@ -0,0 +1,28 @@
+package sniff_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffTLSFingerprint(t *testing.T) {
+	t.Parallel()
+	// crypto/tls client hello with a GREASE cipher suite prepended
+	pkt, err := hex.DecodeString("160301012c010001280303e2ecf0967d2f7a4436d0245f60b15e945c011067fd72d701cf13c950fcd7f7e9205fd2f35a174ba5bdcc698a4475e9ac65b16ff588e281511513c6768ebbc64646001c0a0ac02bc02fc02cc030cca9cca8c009c013c00ac014130113021303010000c300000010000e00000b6578616d706c652e636f6d000b00020100ff010001000017000000120000000500050100000000000a00060004001d0017000d001c001a090409050906080404030807080508060401050106010503060300320020001e0904090509060804040308070805080604010501060105030603020102030010000e000c02683208687474702f312e31002b00050403040303003300260024001d002012f1b7214d843cfd05d582cea5af024b6666b6c95acfde2438c5250d732e551c")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.TLSClientHello(context.Background(), &metadata, bytes.NewReader(pkt))
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolTLS, metadata.Protocol)
+	require.Equal(t, "example.com", metadata.Domain)
+	require.Equal(t, "47b824e952130fdd8e43c4e03399d50d", metadata.JA3)
+	require.Equal(t, "t13d1312h2_f57a46bbacb6_f50d94e863eb", metadata.JA4)
+}
@@ -0,0 +1,27 @@
+package sniff
+
+import (
+	"context"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+)
+
+const (
+	wireGuardMessageInitiation     = 1
+	wireGuardMessageInitiationSize = 148
+)
+
+func WireGuard(_ context.Context, metadata *adapter.InboundContext, packet []byte) error {
+	// only the handshake initiation is accepted, since it is the first packet of a flow
+	// and transport data messages are indistinguishable from random data
+	if len(packet) != wireGuardMessageInitiationSize {
+		return os.ErrInvalid
+	}
+	if packet[0] != wireGuardMessageInitiation || packet[1] != 0 || packet[2] != 0 || packet[3] != 0 {
+		return os.ErrInvalid
+	}
+	metadata.Protocol = C.ProtocolWireGuard
+	return nil
+}
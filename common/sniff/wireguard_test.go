@@ -0,0 +1,34 @@
+package sniff_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffWireGuardHandshakeInitiation(t *testing.T) {
+	t.Parallel()
+
+	packet, err := hex.DecodeString("010000006e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d4bf5122f344554c53bde2ebb8cd2b7e3d1600ad631c385a5d7cce23c7785459adbc1b4c900ffe48d575b5da5c638040125f65db0fe3e24494b76ea986457d986084fed08b978af4d7d196a7446a86b58009e636b611db16211b65a9aadff29c5e52d9c508c502347344d8c07ad91cbd6")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.WireGuard(context.Background(), &metadata, packet)
+	require.NoError(t, err)
+	require.Equal(t, C.ProtocolWireGuard, metadata.Protocol)
+}
+
+func TestSniffWireGuardTransportData(t *testing.T) {
+	t.Parallel()
+
+	packet, err := hex.DecodeString("040000006e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d4bf5122f344554c53bde2ebb8cd2b7e3d1600ad631c385a5d7cce23c7785459adbc1b4c900ffe48d575b5da5c638040125f65db0fe3e24494b76ea986457d986084fed08b978af4d7d196a7446a86b58009e636b611db16211b65a9aadff29c5e52d9c508c502347344d8c07ad91cbd6")
+	require.NoError(t, err)
+	var metadata adapter.InboundContext
+	err = sniff.WireGuard(context.Background(), &metadata, packet)
+	require.Error(t, err)
+}
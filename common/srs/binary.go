@@ -2,12 +2,16 @@ package srs
 
 import (
 	"bufio"
+	"bytes"
 	"compress/zlib"
 	"encoding/binary"
 	"io"
+	"math"
 	"net/netip"
+	"os"
 	"unsafe"
 
+	"github.com/sagernet/sing-box/common/srs/mapped"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
@@ -46,10 +50,91 @@ const (
 	ruleItemNetworkIsConstrained
 	ruleItemNetworkInterfaceAddress
 	ruleItemDefaultInterfaceAddress
+	ruleItemJA3
+	ruleItemJA4
+	ruleItemHTTPMethod
+	ruleItemHTTPHost
+	ruleItemHTTPPathPrefix
+	ruleItemHTTPPathRegex
+	ruleItemHTTPUserAgentKeyword
+	ruleItemHTTPUserAgentRegex
+	ruleItemSourceIPASN
+	ruleItemIPASN
+	ruleItemSourceIPCountry
+	ruleItemIPCountry
 	ruleItemFinal uint8 = 0xFF
 )
 
 func Read(reader io.Reader, recover bool) (ruleSetCompat option.PlainRuleSetCompat, err error) {
+	version, err := readHeader(reader)
+	if err != nil {
+		return
+	}
+	if version >= C.RuleSetVersion7 {
+		var content []byte
+		content, err = io.ReadAll(reader)
+		if err != nil {
+			return
+		}
+		return readMapped(mapped.NewReader(nil, content), version, recover)
+	}
+	compressReader, err := zlib.NewReader(reader)
+	if err != nil {
+		return
+	}
+	return readRules(bufio.NewReader(compressReader), version, recover)
+}
+
+// ReadBytes reads a rule-set from content. Rule-sets in the mapped layout
+// are queried in place and keep a reference to content.
+func ReadBytes(content []byte, recover bool) (ruleSetCompat option.PlainRuleSetCompat, err error) {
+	return readBytes(nil, content, recover)
+}
+
+// ReadFile reads a rule-set from the file at path. Rule-sets in the mapped layout
+// are memory-mapped and queried in place, so the file must be replaced rather
+// than rewritten while in use.
+func ReadFile(path string, recover bool) (ruleSetCompat option.PlainRuleSetCompat, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	version, err := readHeader(file)
+	if err != nil || version < C.RuleSetVersion7 {
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err == nil {
+			ruleSetCompat, err = Read(file, recover)
+		}
+		file.Close()
+		return
+	}
+	file.Close()
+	mappedFile, err := mapped.Open(path)
+	if err != nil {
+		return
+	}
+	return readBytes(mappedFile, mappedFile.Bytes(), recover)
+}
+
+func readBytes(file *mapped.File, content []byte, recover bool) (ruleSetCompat option.PlainRuleSetCompat, err error) {
+	reader := bytes.NewReader(content)
+	version, err := readHeader(reader)
+	if err != nil {
+		return
+	}
+	if version < C.RuleSetVersion7 {
+		_, err = reader.Seek(0, io.SeekStart)
+		if err != nil {
+			return
+		}
+		return Read(reader, recover)
+	}
+	return readMapped(mapped.NewReader(file, content[len(content)-reader.Len():]), version, recover)
+}
+
+func readHeader(reader io.Reader) (version uint8, err error) {
 	var magicBytes [3]byte
 	_, err = io.ReadFull(reader, magicBytes[:])
 	if err != nil {
@@ -59,27 +144,36 @@ func Read(reader io.Reader, recover bool) (ruleSetCompat option.PlainRuleSetComp
 		err = E.New("invalid sing-box rule-set file")
 		return
 	}
-	var version uint8
 	err = binary.Read(reader, binary.BigEndian, &version)
 	if err != nil {
-		return ruleSetCompat, err
+		return
 	}
 	if version > C.RuleSetVersionCurrent {
-		return ruleSetCompat, E.New("unsupported version: ", version)
+		err = E.New("unsupported version: ", version)
 	}
-	compressReader, err := zlib.NewReader(reader)
+	return
+}
+
+func readMapped(reader *mapped.Reader, version uint8, recover bool) (ruleSetCompat option.PlainRuleSetCompat, err error) {
+	ruleSetCompat, err = readRules(reader, version, recover)
 	if err != nil {
 		return
 	}
-	bReader := bufio.NewReader(compressReader)
-	length, err := binary.ReadUvarint(bReader)
+	if _, err = reader.ReadByte(); err != io.EOF {
+		return ruleSetCompat, E.New("unexpected trailing data")
+	}
+	return ruleSetCompat, nil
+}
+
+func readRules(reader varbin.Reader, version uint8, recover bool) (ruleSetCompat option.PlainRuleSetCompat, err error) {
+	length, err := binary.ReadUvarint(reader)
 	if err != nil {
 		return
 	}
 	ruleSetCompat.Version = version
 	ruleSetCompat.Options.Rules = make([]option.HeadlessRule, length)
 	for i := uint64(0); i < length; i++ {
-		ruleSetCompat.Options.Rules[i], err = readRule(bReader, recover)
+		ruleSetCompat.Options.Rules[i], err = readRule(reader, version, recover)
 		if err != nil {
 			err = E.Cause(err, "read rule[", i, "]")
 			return
@@ -97,9 +191,15 @@ func Write(writer io.Writer, ruleSet option.PlainRuleSet, generateVersion uint8)
 	if err != nil {
 		return err
 	}
-	compressWriter, err := zlib.NewWriterLevel(writer, zlib.BestCompression)
-	if err != nil {
-		return err
+	// the mapped layout is stored uncompressed to be queried in place
+	var compressWriter io.WriteCloser
+	if generateVersion >= C.RuleSetVersion7 {
+		compressWriter = nopWriteCloser{writer}
+	} else {
+		compressWriter, err = zlib.NewWriterLevel(writer, zlib.BestCompression)
+		if err != nil {
+			return err
+		}
 	}
 	bWriter := bufio.NewWriter(compressWriter)
 	_, err = varbin.WriteUvarint(bWriter, uint64(len(ruleSet.Rules)))
@@ -119,7 +219,15 @@ func Write(writer io.Writer, ruleSet option.PlainRuleSet, generateVersion uint8)
 	return compressWriter.Close()
 }
 
-func readRule(reader varbin.Reader, recover bool) (rule option.HeadlessRule, err error) {
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func readRule(reader varbin.Reader, version uint8, recover bool) (rule option.HeadlessRule, err error) {
 	var ruleType uint8
 	err = binary.Read(reader, binary.BigEndian, &ruleType)
 	if err != nil {
@@ -128,10 +236,10 @@ func readRule(reader varbin.Reader, recover bool) (rule option.HeadlessRule, err
 	switch ruleType {
 	case 0:
 		rule.Type = C.RuleTypeDefault
-		rule.DefaultOptions, err = readDefaultRule(reader, recover)
+		rule.DefaultOptions, err = readDefaultRule(reader, version, recover)
 	case 1:
 		rule.Type = C.RuleTypeLogical
-		rule.LogicalOptions, err = readLogicalRule(reader, recover)
+		rule.LogicalOptions, err = readLogicalRule(reader, version, recover)
 	default:
 		err = E.New("unknown rule type: ", ruleType)
 	}
@@ -149,7 +257,7 @@ func writeRule(writer varbin.Writer, rule option.HeadlessRule, generateVersion u
 	}
 }
 
-func readDefaultRule(reader varbin.Reader, recover bool) (rule option.DefaultHeadlessRule, err error) {
+func readDefaultRule(reader varbin.Reader, version uint8, recover bool) (rule option.DefaultHeadlessRule, err error) {
 	var lastItemType uint8
 	for {
 		var itemType uint8
@@ -170,6 +278,18 @@ func readDefaultRule(reader varbin.Reader, recover bool) (rule option.DefaultHea
 		case ruleItemNetwork:
 			rule.Network, err = readRuleItemString(reader)
 		case ruleItemDomain:
+			if version >= C.RuleSetVersion7 {
+				var matcher *mapped.DomainMatcher
+				matcher, err = mapped.ReadDomainMatcher(reader.(*mapped.Reader))
+				if err != nil {
+					return
+				}
+				rule.MappedDomainMatcher = matcher
+				if recover {
+					rule.Domain, rule.DomainSuffix = matcher.Dump()
+				}
+				break
+			}
 			var matcher *domain.Matcher
 			matcher, err = domain.ReadMatcher(reader)
 			if err != nil {
@@ -184,6 +304,10 @@ func readDefaultRule(reader varbin.Reader, recover bool) (rule option.DefaultHea
 		case ruleItemDomainRegex:
 			rule.DomainRegex, err = readRuleItemString(reader)
 		case ruleItemSourceIPCIDR:
+			if version >= C.RuleSetVersion7 {
+				rule.MappedSourceIPSet, rule.SourceIPCIDR, err = readMappedIPSet(reader, recover)
+				break
+			}
 			rule.SourceIPSet, err = readIPSet(reader)
 			if err != nil {
 				return
@@ -192,6 +316,10 @@ func readDefaultRule(reader varbin.Reader, recover bool) (rule option.DefaultHea
 				rule.SourceIPCIDR = common.Map(rule.SourceIPSet.Prefixes(), netip.Prefix.String)
 			}
 		case ruleItemIPCIDR:
+			if version >= C.RuleSetVersion7 {
+				rule.MappedIPSet, rule.IPCIDR, err = readMappedIPSet(reader, recover)
+				break
+			}
 			rule.IPSet, err = readIPSet(reader)
 			if err != nil {
 				return
@@ -280,6 +408,30 @@ func readDefaultRule(reader varbin.Reader, recover bool) (rule option.DefaultHea
 				value = append(value, common.Ptr(badoption.Prefixable(prefix)))
 			}
 			rule.DefaultInterfaceAddress = value
+		case ruleItemJA3:
+			rule.JA3, err = readRuleItemString(reader)
+		case ruleItemJA4:
+			rule.JA4, err = readRuleItemString(reader)
+		case ruleItemHTTPMethod:
+			rule.HTTPMethod, err = readRuleItemString(reader)
+		case ruleItemHTTPHost:
+			rule.HTTPHost, err = readRuleItemString(reader)
+		case ruleItemHTTPPathPrefix:
+			rule.HTTPPathPrefix, err = readRuleItemString(reader)
+		case ruleItemHTTPPathRegex:
+			rule.HTTPPathRegex, err = readRuleItemString(reader)
+		case ruleItemHTTPUserAgentKeyword:
+			rule.HTTPUserAgentKeyword, err = readRuleItemString(reader)
+		case ruleItemHTTPUserAgentRegex:
+			rule.HTTPUserAgentRegex, err = readRuleItemString(reader)
+		case ruleItemSourceIPASN:
+			rule.SourceIPASN, err = readRuleItemASN(reader)
+		case ruleItemIPASN:
+			rule.IPASN, err = readRuleItemASN(reader)
+		case ruleItemSourceIPCountry:
+			rule.SourceIPCountry, err = readRuleItemString(reader)
+		case ruleItemIPCountry:
+			rule.IPCountry, err = readRuleItemString(reader)
 		case ruleItemFinal:
 			err = binary.Read(reader, binary.BigEndian, &rule.Invert)
 			return
@@ -317,7 +469,12 @@ func writeDefaultRule(writer varbin.Writer, rule option.DefaultHeadlessRule, gen
 		if err != nil {
 			return err
 		}
-		err = domain.NewMatcher(rule.Domain, rule.DomainSuffix, generateVersion == C.RuleSetVersion1).Write(writer)
+		matcher := domain.NewMatcher(rule.Domain, rule.DomainSuffix, generateVersion == C.RuleSetVersion1)
+		if generateVersion >= C.RuleSetVersion7 {
+			err = mapped.WriteDomainMatcher(writer, matcher)
+		} else {
+			err = matcher.Write(writer)
+		}
 		if err != nil {
 			return err
 		}
@@ -335,13 +492,13 @@ func writeDefaultRule(writer varbin.Writer, rule option.DefaultHeadlessRule, gen
 		}
 	}
 	if len(rule.SourceIPCIDR) > 0 {
-		err = writeRuleItemCIDR(writer, ruleItemSourceIPCIDR, rule.SourceIPCIDR)
+		err = writeRuleItemCIDR(writer, ruleItemSourceIPCIDR, rule.SourceIPCIDR, generateVersion)
 		if err != nil {
 			return E.Cause(err, "source_ip_cidr")
 		}
 	}
 	if len(rule.IPCIDR) > 0 {
-		err = writeRuleItemCIDR(writer, ruleItemIPCIDR, rule.IPCIDR)
+		err = writeRuleItemCIDR(writer, ruleItemIPCIDR, rule.IPCIDR, generateVersion)
 		if err != nil {
 			return E.Cause(err, "ipcidr")
 		}
@@ -469,6 +626,114 @@ func writeDefaultRule(writer varbin.Writer, rule option.DefaultHeadlessRule, gen
 			}
 		}
 	}
+	if len(rule.JA3) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`ja3` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemJA3, rule.JA3)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.JA4) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`ja4` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemJA4, rule.JA4)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.HTTPMethod) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`http_method` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemHTTPMethod, rule.HTTPMethod)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.HTTPHost) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`http_host` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemHTTPHost, rule.HTTPHost)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.HTTPPathPrefix) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`http_path_prefix` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemHTTPPathPrefix, rule.HTTPPathPrefix)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.HTTPPathRegex) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`http_path_regex` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemHTTPPathRegex, rule.HTTPPathRegex)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.HTTPUserAgentKeyword) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`http_user_agent_keyword` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemHTTPUserAgentKeyword, rule.HTTPUserAgentKeyword)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.HTTPUserAgentRegex) > 0 {
+		if generateVersion < C.RuleSetVersion5 {
+			return E.New("`http_user_agent_regex` rule item is only supported in version 5 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemHTTPUserAgentRegex, rule.HTTPUserAgentRegex)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.SourceIPASN) > 0 {
+		if generateVersion < C.RuleSetVersion6 {
+			return E.New("`source_ip_asn` rule item is only supported in version 6 or later")
+		}
+		err = writeRuleItemASN(writer, ruleItemSourceIPASN, rule.SourceIPASN)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.IPASN) > 0 {
+		if generateVersion < C.RuleSetVersion6 {
+			return E.New("`ip_asn` rule item is only supported in version 6 or later")
+		}
+		err = writeRuleItemASN(writer, ruleItemIPASN, rule.IPASN)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.SourceIPCountry) > 0 {
+		if generateVersion < C.RuleSetVersion6 {
+			return E.New("`source_ip_country` rule item is only supported in version 6 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemSourceIPCountry, rule.SourceIPCountry)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.IPCountry) > 0 {
+		if generateVersion < C.RuleSetVersion6 {
+			return E.New("`ip_country` rule item is only supported in version 6 or later")
+		}
+		err = writeRuleItemString(writer, ruleItemIPCountry, rule.IPCountry)
+		if err != nil {
+			return err
+		}
+	}
 	if len(rule.WIFISSID) > 0 {
 		err = writeRuleItemString(writer, ruleItemWIFISSID, rule.WIFISSID)
 		if err != nil {
@@ -599,7 +864,44 @@ func writeRuleItemUint16(writer varbin.Writer, itemType uint8, value []uint16) e
 	return binary.Write(writer, binary.BigEndian, value)
 }
 
-func writeRuleItemCIDR(writer varbin.Writer, itemType uint8, value []string) error {
+func readRuleItemASN(reader varbin.Reader) ([]option.ASN, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	var result []option.ASN
+	for i := uint64(0); i < length; i++ {
+		number, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		if number > math.MaxUint32 {
+			return nil, E.New("invalid ASN: ", number)
+		}
+		result = append(result, option.ASN(number))
+	}
+	return result, nil
+}
+
+func writeRuleItemASN(writer varbin.Writer, itemType uint8, value []option.ASN) error {
+	err := writer.WriteByte(itemType)
+	if err != nil {
+		return err
+	}
+	_, err = varbin.WriteUvarint(writer, uint64(len(value)))
+	if err != nil {
+		return err
+	}
+	for _, number := range value {
+		_, err = varbin.WriteUvarint(writer, uint64(number))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRuleItemCIDR(writer varbin.Writer, itemType uint8, value []string, generateVersion uint8) error {
 	var builder netipx.IPSetBuilder
 	for i, prefixString := range value {
 		prefix, err := netip.ParsePrefix(prefixString)
@@ -622,10 +924,25 @@ func writeRuleItemCIDR(writer varbin.Writer, itemType uint8, value []string) err
 	if err != nil {
 		return err
 	}
+	if generateVersion >= C.RuleSetVersion7 {
+		return mapped.WriteIPSet(writer, ipSet)
+	}
 	return writeIPSet(writer, ipSet)
 }
 
-func readLogicalRule(reader varbin.Reader, recovery bool) (logicalRule option.LogicalHeadlessRule, err error) {
+func readMappedIPSet(reader varbin.Reader, recover bool) (*mapped.IPSet, []string, error) {
+	ipSet, err := mapped.ReadIPSet(reader.(*mapped.Reader))
+	if err != nil || !recover {
+		return ipSet, nil, err
+	}
+	decodedSet, err := ipSet.IPSet()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ipSet, common.Map(decodedSet.Prefixes(), netip.Prefix.String), nil
+}
+
+func readLogicalRule(reader varbin.Reader, version uint8, recovery bool) (logicalRule option.LogicalHeadlessRule, err error) {
 	mode, err := reader.ReadByte()
 	if err != nil {
 		return
@@ -645,7 +962,7 @@ func readLogicalRule(reader varbin.Reader, recovery bool) (logicalRule option.Lo
 	}
 	logicalRule.Rules = make([]option.HeadlessRule, length)
 	for i := uint64(0); i < length; i++ {
-		logicalRule.Rules[i], err = readRule(reader, recovery)
+		logicalRule.Rules[i], err = readRule(reader, version, recovery)
 		if err != nil {
 			err = E.Cause(err, "read logical rule [", i, "]")
 			return
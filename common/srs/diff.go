@@ -0,0 +1,376 @@
+package srs
+
+import (
+	"net/netip"
+	"reflect"
+	"slices"
+	"sort"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"go4.org/netipx"
+)
+
+// AddressItems are the items of a rule that a rule-set update usually changes.
+type AddressItems struct {
+	Domain        []string
+	DomainSuffix  []string
+	DomainKeyword []string
+	DomainRegex   []string
+	IPCIDR        []string
+	SourceIPCIDR  []string
+}
+
+func (i AddressItems) IsEmpty() bool {
+	return len(i.Domain) == 0 && len(i.DomainSuffix) == 0 && len(i.DomainKeyword) == 0 &&
+		len(i.DomainRegex) == 0 && len(i.IPCIDR) == 0 && len(i.SourceIPCIDR) == 0
+}
+
+// RuleDiff describes the changes of the rule at Path.
+type RuleDiff struct {
+	Path    string
+	Added   AddressItems
+	Removed AddressItems
+	// OtherChanged is set if items other than AddressItems, the logical mode
+	// or invert differ.
+	OtherChanged bool
+}
+
+// Diff compares the rules of two rule-sets and returns the changed rules.
+// Rules are aligned by their items other than AddressItems before comparing,
+// so that an inserted, removed or moved rule does not change the paths of the others.
+// Paths use the index in to, or in from for removed rules.
+// Rules must be recovered (see Read) if they are loaded from binary.
+func Diff(from []option.HeadlessRule, to []option.HeadlessRule) ([]RuleDiff, error) {
+	var diffs []RuleDiff
+	err := diffRules(&diffs, "rules", from, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+// rulePair is an aligned pair of rules, an index is -1 if the rule is added or removed.
+type rulePair struct {
+	from int
+	to   int
+}
+
+func diffRules(diffs *[]RuleDiff, path string, from []option.HeadlessRule, to []option.HeadlessRule) error {
+	pairs, err := alignRules(from, to)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		var fromRule, toRule option.HeadlessRule
+		var index int
+		switch {
+		case pair.from == -1:
+			toRule, index = to[pair.to], pair.to
+			fromRule = emptyRule(toRule)
+		case pair.to == -1:
+			fromRule, index = from[pair.from], pair.from
+			toRule = emptyRule(fromRule)
+		default:
+			fromRule, toRule, index = from[pair.from], to[pair.to], pair.to
+		}
+		err = diffRule(diffs, F.ToString(path, "[", index, "]"), fromRule, toRule)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alignRules matches rules with the same items other than AddressItems in order,
+// preferring pairs sharing more address items.
+// Unmatched rules between two matched pairs are paired by position as changed rules,
+// and a removed rule equal to an added one is treated as moved and dropped.
+func alignRules(from []option.HeadlessRule, to []option.HeadlessRule) ([]rulePair, error) {
+	fromKeys := common.Map(from, addressKeys)
+	toKeys := common.Map(to, addressKeys)
+	score := make([][]int, len(from)+1)
+	for i := range score {
+		score[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			score[i][j] = max(score[i+1][j], score[i][j+1])
+			if sameShape(from[i], to[j]) {
+				score[i][j] = max(score[i][j], score[i+1][j+1]+1+sharedKeys(fromKeys[i], toKeys[j]))
+			}
+		}
+	}
+	var (
+		pairs          []rulePair
+		removed, added []int
+		i, j           int
+	)
+	flushGap := func(fromEnd int, toEnd int) {
+		for ; i < fromEnd && j < toEnd; i, j = i+1, j+1 {
+			pairs = append(pairs, rulePair{i, j})
+		}
+		for ; i < fromEnd; i++ {
+			removed = append(removed, len(pairs))
+			pairs = append(pairs, rulePair{i, -1})
+		}
+		for ; j < toEnd; j++ {
+			added = append(added, len(pairs))
+			pairs = append(pairs, rulePair{-1, j})
+		}
+	}
+	for fromIndex, toIndex := 0, 0; fromIndex < len(from) && toIndex < len(to); {
+		current := score[fromIndex][toIndex]
+		switch {
+		case current == score[fromIndex+1][toIndex]:
+			fromIndex++
+		case current == score[fromIndex][toIndex+1]:
+			toIndex++
+		default:
+			flushGap(fromIndex, toIndex)
+			pairs = append(pairs, rulePair{i, j})
+			i, j = i+1, j+1
+			fromIndex, toIndex = i, j
+		}
+	}
+	flushGap(len(from), len(to))
+	dropped := make(map[int]bool)
+	for _, removedIndex := range removed {
+		for _, addedIndex := range added {
+			if dropped[addedIndex] {
+				continue
+			}
+			var moveDiffs []RuleDiff
+			err := diffRule(&moveDiffs, "", from[pairs[removedIndex].from], to[pairs[addedIndex].to])
+			if err != nil {
+				return nil, err
+			}
+			if len(moveDiffs) == 0 {
+				dropped[removedIndex] = true
+				dropped[addedIndex] = true
+				break
+			}
+		}
+	}
+	if len(dropped) == 0 {
+		return pairs, nil
+	}
+	alignedPairs := make([]rulePair, 0, len(pairs)-len(dropped))
+	for index, pair := range pairs {
+		if !dropped[index] {
+			alignedPairs = append(alignedPairs, pair)
+		}
+	}
+	return alignedPairs, nil
+}
+
+// sameShape reports whether two rules may be aligned,
+// which requires the same type and the same items other than AddressItems, mode and sub-rules.
+func sameShape(from option.HeadlessRule, to option.HeadlessRule) bool {
+	fromType, toType := ruleType(from), ruleType(to)
+	if fromType != toType {
+		return false
+	}
+	if fromType == C.RuleTypeLogical {
+		return from.LogicalOptions.Mode == to.LogicalOptions.Mode && from.LogicalOptions.Invert == to.LogicalOptions.Invert
+	}
+	return reflect.DeepEqual(otherItems(from.DefaultOptions), otherItems(to.DefaultOptions))
+}
+
+// addressKeys collects the AddressItems of a rule and its sub-rules as prefixed strings.
+func addressKeys(rule option.HeadlessRule) map[string]bool {
+	keys := make(map[string]bool)
+	var collect func(rule option.HeadlessRule)
+	collect = func(rule option.HeadlessRule) {
+		if ruleType(rule) == C.RuleTypeLogical {
+			for _, subRule := range rule.LogicalOptions.Rules {
+				collect(subRule)
+			}
+			return
+		}
+		for _, items := range []struct {
+			prefix string
+			value  []string
+		}{
+			{"domain:", rule.DefaultOptions.Domain},
+			{"domain_suffix:", rule.DefaultOptions.DomainSuffix},
+			{"domain_keyword:", rule.DefaultOptions.DomainKeyword},
+			{"domain_regex:", rule.DefaultOptions.DomainRegex},
+			{"ip_cidr:", rule.DefaultOptions.IPCIDR},
+			{"source_ip_cidr:", rule.DefaultOptions.SourceIPCIDR},
+		} {
+			for _, item := range items.value {
+				keys[items.prefix+item] = true
+			}
+		}
+	}
+	collect(rule)
+	return keys
+}
+
+func sharedKeys(from map[string]bool, to map[string]bool) int {
+	if len(from) > len(to) {
+		from, to = to, from
+	}
+	var shared int
+	for key := range from {
+		if to[key] {
+			shared++
+		}
+	}
+	return shared
+}
+
+func diffRule(diffs *[]RuleDiff, path string, from option.HeadlessRule, to option.HeadlessRule) error {
+	fromType, toType := ruleType(from), ruleType(to)
+	if fromType == C.RuleTypeLogical && toType == C.RuleTypeLogical {
+		if from.LogicalOptions.Mode != to.LogicalOptions.Mode || from.LogicalOptions.Invert != to.LogicalOptions.Invert {
+			*diffs = append(*diffs, RuleDiff{Path: path, OtherChanged: true})
+		}
+		return diffRules(diffs, path+".rules", from.LogicalOptions.Rules, to.LogicalOptions.Rules)
+	}
+	if fromType != toType {
+		// a rule replaced with one of another type is reported as removed and added
+		err := diffRule(diffs, path, from, emptyRule(from))
+		if err != nil {
+			return err
+		}
+		return diffRule(diffs, path, emptyRule(to), to)
+	}
+	fromItems, toItems := from.DefaultOptions, to.DefaultOptions
+	ruleDiff := RuleDiff{Path: path}
+	ruleDiff.Added.Domain, ruleDiff.Removed.Domain = diffStrings(fromItems.Domain, toItems.Domain)
+	ruleDiff.Added.DomainSuffix, ruleDiff.Removed.DomainSuffix = diffStrings(fromItems.DomainSuffix, toItems.DomainSuffix)
+	ruleDiff.Added.DomainKeyword, ruleDiff.Removed.DomainKeyword = diffStrings(fromItems.DomainKeyword, toItems.DomainKeyword)
+	ruleDiff.Added.DomainRegex, ruleDiff.Removed.DomainRegex = diffStrings(fromItems.DomainRegex, toItems.DomainRegex)
+	var err error
+	ruleDiff.Added.IPCIDR, ruleDiff.Removed.IPCIDR, err = diffPrefixes(fromItems.IPCIDR, toItems.IPCIDR)
+	if err != nil {
+		return E.Cause(err, path, ".ip_cidr")
+	}
+	ruleDiff.Added.SourceIPCIDR, ruleDiff.Removed.SourceIPCIDR, err = diffPrefixes(fromItems.SourceIPCIDR, toItems.SourceIPCIDR)
+	if err != nil {
+		return E.Cause(err, path, ".source_ip_cidr")
+	}
+	ruleDiff.OtherChanged = !reflect.DeepEqual(otherItems(fromItems), otherItems(toItems))
+	if !ruleDiff.Added.IsEmpty() || !ruleDiff.Removed.IsEmpty() || ruleDiff.OtherChanged {
+		*diffs = append(*diffs, ruleDiff)
+	}
+	return nil
+}
+
+func ruleType(rule option.HeadlessRule) string {
+	if rule.Type == C.RuleTypeLogical {
+		return C.RuleTypeLogical
+	}
+	return C.RuleTypeDefault
+}
+
+func emptyRule(rule option.HeadlessRule) option.HeadlessRule {
+	return option.HeadlessRule{
+		Type: rule.Type,
+		DefaultOptions: option.DefaultHeadlessRule{
+			Invert: rule.DefaultOptions.Invert,
+		},
+		LogicalOptions: option.LogicalHeadlessRule{
+			Mode:   rule.LogicalOptions.Mode,
+			Invert: rule.LogicalOptions.Invert,
+		},
+	}
+}
+
+func otherItems(rule option.DefaultHeadlessRule) option.DefaultHeadlessRule {
+	rule.Domain = nil
+	rule.DomainSuffix = nil
+	rule.DomainKeyword = nil
+	rule.DomainRegex = nil
+	rule.IPCIDR = nil
+	rule.SourceIPCIDR = nil
+	rule.DomainMatcher = nil
+	rule.IPSet = nil
+	rule.SourceIPSet = nil
+	rule.AdGuardDomainMatcher = nil
+	rule.MappedDomainMatcher = nil
+	rule.MappedIPSet = nil
+	rule.MappedSourceIPSet = nil
+	return rule
+}
+
+func diffStrings(from []string, to []string) (added []string, removed []string) {
+	fromMap := make(map[string]bool, len(from))
+	for _, item := range from {
+		fromMap[item] = true
+	}
+	toMap := make(map[string]bool, len(to))
+	for _, item := range to {
+		toMap[item] = true
+	}
+	for _, item := range to {
+		if !fromMap[item] {
+			added = append(added, item)
+		}
+	}
+	for _, item := range from {
+		if !toMap[item] {
+			removed = append(removed, item)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return slices.Compact(added), slices.Compact(removed)
+}
+
+// diffPrefixes compares the address ranges covered by from and to,
+// so that merged or split prefixes are not reported as changes.
+func diffPrefixes(from []string, to []string) (added []string, removed []string, err error) {
+	fromSet, err := parseIPSet(from)
+	if err != nil {
+		return
+	}
+	toSet, err := parseIPSet(to)
+	if err != nil {
+		return
+	}
+	added, err = subtractIPSet(toSet, fromSet)
+	if err != nil {
+		return
+	}
+	removed, err = subtractIPSet(fromSet, toSet)
+	return
+}
+
+func parseIPSet(prefixList []string) (*netipx.IPSet, error) {
+	var builder netipx.IPSetBuilder
+	for _, prefixString := range prefixList {
+		prefix, err := netip.ParsePrefix(prefixString)
+		if err == nil {
+			builder.AddPrefix(prefix)
+			continue
+		}
+		address, addrErr := netip.ParseAddr(prefixString)
+		if addrErr != nil {
+			return nil, err
+		}
+		builder.Add(address)
+	}
+	return builder.IPSet()
+}
+
+func subtractIPSet(set *netipx.IPSet, other *netipx.IPSet) ([]string, error) {
+	var builder netipx.IPSetBuilder
+	builder.AddSet(set)
+	builder.RemoveSet(other)
+	result, err := builder.IPSet()
+	if err != nil {
+		return nil, err
+	}
+	prefixes := result.Prefixes()
+	if len(prefixes) == 0 {
+		return nil, nil
+	}
+	return common.Map(prefixes, netip.Prefix.String), nil
+}
@@ -0,0 +1,178 @@
+package srs
+
+import (
+	"bytes"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	from := []option.HeadlessRule{
+		{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain:        []string{"example.com", "example.net"},
+				DomainSuffix:  []string{"example.org"},
+				DomainKeyword: []string{"ads"},
+				IPCIDR:        []string{"10.0.0.0/24", "10.0.1.0/24", "192.168.0.1"},
+			},
+		},
+		{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeAnd,
+				Rules: []option.HeadlessRule{
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{DomainRegex: []string{`^a\.`}}},
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Port: []uint16{443}}},
+				},
+			},
+		},
+	}
+	to := []option.HeadlessRule{
+		{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain:        []string{"example.com", "example.io"},
+				DomainSuffix:  []string{"example.org"},
+				DomainKeyword: []string{"ads"},
+				IPCIDR:        []string{"10.0.0.0/23", "192.168.0.2/32"},
+			},
+		},
+		{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeAnd,
+				Rules: []option.HeadlessRule{
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{DomainRegex: []string{`^a\.`}}},
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Port: []uint16{80}}},
+				},
+			},
+		},
+		{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{DomainSuffix: []string{"example.dev"}},
+		},
+	}
+	diffs, err := Diff(from, to)
+	require.NoError(t, err)
+	require.Equal(t, []RuleDiff{
+		{
+			Path: "rules[0]",
+			Added: AddressItems{
+				Domain: []string{"example.io"},
+				IPCIDR: []string{"192.168.0.2/32"},
+			},
+			Removed: AddressItems{
+				Domain: []string{"example.net"},
+				IPCIDR: []string{"192.168.0.1/32"},
+			},
+		},
+		{
+			Path:         "rules[1].rules[1]",
+			OtherChanged: true,
+		},
+		{
+			Path:  "rules[2]",
+			Added: AddressItems{DomainSuffix: []string{"example.dev"}},
+		},
+	}, diffs)
+}
+
+func TestDiffAlign(t *testing.T) {
+	t.Parallel()
+	domainRule := func(domain ...string) option.HeadlessRule {
+		return option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Domain: domain}}
+	}
+	portRule := option.HeadlessRule{
+		Type:           C.RuleTypeDefault,
+		DefaultOptions: option.DefaultHeadlessRule{Port: []uint16{443}, DomainSuffix: []string{"example.org"}},
+	}
+	from := []option.HeadlessRule{
+		domainRule("a.example.com", "b.example.com"),
+		portRule,
+		domainRule("c.example.com"),
+		domainRule("d.example.com"),
+	}
+	to := []option.HeadlessRule{
+		domainRule("new.example.com"),
+		domainRule("a.example.com", "b.example.com"),
+		domainRule("d.example.com", "e.example.com"),
+		domainRule("c.example.com"),
+		portRule,
+	}
+	diffs, err := Diff(from, to)
+	require.NoError(t, err)
+	require.Equal(t, []RuleDiff{
+		{
+			Path:  "rules[0]",
+			Added: AddressItems{Domain: []string{"new.example.com"}},
+		},
+		{
+			Path:  "rules[2]",
+			Added: AddressItems{Domain: []string{"e.example.com"}},
+		},
+	}, diffs)
+}
+
+func TestDiffBinary(t *testing.T) {
+	t.Parallel()
+	rules := []option.HeadlessRule{
+		{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain:       []string{"example.com"},
+				DomainSuffix: []string{"example.org", ".example.net"},
+				IPCIDR:       []string{"10.0.0.0/8", "2001:db8::/32"},
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, option.PlainRuleSet{Rules: rules}, C.RuleSetVersionCurrent))
+	ruleSet, err := Read(&buffer, true)
+	require.NoError(t, err)
+	diffs, err := Diff(rules, ruleSet.Options.Rules)
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+}
+
+func TestCollectStats(t *testing.T) {
+	t.Parallel()
+	stats, err := CollectStats([]option.HeadlessRule{
+		{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{
+				Domain:        []string{"example.com", "example.net"},
+				DomainSuffix:  []string{"example.org"},
+				DomainKeyword: []string{"ads"},
+				IPCIDR:        []string{"10.0.0.0/24", "10.0.1.0/24"},
+			},
+		},
+		{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeOr,
+				Rules: []option.HeadlessRule{
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{DomainRegex: []string{`^a\.`}}},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.Rules)
+	require.Equal(t, 1, stats.LogicalRules)
+	require.Equal(t, 2, stats.Domain)
+	require.Equal(t, 1, stats.DomainSuffix)
+	require.Equal(t, 1, stats.DomainKeyword)
+	require.Equal(t, 1, stats.DomainRegex)
+	require.Equal(t, 2, stats.IPCIDR)
+	require.Positive(t, stats.DomainMatcherSize)
+	require.Positive(t, stats.DomainRegexSize)
+	// adjacent prefixes are merged into one range
+	require.Equal(t, stats.IPSetSize, 48)
+	require.Equal(t, stats.DomainMatcherSize+stats.DomainKeywordSize+stats.DomainRegexSize+stats.IPSetSize, stats.MemorySize())
+}
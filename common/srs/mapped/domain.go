@@ -0,0 +1,336 @@
+package mapped
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"runtime/debug"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/sagernet/sing/common/domain"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/varbin"
+)
+
+const (
+	prefixLabel = '\r'
+	rootLabel   = '\n'
+)
+
+// DomainMatcher is a domain.Matcher queried in place.
+//
+// It stores the same succinct trie as domain.Matcher, together with its rank and select
+// indexes, as little-endian arrays so that nothing has to be decoded or built on load.
+type DomainMatcher struct {
+	file        *File
+	leaves      []byte
+	labelBitmap []byte
+	labels      []byte
+	ranks       []byte
+	selects     []byte
+}
+
+// WriteDomainMatcher writes matcher in the layout read by ReadDomainMatcher.
+func WriteDomainMatcher(writer varbin.Writer, matcher *domain.Matcher) error {
+	var buffer bytes.Buffer
+	err := matcher.Write(&buffer)
+	if err != nil {
+		return err
+	}
+	_, err = buffer.ReadByte()
+	if err != nil {
+		return err
+	}
+	leaves, err := readUint64Slice(&buffer)
+	if err != nil {
+		return err
+	}
+	labelBitmap, err := readUint64Slice(&buffer)
+	if err != nil {
+		return err
+	}
+	labelsLength, err := binary.ReadUvarint(&buffer)
+	if err != nil {
+		return err
+	}
+	labels := buffer.Next(int(labelsLength))
+	if len(labels) != int(labelsLength) {
+		return E.New("invalid domain matcher")
+	}
+	selects, ranks := indexSelect32R64(labelBitmap)
+	for _, length := range []int{len(leaves), len(labelBitmap), len(labels), len(selects)} {
+		_, err = varbin.WriteUvarint(writer, uint64(length))
+		if err != nil {
+			return err
+		}
+	}
+	err = binary.Write(writer, binary.LittleEndian, leaves)
+	if err != nil {
+		return err
+	}
+	err = binary.Write(writer, binary.LittleEndian, labelBitmap)
+	if err != nil {
+		return err
+	}
+	err = binary.Write(writer, binary.LittleEndian, ranks)
+	if err != nil {
+		return err
+	}
+	err = binary.Write(writer, binary.LittleEndian, selects)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(labels)
+	return err
+}
+
+func ReadDomainMatcher(reader *Reader) (*DomainMatcher, error) {
+	var lengths [4]int
+	for i := range lengths {
+		length, err := reader.readLength(1)
+		if err != nil {
+			return nil, err
+		}
+		lengths[i] = length
+	}
+	matcher := &DomainMatcher{file: reader.file}
+	for _, field := range []struct {
+		data *[]byte
+		size int
+	}{
+		{&matcher.leaves, lengths[0] * 8},
+		{&matcher.labelBitmap, lengths[1] * 8},
+		{&matcher.ranks, (lengths[1] + 1) * 4},
+		{&matcher.selects, lengths[3] * 4},
+		{&matcher.labels, lengths[2]},
+	} {
+		data, err := reader.Next(field.size)
+		if err != nil {
+			return nil, err
+		}
+		*field.data = data
+	}
+	return matcher, nil
+}
+
+// Size returns the size of the matcher in bytes.
+func (m *DomainMatcher) Size() int {
+	return len(m.leaves) + len(m.labelBitmap) + len(m.labels) + len(m.ranks) + len(m.selects)
+}
+
+func (m *DomainMatcher) Match(domain string) (matched bool) {
+	if m.file.isMapped() {
+		defer recoverFault(&matched, debug.SetPanicOnFault(true))
+	} else {
+		defer recoverRange(&matched)
+	}
+	return m.has(reverseDomain(domain))
+}
+
+func (m *DomainMatcher) has(key string) bool {
+	var nodeId, bmIdx int
+	for i := 0; i < len(key); i++ {
+		currentChar := key[i]
+		for ; ; bmIdx++ {
+			if getBit(m.labelBitmap, bmIdx) != 0 {
+				return false
+			}
+			nextLabel := m.labels[bmIdx-nodeId]
+			if nextLabel == prefixLabel {
+				return true
+			}
+			if nextLabel == rootLabel {
+				nextNodeId := m.countZeros(bmIdx + 1)
+				hasNext := getBit(m.leaves, nextNodeId) != 0
+				if currentChar == '.' && hasNext {
+					return true
+				}
+			}
+			if nextLabel == currentChar {
+				break
+			}
+		}
+		nodeId = m.countZeros(bmIdx + 1)
+		bmIdx = m.selectIthOne(nodeId-1) + 1
+	}
+	if getBit(m.leaves, nodeId) != 0 {
+		return true
+	}
+	for ; ; bmIdx++ {
+		if getBit(m.labelBitmap, bmIdx) != 0 {
+			return false
+		}
+		nextLabel := m.labels[bmIdx-nodeId]
+		if nextLabel == prefixLabel || nextLabel == rootLabel {
+			return true
+		}
+	}
+}
+
+// Dump returns the domains and domain suffixes in the matcher, like domain.Matcher.Dump.
+func (m *DomainMatcher) Dump() (domainList []string, prefixList []string) {
+	domainMap := make(map[string]bool)
+	prefixMap := make(map[string]bool)
+	for _, key := range m.keys() {
+		key = reverseDomain(key)
+		if key[0] == prefixLabel {
+			prefixMap[key[1:]] = true
+		} else if key[0] == rootLabel {
+			prefixList = append(prefixList, key[1:])
+		} else {
+			domainMap[key] = true
+		}
+	}
+	for rawPrefix := range prefixMap {
+		if rawPrefix[0] == '.' {
+			if rootDomain := rawPrefix[1:]; domainMap[rootDomain] {
+				delete(domainMap, rootDomain)
+				prefixList = append(prefixList, rootDomain)
+				continue
+			}
+		}
+		prefixList = append(prefixList, rawPrefix)
+	}
+	for domain := range domainMap {
+		domainList = append(domainList, domain)
+	}
+	sort.Strings(domainList)
+	sort.Strings(prefixList)
+	return domainList, prefixList
+}
+
+func (m *DomainMatcher) keys() []string {
+	var result []string
+	var currentKey []byte
+	var traverse func(int, int)
+	traverse = func(nodeId, bmIdx int) {
+		if getBit(m.leaves, nodeId) != 0 {
+			result = append(result, string(currentKey))
+		}
+		for ; ; bmIdx++ {
+			if getBit(m.labelBitmap, bmIdx) != 0 {
+				return
+			}
+			currentKey = append(currentKey, m.labels[bmIdx-nodeId])
+			nextNodeId := m.countZeros(bmIdx + 1)
+			traverse(nextNodeId, m.selectIthOne(nextNodeId-1)+1)
+			currentKey = currentKey[:len(currentKey)-1]
+		}
+	}
+	traverse(0, 0)
+	return result
+}
+
+func (m *DomainMatcher) countZeros(i int) int {
+	wordI := i >> 6
+	n := int(int32(binary.LittleEndian.Uint32(m.ranks[wordI<<2:])))
+	w := word(m.labelBitmap, wordI)
+	return i - (n + bits.OnesCount64(w&mask[i&63]))
+}
+
+func (m *DomainMatcher) selectIthOne(i int) int {
+	rank := func(wordI int) int {
+		return int(int32(binary.LittleEndian.Uint32(m.ranks[wordI<<2:])))
+	}
+	wordI := int(int32(binary.LittleEndian.Uint32(m.selects[(i>>5)<<2:]))) >> 6
+	for ; rank(wordI+1) <= i; wordI++ {
+	}
+	w := word(m.labelBitmap, wordI)
+	findIth := i - rank(wordI)
+	offset := 0
+	ones := bits.OnesCount32(uint32(w))
+	if ones <= findIth {
+		findIth -= ones
+		offset |= 32
+		w >>= 32
+	}
+	ones = bits.OnesCount16(uint16(w))
+	if ones <= findIth {
+		findIth -= ones
+		offset |= 16
+		w >>= 16
+	}
+	ones = bits.OnesCount8(uint8(w))
+	if ones <= findIth {
+		return wordI<<6 + int(select8Lookup[(w>>5)&(0x7f8)|uint64(findIth-ones)]) + offset + 8
+	}
+	return wordI<<6 + int(select8Lookup[(w&0xff)<<3|uint64(findIth)]) + offset
+}
+
+func word(data []byte, i int) uint64 {
+	return binary.LittleEndian.Uint64(data[i<<3:])
+}
+
+func getBit(bm []byte, i int) uint64 {
+	if i>>6 >= len(bm)>>3 {
+		return 0
+	}
+	return word(bm, i>>6) & (1 << uint(i&63))
+}
+
+func reverseDomain(domain string) string {
+	l := len(domain)
+	b := make([]byte, l)
+	for i := 0; i < l; {
+		r, n := utf8.DecodeRuneInString(domain[i:])
+		i += n
+		utf8.EncodeRune(b[l-i:], r)
+	}
+	return string(b)
+}
+
+func readUint64Slice(reader *bytes.Buffer) ([]uint64, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(reader.Len()/8) {
+		return nil, E.New("invalid domain matcher")
+	}
+	result := make([]uint64, length)
+	err = binary.Read(reader, binary.BigEndian, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func indexSelect32R64(words []uint64) ([]int32, []int32) {
+	selects := make([]int32, 0, len(words))
+	ith := -1
+	for i := 0; i < len(words)<<6; i++ {
+		if words[i>>6]&(1<<uint(i&63)) != 0 {
+			ith++
+			if ith&31 == 0 {
+				selects = append(selects, int32(i))
+			}
+		}
+	}
+	ranks := make([]int32, len(words)+1)
+	var n int32
+	for i, w := range words {
+		ranks[i] = n
+		n += int32(bits.OnesCount64(w))
+	}
+	ranks[len(words)] = n
+	return selects, ranks
+}
+
+var (
+	mask          [64]uint64
+	select8Lookup [256 * 8]uint8
+)
+
+func init() {
+	for i := 0; i < 64; i++ {
+		mask[i] = (1 << uint(i)) - 1
+	}
+	for i := 0; i < 256; i++ {
+		w := uint8(i)
+		for j := 0; j < 8; j++ {
+			select8Lookup[i*8+j] = uint8(bits.TrailingZeros8(w))
+			w &= w - 1
+		}
+	}
+}
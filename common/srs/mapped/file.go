@@ -0,0 +1,65 @@
+package mapped
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// File is a read-only mapping of a file.
+//
+// Matchers read from a File keep a reference to it, and the mapping is released
+// only after all of them are unreachable.
+type File struct {
+	data   []byte
+	mapped bool
+}
+
+// Open maps the file at path into memory.
+// On platforms without mmap support, the file is read into the heap instead.
+func Open(path string) (*File, error) {
+	data, mapped, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{data: data, mapped: mapped}
+	if mapped {
+		runtime.SetFinalizer(file, (*File).unmap)
+	}
+	return file, nil
+}
+
+func (f *File) Bytes() []byte {
+	return f.data
+}
+
+func (f *File) isMapped() bool {
+	return f != nil && f.mapped
+}
+
+func (f *File) unmap() {
+	_ = unmapFile(f.data)
+	f.data = nil
+}
+
+// recoverFault turns faults and out of range reads, caused by a mapped file being
+// truncated or rewritten in place, into a failed match.
+func recoverFault(result *bool, oldPanicOnFault bool) {
+	debug.SetPanicOnFault(oldPanicOnFault)
+	failOnRuntimeError(recover(), result)
+}
+
+// recoverRange turns out of range reads, caused by corrupt content on the heap
+// that is only checked for lengths on load, into a failed match.
+func recoverRange(result *bool) {
+	failOnRuntimeError(recover(), result)
+}
+
+func failOnRuntimeError(recovered any, result *bool) {
+	if recovered == nil {
+		return
+	}
+	if _, isRuntimeError := recovered.(runtime.Error); !isRuntimeError {
+		panic(recovered)
+	}
+	*result = false
+}
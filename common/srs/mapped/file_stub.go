@@ -0,0 +1,14 @@
+//go:build !unix
+
+package mapped
+
+import "os"
+
+func mapFile(path string) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	return data, false, err
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package mapped
+
+import (
+	"os"
+
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"golang.org/x/sys/unix"
+)
+
+func mapFile(path string) ([]byte, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	size := fileInfo.Size()
+	if size == 0 {
+		return nil, false, nil
+	}
+	if int64(int(size)) != size {
+		return nil, false, E.New("file too large: ", size)
+	}
+	data, err := unix.Mmap(int(file.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, false, E.Cause(err, "mmap ", path)
+	}
+	return data, true, nil
+}
+
+func unmapFile(data []byte) error {
+	return unix.Munmap(data)
+}
@@ -0,0 +1,138 @@
+package mapped
+
+import (
+	"bytes"
+	"net/netip"
+	"runtime/debug"
+	"sort"
+
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/varbin"
+
+	"go4.org/netipx"
+)
+
+// IPSet is a netipx.IPSet queried in place.
+//
+// Ranges are stored sorted as pairs of big-endian addresses, four bytes each for IPv4
+// and sixteen bytes each for IPv6, and looked up by binary search.
+type IPSet struct {
+	file *File
+	ipv4 []byte
+	ipv6 []byte
+}
+
+// WriteIPSet writes ipSet in the layout read by ReadIPSet.
+func WriteIPSet(writer varbin.Writer, ipSet *netipx.IPSet) error {
+	var ipv4Ranges, ipv6Ranges []netipx.IPRange
+	for _, ipRange := range ipSet.Ranges() {
+		if ipRange.From().Is4() {
+			ipv4Ranges = append(ipv4Ranges, ipRange)
+		} else {
+			ipv6Ranges = append(ipv6Ranges, ipRange)
+		}
+	}
+	_, err := varbin.WriteUvarint(writer, uint64(len(ipv4Ranges)))
+	if err != nil {
+		return err
+	}
+	_, err = varbin.WriteUvarint(writer, uint64(len(ipv6Ranges)))
+	if err != nil {
+		return err
+	}
+	for _, ipRange := range append(ipv4Ranges, ipv6Ranges...) {
+		_, err = writer.Write(ipRange.From().AsSlice())
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(ipRange.To().AsSlice())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ReadIPSet(reader *Reader) (*IPSet, error) {
+	ipv4Length, err := reader.readLength(8)
+	if err != nil {
+		return nil, err
+	}
+	ipv6Length, err := reader.readLength(32)
+	if err != nil {
+		return nil, err
+	}
+	ipSet := &IPSet{file: reader.file}
+	ipSet.ipv4, err = reader.Next(ipv4Length * 8)
+	if err != nil {
+		return nil, err
+	}
+	ipSet.ipv6, err = reader.Next(ipv6Length * 32)
+	if err != nil {
+		return nil, err
+	}
+	return ipSet, nil
+}
+
+// Size returns the size of the set in bytes.
+func (s *IPSet) Size() int {
+	return len(s.ipv4) + len(s.ipv6)
+}
+
+func (s *IPSet) Contains(addr netip.Addr) (contains bool) {
+	if addr.Zone() != "" {
+		return false
+	}
+	if s.file.isMapped() {
+		defer recoverFault(&contains, debug.SetPanicOnFault(true))
+	} else {
+		defer recoverRange(&contains)
+	}
+	if addr.Is4() {
+		address := addr.As4()
+		return containsAddress(s.ipv4, address[:])
+	} else if addr.Is6() {
+		address := addr.As16()
+		return containsAddress(s.ipv6, address[:])
+	}
+	return false
+}
+
+func containsAddress(ranges []byte, address []byte) bool {
+	addressLength := len(address)
+	rangeLength := addressLength * 2
+	count := len(ranges) / rangeLength
+	// find the first range starting after address
+	index := sort.Search(count, func(i int) bool {
+		return bytes.Compare(address, ranges[i*rangeLength:i*rangeLength+addressLength]) < 0
+	})
+	if index == 0 {
+		return false
+	}
+	to := ranges[(index-1)*rangeLength+addressLength : index*rangeLength]
+	return bytes.Compare(address, to) <= 0
+}
+
+// IPSet decodes the set, for callers that need a netipx.IPSet.
+func (s *IPSet) IPSet() (*netipx.IPSet, error) {
+	var builder netipx.IPSetBuilder
+	for _, table := range []struct {
+		ranges        []byte
+		addressLength int
+	}{
+		{s.ipv4, 4},
+		{s.ipv6, 16},
+	} {
+		rangeLength := table.addressLength * 2
+		for i := 0; i+rangeLength <= len(table.ranges); i += rangeLength {
+			from, _ := netip.AddrFromSlice(table.ranges[i : i+table.addressLength])
+			to, _ := netip.AddrFromSlice(table.ranges[i+table.addressLength : i+rangeLength])
+			ipRange := netipx.IPRangeFrom(from, to)
+			if !ipRange.IsValid() {
+				return nil, E.New("invalid range: ", from, "-", to)
+			}
+			builder.AddRange(ipRange)
+		}
+	}
+	return builder.IPSet()
+}
@@ -0,0 +1,61 @@
+package mapped
+
+import (
+	"encoding/binary"
+	"io"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// Reader reads from a byte slice without copying.
+// Slices returned by Next alias the underlying data.
+type Reader struct {
+	file   *File
+	data   []byte
+	offset int
+}
+
+// NewReader creates a reader of data. file is referenced by matchers read from data
+// to keep the mapping alive and may be nil if data is on the heap.
+func NewReader(file *File, data []byte) *Reader {
+	return &Reader{file: file, data: data}
+}
+
+func (r *Reader) Read(p []byte) (n int, err error) {
+	if r.offset >= len(r.data) {
+		return 0, io.EOF
+	}
+	n = copy(p, r.data[r.offset:])
+	r.offset += n
+	return
+}
+
+func (r *Reader) ReadByte() (byte, error) {
+	if r.offset >= len(r.data) {
+		return 0, io.EOF
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b, nil
+}
+
+// Next returns the next n bytes.
+func (r *Reader) Next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.offset {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := r.data[r.offset : r.offset+n : r.offset+n]
+	r.offset += n
+	return data, nil
+}
+
+func (r *Reader) readLength(elementSize int) (int, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if length > uint64((len(r.data)-r.offset)/elementSize) {
+		return 0, E.New("invalid length: ", length)
+	}
+	return int(length), nil
+}
@@ -0,0 +1,270 @@
+package srs
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMappedRuleSet(t *testing.T) {
+	t.Parallel()
+	ruleSet := option.PlainRuleSet{
+		Rules: []option.HeadlessRule{
+			{
+				Type: C.RuleTypeDefault,
+				DefaultOptions: option.DefaultHeadlessRule{
+					Domain:       []string{"example.com", "www.example.net", "例子.测试"},
+					DomainSuffix: []string{"example.org", ".example.io"},
+					IPCIDR:       []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"},
+					IPASN:        []option.ASN{13335, 4294967295},
+					IPCountry:    []string{"jp"},
+					Port:         []uint16{443},
+				},
+			},
+			{
+				Type: C.RuleTypeLogical,
+				LogicalOptions: option.LogicalHeadlessRule{
+					Mode: C.LogicalTypeAnd,
+					Rules: []option.HeadlessRule{
+						{
+							Type:           C.RuleTypeDefault,
+							DefaultOptions: option.DefaultHeadlessRule{SourceIPCIDR: []string{"172.16.0.0/12"}},
+						},
+						{
+							Type:           C.RuleTypeDefault,
+							DefaultOptions: option.DefaultHeadlessRule{DomainKeyword: []string{"ads"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, ruleSet, C.RuleSetVersion7))
+	content := buffer.Bytes()
+	path := filepath.Join(t.TempDir(), "test.srs")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	for name, read := range map[string]func() (option.PlainRuleSetCompat, error){
+		"bytes":  func() (option.PlainRuleSetCompat, error) { return ReadBytes(content, false) },
+		"reader": func() (option.PlainRuleSetCompat, error) { return Read(bytes.NewReader(content), false) },
+		"file":   func() (option.PlainRuleSetCompat, error) { return ReadFile(path, false) },
+	} {
+		compat, err := read()
+		require.NoError(t, err, name)
+		require.Equal(t, uint8(C.RuleSetVersion7), compat.Version)
+		rule := compat.Options.Rules[0].DefaultOptions
+		require.Empty(t, rule.Domain)
+		require.Nil(t, rule.DomainMatcher)
+		require.NotNil(t, rule.MappedDomainMatcher, name)
+		for domain, matched := range map[string]bool{
+			"example.com":     true,
+			"www.example.com": false,
+			"www.example.net": true,
+			"example.net":     false,
+			"例子.测试":           true,
+			"example.org":     true,
+			"a.b.example.org": true,
+			"example.io":      false,
+			"a.example.io":    true,
+			"example.dev":     false,
+		} {
+			require.Equal(t, matched, rule.MappedDomainMatcher.Match(domain), domain)
+		}
+		for address, contains := range map[string]bool{
+			"10.1.2.3":        true,
+			"11.0.0.0":        false,
+			"192.168.1.1":     true,
+			"192.168.1.2":     false,
+			"2001:db8::1":     true,
+			"2001:db9::1":     false,
+			"::ffff:10.0.0.1": false,
+		} {
+			addr, _ := netip.ParseAddr(address)
+			require.Equal(t, contains, rule.MappedIPSet.Contains(addr), address)
+		}
+		require.Equal(t, []uint16{443}, []uint16(rule.Port))
+		require.Equal(t, []option.ASN{13335, 4294967295}, []option.ASN(rule.IPASN))
+		require.Equal(t, []string{"jp"}, []string(rule.IPCountry))
+		sourceRule := compat.Options.Rules[1].LogicalOptions.Rules[0].DefaultOptions
+		require.True(t, sourceRule.MappedSourceIPSet.Contains(netip.MustParseAddr("172.20.0.1")))
+		require.Equal(t, []string{"ads"}, []string(compat.Options.Rules[1].LogicalOptions.Rules[1].DefaultOptions.DomainKeyword))
+	}
+
+	require.Error(t, Write(&bytes.Buffer{}, ruleSet, C.RuleSetVersion5))
+
+	// version 6 supports the new rule items without the mapped layout
+	var decodedBuffer bytes.Buffer
+	require.NoError(t, Write(&decodedBuffer, ruleSet, C.RuleSetVersion6))
+	decoded, err := ReadBytes(decodedBuffer.Bytes(), false)
+	require.NoError(t, err)
+	require.Equal(t, uint8(C.RuleSetVersion6), decoded.Version)
+	decodedRule := decoded.Options.Rules[0].DefaultOptions
+	require.Nil(t, decodedRule.MappedDomainMatcher)
+	require.True(t, decodedRule.DomainMatcher.Match("example.com"))
+	require.Equal(t, []option.ASN{13335, 4294967295}, []option.ASN(decodedRule.IPASN))
+	require.Equal(t, []string{"jp"}, []string(decodedRule.IPCountry))
+
+	compat, err := ReadBytes(content, true)
+	require.NoError(t, err)
+	rule := compat.Options.Rules[0].DefaultOptions
+	require.Equal(t, []string{"example.com", "www.example.net", "例子.测试"}, []string(rule.Domain))
+	require.Equal(t, []string{".example.io", "example.org"}, []string(rule.DomainSuffix))
+	require.Equal(t, []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}, []string(rule.IPCIDR))
+}
+
+func TestMappedRuleSetCompatible(t *testing.T) {
+	t.Parallel()
+	ruleSet := generateRuleSet(5000, 2000)
+	var decodedBuffer, mappedBuffer bytes.Buffer
+	require.NoError(t, Write(&decodedBuffer, ruleSet, C.RuleSetVersion5))
+	require.NoError(t, Write(&mappedBuffer, ruleSet, C.RuleSetVersion7))
+	decoded, err := ReadBytes(decodedBuffer.Bytes(), false)
+	require.NoError(t, err)
+	mapped, err := ReadBytes(mappedBuffer.Bytes(), false)
+	require.NoError(t, err)
+	decodedRule := decoded.Options.Rules[0].DefaultOptions
+	mappedRule := mapped.Options.Rules[0].DefaultOptions
+	for i := 0; i < 10000; i++ {
+		for _, domain := range []string{
+			"www.domain-" + strconv.Itoa(i) + ".example.com",
+			"domain-" + strconv.Itoa(i) + ".example.com",
+			"domain-" + strconv.Itoa(i) + ".example.net",
+			"a.domain-" + strconv.Itoa(i) + ".example.net",
+			"xdomain-" + strconv.Itoa(i) + ".example.net",
+		} {
+			require.Equal(t, decodedRule.DomainMatcher.Match(domain), mappedRule.MappedDomainMatcher.Match(domain), domain)
+		}
+		addr := netip.AddrFrom4([4]byte{byte(i >> 8), byte(i), byte(i * 7), byte(i * 13)})
+		require.Equal(t, decodedRule.IPSet.Contains(addr), mappedRule.MappedIPSet.Contains(addr), addr)
+	}
+}
+
+func TestMappedRuleSetTruncated(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: option.DefaultHeadlessRule{Domain: []string{"example.com"}},
+		}},
+	}, C.RuleSetVersion7))
+	content := buffer.Bytes()
+	for i := 4; i < len(content); i++ {
+		_, err := ReadBytes(content[:i], false)
+		require.Error(t, err, i)
+	}
+}
+
+func TestMappedRuleSetCorrupt(t *testing.T) {
+	t.Parallel()
+	var buffer bytes.Buffer
+	require.NoError(t, Write(&buffer, generateRuleSet(200, 50), C.RuleSetVersion7))
+	content := buffer.Bytes()
+	// rule-sets read from memory, such as remote ones, are not protected by the file mapping
+	for i := 4; i < len(content); i++ {
+		corrupted := bytes.Clone(content)
+		corrupted[i] ^= 0xFF
+		compat, err := ReadBytes(corrupted, false)
+		if err != nil {
+			continue
+		}
+		rule := compat.Options.Rules[0].DefaultOptions
+		require.NotPanics(t, func() {
+			for j := 0; j < 400; j += 7 {
+				if rule.MappedDomainMatcher != nil {
+					rule.MappedDomainMatcher.Match("a.domain-" + strconv.Itoa(j) + ".example.net")
+					rule.MappedDomainMatcher.Match("www.domain-" + strconv.Itoa(j) + ".example.com")
+				}
+				if rule.MappedIPSet != nil {
+					rule.MappedIPSet.Contains(netip.AddrFrom4([4]byte{0, 0, byte(j), 1}))
+				}
+			}
+		}, i)
+	}
+}
+
+func generateRuleSet(domainCount int, prefixCount int) option.PlainRuleSet {
+	var rule option.DefaultHeadlessRule
+	for i := 0; i < domainCount; i++ {
+		if i%2 == 0 {
+			rule.Domain = append(rule.Domain, "www.domain-"+strconv.Itoa(i)+".example.com")
+		} else {
+			rule.DomainSuffix = append(rule.DomainSuffix, "domain-"+strconv.Itoa(i)+".example.net")
+		}
+	}
+	for i := 0; i < prefixCount; i++ {
+		rule.IPCIDR = append(rule.IPCIDR, netip.PrefixFrom(netip.AddrFrom4([4]byte{byte(i >> 16), byte(i >> 8), byte(i), 0}), 25).String())
+	}
+	return option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{Type: C.RuleTypeDefault, DefaultOptions: rule}},
+	}
+}
+
+// BenchmarkReadRuleSet reports the heap retained by a loaded rule-set
+// of 200k domains and 50k CIDRs in each layout.
+func BenchmarkReadRuleSet(b *testing.B) {
+	ruleSet := generateRuleSet(200000, 50000)
+	for _, version := range []uint8{C.RuleSetVersion5, C.RuleSetVersion7} {
+		var buffer bytes.Buffer
+		require.NoError(b, Write(&buffer, ruleSet, version))
+		content := buffer.Bytes()
+		path := filepath.Join(b.TempDir(), "test.srs")
+		require.NoError(b, os.WriteFile(path, content, 0o644))
+		b.Run("v"+strconv.Itoa(int(version)), func(b *testing.B) {
+			b.ReportAllocs()
+			var memStats runtime.MemStats
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&memStats)
+				before := memStats.HeapAlloc
+				compat, err := ReadFile(path, false)
+				if err != nil {
+					b.Fatal(err)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&memStats)
+				retained += memStats.HeapAlloc - min(before, memStats.HeapAlloc)
+				runtime.KeepAlive(compat)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
+
+func BenchmarkMatchRuleSet(b *testing.B) {
+	ruleSet := generateRuleSet(200000, 50000)
+	for _, version := range []uint8{C.RuleSetVersion5, C.RuleSetVersion7} {
+		var buffer bytes.Buffer
+		require.NoError(b, Write(&buffer, ruleSet, version))
+		compat, err := ReadBytes(buffer.Bytes(), false)
+		require.NoError(b, err)
+		rule := compat.Options.Rules[0].DefaultOptions
+		match := func(domain string) bool { return rule.DomainMatcher.Match(domain) }
+		contains := func(addr netip.Addr) bool { return rule.IPSet.Contains(addr) }
+		if version >= C.RuleSetVersion7 {
+			match = rule.MappedDomainMatcher.Match
+			contains = rule.MappedIPSet.Contains
+		}
+		b.Run("domain/v"+strconv.Itoa(int(version)), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				match("a.domain-" + strconv.Itoa(i%400000) + ".example.net")
+			}
+		})
+		b.Run("ip/v"+strconv.Itoa(int(version)), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				contains(netip.AddrFrom4([4]byte{byte(i >> 16), byte(i >> 8), byte(i), 1}))
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package srs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"regexp/syntax"
+	"unsafe"
+
+	"github.com/sagernet/sing-box/common/srs/mapped"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/domain"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/varbin"
+
+	"go4.org/netipx"
+)
+
+// Stats are item counts of a rule-set and the estimated memory footprint
+// of its compiled matchers in bytes. Matchers of the mapped layout are counted
+// by their size in the file.
+type Stats struct {
+	Rules         int
+	LogicalRules  int
+	Domain        int
+	DomainSuffix  int
+	DomainKeyword int
+	DomainRegex   int
+	IPCIDR        int
+	SourceIPCIDR  int
+	AdGuardDomain int
+
+	DomainMatcherSize  int
+	DomainKeywordSize  int
+	DomainRegexSize    int
+	IPSetSize          int
+	AdGuardMatcherSize int
+}
+
+func (s Stats) MemorySize() int {
+	return s.DomainMatcherSize + s.DomainKeywordSize + s.DomainRegexSize + s.IPSetSize + s.AdGuardMatcherSize
+}
+
+// CollectStats counts the items of rules. Rules loaded from binary must be recovered (see Read).
+func CollectStats(rules []option.HeadlessRule) (Stats, error) {
+	var stats Stats
+	err := collectStats(&stats, rules)
+	return stats, err
+}
+
+func collectStats(stats *Stats, rules []option.HeadlessRule) error {
+	for _, rule := range rules {
+		stats.Rules++
+		if rule.Type == C.RuleTypeLogical {
+			stats.LogicalRules++
+			err := collectStats(stats, rule.LogicalOptions.Rules)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := collectDefaultRuleStats(stats, rule.DefaultOptions)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collectDefaultRuleStats(stats *Stats, rule option.DefaultHeadlessRule) error {
+	stats.Domain += len(rule.Domain)
+	stats.DomainSuffix += len(rule.DomainSuffix)
+	stats.DomainKeyword += len(rule.DomainKeyword)
+	stats.DomainRegex += len(rule.DomainRegex)
+	stats.IPCIDR += len(rule.IPCIDR)
+	stats.SourceIPCIDR += len(rule.SourceIPCIDR)
+	stats.AdGuardDomain += len(rule.AdGuardDomain)
+	if rule.MappedDomainMatcher != nil {
+		stats.DomainMatcherSize += rule.MappedDomainMatcher.Size()
+	} else if len(rule.Domain) > 0 || len(rule.DomainSuffix) > 0 {
+		matcher := rule.DomainMatcher
+		if matcher == nil {
+			matcher = domain.NewMatcher(rule.Domain, rule.DomainSuffix, false)
+		}
+		size, err := succinctSetSize(matcher)
+		if err != nil {
+			return E.Cause(err, "estimate domain matcher")
+		}
+		stats.DomainMatcherSize += size
+	}
+	if len(rule.AdGuardDomain) > 0 {
+		matcher := rule.AdGuardDomainMatcher
+		if matcher == nil {
+			matcher = domain.NewAdGuardMatcher(rule.AdGuardDomain)
+		}
+		size, err := succinctSetSize(matcher)
+		if err != nil {
+			return E.Cause(err, "estimate adguard domain matcher")
+		}
+		stats.AdGuardMatcherSize += size
+	}
+	for _, keyword := range rule.DomainKeyword {
+		stats.DomainKeywordSize += int(unsafe.Sizeof("")) + len(keyword)
+	}
+	for _, regex := range rule.DomainRegex {
+		size, err := regexSize(regex)
+		if err != nil {
+			return E.Cause(err, "parse domain_regex: ", regex)
+		}
+		stats.DomainRegexSize += size
+	}
+	for _, item := range []struct {
+		set       *netipx.IPSet
+		mappedSet *mapped.IPSet
+		prefixes  []string
+	}{
+		{rule.IPSet, rule.MappedIPSet, rule.IPCIDR},
+		{rule.SourceIPSet, rule.MappedSourceIPSet, rule.SourceIPCIDR},
+	} {
+		if item.mappedSet != nil {
+			stats.IPSetSize += item.mappedSet.Size()
+			continue
+		}
+		if len(item.prefixes) == 0 {
+			continue
+		}
+		ipSet := item.set
+		if ipSet == nil {
+			var err error
+			ipSet, err = parseIPSet(item.prefixes)
+			if err != nil {
+				return err
+			}
+		}
+		stats.IPSetSize += len(ipSet.Ranges()) * int(unsafe.Sizeof(netipx.IPRange{}))
+	}
+	return nil
+}
+
+// succinctSetSize estimates the memory used by a domain matcher from its serialized form:
+// leaves, label bitmap and labels as stored, plus the rank and select indexes built on load.
+func succinctSetSize(matcher interface{ Write(varbin.Writer) error }) (int, error) {
+	var buffer bytes.Buffer
+	err := matcher.Write(&buffer)
+	if err != nil {
+		return 0, err
+	}
+	_, err = buffer.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	leaves, err := readUint64Words(&buffer)
+	if err != nil {
+		return 0, err
+	}
+	labelBitmap, err := readUint64Words(&buffer)
+	if err != nil {
+		return 0, err
+	}
+	labelsLength, err := binary.ReadUvarint(&buffer)
+	if err != nil {
+		return 0, err
+	}
+	var ones int
+	for _, word := range labelBitmap {
+		ones += bits.OnesCount64(word)
+	}
+	size := 8*len(leaves) + 8*len(labelBitmap) + int(labelsLength)
+	size += 4 * (len(labelBitmap) + 1) // ranks
+	size += 4 * ((ones + 31) / 32)     // selects
+	return size, nil
+}
+
+func readUint64Words(reader *bytes.Buffer) ([]uint64, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(reader.Len()/8) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	result := make([]uint64, length)
+	err = binary.Read(reader, binary.BigEndian, result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// regexSize estimates the memory used by a compiled regular expression by its program size.
+func regexSize(expr string) (int, error) {
+	regex, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return 0, err
+	}
+	program, err := syntax.Compile(regex.Simplify())
+	if err != nil {
+		return 0, err
+	}
+	size := len(expr) + len(program.Inst)*int(unsafe.Sizeof(syntax.Inst{}))
+	for _, inst := range program.Inst {
+		size += 4 * len(inst.Rune)
+	}
+	return size, nil
+}
@@ -8,6 +8,8 @@ import (
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"math/big"
+	"net"
+	"net/netip"
 	"time"
 )
 
@@ -45,7 +47,11 @@ func GenerateCertificate(parent *x509.Certificate, parentKey any, timeFunc func(
 		Subject: pkix.Name{
 			CommonName: serverName,
 		},
-		DNSNames: []string{serverName},
+	}
+	if address, parseErr := netip.ParseAddr(serverName); parseErr == nil {
+		template.IPAddresses = []net.IP{address.AsSlice()}
+	} else {
+		template.DNSNames = []string{serverName}
 	}
 	if parent == nil {
 		parent = template
@@ -63,3 +69,41 @@ func GenerateCertificate(parent *x509.Certificate, parentKey any, timeFunc func(
 	privateKeyPem = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer})
 	return
 }
+
+func GenerateCA(timeFunc func() time.Time, commonName string, expire time.Time) (privateKeyPem []byte, publicKeyPem []byte, err error) {
+	if timeFunc == nil {
+		timeFunc = time.Now
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		NotBefore:             timeFunc().Add(time.Hour * -1),
+		NotAfter:              expire,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{commonName},
+		},
+	}
+	publicDer, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return
+	}
+	privateDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return
+	}
+	publicKeyPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: publicDer})
+	privateKeyPem = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer})
+	return
+}
@@ -1,16 +1,23 @@
 package constant
 
 const (
-	ProtocolTLS        = "tls"
-	ProtocolHTTP       = "http"
-	ProtocolQUIC       = "quic"
-	ProtocolDNS        = "dns"
-	ProtocolSTUN       = "stun"
-	ProtocolBitTorrent = "bittorrent"
-	ProtocolDTLS       = "dtls"
-	ProtocolSSH        = "ssh"
-	ProtocolRDP        = "rdp"
-	ProtocolNTP        = "ntp"
+	ProtocolTLS         = "tls"
+	ProtocolHTTP        = "http"
+	ProtocolQUIC        = "quic"
+	ProtocolDNS         = "dns"
+	ProtocolSTUN        = "stun"
+	ProtocolBitTorrent  = "bittorrent"
+	ProtocolDTLS        = "dtls"
+	ProtocolSSH         = "ssh"
+	ProtocolRDP         = "rdp"
+	ProtocolNTP         = "ntp"
+	ProtocolSMTP        = "smtp"
+	ProtocolIMAP        = "imap"
+	ProtocolPOP3        = "pop3"
+	ProtocolMQTT        = "mqtt"
+	ProtocolWireGuard   = "wireguard"
+	ProtocolSOCKS       = "socks"
+	ProtocolHTTPConnect = "http-connect"
 )
 
 const (
@@ -25,6 +25,9 @@ const (
 	TypeTUIC         = "tuic"
 	TypeHysteria2    = "hysteria2"
 	TypeTailscale    = "tailscale"
+	TypeBridge       = "bridge"
+	TypePortal       = "portal"
+	TypeForward      = "forward"
 	TypeDERP         = "derp"
 	TypeResolved     = "resolved"
 	TypeSSMAPI       = "ssm-api"
@@ -92,6 +95,12 @@ func ProxyDisplayName(proxyType string) string {
 		return "AnyTLS"
 	case TypeTailscale:
 		return "Tailscale"
+	case TypeBridge:
+		return "Bridge"
+	case TypePortal:
+		return "Portal"
+	case TypeForward:
+		return "Forward"
 	case TypeSelector:
 		return "Selector"
 	case TypeURLTest:
@@ -18,12 +18,24 @@ const (
 	RuleSetFormatBinary = "binary"
 )
 
+const (
+	RuleSetFormatAdGuard        = "adguard"
+	RuleSetFormatClashDomain    = "clash-domain"
+	RuleSetFormatClashIPCIDR    = "clash-ipcidr"
+	RuleSetFormatClashClassical = "clash-classical"
+	RuleSetFormatSurgeList      = "surge-list"
+	RuleSetFormatHosts          = "hosts"
+)
+
 const (
 	RuleSetVersion1 = 1 + iota
 	RuleSetVersion2
 	RuleSetVersion3
 	RuleSetVersion4
-	RuleSetVersionCurrent = RuleSetVersion4
+	RuleSetVersion5
+	RuleSetVersion6
+	RuleSetVersion7
+	RuleSetVersionCurrent = RuleSetVersion7
 )
 
 const (
@@ -36,6 +48,12 @@ const (
 	RuleActionTypeSniff        = "sniff"
 	RuleActionTypeResolve      = "resolve"
 	RuleActionTypePredefined   = "predefined"
+	RuleActionTypeRewrite      = "rewrite"
+	RuleActionTypeMITM         = "mitm"
+	RuleActionTypeHTTPRedirect = "http-redirect"
+	RuleActionTypeHTTPReject   = "http-reject"
+	RuleActionTypeHTTPMock     = "http-mock"
+	RuleActionTypeHTTPHeader   = "http-header"
 )
 
 const (
@@ -43,3 +61,9 @@ const (
 	RuleActionRejectMethodDrop    = "drop"
 	RuleActionRejectMethodReply   = "reply"
 )
+
+const (
+	RuleActionHTTPRejectMethodNotFound = "not-found"
+	RuleActionHTTPRejectMethodEmpty    = "empty"
+	RuleActionHTTPRejectMethodGIF      = "gif"
+)
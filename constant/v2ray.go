@@ -6,4 +6,22 @@ const (
 	V2RayTransportTypeQUIC        = "quic"
 	V2RayTransportTypeGRPC        = "grpc"
 	V2RayTransportTypeHTTPUpgrade = "httpupgrade"
+	V2RayTransportTypeXHTTP       = "xhttp"
+	V2RayTransportTypeKCP         = "kcp"
+)
+
+const (
+	XHTTPModeAuto      = "auto"
+	XHTTPModePacketUp  = "packet-up"
+	XHTTPModeStreamUp  = "stream-up"
+	XHTTPModeStreamOne = "stream-one"
+)
+
+const (
+	KCPHeaderNone        = "none"
+	KCPHeaderSRTP        = "srtp"
+	KCPHeaderUTP         = "utp"
+	KCPHeaderWechatVideo = "wechat-video"
+	KCPHeaderDTLS        = "dtls"
+	KCPHeaderWireGuard   = "wireguard"
 )
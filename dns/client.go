@@ -29,6 +29,12 @@ var (
 	ErrResponseRejectedCached = E.Extend(ErrResponseRejected, "cached")
 )
 
+const (
+	// staleTTL is the TTL of stale answers, as recommended by RFC 8767.
+	staleTTL                = 30
+	defaultServeStaleMaxAge = 3 * 24 * time.Hour
+)
+
 var _ adapter.DNSClient = (*Client)(nil)
 
 type Client struct {
@@ -36,6 +42,9 @@ type Client struct {
 	disableCache       bool
 	disableExpire      bool
 	independentCache   bool
+	serveStale         bool
+	serveStaleMaxAge   time.Duration
+	prefetch           bool
 	clientSubnet       netip.Prefix
 	rdrc               adapter.RDRCStore
 	initRDRCFunc       func() adapter.RDRCStore
@@ -44,6 +53,7 @@ type Client struct {
 	cacheLock          compatible.Map[dns.Question, chan struct{}]
 	transportCache     freelru.Cache[transportCacheKey, *dns.Msg]
 	transportCacheLock compatible.Map[dns.Question, chan struct{}]
+	refreshing         compatible.Map[transportCacheKey, struct{}]
 }
 
 type ClientOptions struct {
@@ -52,6 +62,9 @@ type ClientOptions struct {
 	DisableExpire    bool
 	IndependentCache bool
 	CacheCapacity    uint32
+	ServeStale       bool
+	ServeStaleMaxAge time.Duration
+	Prefetch         bool
 	ClientSubnet     netip.Prefix
 	RDRC             func() adapter.RDRCStore
 	Logger           logger.ContextLogger
@@ -63,6 +76,9 @@ func NewClient(options ClientOptions) *Client {
 		disableCache:     options.DisableCache,
 		disableExpire:    options.DisableExpire,
 		independentCache: options.IndependentCache,
+		serveStale:       options.ServeStale && !options.DisableExpire,
+		serveStaleMaxAge: options.ServeStaleMaxAge,
+		prefetch:         options.Prefetch && !options.DisableExpire,
 		clientSubnet:     options.ClientSubnet,
 		initRDRCFunc:     options.RDRC,
 		logger:           options.Logger,
@@ -70,6 +86,9 @@ func NewClient(options ClientOptions) *Client {
 	if client.timeout == 0 {
 		client.timeout = C.DNSTimeout
 	}
+	if client.serveStale && client.serveStaleMaxAge == 0 {
+		client.serveStaleMaxAge = defaultServeStaleMaxAge
+	}
 	cacheCapacity := options.CacheCapacity
 	if cacheCapacity < 1024 {
 		cacheCapacity = 1024
@@ -140,7 +159,7 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 			len(message.Extra[0].(*dns.OPT).Option) == 0) &&
 		!options.ClientSubnet.IsValid()
 	disableCache := !isSimpleRequest || c.disableCache || options.DisableCache
-	if !disableCache {
+	if !disableCache && !isRefreshContext(ctx) {
 		if c.cache != nil {
 			cond, loaded := c.cacheLock.LoadOrStore(question, make(chan struct{}))
 			if loaded {
@@ -170,8 +189,13 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 				}()
 			}
 		}
-		response, ttl := c.loadResponse(question, transport)
+		response, ttl, needRefresh := c.loadResponse(question, transport)
 		if response != nil {
+			if needRefresh {
+				c.refreshAsync(ctx, transport, message, options, responseChecker)
+			}
+			queryStatusFromContext(ctx).setCached()
+			rewriteResponse(ctx, response, options)
 			logCachedResponse(c.logger, ctx, response, ttl)
 			response.Id = message.Id
 			return response, nil
@@ -242,10 +266,18 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 		// TODO: add accept_any rule and support to check response instead of addresses
 		if response.Rcode != dns.RcodeSuccess && response.Rcode != dns.RcodeNameError {
 			rejected = true
-		} else if len(response.Answer) == 0 {
-			rejected = !responseChecker(nil)
 		} else {
-			rejected = !responseChecker(MessageToAddresses(response))
+			checkedResponse := response
+			if len(options.Rewriters) > 0 {
+				// check what the query will return, not the upstream response
+				checkedResponse = response.Copy()
+				rewriteResponse(ctx, checkedResponse, options)
+			}
+			if len(checkedResponse.Answer) == 0 {
+				rejected = !responseChecker(nil)
+			} else {
+				rejected = !responseChecker(MessageToAddresses(checkedResponse))
+			}
 		}
 		if rejected {
 			if !disableCache && c.rdrc != nil {
@@ -306,6 +338,11 @@ func (c *Client) Exchange(ctx context.Context, transport adapter.DNSTransport, m
 	if !disableCache {
 		c.storeCache(transport, question, response, timeToLive)
 	}
+	if len(options.Rewriters) > 0 {
+		// rewriters depend on the matched rule, so the cache keeps the upstream response
+		response = response.Copy()
+		rewriteResponse(ctx, response, options)
+	}
 	response.Id = messageId
 	requestEDNSOpt := message.IsEdns0()
 	responseEDNSOpt := response.IsEdns0()
@@ -385,6 +422,10 @@ func (c *Client) storeCache(transport adapter.DNSTransport, question dns.Questio
 	if timeToLive == 0 {
 		return
 	}
+	lifetime := time.Second * time.Duration(timeToLive)
+	if c.serveStale {
+		lifetime += c.serveStaleMaxAge
+	}
 	if c.disableExpire {
 		if !c.independentCache {
 			c.cache.Add(question, message.Copy())
@@ -396,12 +437,12 @@ func (c *Client) storeCache(transport adapter.DNSTransport, question dns.Questio
 		}
 	} else {
 		if !c.independentCache {
-			c.cache.AddWithLifetime(question, message.Copy(), time.Second*time.Duration(timeToLive))
+			c.cache.AddWithLifetime(question, message.Copy(), lifetime)
 		} else {
 			c.transportCache.AddWithLifetime(transportCacheKey{
 				Question:     question,
 				transportTag: transport.Tag(),
-			}, message.Copy(), time.Second*time.Duration(timeToLive))
+			}, message.Copy(), lifetime)
 		}
 	}
 }
@@ -412,19 +453,19 @@ func (c *Client) lookupToExchange(ctx context.Context, transport adapter.DNSTran
 		Qtype:  qType,
 		Qclass: dns.ClassINET,
 	}
-	disableCache := c.disableCache || options.DisableCache
-	if !disableCache {
-		cachedAddresses, err := c.questionCache(question, transport)
-		if err != ErrNotCached {
-			return cachedAddresses, err
-		}
-	}
 	message := dns.Msg{
 		MsgHdr: dns.MsgHdr{
 			RecursionDesired: true,
 		},
 		Question: []dns.Question{question},
 	}
+	disableCache := c.disableCache || options.DisableCache
+	if !disableCache {
+		cachedAddresses, err := c.questionCache(ctx, transport, &message, options, responseChecker)
+		if err != ErrNotCached {
+			return cachedAddresses, err
+		}
+	}
 	response, err := c.Exchange(ctx, transport, &message, options, responseChecker)
 	if err != nil {
 		return nil, err
@@ -435,18 +476,32 @@ func (c *Client) lookupToExchange(ctx context.Context, transport adapter.DNSTran
 	return MessageToAddresses(response), nil
 }
 
-func (c *Client) questionCache(question dns.Question, transport adapter.DNSTransport) ([]netip.Addr, error) {
-	response, _ := c.loadResponse(question, transport)
+func (c *Client) questionCache(ctx context.Context, transport adapter.DNSTransport, message *dns.Msg, options adapter.DNSQueryOptions, responseChecker func(responseAddrs []netip.Addr) bool) ([]netip.Addr, error) {
+	response, _, needRefresh := c.loadResponse(message.Question[0], transport)
 	if response == nil {
 		return nil, ErrNotCached
 	}
+	if needRefresh {
+		c.refreshAsync(ctx, transport, message, options, responseChecker)
+	}
+	rewriteResponse(ctx, response, options)
 	if response.Rcode != dns.RcodeSuccess {
 		return nil, RcodeError(response.Rcode)
 	}
 	return MessageToAddresses(response), nil
 }
 
-func (c *Client) loadResponse(question dns.Question, transport adapter.DNSTransport) (*dns.Msg, int) {
+// rewriteResponse applies the rewriters of the matched rule in place,
+// the cache always keeps the upstream response.
+func rewriteResponse(ctx context.Context, response *dns.Msg, options adapter.DNSQueryOptions) {
+	for _, rewriter := range options.Rewriters {
+		rewriter.RewriteResponse(ctx, response)
+	}
+}
+
+// loadResponse returns the cached response and its remaining TTL, and whether
+// the entry is stale or close to expiry and should be refreshed in background.
+func (c *Client) loadResponse(question dns.Question, transport adapter.DNSTransport) (*dns.Msg, int, bool) {
 	var (
 		response *dns.Msg
 		loaded   bool
@@ -461,9 +516,9 @@ func (c *Client) loadResponse(question dns.Question, transport adapter.DNSTransp
 			})
 		}
 		if !loaded {
-			return nil, 0
+			return nil, 0, false
 		}
-		return response.Copy(), 0
+		return response.Copy(), 0, false
 	} else {
 		var expireAt time.Time
 		if !c.independentCache {
@@ -475,7 +530,7 @@ func (c *Client) loadResponse(question dns.Question, transport adapter.DNSTransp
 			})
 		}
 		if !loaded {
-			return nil, 0
+			return nil, 0, false
 		}
 		timeNow := time.Now()
 		if timeNow.After(expireAt) {
@@ -487,7 +542,22 @@ func (c *Client) loadResponse(question dns.Question, transport adapter.DNSTransp
 					transportTag: transport.Tag(),
 				})
 			}
-			return nil, 0
+			return nil, 0, false
+		}
+		if c.serveStale {
+			expireAt = expireAt.Add(-c.serveStaleMaxAge)
+			if timeNow.After(expireAt) {
+				response = response.Copy()
+				for _, recordList := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+					for _, record := range recordList {
+						if record.Header().Rrtype == dns.TypeOPT {
+							continue
+						}
+						record.Header().Ttl = staleTTL
+					}
+				}
+				return response, staleTTL, true
+			}
 		}
 		var originTTL int
 		for _, recordList := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
@@ -525,8 +595,28 @@ func (c *Client) loadResponse(question dns.Question, transport adapter.DNSTransp
 				}
 			}
 		}
-		return response, nowTTL
+		needRefresh := c.prefetch && originTTL > 0 && nowTTL <= originTTL/10
+		return response, nowTTL, needRefresh
+	}
+}
+
+func (c *Client) refreshAsync(ctx context.Context, transport adapter.DNSTransport, message *dns.Msg, options adapter.DNSQueryOptions, responseChecker func(responseAddrs []netip.Addr) bool) {
+	key := transportCacheKey{
+		Question:     message.Question[0],
+		transportTag: transport.Tag(),
 	}
+	_, loaded := c.refreshing.LoadOrStore(key, struct{}{})
+	if loaded {
+		return
+	}
+	message = message.Copy()
+	go func() {
+		defer c.refreshing.Delete(key)
+		_, err := c.Exchange(contextWithRefresh(context.WithoutCancel(ctx)), transport, message, options, responseChecker)
+		if err != nil && c.logger != nil {
+			c.logger.DebugContext(ctx, E.Cause(err, "refresh ", FormatQuestion(key.Question.String())))
+		}
+	}()
 }
 
 func MessageToAddresses(response *dns.Msg) []netip.Addr {
@@ -574,6 +664,16 @@ func transportTagFromContext(ctx context.Context) (string, bool) {
 	return value, loaded
 }
 
+type refreshKey struct{}
+
+func contextWithRefresh(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refreshKey{}, true)
+}
+
+func isRefreshContext(ctx context.Context) bool {
+	return ctx.Value(refreshKey{}) != nil
+}
+
 func FixedResponseStatus(message *dns.Msg, rcode int) *dns.Msg {
 	return &dns.Msg{
 		MsgHdr: dns.MsgHdr{
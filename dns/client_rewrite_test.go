@@ -0,0 +1,83 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type testStripRewriter struct{}
+
+func (testStripRewriter) RewriteResponse(ctx context.Context, response *dns.Msg) {
+	response.Answer = nil
+}
+
+func TestClientRewriteNotCached(t *testing.T) {
+	t.Parallel()
+	transport := &testStaleTransport{}
+	client := NewClient(ClientOptions{})
+	message := new(dns.Msg)
+	message.SetQuestion("example.com.", dns.TypeA)
+	rewriteOptions := adapter.DNSQueryOptions{Rewriters: []adapter.DNSResponseRewriter{testStripRewriter{}}}
+	response, err := client.Exchange(context.Background(), transport, message, rewriteOptions, nil)
+	require.NoError(t, err)
+	require.Empty(t, response.Answer)
+	response, err = client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{}, nil)
+	require.NoError(t, err)
+	require.Len(t, response.Answer, 1)
+	response, err = client.Exchange(context.Background(), transport, message, rewriteOptions, nil)
+	require.NoError(t, err)
+	require.Empty(t, response.Answer)
+	response, err = client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{}, nil)
+	require.NoError(t, err)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, int32(1), transport.exchanged.Load())
+}
+
+func TestClientLookupRewriteCached(t *testing.T) {
+	t.Parallel()
+	transport := &testStaleTransport{}
+	client := NewClient(ClientOptions{})
+	rewriteOptions := adapter.DNSQueryOptions{
+		Strategy:  C.DomainStrategyIPv4Only,
+		Rewriters: []adapter.DNSResponseRewriter{testStripRewriter{}},
+	}
+	for i := 0; i < 2; i++ {
+		addresses, err := client.Lookup(context.Background(), transport, "example.com", rewriteOptions, nil)
+		require.NoError(t, err)
+		require.Empty(t, addresses)
+	}
+	addresses, err := client.Lookup(context.Background(), transport, "example.com", adapter.DNSQueryOptions{Strategy: C.DomainStrategyIPv4Only}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []netip.Addr{netip.MustParseAddr("1.1.1.1")}, addresses)
+	require.Equal(t, int32(1), transport.exchanged.Load())
+}
+
+func TestClientRewriteBeforeCheck(t *testing.T) {
+	t.Parallel()
+	transport := &testStaleTransport{}
+	client := NewClient(ClientOptions{})
+	message := new(dns.Msg)
+	message.SetQuestion("example.com.", dns.TypeA)
+	var checked []netip.Addr
+	response, err := client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{
+		Rewriters: []adapter.DNSResponseRewriter{testStripRewriter{}},
+	}, func(responseAddrs []netip.Addr) bool {
+		checked = responseAddrs
+		return true
+	})
+	require.NoError(t, err)
+	require.Empty(t, response.Answer)
+	require.Empty(t, checked)
+	// the upstream response is still cached
+	response, err = client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{}, nil)
+	require.NoError(t, err)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, int32(1), transport.exchanged.Load())
+}
@@ -0,0 +1,57 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type testStaleTransport struct {
+	exchanged atomic.Int32
+	failed    atomic.Bool
+}
+
+func (t *testStaleTransport) Start(stage adapter.StartStage) error { return nil }
+func (t *testStaleTransport) Close() error                         { return nil }
+func (t *testStaleTransport) Type() string                         { return "test" }
+func (t *testStaleTransport) Tag() string                          { return "test" }
+func (t *testStaleTransport) Dependencies() []string               { return nil }
+func (t *testStaleTransport) Reset()                               {}
+
+func (t *testStaleTransport) Exchange(ctx context.Context, message *dns.Msg) (*dns.Msg, error) {
+	t.exchanged.Add(1)
+	if t.failed.Load() {
+		return nil, context.DeadlineExceeded
+	}
+	return FixedResponse(message.Id, message.Question[0], []netip.Addr{netip.MustParseAddr("1.1.1.1")}, 1), nil
+}
+
+func TestClientServeStale(t *testing.T) {
+	t.Parallel()
+	transport := &testStaleTransport{}
+	client := NewClient(ClientOptions{
+		ServeStale:       true,
+		ServeStaleMaxAge: time.Minute,
+	})
+	message := new(dns.Msg)
+	message.SetQuestion("example.com.", dns.TypeA)
+	_, err := client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), transport.exchanged.Load())
+	transport.failed.Store(true)
+	time.Sleep(1100 * time.Millisecond)
+	response, err := client.Exchange(context.Background(), transport, message, adapter.DNSQueryOptions{}, nil)
+	require.NoError(t, err)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, uint32(staleTTL), response.Answer[0].Header().Ttl)
+	require.Eventually(t, func() bool {
+		return transport.exchanged.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,256 @@
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/observable"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	defaultQueryLogCapacity           = 1024
+	defaultQueryLogStatisticsCapacity = 4096
+)
+
+var _ adapter.DNSQueryLog = (*QueryLog)(nil)
+
+type QueryLog struct {
+	access             sync.RWMutex
+	records            []adapter.DNSQueryRecord
+	recordIndex        int
+	recordCount        int
+	nextID             uint64
+	statisticsCapacity int
+	domainStatistics   map[string]*adapter.DNSQueryStatistics
+	clientStatistics   map[string]*adapter.DNSQueryStatistics
+	subscriber         *observable.Subscriber[adapter.DNSQueryRecord]
+	observer           *observable.Observer[adapter.DNSQueryRecord]
+}
+
+func NewQueryLog(options option.DNSQueryLogOptions) *QueryLog {
+	capacity := int(options.Capacity)
+	if capacity == 0 {
+		capacity = defaultQueryLogCapacity
+	}
+	statisticsCapacity := int(options.StatisticsCapacity)
+	if statisticsCapacity == 0 {
+		statisticsCapacity = defaultQueryLogStatisticsCapacity
+	}
+	subscriber := observable.NewSubscriber[adapter.DNSQueryRecord](128)
+	return &QueryLog{
+		records:            make([]adapter.DNSQueryRecord, capacity),
+		statisticsCapacity: statisticsCapacity,
+		domainStatistics:   make(map[string]*adapter.DNSQueryStatistics),
+		clientStatistics:   make(map[string]*adapter.DNSQueryStatistics),
+		subscriber:         subscriber,
+		observer:           observable.NewObserver[adapter.DNSQueryRecord](subscriber, 64),
+	}
+}
+
+func (l *QueryLog) Add(record adapter.DNSQueryRecord) {
+	l.access.Lock()
+	l.nextID++
+	record.ID = l.nextID
+	l.records[l.recordIndex] = record
+	l.recordIndex = (l.recordIndex + 1) % len(l.records)
+	if l.recordCount < len(l.records) {
+		l.recordCount++
+	}
+	l.updateStatistics(l.domainStatistics, record.Domain, &record)
+	if record.Client != "" {
+		l.updateStatistics(l.clientStatistics, record.Client, &record)
+	}
+	l.access.Unlock()
+	l.subscriber.Emit(record)
+}
+
+func (l *QueryLog) updateStatistics(statisticsMap map[string]*adapter.DNSQueryStatistics, name string, record *adapter.DNSQueryRecord) {
+	statistics, loaded := statisticsMap[name]
+	if !loaded {
+		if len(statisticsMap) >= l.statisticsCapacity {
+			evictOldestStatistics(statisticsMap)
+		}
+		statistics = &adapter.DNSQueryStatistics{Name: name}
+		statisticsMap[name] = statistics
+	}
+	statistics.Total++
+	if record.Cached {
+		statistics.Cached++
+	}
+	if record.Error != "" || record.Rcode != "" && record.Rcode != dns.RcodeToString[dns.RcodeSuccess] {
+		statistics.Failed++
+	}
+	statistics.LastSeen = record.Time
+}
+
+func evictOldestStatistics(statisticsMap map[string]*adapter.DNSQueryStatistics) {
+	var (
+		oldestName string
+		oldestTime time.Time
+	)
+	for name, statistics := range statisticsMap {
+		if oldestName == "" || statistics.LastSeen.Before(oldestTime) {
+			oldestName = name
+			oldestTime = statistics.LastSeen
+		}
+	}
+	delete(statisticsMap, oldestName)
+}
+
+// Records returns the latest records, newest first.
+func (l *QueryLog) Records(limit int) []adapter.DNSQueryRecord {
+	l.access.RLock()
+	defer l.access.RUnlock()
+	count := l.recordCount
+	if limit > 0 && limit < count {
+		count = limit
+	}
+	records := make([]adapter.DNSQueryRecord, 0, count)
+	for i := 1; i <= count; i++ {
+		index := (l.recordIndex - i + len(l.records)) % len(l.records)
+		records = append(records, l.records[index])
+	}
+	return records
+}
+
+func (l *QueryLog) DomainStatistics() []adapter.DNSQueryStatistics {
+	l.access.RLock()
+	defer l.access.RUnlock()
+	return sortStatistics(l.domainStatistics)
+}
+
+func (l *QueryLog) ClientStatistics() []adapter.DNSQueryStatistics {
+	l.access.RLock()
+	defer l.access.RUnlock()
+	return sortStatistics(l.clientStatistics)
+}
+
+func sortStatistics(statisticsMap map[string]*adapter.DNSQueryStatistics) []adapter.DNSQueryStatistics {
+	statisticsList := make([]adapter.DNSQueryStatistics, 0, len(statisticsMap))
+	for _, statistics := range statisticsMap {
+		statisticsList = append(statisticsList, *statistics)
+	}
+	sort.Slice(statisticsList, func(i, j int) bool {
+		if statisticsList[i].Total != statisticsList[j].Total {
+			return statisticsList[i].Total > statisticsList[j].Total
+		}
+		return statisticsList[i].Name < statisticsList[j].Name
+	})
+	return statisticsList
+}
+
+func (l *QueryLog) Subscribe() (subscription observable.Subscription[adapter.DNSQueryRecord], done <-chan struct{}, err error) {
+	return l.observer.Subscribe()
+}
+
+func (l *QueryLog) UnSubscribe(subscription observable.Subscription[adapter.DNSQueryRecord]) {
+	l.observer.UnSubscribe(subscription)
+}
+
+func (l *QueryLog) Reset() {
+	l.access.Lock()
+	defer l.access.Unlock()
+	clear(l.records)
+	l.recordIndex = 0
+	l.recordCount = 0
+	l.domainStatistics = make(map[string]*adapter.DNSQueryStatistics)
+	l.clientStatistics = make(map[string]*adapter.DNSQueryStatistics)
+}
+
+func (l *QueryLog) Close() error {
+	return l.subscriber.Close()
+}
+
+type queryStatus struct {
+	rule      string
+	transport string
+	cached    bool
+}
+
+type queryStatusKey struct{}
+
+func contextWithQueryStatus(ctx context.Context) (context.Context, *queryStatus) {
+	status := new(queryStatus)
+	return context.WithValue(ctx, queryStatusKey{}, status), status
+}
+
+func queryStatusFromContext(ctx context.Context) *queryStatus {
+	status, _ := ctx.Value(queryStatusKey{}).(*queryStatus)
+	return status
+}
+
+func (s *queryStatus) update(rule adapter.DNSRule, transport adapter.DNSTransport) {
+	if s == nil {
+		return
+	}
+	if rule != nil {
+		s.rule = F.ToString(rule, " => ", rule.Action())
+	} else {
+		s.rule = ""
+	}
+	if transport != nil {
+		s.transport = transport.Tag()
+	} else {
+		s.transport = ""
+	}
+}
+
+func (s *queryStatus) setCached() {
+	if s == nil {
+		return
+	}
+	s.cached = true
+}
+
+func newQueryRecord(metadata *adapter.InboundContext, message *dns.Msg, response *dns.Msg, err error, status *queryStatus, startedAt time.Time) adapter.DNSQueryRecord {
+	question := message.Question[0]
+	record := adapter.DNSQueryRecord{
+		Time:      startedAt,
+		Domain:    FqdnToDomain(question.Name),
+		QueryType: dns.Type(question.Qtype).String(),
+		Rule:      status.rule,
+		Transport: status.transport,
+		Latency:   time.Since(startedAt),
+		Cached:    status.cached,
+	}
+	if metadata != nil {
+		record.Inbound = metadata.Inbound
+		if metadata.Source.IsIP() {
+			record.Client = metadata.Source.Addr.Unmap().String()
+		}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if response != nil {
+		record.Rcode = dns.RcodeToString[response.Rcode]
+		for _, answer := range response.Answer {
+			record.Answers = append(record.Answers, formatAnswer(answer))
+		}
+	}
+	return record
+}
+
+func formatAnswer(answer dns.RR) string {
+	switch record := answer.(type) {
+	case *dns.A:
+		addr, _ := netip.AddrFromSlice(record.A)
+		return addr.Unmap().String()
+	case *dns.AAAA:
+		addr, _ := netip.AddrFromSlice(record.AAAA)
+		return addr.String()
+	case *dns.CNAME:
+		return FqdnToDomain(record.Target)
+	default:
+		header := answer.Header()
+		return dns.Type(header.Rrtype).String() + " " + FormatQuestion(answer.String()[len(header.String()):])
+	}
+}
@@ -0,0 +1,48 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLogRingBuffer(t *testing.T) {
+	t.Parallel()
+	queryLog := NewQueryLog(option.DNSQueryLogOptions{Capacity: 3})
+	defer queryLog.Close()
+	for _, domain := range []string{"a.com", "b.com", "c.com", "d.com"} {
+		queryLog.Add(adapter.DNSQueryRecord{Time: time.Now(), Domain: domain, Client: "10.0.0.1", Rcode: "NOERROR"})
+	}
+	records := queryLog.Records(0)
+	require.Len(t, records, 3)
+	require.Equal(t, "d.com", records[0].Domain)
+	require.Equal(t, "b.com", records[2].Domain)
+	require.Equal(t, uint64(4), records[0].ID)
+	require.Len(t, queryLog.Records(1), 1)
+	clients := queryLog.ClientStatistics()
+	require.Len(t, clients, 1)
+	require.Equal(t, uint64(4), clients[0].Total)
+	queryLog.Reset()
+	require.Empty(t, queryLog.Records(0))
+	require.Empty(t, queryLog.DomainStatistics())
+}
+
+func TestQueryLogStatistics(t *testing.T) {
+	t.Parallel()
+	queryLog := NewQueryLog(option.DNSQueryLogOptions{StatisticsCapacity: 2})
+	defer queryLog.Close()
+	now := time.Now()
+	queryLog.Add(adapter.DNSQueryRecord{Time: now, Domain: "a.com", Rcode: "NOERROR", Cached: true})
+	queryLog.Add(adapter.DNSQueryRecord{Time: now.Add(time.Second), Domain: "a.com", Rcode: "SERVFAIL"})
+	queryLog.Add(adapter.DNSQueryRecord{Time: now.Add(2 * time.Second), Domain: "b.com", Error: "timeout"})
+	queryLog.Add(adapter.DNSQueryRecord{Time: now.Add(3 * time.Second), Domain: "c.com", Rcode: "NOERROR"})
+	domains := queryLog.DomainStatistics()
+	require.Len(t, domains, 2)
+	require.Equal(t, "b.com", domains[0].Name)
+	require.Equal(t, uint64(1), domains[0].Failed)
+	require.Equal(t, "c.com", domains[1].Name)
+}
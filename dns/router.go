@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"net/netip"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -34,9 +36,11 @@ type Router struct {
 	transport             adapter.DNSTransportManager
 	outbound              adapter.OutboundManager
 	client                adapter.DNSClient
+	ruleAccess            sync.RWMutex
 	rules                 []adapter.DNSRule
 	defaultDomainStrategy C.DomainStrategy
 	dnsReverseMapping     freelru.Cache[netip.Addr, string]
+	queryLog              *QueryLog
 	platformInterface     adapter.PlatformInterface
 }
 
@@ -54,6 +58,9 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.DNSOp
 		DisableExpire:    options.DNSClientOptions.DisableExpire,
 		IndependentCache: options.DNSClientOptions.IndependentCache,
 		CacheCapacity:    options.DNSClientOptions.CacheCapacity,
+		ServeStale:       options.DNSClientOptions.ServeStale,
+		ServeStaleMaxAge: time.Duration(options.DNSClientOptions.ServeStaleMaxAge),
+		Prefetch:         options.DNSClientOptions.Prefetch,
 		ClientSubnet:     options.DNSClientOptions.ClientSubnet.Build(netip.Prefix{}),
 		RDRC: func() adapter.RDRCStore {
 			cacheFile := service.FromContext[adapter.CacheFile](ctx)
@@ -67,9 +74,13 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.DNSOp
 		},
 		Logger: router.logger,
 	})
+	service.MustRegister[adapter.DNSClient](ctx, router.client)
 	if options.ReverseMapping {
 		router.dnsReverseMapping = common.Must1(freelru.NewSharded[netip.Addr, string](1024, maphash.NewHasher[netip.Addr]().Hash32))
 	}
+	if options.QueryLog != nil && options.QueryLog.Enabled {
+		router.queryLog = NewQueryLog(*options.QueryLog)
+	}
 	return router
 }
 
@@ -84,6 +95,40 @@ func (r *Router) Initialize(rules []option.DNSRule) error {
 	return nil
 }
 
+// NewRules parses DNS rules to replace current rules with SwapRules.
+// The rules are not started, so that they can be checked before other changes are applied.
+func (r *Router) NewRules(rules []option.DNSRule) ([]adapter.DNSRule, error) {
+	newRules := make([]adapter.DNSRule, 0, len(rules))
+	for i, ruleOptions := range rules {
+		dnsRule, err := R.NewDNSRule(r.ctx, r.logger, ruleOptions, true)
+		if err != nil {
+			closeRules(newRules)
+			return nil, E.Cause(err, "parse dns rule[", i, "]")
+		}
+		newRules = append(newRules, dnsRule)
+	}
+	return newRules, nil
+}
+
+// SwapRules replaces DNS rules with started rules from NewRules in place of a restart.
+// swap is called with the rule lock held, so that route rules can be replaced in the same step.
+func (r *Router) SwapRules(rules []adapter.DNSRule, swap func()) {
+	r.ruleAccess.Lock()
+	oldRules := r.rules
+	r.rules = rules
+	if swap != nil {
+		swap()
+	}
+	r.ruleAccess.Unlock()
+	closeRules(oldRules)
+}
+
+func closeRules(rules []adapter.DNSRule) {
+	for _, rule := range rules {
+		rule.Close()
+	}
+}
+
 func (r *Router) Start(stage adapter.StartStage) error {
 	monitor := taskmonitor.New(r.logger, C.StartTimeout)
 	switch stage {
@@ -114,6 +159,11 @@ func (r *Router) Close() error {
 		})
 		monitor.Finish()
 	}
+	if r.queryLog != nil {
+		err = E.Append(err, r.queryLog.Close(), func(err error) error {
+			return E.Cause(err, "close dns query log")
+		})
+	}
 	return err
 }
 
@@ -126,8 +176,11 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 	if ruleIndex != -1 {
 		currentRuleIndex = ruleIndex + 1
 	}
-	for ; currentRuleIndex < len(r.rules); currentRuleIndex++ {
-		currentRule := r.rules[currentRuleIndex]
+	r.ruleAccess.RLock()
+	rules := r.rules
+	r.ruleAccess.RUnlock()
+	for ; currentRuleIndex < len(rules); currentRuleIndex++ {
+		currentRule := rules[currentRuleIndex]
 		if currentRule.WithAddressLimit() && !isAddressQuery {
 			continue
 		}
@@ -188,6 +241,8 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 				if action.ClientSubnet.IsValid() {
 					options.ClientSubnet = action.ClientSubnet
 				}
+			case *R.RuleActionDNSRewrite:
+				options.Rewriters = append(slices.Clip(options.Rewriters), action)
 			case *R.RuleActionReject:
 				return nil, currentRule, currentRuleIndex
 			case *R.RuleActionPredefined:
@@ -208,6 +263,18 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, ruleIndex int,
 }
 
 func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg, options adapter.DNSQueryOptions) (*mDNS.Msg, error) {
+	if r.queryLog == nil || len(message.Question) != 1 {
+		return r.exchange(ctx, message, options)
+	}
+	startedAt := time.Now()
+	metadata := adapter.ContextFrom(ctx)
+	ctx, status := contextWithQueryStatus(ctx)
+	response, err := r.exchange(ctx, message, options)
+	r.queryLog.Add(newQueryRecord(metadata, message, response, err, status, startedAt))
+	return response, err
+}
+
+func (r *Router) exchange(ctx context.Context, message *mDNS.Msg, options adapter.DNSQueryOptions) (*mDNS.Msg, error) {
 	if len(message.Question) != 1 {
 		r.logger.WarnContext(ctx, "bad question size: ", len(message.Question))
 		responseMessage := mDNS.Msg{
@@ -237,8 +304,10 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg, options adapte
 		metadata.IPVersion = 6
 	}
 	metadata.Domain = FqdnToDomain(message.Question[0].Name)
+	status := queryStatusFromContext(ctx)
 	if options.Transport != nil {
 		transport = options.Transport
+		status.update(nil, transport)
 		if legacyTransport, isLegacy := transport.(adapter.LegacyDNSTransport); isLegacy {
 			if options.Strategy == C.DomainStrategyAsIS {
 				options.Strategy = legacyTransport.LegacyStrategy()
@@ -261,6 +330,7 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg, options adapte
 			dnsCtx := adapter.OverrideContext(ctx)
 			dnsOptions := options
 			transport, rule, ruleIndex = r.matchDNS(ctx, true, ruleIndex, isAddressQuery(message), &dnsOptions)
+			status.update(rule, transport)
 			if rule != nil {
 				switch action := rule.Action().(type) {
 				case *R.RuleActionReject:
@@ -452,6 +522,13 @@ func (r *Router) LookupReverseMapping(ip netip.Addr) (string, bool) {
 	return domain, loaded
 }
 
+func (r *Router) QueryLog() adapter.DNSQueryLog {
+	if r.queryLog == nil {
+		return nil
+	}
+	return r.queryLog
+}
+
 func (r *Router) ResetNetwork() {
 	r.ClearCache()
 	for _, transport := range r.transport.Transports() {
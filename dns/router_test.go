@@ -0,0 +1,45 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json/badoption"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterSwapRules(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(service.ContextWithDefaultRegistry(context.Background()), log.NewNOPFactory(), option.DNSOptions{})
+	newRule := func(domain string) option.DNSRule {
+		return option.DNSRule{
+			Type: C.RuleTypeDefault,
+			DefaultOptions: option.DefaultDNSRule{
+				RawDefaultDNSRule: option.RawDefaultDNSRule{Domain: badoption.Listable[string]{domain}},
+				DNSRuleAction: option.DNSRuleAction{
+					Action:       C.RuleActionTypeRoute,
+					RouteOptions: option.DNSRouteActionOptions{Server: "remote"},
+				},
+			},
+		}
+	}
+	require.NoError(t, router.Initialize([]option.DNSRule{newRule("a.com")}))
+	rules, err := router.NewRules([]option.DNSRule{newRule("b.com"), newRule("c.com")})
+	require.NoError(t, err)
+	require.Len(t, router.rules, 1)
+	var swapped bool
+	router.SwapRules(rules, func() {
+		swapped = true
+	})
+	require.True(t, swapped)
+	require.Len(t, router.rules, 2)
+	require.Equal(t, "domain=b.com", router.rules[0].String())
+	_, err = router.NewRules([]option.DNSRule{{Type: "unknown"}})
+	require.Error(t, err)
+	require.Len(t, router.rules, 2)
+}
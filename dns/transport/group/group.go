@@ -0,0 +1,277 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	R "github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+)
+
+func RegisterTransport(registry *dns.TransportRegistry) {
+	dns.RegisterTransport[option.GroupDNSServerOptions](registry, C.DNSTypeGroup, NewTransport)
+}
+
+var _ adapter.DNSTransport = (*Transport)(nil)
+
+type Transport struct {
+	dns.TransportAdapter
+	ctx        context.Context
+	logger     log.ContextLogger
+	strategy   string
+	serverTags []string
+	servers    []adapter.DNSTransport
+	client     adapter.DNSClient
+	validator  adapter.DNSRule
+}
+
+func NewTransport(ctx context.Context, logger log.ContextLogger, tag string, options option.GroupDNSServerOptions) (adapter.DNSTransport, error) {
+	if len(options.Servers) == 0 {
+		return nil, E.New("missing servers")
+	}
+	for _, server := range options.Servers {
+		if server == tag {
+			return nil, E.New("group can not contain itself")
+		}
+	}
+	transport := &Transport{
+		TransportAdapter: dns.NewTransportAdapter(C.DNSTypeGroup, tag, options.Servers),
+		ctx:              ctx,
+		logger:           logger,
+		strategy:         options.Strategy,
+		serverTags:       options.Servers,
+	}
+	hasCondition := len(options.IPCIDR) > 0 || options.IPIsPrivate || len(options.RuleSet) > 0
+	switch transport.strategy {
+	case "":
+		transport.strategy = C.DNSGroupStrategyRace
+		fallthrough
+	case C.DNSGroupStrategyRace, C.DNSGroupStrategyFallback:
+		if hasCondition {
+			return nil, E.New("ip_cidr, ip_is_private and rule_set are only supported by validate strategy")
+		}
+	case C.DNSGroupStrategyValidate:
+		if len(options.Servers) < 2 {
+			return nil, E.New("validate strategy requires at least two servers")
+		}
+		if !hasCondition {
+			return nil, E.New("validate strategy requires ip_cidr, ip_is_private or rule_set")
+		}
+		validator, err := R.NewDefaultDNSRule(ctx, logger, option.DefaultDNSRule{
+			RawDefaultDNSRule: option.RawDefaultDNSRule{
+				IPCIDR:                   options.IPCIDR,
+				IPIsPrivate:              options.IPIsPrivate,
+				RuleSet:                  options.RuleSet,
+				RuleSetIPCIDRAcceptEmpty: options.RuleSetIPCIDRAcceptEmpty,
+			},
+		})
+		if err != nil {
+			return nil, E.Cause(err, "create validator")
+		}
+		transport.validator = validator
+	default:
+		return nil, E.New("unknown strategy: ", transport.strategy)
+	}
+	return transport, nil
+}
+
+func (t *Transport) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	transportManager := service.FromContext[adapter.DNSTransportManager](t.ctx)
+	for _, tag := range t.serverTags {
+		server, loaded := transportManager.Transport(tag)
+		if !loaded {
+			return E.New("DNS server not found: ", tag)
+		}
+		t.servers = append(t.servers, server)
+	}
+	err := t.checkLoop(transportManager, []string{t.Tag()}, t.serverTags)
+	if err != nil {
+		return err
+	}
+	if t.validator != nil {
+		t.client = service.FromContext[adapter.DNSClient](t.ctx)
+		if t.client == nil {
+			return E.New("missing DNS client")
+		}
+		err = t.validator.Start()
+		if err != nil {
+			return E.Cause(err, "initialize validator")
+		}
+	}
+	return nil
+}
+
+// checkLoop rejects nested groups containing this group,
+// which are not covered by the startup dependency check when servers are created later.
+func (t *Transport) checkLoop(transportManager adapter.DNSTransportManager, path []string, serverTags []string) error {
+	for _, tag := range serverTags {
+		if tag == t.Tag() {
+			return E.New("circular server dependency: ", strings.Join(append(path, tag), " -> "))
+		}
+		if common.Contains(path, tag) {
+			continue
+		}
+		server, loaded := transportManager.Transport(tag)
+		if !loaded {
+			continue
+		}
+		group, isGroup := server.(*Transport)
+		if !isGroup {
+			continue
+		}
+		err := t.checkLoop(transportManager, append(path, tag), group.serverTags)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Transport) Close() error {
+	if t.validator != nil {
+		return t.validator.Close()
+	}
+	return nil
+}
+
+func (t *Transport) Reset() {
+}
+
+func (t *Transport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	switch t.strategy {
+	case C.DNSGroupStrategyFallback:
+		return t.exchangeFallback(ctx, message, t.servers)
+	case C.DNSGroupStrategyValidate:
+		return t.exchangeValidate(ctx, message)
+	default:
+		return t.exchangeRace(ctx, message)
+	}
+}
+
+type exchangeResult struct {
+	server   adapter.DNSTransport
+	response *mDNS.Msg
+	err      error
+}
+
+func (t *Transport) exchangeRace(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan exchangeResult, len(t.servers))
+	for _, server := range t.servers {
+		go func() {
+			response, err := exchangeOne(ctx, server, message)
+			results <- exchangeResult{server, response, err}
+		}()
+	}
+	var (
+		lastResponse *mDNS.Msg
+		errorList    []error
+	)
+	for range t.servers {
+		result := <-results
+		if result.err != nil {
+			errorList = append(errorList, E.Cause(result.err, result.server.Tag()))
+			continue
+		}
+		if isSuccessResponse(result.response) {
+			t.logger.DebugContext(ctx, "race won by ", result.server.Tag())
+			return result.response, nil
+		}
+		lastResponse = result.response
+	}
+	if lastResponse != nil {
+		return lastResponse, nil
+	}
+	return nil, E.Errors(errorList...)
+}
+
+func (t *Transport) exchangeFallback(ctx context.Context, message *mDNS.Msg, servers []adapter.DNSTransport) (*mDNS.Msg, error) {
+	var (
+		lastResponse *mDNS.Msg
+		errorList    []error
+	)
+	for _, server := range servers {
+		response, err := exchangeOne(ctx, server, message)
+		if err != nil {
+			if E.IsClosedOrCanceled(err) && ctx.Err() != nil {
+				return nil, err
+			}
+			t.logger.DebugContext(ctx, "exchange failed on ", server.Tag(), ": ", err)
+			errorList = append(errorList, E.Cause(err, server.Tag()))
+			continue
+		}
+		if isSuccessResponse(response) {
+			return response, nil
+		}
+		lastResponse = response
+	}
+	if lastResponse != nil {
+		return lastResponse, nil
+	}
+	return nil, E.Errors(errorList...)
+}
+
+func (t *Transport) exchangeValidate(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	if len(message.Question) == 0 || (message.Question[0].Qtype != mDNS.TypeA && message.Question[0].Qtype != mDNS.TypeAAAA) {
+		// only address answers can be validated
+		return t.exchangeFallback(ctx, message, t.servers)
+	}
+	primary := t.servers[0]
+	response, err := t.client.Exchange(ctx, primary, message.Copy(), adapter.DNSQueryOptions{DisableCache: true}, t.checkAddresses(ctx))
+	if err == nil {
+		return response, nil
+	}
+	if E.IsClosedOrCanceled(err) && ctx.Err() != nil {
+		return nil, err
+	}
+	if errors.Is(err, dns.ErrResponseRejected) {
+		t.logger.DebugContext(ctx, "response from ", primary.Tag(), " rejected by validator")
+	} else {
+		t.logger.DebugContext(ctx, "exchange failed on ", primary.Tag(), ": ", err)
+	}
+	return t.exchangeFallback(ctx, message, t.servers[1:])
+}
+
+func (t *Transport) checkAddresses(ctx context.Context) func(responseAddrs []netip.Addr) bool {
+	var metadata adapter.InboundContext
+	if contextMetadata := adapter.ContextFrom(ctx); contextMetadata != nil {
+		metadata = *contextMetadata
+	}
+	return func(responseAddrs []netip.Addr) bool {
+		if len(responseAddrs) == 0 {
+			// NODATA and NXDOMAIN responses have nothing to validate
+			return true
+		}
+		checkMetadata := metadata
+		checkMetadata.DestinationAddresses = responseAddrs
+		return t.validator.MatchAddressLimit(&checkMetadata)
+	}
+}
+
+func exchangeOne(ctx context.Context, server adapter.DNSTransport, message *mDNS.Msg) (*mDNS.Msg, error) {
+	response, err := server.Exchange(ctx, message.Copy())
+	if err != nil {
+		return nil, err
+	}
+	response.Id = message.Id
+	return response, nil
+}
+
+func isSuccessResponse(response *mDNS.Msg) bool {
+	return response.Rcode == mDNS.RcodeSuccess || response.Rcode == mDNS.RcodeNameError
+}
@@ -0,0 +1,167 @@
+package group
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json/badoption"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	tag     string
+	delay   time.Duration
+	address netip.Addr
+	rcode   int
+	err     error
+}
+
+func (t *testTransport) Start(stage adapter.StartStage) error { return nil }
+func (t *testTransport) Close() error                         { return nil }
+func (t *testTransport) Type() string                         { return "test" }
+func (t *testTransport) Tag() string                          { return t.tag }
+func (t *testTransport) Dependencies() []string               { return nil }
+func (t *testTransport) Reset()                               {}
+
+func (t *testTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	select {
+	case <-time.After(t.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	if t.rcode != mDNS.RcodeSuccess {
+		return dns.FixedResponseStatus(message, t.rcode), nil
+	}
+	return dns.FixedResponse(message.Id, message.Question[0], []netip.Addr{t.address}, C.DefaultDNSTTL), nil
+}
+
+func newTestTransport(t *testing.T, options option.GroupDNSServerOptions, servers ...adapter.DNSTransport) *Transport {
+	ctx := service.ContextWith[adapter.DNSClient](context.Background(), dns.NewClient(dns.ClientOptions{}))
+	transport, err := NewTransport(ctx, log.NewNOPFactory().Logger(), "group", options)
+	require.NoError(t, err)
+	groupTransport := transport.(*Transport)
+	groupTransport.servers = servers
+	if groupTransport.validator != nil {
+		groupTransport.client = service.FromContext[adapter.DNSClient](ctx)
+		require.NoError(t, groupTransport.validator.Start())
+	}
+	return groupTransport
+}
+
+func exchangeAddress(t *testing.T, transport *Transport) netip.Addr {
+	message := new(mDNS.Msg)
+	message.SetQuestion("example.com.", mDNS.TypeA)
+	response, err := transport.Exchange(context.Background(), message)
+	require.NoError(t, err)
+	require.Equal(t, message.Id, response.Id)
+	addresses := dns.MessageToAddresses(response)
+	require.Len(t, addresses, 1)
+	return addresses[0]
+}
+
+func TestGroupRace(t *testing.T) {
+	t.Parallel()
+	transport := newTestTransport(t, option.GroupDNSServerOptions{
+		Servers: []string{"slow", "failed", "fast"},
+	},
+		&testTransport{tag: "slow", delay: time.Second, address: netip.MustParseAddr("1.1.1.1")},
+		&testTransport{tag: "failed", err: E.New("failed")},
+		&testTransport{tag: "fast", delay: 10 * time.Millisecond, address: netip.MustParseAddr("2.2.2.2")},
+	)
+	require.Equal(t, netip.MustParseAddr("2.2.2.2"), exchangeAddress(t, transport))
+}
+
+func TestGroupFallback(t *testing.T) {
+	t.Parallel()
+	transport := newTestTransport(t, option.GroupDNSServerOptions{
+		Servers:  []string{"failed", "second", "third"},
+		Strategy: C.DNSGroupStrategyFallback,
+	},
+		&testTransport{tag: "failed", err: E.New("failed")},
+		&testTransport{tag: "second", address: netip.MustParseAddr("2.2.2.2")},
+		&testTransport{tag: "third", address: netip.MustParseAddr("3.3.3.3")},
+	)
+	require.Equal(t, netip.MustParseAddr("2.2.2.2"), exchangeAddress(t, transport))
+}
+
+func TestGroupValidate(t *testing.T) {
+	t.Parallel()
+	options := option.GroupDNSServerOptions{
+		Servers:  []string{"domestic", "foreign"},
+		Strategy: C.DNSGroupStrategyValidate,
+		IPCIDR:   badoption.Listable[string]{"10.0.0.0/8"},
+	}
+	foreign := &testTransport{tag: "foreign", address: netip.MustParseAddr("8.8.8.8")}
+	transport := newTestTransport(t, options, &testTransport{tag: "domestic", address: netip.MustParseAddr("10.0.0.1")}, foreign)
+	require.Equal(t, netip.MustParseAddr("10.0.0.1"), exchangeAddress(t, transport))
+	transport = newTestTransport(t, options, &testTransport{tag: "domestic", address: netip.MustParseAddr("1.1.1.1")}, foreign)
+	require.Equal(t, netip.MustParseAddr("8.8.8.8"), exchangeAddress(t, transport))
+}
+
+func TestGroupValidatePassThrough(t *testing.T) {
+	t.Parallel()
+	options := option.GroupDNSServerOptions{
+		Servers:  []string{"domestic", "foreign"},
+		Strategy: C.DNSGroupStrategyValidate,
+		IPCIDR:   badoption.Listable[string]{"10.0.0.0/8"},
+	}
+	// responses without addresses are returned from the primary server without falling back
+	foreign := &testTransport{tag: "foreign", err: E.New("unexpected fallback")}
+	transport := newTestTransport(t, options, &testTransport{tag: "domestic", address: netip.MustParseAddr("1.1.1.1")}, foreign)
+	for _, queryType := range []uint16{mDNS.TypeMX, mDNS.TypeTXT, mDNS.TypeHTTPS, mDNS.TypeAAAA} {
+		message := new(mDNS.Msg)
+		message.SetQuestion("example.com.", queryType)
+		response, err := transport.Exchange(context.Background(), message)
+		require.NoError(t, err, mDNS.TypeToString[queryType])
+		require.Equal(t, mDNS.RcodeSuccess, response.Rcode)
+	}
+	transport = newTestTransport(t, options, &testTransport{tag: "domestic", rcode: mDNS.RcodeNameError}, foreign)
+	message := new(mDNS.Msg)
+	message.SetQuestion("example.com.", mDNS.TypeA)
+	response, err := transport.Exchange(context.Background(), message)
+	require.NoError(t, err)
+	require.Equal(t, mDNS.RcodeNameError, response.Rcode)
+}
+
+type testTransportManager struct {
+	adapter.DNSTransportManager
+	transports map[string]adapter.DNSTransport
+}
+
+func (m *testTransportManager) Transport(tag string) (adapter.DNSTransport, bool) {
+	transport, loaded := m.transports[tag]
+	return transport, loaded
+}
+
+func TestGroupNestedLoop(t *testing.T) {
+	t.Parallel()
+	manager := &testTransportManager{transports: make(map[string]adapter.DNSTransport)}
+	manager.transports["direct"] = &testTransport{tag: "direct"}
+	for tag, servers := range map[string][]string{
+		"outer":  {"direct", "inner"},
+		"inner":  {"direct", "nested"},
+		"nested": {"outer"},
+	} {
+		transport, err := NewTransport(context.Background(), log.NewNOPFactory().Logger(), tag, option.GroupDNSServerOptions{Servers: servers})
+		require.NoError(t, err)
+		manager.transports[tag] = transport
+	}
+	group := manager.transports["outer"].(*Transport)
+	require.ErrorContains(t, group.checkLoop(manager, []string{group.Tag()}, group.serverTags), "circular server dependency: outer -> inner -> nested -> outer")
+	manager.transports["nested"] = &testTransport{tag: "nested"}
+	require.NoError(t, group.checkLoop(manager, []string{group.Tag()}, group.serverTags))
+}
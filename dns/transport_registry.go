@@ -2,6 +2,7 @@ package dns
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -54,6 +55,17 @@ func (r *TransportRegistry) CreateOptions(transportType string) (any, bool) {
 	return optionsConstructor(), true
 }
 
+func (r *TransportRegistry) Types() []string {
+	r.access.Lock()
+	defer r.access.Unlock()
+	types := make([]string, 0, len(r.optionsType))
+	for optionsType := range r.optionsType {
+		types = append(types, optionsType)
+	}
+	sort.Strings(types)
+	return types
+}
+
 func (r *TransportRegistry) CreateDNSTransport(ctx context.Context, logger log.ContextLogger, tag string, transportType string, options any) (adapter.DNSTransport, error) {
 	r.access.Lock()
 	defer r.access.Unlock()
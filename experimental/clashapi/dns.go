@@ -16,6 +16,11 @@ import (
 func dnsRouter(router adapter.DNSRouter) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/query", queryDNS(router))
+	r.Route("/queries", func(r chi.Router) {
+		r.Get("/", getDNSQueries(router))
+		r.Delete("/", resetDNSQueries(router))
+		r.Get("/stats", getDNSQueryStatistics(router))
+	})
 	return r
 }
 
@@ -0,0 +1,166 @@
+package clashapi
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsutil"
+
+	"github.com/go-chi/render"
+)
+
+type dnsQueryFilter struct {
+	domain string
+	client string
+}
+
+func newDNSQueryFilter(r *http.Request) dnsQueryFilter {
+	return dnsQueryFilter{
+		domain: strings.ToLower(r.URL.Query().Get("domain")),
+		client: r.URL.Query().Get("client"),
+	}
+}
+
+func (f dnsQueryFilter) match(record adapter.DNSQueryRecord) bool {
+	if f.domain != "" && !strings.Contains(record.Domain, f.domain) {
+		return false
+	}
+	if f.client != "" && record.Client != f.client {
+		return false
+	}
+	return true
+}
+
+func getDNSQueries(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryLog := router.QueryLog()
+		if queryLog == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("DNS query log is not enabled"))
+			return
+		}
+		filter := newDNSQueryFilter(r)
+		if r.Header.Get("Upgrade") != "websocket" {
+			var limit int
+			if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+				var err error
+				limit, err = strconv.Atoi(limitStr)
+				if err != nil {
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, ErrBadRequest)
+					return
+				}
+			}
+			records := common.Filter(queryLog.Records(0), filter.match)
+			if limit > 0 && len(records) > limit {
+				records = records[:limit]
+			}
+			render.JSON(w, r, render.M{
+				"queries": records,
+			})
+			return
+		}
+
+		subscription, done, err := queryLog.Subscribe()
+		if err != nil {
+			render.Status(r, http.StatusNoContent)
+			return
+		}
+		defer queryLog.UnSubscribe(subscription)
+
+		var conn net.Conn
+		conn, _, _, err = ws.UpgradeHTTP(r, w)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		buf := &bytes.Buffer{}
+		var record adapter.DNSQueryRecord
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-done:
+				return
+			case record = <-subscription:
+			}
+			if !filter.match(record) {
+				continue
+			}
+			buf.Reset()
+			err = json.NewEncoder(buf).Encode(record)
+			if err != nil {
+				return
+			}
+			err = wsutil.WriteServerText(conn, buf.Bytes())
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func resetDNSQueries(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryLog := router.QueryLog()
+		if queryLog == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("DNS query log is not enabled"))
+			return
+		}
+		queryLog.Reset()
+		render.NoContent(w, r)
+	}
+}
+
+func getDNSQueryStatistics(router adapter.DNSRouter) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryLog := router.QueryLog()
+		if queryLog == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, newError("DNS query log is not enabled"))
+			return
+		}
+		domains := queryLog.DomainStatistics()
+		clients := queryLog.ClientStatistics()
+		var (
+			total  uint64
+			cached uint64
+			failed uint64
+		)
+		for _, statistics := range domains {
+			total += statistics.Total
+			cached += statistics.Cached
+			failed += statistics.Failed
+		}
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			limit, err := strconv.Atoi(limitStr)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, ErrBadRequest)
+				return
+			}
+			if limit > 0 && len(domains) > limit {
+				domains = domains[:limit]
+			}
+			if limit > 0 && len(clients) > limit {
+				clients = clients[:limit]
+			}
+		}
+		render.JSON(w, r, render.M{
+			"total":   total,
+			"cached":  cached,
+			"failed":  failed,
+			"domains": domains,
+			"clients": clients,
+		})
+	}
+}
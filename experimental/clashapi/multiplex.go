@@ -0,0 +1,86 @@
+package clashapi
+
+import (
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func multiplexRouter(server *Server) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", getMultiplexSessions(server))
+	r.Route("/{name}", func(r chi.Router) {
+		r.Get("/", getOutboundMultiplexSessions(server))
+		r.Delete("/", drainOutboundMultiplex(server))
+	})
+	return r
+}
+
+func getMultiplexSessions(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		outbounds := make(map[string][]adapter.MultiplexSession)
+		for _, outbound := range server.outbound.Outbounds() {
+			multiplexOutbound, isMultiplex := outbound.(adapter.MultiplexOutbound)
+			if !isMultiplex {
+				continue
+			}
+			client := multiplexOutbound.MultiplexClient()
+			if client == nil {
+				continue
+			}
+			outbounds[outbound.Tag()] = sessionsOrEmpty(client.Sessions())
+		}
+		render.JSON(w, r, render.M{
+			"outbounds": outbounds,
+		})
+	}
+}
+
+func getOutboundMultiplexSessions(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := findMultiplexClient(server, getEscapeParam(r, "name"))
+		if client == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, ErrNotFound)
+			return
+		}
+		render.JSON(w, r, render.M{
+			"sessions": sessionsOrEmpty(client.Sessions()),
+		})
+	}
+}
+
+func drainOutboundMultiplex(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := findMultiplexClient(server, getEscapeParam(r, "name"))
+		if client == nil {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, ErrNotFound)
+			return
+		}
+		client.Drain()
+		render.NoContent(w, r)
+	}
+}
+
+func findMultiplexClient(server *Server, tag string) adapter.MultiplexClient {
+	outbound, loaded := server.outbound.Outbound(tag)
+	if !loaded {
+		return nil
+	}
+	multiplexOutbound, isMultiplex := outbound.(adapter.MultiplexOutbound)
+	if !isMultiplex {
+		return nil
+	}
+	return multiplexOutbound.MultiplexClient()
+}
+
+func sessionsOrEmpty(sessions []adapter.MultiplexSession) []adapter.MultiplexSession {
+	if sessions == nil {
+		return []adapter.MultiplexSession{}
+	}
+	return sessions
+}
@@ -11,7 +11,7 @@ import (
 func reload(server *Server) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
-			server.logger.Warn("sing-box restarting...")
+			server.logger.Warn("sing-box reloading...")
 			server.router.Reload()
 		}()
 		render.NoContent(w, r)
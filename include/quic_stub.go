@@ -16,6 +16,7 @@ import (
 	"github.com/sagernet/sing-box/dns"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	dnsInbound "github.com/sagernet/sing-box/protocol/dns"
 	"github.com/sagernet/sing-box/protocol/naive"
 	"github.com/sagernet/sing-box/transport/v2ray"
 	"github.com/sagernet/sing/common/logger"
@@ -47,6 +48,12 @@ func registerQUICInbounds(registry *inbound.Registry) {
 	naive.ConfigureHTTP3ListenerFunc = func(ctx context.Context, logger logger.Logger, listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig, options option.NaiveInboundOptions) (io.Closer, error) {
 		return nil, C.ErrQUICNotIncluded
 	}
+	dnsInbound.ListenHTTP3Func = func(ctx context.Context, logger logger.ContextLogger, listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig) (io.Closer, error) {
+		return nil, C.ErrQUICNotIncluded
+	}
+	dnsInbound.ListenQUICFunc = func(ctx context.Context, logger logger.ContextLogger, listener *listener.Listener, tlsConfig tls.ServerConfig, router adapter.DNSRouter, metadata adapter.InboundContext) (io.Closer, error) {
+		return nil, C.ErrQUICNotIncluded
+	}
 }
 
 func registerQUICOutbounds(registry *outbound.Registry) {
@@ -19,10 +19,11 @@ import (
 )
 
 type RawDNSOptions struct {
-	Servers        []DNSServerOptions `json:"servers,omitempty"`
-	Rules          []DNSRule          `json:"rules,omitempty"`
-	Final          string             `json:"final,omitempty"`
-	ReverseMapping bool               `json:"reverse_mapping,omitempty"`
+	Servers        []DNSServerOptions  `json:"servers,omitempty"`
+	Rules          []DNSRule           `json:"rules,omitempty"`
+	Final          string              `json:"final,omitempty"`
+	ReverseMapping bool                `json:"reverse_mapping,omitempty"`
+	QueryLog       *DNSQueryLogOptions `json:"query_log,omitempty"`
 	DNSClientOptions
 }
 
@@ -108,9 +109,18 @@ type DNSClientOptions struct {
 	DisableExpire    bool                  `json:"disable_expire,omitempty"`
 	IndependentCache bool                  `json:"independent_cache,omitempty"`
 	CacheCapacity    uint32                `json:"cache_capacity,omitempty"`
+	ServeStale       bool                  `json:"serve_stale,omitempty"`
+	ServeStaleMaxAge badoption.Duration    `json:"serve_stale_max_age,omitempty"`
+	Prefetch         bool                  `json:"prefetch,omitempty"`
 	ClientSubnet     *badoption.Prefixable `json:"client_subnet,omitempty"`
 }
 
+type DNSQueryLogOptions struct {
+	Enabled            bool   `json:"enabled,omitempty"`
+	Capacity           uint32 `json:"capacity,omitempty"`
+	StatisticsCapacity uint32 `json:"statistics_capacity,omitempty"`
+}
+
 type LegacyDNSFakeIPOptions struct {
 	Enabled    bool              `json:"enabled,omitempty"`
 	Inet4Range *badoption.Prefix `json:"inet4_range,omitempty"`
@@ -403,6 +413,15 @@ type FakeIPDNSServerOptions struct {
 	Inet6Range *badoption.Prefix `json:"inet6_range,omitempty"`
 }
 
+type GroupDNSServerOptions struct {
+	Servers                  badoption.Listable[string] `json:"servers"`
+	Strategy                 string                     `json:"strategy,omitempty"`
+	IPCIDR                   badoption.Listable[string] `json:"ip_cidr,omitempty"`
+	IPIsPrivate              bool                       `json:"ip_is_private,omitempty"`
+	RuleSet                  badoption.Listable[string] `json:"rule_set,omitempty"`
+	RuleSetIPCIDRAcceptEmpty bool                       `json:"rule_set_ip_cidr_accept_empty,omitempty"`
+}
+
 type DHCPDNSServerOptions struct {
 	LocalDNSServerOptions
 	Interface string `json:"interface,omitempty"`
@@ -0,0 +1,8 @@
+package option
+
+type DNSInboundOptions struct {
+	ListenOptions
+	Protocol string `json:"protocol,omitempty"`
+	Path     string `json:"path,omitempty"`
+	InboundTLSOptionsContainer
+}
@@ -0,0 +1,15 @@
+package option
+
+type ForwardInboundOptions struct {
+	ListenOptions
+	Network  NetworkList      `json:"network,omitempty"`
+	Forwards []ForwardOptions `json:"forwards,omitempty"`
+}
+
+type ForwardOptions struct {
+	ListenPort      uint16 `json:"listen_port,omitempty"`
+	ListenPortRange string `json:"listen_port_range,omitempty"`
+	OverrideAddress string `json:"override_address,omitempty"`
+	OverridePort    uint16 `json:"override_port,omitempty"`
+	ProxyProtocol   uint8  `json:"proxy_protocol,omitempty"`
+}
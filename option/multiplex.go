@@ -1,5 +1,7 @@
 package option
 
+import "github.com/sagernet/sing/common/json/badoption"
+
 type InboundMultiplexOptions struct {
 	Enabled bool           `json:"enabled,omitempty"`
 	Padding bool           `json:"padding,omitempty"`
@@ -7,13 +9,15 @@ type InboundMultiplexOptions struct {
 }
 
 type OutboundMultiplexOptions struct {
-	Enabled        bool           `json:"enabled,omitempty"`
-	Protocol       string         `json:"protocol,omitempty"`
-	MaxConnections int            `json:"max_connections,omitempty"`
-	MinStreams     int            `json:"min_streams,omitempty"`
-	MaxStreams     int            `json:"max_streams,omitempty"`
-	Padding        bool           `json:"padding,omitempty"`
-	Brutal         *BrutalOptions `json:"brutal,omitempty"`
+	Enabled        bool               `json:"enabled,omitempty"`
+	Protocol       string             `json:"protocol,omitempty"`
+	MaxConnections int                `json:"max_connections,omitempty"`
+	MinStreams     int                `json:"min_streams,omitempty"`
+	MaxStreams     int                `json:"max_streams,omitempty"`
+	Padding        bool               `json:"padding,omitempty"`
+	Brutal         *BrutalOptions     `json:"brutal,omitempty"`
+	IdleTimeout    badoption.Duration `json:"idle_timeout,omitempty"`
+	MaxLifetime    badoption.Duration `json:"max_lifetime,omitempty"`
 }
 
 type BrutalOptions struct {
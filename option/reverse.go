@@ -0,0 +1,12 @@
+package option
+
+type BridgeInboundOptions struct {
+	Domain      string `json:"domain,omitempty"`
+	Outbound    string `json:"outbound,omitempty"`
+	Connections int    `json:"connections,omitempty"`
+}
+
+type PortalOutboundOptions struct {
+	Domain   string `json:"domain,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
@@ -18,6 +18,24 @@ type RouteOptions struct {
 	DefaultNetworkType         badoption.Listable[InterfaceType] `json:"default_network_type,omitempty"`
 	DefaultFallbackNetworkType badoption.Listable[InterfaceType] `json:"default_fallback_network_type,omitempty"`
 	DefaultFallbackDelay       badoption.Duration                `json:"default_fallback_delay,omitempty"`
+	MITM                       *MITMOptions                      `json:"mitm,omitempty"`
+	ASN                        *ASNOptions                       `json:"asn,omitempty"`
+	Country                    *CountryOptions                   `json:"country,omitempty"`
+}
+
+type MITMOptions struct {
+	Certificate     badoption.Listable[string] `json:"certificate,omitempty"`
+	CertificatePath string                     `json:"certificate_path,omitempty"`
+	Key             badoption.Listable[string] `json:"key,omitempty"`
+	KeyPath         string                     `json:"key_path,omitempty"`
+}
+
+type ASNOptions struct {
+	Path string `json:"path,omitempty"`
+}
+
+type CountryOptions struct {
+	Path string `json:"path,omitempty"`
 }
 
 type GeoIPOptions struct {
@@ -73,6 +73,14 @@ type RawDefaultRule struct {
 	AuthUser                 badoption.Listable[string]                                                  `json:"auth_user,omitempty"`
 	Protocol                 badoption.Listable[string]                                                  `json:"protocol,omitempty"`
 	Client                   badoption.Listable[string]                                                  `json:"client,omitempty"`
+	JA3                      badoption.Listable[string]                                                  `json:"ja3,omitempty"`
+	JA4                      badoption.Listable[string]                                                  `json:"ja4,omitempty"`
+	HTTPMethod               badoption.Listable[string]                                                  `json:"http_method,omitempty"`
+	HTTPHost                 badoption.Listable[string]                                                  `json:"http_host,omitempty"`
+	HTTPPathPrefix           badoption.Listable[string]                                                  `json:"http_path_prefix,omitempty"`
+	HTTPPathRegex            badoption.Listable[string]                                                  `json:"http_path_regex,omitempty"`
+	HTTPUserAgentKeyword     badoption.Listable[string]                                                  `json:"http_user_agent_keyword,omitempty"`
+	HTTPUserAgentRegex       badoption.Listable[string]                                                  `json:"http_user_agent_regex,omitempty"`
 	Domain                   badoption.Listable[string]                                                  `json:"domain,omitempty"`
 	DomainSuffix             badoption.Listable[string]                                                  `json:"domain_suffix,omitempty"`
 	DomainKeyword            badoption.Listable[string]                                                  `json:"domain_keyword,omitempty"`
@@ -82,8 +90,12 @@ type RawDefaultRule struct {
 	GeoIP                    badoption.Listable[string]                                                  `json:"geoip,omitempty"`
 	SourceIPCIDR             badoption.Listable[string]                                                  `json:"source_ip_cidr,omitempty"`
 	SourceIPIsPrivate        bool                                                                        `json:"source_ip_is_private,omitempty"`
+	SourceIPASN              badoption.Listable[ASN]                                                     `json:"source_ip_asn,omitempty"`
+	SourceIPCountry          badoption.Listable[string]                                                  `json:"source_ip_country,omitempty"`
 	IPCIDR                   badoption.Listable[string]                                                  `json:"ip_cidr,omitempty"`
 	IPIsPrivate              bool                                                                        `json:"ip_is_private,omitempty"`
+	IPASN                    badoption.Listable[ASN]                                                     `json:"ip_asn,omitempty"`
+	IPCountry                badoption.Listable[string]                                                  `json:"ip_country,omitempty"`
 	SourcePort               badoption.Listable[uint16]                                                  `json:"source_port,omitempty"`
 	SourcePortRange          badoption.Listable[string]                                                  `json:"source_port_range,omitempty"`
 	Port                     badoption.Listable[uint16]                                                  `json:"port,omitempty"`
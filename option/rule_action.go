@@ -22,6 +22,10 @@ type _RuleAction struct {
 	RejectOptions       RejectActionOptions       `json:"-"`
 	SniffOptions        RouteActionSniff          `json:"-"`
 	ResolveOptions      RouteActionResolve        `json:"-"`
+	HTTPRedirectOptions HTTPRedirectActionOptions `json:"-"`
+	HTTPRejectOptions   HTTPRejectActionOptions   `json:"-"`
+	HTTPMockOptions     HTTPMockActionOptions     `json:"-"`
+	HTTPHeaderOptions   HTTPHeaderActionOptions   `json:"-"`
 }
 
 type RuleAction _RuleAction
@@ -45,10 +49,20 @@ func (r RuleAction) MarshalJSON() ([]byte, error) {
 		v = r.RejectOptions
 	case C.RuleActionTypeHijackDNS:
 		v = nil
+	case C.RuleActionTypeMITM:
+		v = nil
 	case C.RuleActionTypeSniff:
 		v = r.SniffOptions
 	case C.RuleActionTypeResolve:
 		v = r.ResolveOptions
+	case C.RuleActionTypeHTTPRedirect:
+		v = r.HTTPRedirectOptions
+	case C.RuleActionTypeHTTPReject:
+		v = r.HTTPRejectOptions
+	case C.RuleActionTypeHTTPMock:
+		v = r.HTTPMockOptions
+	case C.RuleActionTypeHTTPHeader:
+		v = r.HTTPHeaderOptions
 	default:
 		return nil, E.New("unknown rule action: " + r.Action)
 	}
@@ -78,10 +92,20 @@ func (r *RuleAction) UnmarshalJSON(data []byte) error {
 		v = &r.RejectOptions
 	case C.RuleActionTypeHijackDNS:
 		v = nil
+	case C.RuleActionTypeMITM:
+		v = nil
 	case C.RuleActionTypeSniff:
 		v = &r.SniffOptions
 	case C.RuleActionTypeResolve:
 		v = &r.ResolveOptions
+	case C.RuleActionTypeHTTPRedirect:
+		v = &r.HTTPRedirectOptions
+	case C.RuleActionTypeHTTPReject:
+		v = &r.HTTPRejectOptions
+	case C.RuleActionTypeHTTPMock:
+		v = &r.HTTPMockOptions
+	case C.RuleActionTypeHTTPHeader:
+		v = &r.HTTPHeaderOptions
 	default:
 		return E.New("unknown rule action: " + r.Action)
 	}
@@ -102,6 +126,7 @@ type _DNSRuleAction struct {
 	RouteOptionsOptions DNSRouteOptionsActionOptions `json:"-"`
 	RejectOptions       RejectActionOptions          `json:"-"`
 	PredefinedOptions   DNSRouteActionPredefined     `json:"-"`
+	RewriteOptions      DNSRewriteActionOptions      `json:"-"`
 }
 
 type DNSRuleAction _DNSRuleAction
@@ -121,6 +146,8 @@ func (r DNSRuleAction) MarshalJSON() ([]byte, error) {
 		v = r.RejectOptions
 	case C.RuleActionTypePredefined:
 		v = r.PredefinedOptions
+	case C.RuleActionTypeRewrite:
+		v = r.RewriteOptions
 	default:
 		return nil, E.New("unknown DNS rule action: " + r.Action)
 	}
@@ -143,6 +170,8 @@ func (r *DNSRuleAction) UnmarshalJSONContext(ctx context.Context, data []byte) e
 		v = &r.RejectOptions
 	case C.RuleActionTypePredefined:
 		v = &r.PredefinedOptions
+	case C.RuleActionTypeRewrite:
+		v = &r.RewriteOptions
 	default:
 		return E.New("unknown DNS rule action: " + r.Action)
 	}
@@ -301,6 +330,107 @@ func (r *RejectActionOptions) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+type _HTTPRedirectActionOptions struct {
+	URLRegex   string `json:"url_regex,omitempty"`
+	Location   string `json:"location,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+}
+
+type HTTPRedirectActionOptions _HTTPRedirectActionOptions
+
+func (r *HTTPRedirectActionOptions) UnmarshalJSON(bytes []byte) error {
+	err := json.Unmarshal(bytes, (*_HTTPRedirectActionOptions)(r))
+	if err != nil {
+		return err
+	}
+	if r.Location == "" {
+		return E.New("missing location")
+	}
+	switch r.StatusCode {
+	case 0, 301, 302, 303, 307, 308:
+	default:
+		return E.New("invalid redirect status code: ", r.StatusCode)
+	}
+	return nil
+}
+
+type _HTTPRejectActionOptions struct {
+	Method string `json:"method,omitempty"`
+}
+
+type HTTPRejectActionOptions _HTTPRejectActionOptions
+
+func (r HTTPRejectActionOptions) MarshalJSON() ([]byte, error) {
+	switch r.Method {
+	case C.RuleActionHTTPRejectMethodNotFound:
+		r.Method = ""
+	}
+	return json.Marshal((_HTTPRejectActionOptions)(r))
+}
+
+func (r *HTTPRejectActionOptions) UnmarshalJSON(bytes []byte) error {
+	err := json.Unmarshal(bytes, (*_HTTPRejectActionOptions)(r))
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case "", C.RuleActionHTTPRejectMethodNotFound:
+		r.Method = C.RuleActionHTTPRejectMethodNotFound
+	case C.RuleActionHTTPRejectMethodEmpty:
+	case C.RuleActionHTTPRejectMethodGIF:
+	default:
+		return E.New("unknown HTTP reject method: " + r.Method)
+	}
+	return nil
+}
+
+type _HTTPMockActionOptions struct {
+	StatusCode int                  `json:"status_code,omitempty"`
+	Headers    badoption.HTTPHeader `json:"headers,omitempty"`
+	Body       string               `json:"body,omitempty"`
+	BodyPath   string               `json:"body_path,omitempty"`
+}
+
+type HTTPMockActionOptions _HTTPMockActionOptions
+
+func (r *HTTPMockActionOptions) UnmarshalJSON(bytes []byte) error {
+	err := json.Unmarshal(bytes, (*_HTTPMockActionOptions)(r))
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != 0 && (r.StatusCode < 200 || r.StatusCode > 599) {
+		return E.New("invalid HTTP status code: ", r.StatusCode)
+	}
+	if r.Body != "" && r.BodyPath != "" {
+		return E.New("`body` and `body_path` are mutually exclusive")
+	}
+	return nil
+}
+
+type _HTTPHeaderActionOptions struct {
+	RequestHeader  *HTTPHeaderRewriteOptions `json:"request_header,omitempty"`
+	ResponseHeader *HTTPHeaderRewriteOptions `json:"response_header,omitempty"`
+}
+
+type HTTPHeaderActionOptions _HTTPHeaderActionOptions
+
+func (r *HTTPHeaderActionOptions) UnmarshalJSON(bytes []byte) error {
+	err := json.Unmarshal(bytes, (*_HTTPHeaderActionOptions)(r))
+	if err != nil {
+		return err
+	}
+	if r.RequestHeader == nil && r.ResponseHeader == nil {
+		return E.New("empty HTTP header action")
+	}
+	return nil
+}
+
+type HTTPHeaderRewriteOptions struct {
+	Add     badoption.HTTPHeader       `json:"add,omitempty"`
+	Replace badoption.HTTPHeader       `json:"replace,omitempty"`
+	Remove  badoption.Listable[string] `json:"remove,omitempty"`
+}
+
 type RouteActionSniff struct {
 	Sniffer badoption.Listable[string] `json:"sniffer,omitempty"`
 	Timeout badoption.Duration         `json:"timeout,omitempty"`
@@ -320,3 +450,14 @@ type DNSRouteActionPredefined struct {
 	Ns     badoption.Listable[DNSRecordOptions] `json:"ns,omitempty"`
 	Extra  badoption.Listable[DNSRecordOptions] `json:"extra,omitempty"`
 }
+
+type DNSRewriteActionOptions struct {
+	StripType    badoption.Listable[DNSQueryType]     `json:"strip_type,omitempty"`
+	StripIPCIDR  badoption.Listable[string]           `json:"strip_ip_cidr,omitempty"`
+	StripRuleSet badoption.Listable[string]           `json:"strip_rule_set,omitempty"`
+	MinTTL       uint32                               `json:"min_ttl,omitempty"`
+	MaxTTL       uint32                               `json:"max_ttl,omitempty"`
+	Answer       badoption.Listable[DNSRecordOptions] `json:"answer,omitempty"`
+	Ns           badoption.Listable[DNSRecordOptions] `json:"ns,omitempty"`
+	Extra        badoption.Listable[DNSRecordOptions] `json:"extra,omitempty"`
+}
@@ -83,9 +83,13 @@ type RawDefaultDNSRule struct {
 	GeoIP                    badoption.Listable[string]                                                  `json:"geoip,omitempty"`
 	IPCIDR                   badoption.Listable[string]                                                  `json:"ip_cidr,omitempty"`
 	IPIsPrivate              bool                                                                        `json:"ip_is_private,omitempty"`
+	IPASN                    badoption.Listable[ASN]                                                     `json:"ip_asn,omitempty"`
+	IPCountry                badoption.Listable[string]                                                  `json:"ip_country,omitempty"`
 	IPAcceptAny              bool                                                                        `json:"ip_accept_any,omitempty"`
 	SourceIPCIDR             badoption.Listable[string]                                                  `json:"source_ip_cidr,omitempty"`
 	SourceIPIsPrivate        bool                                                                        `json:"source_ip_is_private,omitempty"`
+	SourceIPASN              badoption.Listable[ASN]                                                     `json:"source_ip_asn,omitempty"`
+	SourceIPCountry          badoption.Listable[string]                                                  `json:"source_ip_country,omitempty"`
 	SourcePort               badoption.Listable[uint16]                                                  `json:"source_port,omitempty"`
 	SourcePortRange          badoption.Listable[string]                                                  `json:"source_port_range,omitempty"`
 	Port                     badoption.Listable[uint16]                                                  `json:"port,omitempty"`
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"reflect"
 
+	"github.com/sagernet/sing-box/common/srs/mapped"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/domain"
@@ -93,6 +94,8 @@ func (r *RuleSet) UnmarshalJSON(bytes []byte) error {
 		case "":
 			return E.New("missing format")
 		case C.RuleSetFormatSource, C.RuleSetFormatBinary:
+		case C.RuleSetFormatAdGuard, C.RuleSetFormatClashDomain, C.RuleSetFormatClashIPCIDR, C.RuleSetFormatClashClassical,
+			C.RuleSetFormatSurgeList, C.RuleSetFormatHosts:
 		default:
 			return E.New("unknown rule-set format: " + r.Format)
 		}
@@ -190,6 +193,10 @@ type DefaultHeadlessRule struct {
 	DomainRegex             badoption.Listable[string]                                                  `json:"domain_regex,omitempty"`
 	SourceIPCIDR            badoption.Listable[string]                                                  `json:"source_ip_cidr,omitempty"`
 	IPCIDR                  badoption.Listable[string]                                                  `json:"ip_cidr,omitempty"`
+	SourceIPASN             badoption.Listable[ASN]                                                     `json:"source_ip_asn,omitempty"`
+	SourceIPCountry         badoption.Listable[string]                                                  `json:"source_ip_country,omitempty"`
+	IPASN                   badoption.Listable[ASN]                                                     `json:"ip_asn,omitempty"`
+	IPCountry               badoption.Listable[string]                                                  `json:"ip_country,omitempty"`
 	SourcePort              badoption.Listable[uint16]                                                  `json:"source_port,omitempty"`
 	SourcePortRange         badoption.Listable[string]                                                  `json:"source_port_range,omitempty"`
 	Port                    badoption.Listable[uint16]                                                  `json:"port,omitempty"`
@@ -205,6 +212,14 @@ type DefaultHeadlessRule struct {
 	WIFIBSSID               badoption.Listable[string]                                                  `json:"wifi_bssid,omitempty"`
 	NetworkInterfaceAddress *badjson.TypedMap[InterfaceType, badoption.Listable[*badoption.Prefixable]] `json:"network_interface_address,omitempty"`
 	DefaultInterfaceAddress badoption.Listable[*badoption.Prefixable]                                   `json:"default_interface_address,omitempty"`
+	JA3                     badoption.Listable[string]                                                  `json:"ja3,omitempty"`
+	JA4                     badoption.Listable[string]                                                  `json:"ja4,omitempty"`
+	HTTPMethod              badoption.Listable[string]                                                  `json:"http_method,omitempty"`
+	HTTPHost                badoption.Listable[string]                                                  `json:"http_host,omitempty"`
+	HTTPPathPrefix          badoption.Listable[string]                                                  `json:"http_path_prefix,omitempty"`
+	HTTPPathRegex           badoption.Listable[string]                                                  `json:"http_path_regex,omitempty"`
+	HTTPUserAgentKeyword    badoption.Listable[string]                                                  `json:"http_user_agent_keyword,omitempty"`
+	HTTPUserAgentRegex      badoption.Listable[string]                                                  `json:"http_user_agent_regex,omitempty"`
 
 	Invert bool `json:"invert,omitempty"`
 
@@ -212,6 +227,10 @@ type DefaultHeadlessRule struct {
 	SourceIPSet   *netipx.IPSet   `json:"-"`
 	IPSet         *netipx.IPSet   `json:"-"`
 
+	MappedDomainMatcher *mapped.DomainMatcher `json:"-"`
+	MappedSourceIPSet   *mapped.IPSet         `json:"-"`
+	MappedIPSet         *mapped.IPSet         `json:"-"`
+
 	AdGuardDomain        badoption.Listable[string] `json:"-"`
 	AdGuardDomainMatcher *domain.AdGuardMatcher     `json:"-"`
 }
@@ -243,7 +262,7 @@ type PlainRuleSetCompat _PlainRuleSetCompat
 func (r PlainRuleSetCompat) MarshalJSON() ([]byte, error) {
 	var v any
 	switch r.Version {
-	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3, C.RuleSetVersion4:
+	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3, C.RuleSetVersion4, C.RuleSetVersion5, C.RuleSetVersion6, C.RuleSetVersion7:
 		v = r.Options
 	default:
 		return nil, E.New("unknown rule-set version: ", r.Version)
@@ -258,7 +277,7 @@ func (r *PlainRuleSetCompat) UnmarshalJSON(bytes []byte) error {
 	}
 	var v any
 	switch r.Version {
-	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3, C.RuleSetVersion4:
+	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3, C.RuleSetVersion4, C.RuleSetVersion5, C.RuleSetVersion6, C.RuleSetVersion7:
 		v = &r.Options
 	case 0:
 		return E.New("missing rule-set version")
@@ -275,7 +294,7 @@ func (r *PlainRuleSetCompat) UnmarshalJSON(bytes []byte) error {
 
 func (r PlainRuleSetCompat) Upgrade() (PlainRuleSet, error) {
 	switch r.Version {
-	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3, C.RuleSetVersion4:
+	case C.RuleSetVersion1, C.RuleSetVersion2, C.RuleSetVersion3, C.RuleSetVersion4, C.RuleSetVersion5, C.RuleSetVersion6, C.RuleSetVersion7:
 	default:
 		return PlainRuleSet{}, E.New("unknown rule-set version: " + F.ToString(r.Version))
 	}
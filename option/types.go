@@ -1,6 +1,7 @@
 package option
 
 import (
+	"strconv"
 	"strings"
 
 	C "github.com/sagernet/sing-box/constant"
@@ -194,3 +195,34 @@ func (t *InterfaceType) UnmarshalJSON(content []byte) error {
 	*t = InterfaceType(interfaceType)
 	return nil
 }
+
+// ASN is an autonomous system number, written as 13335 or "AS13335".
+type ASN uint32
+
+func (a ASN) String() string {
+	return "AS" + F.ToString(uint32(a))
+}
+
+func (a ASN) MarshalJSON() ([]byte, error) {
+	return json.Marshal(uint32(a))
+}
+
+func (a *ASN) UnmarshalJSON(content []byte) error {
+	var valueNumber uint32
+	err := json.Unmarshal(content, &valueNumber)
+	if err == nil {
+		*a = ASN(valueNumber)
+		return nil
+	}
+	var valueString string
+	err = json.Unmarshal(content, &valueString)
+	if err == nil {
+		valueString = strings.TrimPrefix(strings.ToUpper(valueString), "AS")
+		valueNumber, err := strconv.ParseUint(valueString, 10, 32)
+		if err == nil {
+			*a = ASN(valueNumber)
+			return nil
+		}
+	}
+	return E.New("invalid ASN: ", string(content))
+}
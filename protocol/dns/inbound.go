@@ -0,0 +1,321 @@
+package dns
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/inbound"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/dns"
+	"github.com/sagernet/sing-box/dns/transport"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var (
+	ListenHTTP3Func func(ctx context.Context, logger logger.ContextLogger, listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig) (io.Closer, error)
+	ListenQUICFunc  func(ctx context.Context, logger logger.ContextLogger, listener *listener.Listener, tlsConfig tls.ServerConfig, router adapter.DNSRouter, metadata adapter.InboundContext) (io.Closer, error)
+)
+
+func RegisterInbound(registry *inbound.Registry) {
+	inbound.Register[option.DNSInboundOptions](registry, C.TypeDNS, NewInbound)
+}
+
+type Inbound struct {
+	inbound.Adapter
+	ctx        context.Context
+	router     adapter.DNSRouter
+	logger     log.ContextLogger
+	listener   *listener.Listener
+	protocol   string
+	path       string
+	tlsConfig  tls.ServerConfig
+	httpServer *http.Server
+	quicServer io.Closer
+}
+
+func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.DNSInboundOptions) (adapter.Inbound, error) {
+	inbound := &Inbound{
+		Adapter:  inbound.NewAdapter(C.TypeDNS, tag),
+		ctx:      ctx,
+		router:   service.FromContext[adapter.DNSRouter](ctx),
+		logger:   logger,
+		protocol: options.Protocol,
+		path:     options.Path,
+	}
+	tlsEnabled := options.TLS != nil && options.TLS.Enabled
+	if inbound.protocol == "" {
+		if tlsEnabled {
+			inbound.protocol = C.DNSTypeTLS
+		} else {
+			inbound.protocol = C.DNSTypeUDP
+		}
+	}
+	var network string
+	switch inbound.protocol {
+	case C.DNSTypeTCP, C.DNSTypeUDP:
+		if tlsEnabled {
+			return nil, E.New("TLS is not supported by ", inbound.protocol, " protocol")
+		}
+		network = inbound.protocol
+	case C.DNSTypeTLS, C.DNSTypeQUIC, C.DNSTypeHTTP3:
+		if !tlsEnabled {
+			return nil, E.New("TLS is required for ", inbound.protocol, " protocol")
+		}
+		if inbound.protocol == C.DNSTypeTLS {
+			network = N.NetworkTCP
+		} else {
+			network = N.NetworkUDP
+		}
+	case C.DNSTypeHTTPS:
+		network = N.NetworkTCP
+	default:
+		return nil, E.New("unknown protocol: ", inbound.protocol)
+	}
+	if inbound.protocol == C.DNSTypeHTTPS || inbound.protocol == C.DNSTypeHTTP3 {
+		if inbound.path == "" {
+			inbound.path = "/dns-query"
+		}
+	} else if inbound.path != "" {
+		return nil, E.New("path is only supported by https and h3 protocol")
+	}
+	if tlsEnabled {
+		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		inbound.tlsConfig = tlsConfig
+	}
+	inbound.listener = listener.New(listener.Options{
+		Context:           ctx,
+		Logger:            logger,
+		Network:           []string{network},
+		Listen:            options.ListenOptions,
+		ConnectionHandler: inbound,
+		PacketHandler:     inbound,
+	})
+	return inbound, nil
+}
+
+func (i *Inbound) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	if i.tlsConfig != nil {
+		err := i.tlsConfig.Start()
+		if err != nil {
+			return E.Cause(err, "create TLS config")
+		}
+	}
+	switch i.protocol {
+	case C.DNSTypeTCP, C.DNSTypeUDP, C.DNSTypeTLS:
+		if i.protocol == C.DNSTypeTLS && len(i.tlsConfig.NextProtos()) == 0 {
+			i.tlsConfig.SetNextProtos([]string{"dot"})
+		}
+		return i.listener.Start()
+	case C.DNSTypeHTTPS:
+		tcpListener, err := i.listener.ListenTCP()
+		if err != nil {
+			return err
+		}
+		i.httpServer = &http.Server{
+			Handler: h2c.NewHandler(i, &http2.Server{}),
+			BaseContext: func(listener net.Listener) context.Context {
+				return i.ctx
+			},
+		}
+		go func() {
+			listener := net.Listener(tcpListener)
+			if i.tlsConfig != nil {
+				if len(i.tlsConfig.NextProtos()) == 0 {
+					i.tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+				} else if !common.Contains(i.tlsConfig.NextProtos(), http2.NextProtoTLS) {
+					i.tlsConfig.SetNextProtos(append([]string{http2.NextProtoTLS}, i.tlsConfig.NextProtos()...))
+				}
+				listener = aTLS.NewListener(tcpListener, i.tlsConfig)
+			}
+			sErr := i.httpServer.Serve(listener)
+			if sErr != nil && !E.IsClosed(sErr) {
+				i.logger.Error("http server serve error: ", sErr)
+			}
+		}()
+	case C.DNSTypeHTTP3:
+		quicServer, err := ListenHTTP3Func(i.ctx, i.logger, i.listener, i, i.tlsConfig)
+		if err != nil {
+			return err
+		}
+		i.quicServer = quicServer
+	case C.DNSTypeQUIC:
+		quicServer, err := ListenQUICFunc(i.ctx, i.logger, i.listener, i.tlsConfig, i.router, i.newMetadata())
+		if err != nil {
+			return err
+		}
+		i.quicServer = quicServer
+	}
+	return nil
+}
+
+func (i *Inbound) Close() error {
+	return common.Close(
+		common.PtrOrNil(i.httpServer),
+		i.quicServer,
+		i.listener,
+		i.tlsConfig,
+	)
+}
+
+func (i *Inbound) newMetadata() adapter.InboundContext {
+	var metadata adapter.InboundContext
+	metadata.Inbound = i.Tag()
+	metadata.InboundType = i.Type()
+	//nolint:staticcheck
+	metadata.InboundDetour = i.listener.ListenOptions().Detour
+	return metadata
+}
+
+func (i *Inbound) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	err := i.newConnection(ctx, conn, metadata)
+	N.CloseOnHandshakeFailure(conn, onClose, err)
+	if err != nil && !E.IsClosedOrCanceled(err) && !errors.Is(err, os.ErrDeadlineExceeded) {
+		i.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", metadata.Source))
+	}
+}
+
+func (i *Inbound) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	if i.tlsConfig != nil {
+		tlsConn, err := tls.ServerHandshake(ctx, conn, i.tlsConfig)
+		if err != nil {
+			return E.Cause(err, "TLS handshake")
+		}
+		conn = tlsConn
+	}
+	metadata.Inbound = i.Tag()
+	metadata.InboundType = i.Type()
+	metadata.Destination = M.Socksaddr{}
+	for {
+		conn.SetReadDeadline(time.Now().Add(C.DNSTimeout))
+		err := HandleStreamDNSRequest(ctx, i.router, conn, metadata)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+}
+
+func (i *Inbound) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
+	ctx := log.ContextWithNewID(i.ctx)
+	var message mDNS.Msg
+	err := message.Unpack(buffer.Bytes())
+	if err != nil {
+		i.logger.ErrorContext(ctx, E.Cause(err, "unpack DNS packet from ", source))
+		return
+	}
+	metadata := i.newMetadata()
+	metadata.Source = source
+	go func() {
+		err := i.exchangePacket(ctx, &message, metadata)
+		if err != nil && !E.IsClosedOrCanceled(err) {
+			i.logger.ErrorContext(ctx, E.Cause(err, "process DNS packet from ", source))
+		}
+	}()
+}
+
+func (i *Inbound) exchangePacket(ctx context.Context, message *mDNS.Msg, metadata adapter.InboundContext) error {
+	response, err := i.router.Exchange(adapter.WithContext(ctx, &metadata), message, adapter.DNSQueryOptions{})
+	if err != nil {
+		return err
+	}
+	responseBuffer, err := dns.TruncateDNSMessage(message, response, 1024)
+	if err != nil {
+		return err
+	}
+	return i.listener.PacketWriter().WritePacket(responseBuffer, metadata.Source)
+}
+
+func (i *Inbound) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	ctx := log.ContextWithNewID(request.Context())
+	if request.URL.Path != i.path {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var (
+		rawMessage []byte
+		err        error
+	)
+	switch request.Method {
+	case http.MethodGet:
+		rawMessage, err = base64.RawURLEncoding.DecodeString(request.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if request.Header.Get("Content-Type") != transport.MimeType {
+			writer.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		rawMessage, err = io.ReadAll(io.LimitReader(request.Body, mDNS.MaxMsgSize))
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil || len(rawMessage) == 0 {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var message mDNS.Msg
+	err = message.Unpack(rawMessage)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	metadata := i.newMetadata()
+	metadata.Source = M.ParseSocksaddr(request.RemoteAddr).Unwrap()
+	response, err := i.router.Exchange(adapter.WithContext(ctx, &metadata), &message, adapter.DNSQueryOptions{})
+	if err != nil {
+		if !E.IsClosedOrCanceled(err) {
+			i.logger.ErrorContext(ctx, E.Cause(err, "process DNS request from ", metadata.Source))
+		}
+		writer.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	rawResponse, err := response.Pack()
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", transport.MimeType)
+	writer.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(responseTTL(response))))
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(rawResponse)
+}
+
+func responseTTL(response *mDNS.Msg) uint32 {
+	var timeToLive uint32
+	for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns} {
+		for _, record := range recordList {
+			if timeToLive == 0 || record.Header().Ttl < timeToLive {
+				timeToLive = record.Header().Ttl
+			}
+		}
+	}
+	return timeToLive
+}
@@ -0,0 +1,19 @@
+//go:build with_quic
+
+package dns_test
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+)
+
+func TestInboundQUIC(t *testing.T) {
+	t.Parallel()
+	testExchange(t, C.DNSTypeQUIC, C.DNSTypeQUIC, "udp")
+}
+
+func TestInboundHTTP3(t *testing.T) {
+	t.Parallel()
+	testExchange(t, C.DNSTypeHTTP3, C.DNSTypeHTTP3, "udp")
+}
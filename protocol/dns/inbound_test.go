@@ -0,0 +1,139 @@
+package dns_test
+
+import (
+	"bytes"
+	"context"
+	stdTLS "crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T, network string) int {
+	if network == "udp" {
+		packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer packetConn.Close()
+		return packetConn.LocalAddr().(*net.UDPAddr).Port
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// startInbound starts a DNS inbound answering from hosts, and a DNS server of the same protocol connecting to it.
+func startInbound(t *testing.T, protocol string, serverType string, network string) (context.Context, string) {
+	privateKeyPem, publicKeyPem, err := tls.GenerateCertificate(nil, nil, time.Now, "dns.example.org", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	certificate, err := json.Marshal(string(publicKeyPem))
+	require.NoError(t, err)
+	key, err := json.Marshal(string(privateKeyPem))
+	require.NoError(t, err)
+	port := strconv.Itoa(freePort(t, network))
+	ctx, cancel := context.WithCancel(include.Context(context.Background()))
+	t.Cleanup(cancel)
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(`{
+  "log": {"disabled": true},
+  "dns": {
+    "servers": [
+      {"type": "hosts", "tag": "hosts", "predefined": {"example.com": "1.2.3.4"}},
+      {
+        "type": "`+serverType+`",
+        "tag": "client",
+        "server": "127.0.0.1",
+        "server_port": `+port+`,
+        "tls": {"enabled": true, "server_name": "dns.example.org", "insecure": true}
+      }
+    ],
+    "rules": [
+      {"source_ip_cidr": "10.0.0.0/8", "action": "predefined", "answer": "example.com. IN A 10.0.0.1"}
+    ],
+    "final": "hosts"
+  },
+  "inbounds": [
+    {
+      "type": "dns",
+      "listen": "127.0.0.1",
+      "listen_port": `+port+`,
+      "protocol": "`+protocol+`",
+      "tls": {"enabled": true, "certificate": `+string(certificate)+`, "key": `+string(key)+`}
+    }
+  ]
+}`))
+	require.NoError(t, err)
+	instance, err := box.New(box.Options{Context: ctx, Options: options})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	t.Cleanup(func() {
+		instance.Close()
+	})
+	return ctx, port
+}
+
+// testExchange exchanges through a DNS server of the same protocol as the inbound.
+func testExchange(t *testing.T, protocol string, serverType string, network string) {
+	ctx, _ := startInbound(t, protocol, serverType, network)
+	client, loaded := service.FromContext[adapter.DNSTransportManager](ctx).Transport("client")
+	require.True(t, loaded)
+	message := new(mDNS.Msg)
+	message.SetQuestion("example.com.", mDNS.TypeA)
+	response, err := client.Exchange(ctx, message)
+	require.NoError(t, err)
+	require.Equal(t, mDNS.RcodeSuccess, response.Rcode)
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, "1.2.3.4", response.Answer[0].(*mDNS.A).A.String())
+}
+
+func TestInboundTLS(t *testing.T) {
+	t.Parallel()
+	testExchange(t, C.DNSTypeTLS, C.DNSTypeTLS, "tcp")
+}
+
+func TestInboundHTTPS(t *testing.T) {
+	t.Parallel()
+	testExchange(t, C.DNSTypeHTTPS, C.DNSTypeHTTPS, "tcp")
+}
+
+func TestInboundHTTPSIgnoreForwardedFor(t *testing.T) {
+	t.Parallel()
+	_, port := startInbound(t, C.DNSTypeHTTPS, C.DNSTypeHTTPS, "tcp")
+	message := new(mDNS.Msg)
+	message.SetQuestion("example.com.", mDNS.TypeA)
+	rawMessage, err := message.Pack()
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "https://127.0.0.1:"+port+"/dns-query", bytes.NewReader(rawMessage))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/dns-message")
+	// a forged forwarding header must not change the source matched by source_ip_cidr
+	request.Header.Set("X-Forwarded-For", "10.0.0.1")
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &stdTLS.Config{InsecureSkipVerify: true},
+	}}
+	defer client.CloseIdleConnections()
+	httpResponse, err := client.Do(request)
+	require.NoError(t, err)
+	defer httpResponse.Body.Close()
+	require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+	rawResponse, err := io.ReadAll(httpResponse.Body)
+	require.NoError(t, err)
+	var response mDNS.Msg
+	require.NoError(t, response.Unpack(rawResponse))
+	require.Len(t, response.Answer, 1)
+	require.Equal(t, "1.2.3.4", response.Answer[0].(*mDNS.A).A.String())
+}
@@ -0,0 +1,134 @@
+package quic
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+
+	"github.com/sagernet/quic-go"
+	"github.com/sagernet/quic-go/http3"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/dns/transport"
+	"github.com/sagernet/sing-box/log"
+	dnsInbound "github.com/sagernet/sing-box/protocol/dns"
+	"github.com/sagernet/sing-quic"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func init() {
+	dnsInbound.ListenHTTP3Func = func(ctx context.Context, logger logger.ContextLogger, listener *listener.Listener, handler http.Handler, tlsConfig tls.ServerConfig) (io.Closer, error) {
+		err := qtls.ConfigureHTTP3(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		udpConn, err := listener.ListenUDP()
+		if err != nil {
+			return nil, err
+		}
+		quicListener, err := qtls.ListenEarly(udpConn, tlsConfig, &quic.Config{
+			MaxIncomingStreams: 1 << 60,
+			Allow0RTT:          true,
+		})
+		if err != nil {
+			udpConn.Close()
+			return nil, err
+		}
+		h3Server := &http3.Server{
+			Handler: handler,
+			ConnContext: func(ctx context.Context, conn *quic.Conn) context.Context {
+				return log.ContextWithNewID(ctx)
+			},
+		}
+		go func() {
+			sErr := h3Server.ServeListener(quicListener)
+			udpConn.Close()
+			if sErr != nil && !E.IsClosedOrCanceled(sErr) {
+				logger.Error("http3 server closed: ", sErr)
+			}
+		}()
+		return quicListener, nil
+	}
+	dnsInbound.ListenQUICFunc = func(ctx context.Context, logger logger.ContextLogger, listener *listener.Listener, tlsConfig tls.ServerConfig, router adapter.DNSRouter, metadata adapter.InboundContext) (io.Closer, error) {
+		if len(tlsConfig.NextProtos()) == 0 {
+			tlsConfig.SetNextProtos([]string{"doq"})
+		}
+		udpConn, err := listener.ListenUDP()
+		if err != nil {
+			return nil, err
+		}
+		quicListener, err := qtls.ListenEarly(udpConn, tlsConfig, &quic.Config{
+			MaxIncomingStreams: 1 << 60,
+			Allow0RTT:          true,
+		})
+		if err != nil {
+			udpConn.Close()
+			return nil, err
+		}
+		go func() {
+			for {
+				conn, aErr := quicListener.Accept(ctx)
+				if aErr != nil {
+					udpConn.Close()
+					if !E.IsClosedOrCanceled(aErr) {
+						logger.Error("quic listener closed: ", aErr)
+					}
+					return
+				}
+				connMetadata := metadata
+				connMetadata.Source = M.SocksaddrFromNet(conn.RemoteAddr()).Unwrap()
+				go handleQUICConnection(log.ContextWithNewID(ctx), logger, router, conn, connMetadata)
+			}
+		}()
+		return quicListener, nil
+	}
+}
+
+func handleQUICConnection(ctx context.Context, logger logger.ContextLogger, router adapter.DNSRouter, conn *quic.Conn, metadata adapter.InboundContext) {
+	logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
+	for {
+		stream, err := conn.AcceptStream(ctx)
+		if err != nil {
+			conn.CloseWithError(0, "")
+			return
+		}
+		go func() {
+			err := handleQUICStream(ctx, router, stream, metadata)
+			if err != nil && !E.IsClosedOrCanceled(err) {
+				logger.ErrorContext(ctx, E.Cause(err, "process DNS stream from ", metadata.Source))
+			}
+		}()
+	}
+}
+
+func handleQUICStream(ctx context.Context, router adapter.DNSRouter, stream *quic.Stream, metadata adapter.InboundContext) error {
+	defer stream.Close()
+	message, err := transport.ReadMessage(stream)
+	if err != nil {
+		stream.CancelRead(0)
+		return E.Cause(err, "read request")
+	}
+	// RFC 9250 4.2.1: the DNS Message ID MUST be set to 0
+	response, err := router.Exchange(adapter.WithContext(ctx, &metadata), message, adapter.DNSQueryOptions{})
+	if err != nil {
+		stream.CancelWrite(0)
+		return err
+	}
+	responseBuffer := buf.NewSize(3 + response.Len())
+	defer responseBuffer.Release()
+	responseBuffer.Resize(2, 0)
+	rawResponse, err := response.PackBuffer(responseBuffer.FreeBytes())
+	if err != nil {
+		stream.CancelWrite(0)
+		return err
+	}
+	responseBuffer.Truncate(len(rawResponse))
+	binary.BigEndian.PutUint16(responseBuffer.ExtendHeader(2), uint16(len(rawResponse)))
+	return common.Error(stream.Write(responseBuffer.Bytes()))
+}
@@ -0,0 +1,218 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/inbound"
+	"github.com/sagernet/sing-box/common/listener"
+	"github.com/sagernet/sing-box/common/proxyproto"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/udpnat2"
+)
+
+func RegisterInbound(registry *inbound.Registry) {
+	inbound.Register[option.ForwardInboundOptions](registry, C.TypeForward, NewInbound)
+}
+
+var _ adapter.Inbound = (*Inbound)(nil)
+
+// Inbound forwards each listen port to its own destination.
+type Inbound struct {
+	inbound.Adapter
+	forwards []*forwardListener
+}
+
+func NewInbound(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ForwardInboundOptions) (adapter.Inbound, error) {
+	if options.ListenPort != 0 {
+		return nil, E.New("`listen_port` is not available for forward inbound, use `forwards` instead")
+	}
+	if len(options.Forwards) == 0 {
+		return nil, E.New("missing forwards")
+	}
+	options.UDPFragmentDefault = true
+	var udpTimeout time.Duration
+	if options.UDPTimeout != 0 {
+		udpTimeout = time.Duration(options.UDPTimeout)
+	} else {
+		udpTimeout = C.UDPTimeout
+	}
+	inbound := &Inbound{
+		Adapter: inbound.NewAdapter(C.TypeForward, tag),
+	}
+	listenPorts := make(map[uint16]bool)
+	for index, forwardOptions := range options.Forwards {
+		start, end, err := parseListenPorts(forwardOptions)
+		if err != nil {
+			return nil, E.Cause(err, "forwards[", index, "]")
+		}
+		switch forwardOptions.ProxyProtocol {
+		case 0, proxyproto.Version1, proxyproto.Version2:
+		default:
+			return nil, E.New("forwards[", index, "]: unknown PROXY protocol version: ", forwardOptions.ProxyProtocol)
+		}
+		if forwardOptions.OverridePort != 0 && int(forwardOptions.OverridePort)+int(end-start) > 0xFFFF {
+			return nil, E.New("forwards[", index, "]: override port range out of bounds")
+		}
+		for port := int(start); port <= int(end); port++ {
+			if listenPorts[uint16(port)] {
+				return nil, E.New("forwards[", index, "]: duplicate listen port: ", port)
+			}
+			listenPorts[uint16(port)] = true
+			forward := &forwardListener{
+				ctx:           ctx,
+				router:        router,
+				logger:        logger,
+				tag:           tag,
+				proxyProtocol: forwardOptions.ProxyProtocol,
+			}
+			if forwardOptions.OverrideAddress != "" {
+				forward.overrideAddress = M.ParseSocksaddrHostPort(forwardOptions.OverrideAddress, 0)
+			}
+			if forwardOptions.OverridePort != 0 {
+				forward.overridePort = forwardOptions.OverridePort + uint16(port-int(start))
+			}
+			listenOptions := options.ListenOptions
+			listenOptions.ListenPort = option.Port(port)
+			forward.udpNat = udpnat.New(forward, forward.preparePacketConnection, udpTimeout, false)
+			forward.listener = listener.New(listener.Options{
+				Context:           ctx,
+				Logger:            logger,
+				Network:           options.Network.Build(),
+				Listen:            listenOptions,
+				ConnectionHandler: forward,
+				PacketHandler:     forward,
+			})
+			inbound.forwards = append(inbound.forwards, forward)
+		}
+	}
+	return inbound, nil
+}
+
+func parseListenPorts(options option.ForwardOptions) (start uint16, end uint16, err error) {
+	if options.ListenPortRange == "" {
+		if options.ListenPort == 0 {
+			return 0, 0, E.New("missing listen port")
+		}
+		return options.ListenPort, options.ListenPort, nil
+	}
+	if options.ListenPort != 0 {
+		return 0, 0, E.New("`listen_port` and `listen_port_range` are mutually exclusive")
+	}
+	startString, endString, loaded := strings.Cut(options.ListenPortRange, ":")
+	if !loaded {
+		return 0, 0, E.New("bad listen port range: ", options.ListenPortRange)
+	}
+	startPort, err := strconv.ParseUint(startString, 10, 16)
+	if err != nil {
+		return 0, 0, E.Cause(err, "bad listen port range: ", options.ListenPortRange)
+	}
+	endPort, err := strconv.ParseUint(endString, 10, 16)
+	if err != nil {
+		return 0, 0, E.Cause(err, "bad listen port range: ", options.ListenPortRange)
+	}
+	if startPort == 0 || startPort > endPort {
+		return 0, 0, E.New("bad listen port range: ", options.ListenPortRange)
+	}
+	return uint16(startPort), uint16(endPort), nil
+}
+
+func (i *Inbound) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStart {
+		return nil
+	}
+	for _, forward := range i.forwards {
+		err := forward.listener.Start()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Inbound) Close() error {
+	return common.Close(common.Map(i.forwards, func(it *forwardListener) any {
+		return it.listener
+	})...)
+}
+
+type forwardListener struct {
+	ctx             context.Context
+	router          adapter.ConnectionRouterEx
+	logger          log.ContextLogger
+	tag             string
+	listener        *listener.Listener
+	udpNat          *udpnat.Service
+	overrideAddress M.Socksaddr
+	overridePort    uint16
+	proxyProtocol   uint8
+}
+
+func (f *forwardListener) override(destination M.Socksaddr) M.Socksaddr {
+	if f.overrideAddress.IsValid() {
+		destination.Addr = f.overrideAddress.Addr
+		destination.Fqdn = f.overrideAddress.Fqdn
+	}
+	if f.overridePort != 0 {
+		destination.Port = f.overridePort
+	}
+	return destination
+}
+
+func (f *forwardListener) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	metadata.Inbound = f.tag
+	metadata.InboundType = C.TypeForward
+	metadata.Destination = f.override(metadata.OriginDestination)
+	f.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
+	// the header is sent after routing, so that sniffing sees the payload
+	metadata.ProxyProtocol = f.proxyProtocol
+	f.router.RouteConnectionEx(ctx, conn, metadata, onClose)
+}
+
+func (f *forwardListener) NewPacketEx(buffer *buf.Buffer, source M.Socksaddr) {
+	f.udpNat.NewPacket([][]byte{buffer.Bytes()}, source, f.listener.UDPAddr(), nil)
+}
+
+func (f *forwardListener) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	f.logger.InfoContext(ctx, "inbound packet connection from ", source)
+	var metadata adapter.InboundContext
+	metadata.Inbound = f.tag
+	metadata.InboundType = C.TypeForward
+	//nolint:staticcheck
+	metadata.InboundDetour = f.listener.ListenOptions().Detour
+	metadata.Source = source
+	metadata.OriginDestination = f.listener.UDPAddr()
+	metadata.Destination = f.override(metadata.OriginDestination)
+	if f.proxyProtocol == proxyproto.Version2 {
+		// version 1 has no UDP form
+		metadata.ProxyProtocol = f.proxyProtocol
+	}
+	f.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
+	conn = bufio.NewDestinationNATPacketConn(bufio.NewNetPacketConn(conn), metadata.OriginDestination, metadata.Destination)
+	f.router.RoutePacketConnectionEx(ctx, conn, metadata, onClose)
+}
+
+func (f *forwardListener) preparePacketConnection(source M.Socksaddr, destination M.Socksaddr, userData any) (bool, context.Context, N.PacketWriter, N.CloseHandlerFunc) {
+	return true, log.ContextWithNewID(f.ctx), &forwardPacketWriter{f.listener.PacketWriter(), source}, nil
+}
+
+type forwardPacketWriter struct {
+	writer N.PacketWriter
+	source M.Socksaddr
+}
+
+func (w *forwardPacketWriter) WritePacket(buffer *buf.Buffer, addr M.Socksaddr) error {
+	return w.writer.WritePacket(buffer, w.source)
+}
@@ -0,0 +1,123 @@
+package forward_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T, network string) int {
+	if network == "udp" {
+		packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer packetConn.Close()
+		return packetConn.LocalAddr().(*net.UDPAddr).Port
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func startForward(t *testing.T, network string, listenPort int, overridePort int, proxyProtocol int) {
+	ctx, cancel := context.WithCancel(include.Context(context.Background()))
+	t.Cleanup(cancel)
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(`{
+  "log": {"disabled": true},
+  "inbounds": [
+    {
+      "type": "forward",
+      "listen": "127.0.0.1",
+      "network": "`+network+`",
+      "forwards": [
+        {
+          "listen_port": `+strconv.Itoa(listenPort)+`,
+          "override_port": `+strconv.Itoa(overridePort)+`,
+          "proxy_protocol": `+strconv.Itoa(proxyProtocol)+`
+        }
+      ]
+    }
+  ],
+  "route": {
+    "rules": [
+      {"action": "sniff", "timeout": "1s"},
+      {"network": "tcp", "protocol": "http", "action": "route", "outbound": "direct"},
+      {"network": "udp", "action": "route", "outbound": "direct"},
+      {"action": "reject"}
+    ]
+  },
+  "outbounds": [
+    {"type": "direct", "tag": "direct"}
+  ]
+}`))
+	require.NoError(t, err)
+	instance, err := box.New(box.Options{Context: ctx, Options: options})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	t.Cleanup(func() {
+		instance.Close()
+	})
+}
+
+func TestForwardProxyProtocol(t *testing.T) {
+	t.Parallel()
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+	listenPort := freePort(t, "tcp")
+	startForward(t, "tcp", listenPort, server.Addr().(*net.TCPAddr).Port, 1)
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(listenPort))
+	require.NoError(t, err)
+	defer conn.Close()
+	request := "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	_, err = conn.Write([]byte(request))
+	require.NoError(t, err)
+	require.NoError(t, server.(*net.TCPListener).SetDeadline(time.Now().Add(5*time.Second)))
+	serverConn, err := server.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	// the connection is sniffed as HTTP before the header is sent by the direct outbound
+	header := "PROXY TCP4 127.0.0.1 127.0.0.1 " + strconv.Itoa(conn.LocalAddr().(*net.TCPAddr).Port) + " " + strconv.Itoa(listenPort) + "\r\n"
+	content := make([]byte, len(header)+len(request))
+	require.NoError(t, serverConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.ReadFull(serverConn, content)
+	require.NoError(t, err)
+	require.Equal(t, header+request, string(content))
+}
+
+func TestForwardProxyProtocolUDP(t *testing.T) {
+	t.Parallel()
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+	listenPort := freePort(t, "udp")
+	startForward(t, "udp", listenPort, server.LocalAddr().(*net.UDPAddr).Port, 2)
+	conn, err := net.Dial("udp", "127.0.0.1:"+strconv.Itoa(listenPort))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	require.NoError(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+	packet := make([]byte, 1024)
+	n, _, err := server.ReadFrom(packet)
+	require.NoError(t, err)
+	packet = packet[:n]
+	// every datagram starts with a version 2 header of a UDP over IPv4 connection
+	require.Len(t, packet, 16+12+4)
+	require.True(t, bytes.HasPrefix(packet, []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A, 0x21, 0x12}))
+	require.Equal(t, []byte{127, 0, 0, 1, 127, 0, 0, 1}, packet[16:24])
+	require.Equal(t, conn.LocalAddr().(*net.UDPAddr).Port, int(packet[24])<<8|int(packet[25]))
+	require.Equal(t, listenPort, int(packet[26])<<8|int(packet[27]))
+	require.Equal(t, "ping", string(packet[28:]))
+}
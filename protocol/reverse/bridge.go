@@ -0,0 +1,144 @@
+package reverse
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/inbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-mux"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const bridgeRetryDelay = 5 * time.Second
+
+func RegisterBridge(registry *inbound.Registry) {
+	inbound.Register[option.BridgeInboundOptions](registry, C.TypeBridge, NewBridge)
+}
+
+var _ adapter.Inbound = (*Bridge)(nil)
+
+// Bridge keeps tunnels open to a portal through an outbound,
+// and routes the connections the portal multiplexes over them.
+type Bridge struct {
+	inbound.Adapter
+	ctx         context.Context
+	cancel      context.CancelFunc
+	logger      log.ContextLogger
+	outbound    adapter.OutboundManager
+	service     *mux.Service
+	domain      string
+	outboundTag string
+	connections int
+	detour      adapter.Outbound
+}
+
+func NewBridge(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.BridgeInboundOptions) (adapter.Inbound, error) {
+	if options.Domain == "" {
+		return nil, E.New("missing domain")
+	}
+	if options.Connections < 0 {
+		return nil, E.New("invalid connections: ", options.Connections)
+	}
+	connections := options.Connections
+	if connections == 0 {
+		connections = 1
+	}
+	muxService, err := mux.NewService(mux.ServiceOptions{
+		NewStreamContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return log.ContextWithNewID(ctx)
+		},
+		Logger:    logger,
+		HandlerEx: adapter.NewRouteContextHandlerEx(router),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Bridge{
+		Adapter:     inbound.NewAdapter(C.TypeBridge, tag),
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      logger,
+		outbound:    service.FromContext[adapter.OutboundManager](ctx),
+		service:     muxService,
+		domain:      strings.ToLower(options.Domain),
+		outboundTag: options.Outbound,
+		connections: connections,
+	}, nil
+}
+
+func (b *Bridge) Start(stage adapter.StartStage) error {
+	if stage != adapter.StartStateStarted {
+		return nil
+	}
+	if b.outboundTag == "" {
+		b.detour = b.outbound.Default()
+	} else {
+		detour, loaded := b.outbound.Outbound(b.outboundTag)
+		if !loaded {
+			return E.New("outbound not found: ", b.outboundTag)
+		}
+		b.detour = detour
+	}
+	if !common.Contains(b.detour.Network(), N.NetworkTCP) {
+		return E.New("TCP is not supported by outbound: ", b.detour.Tag())
+	}
+	for i := 0; i < b.connections; i++ {
+		go b.loopTunnel()
+	}
+	return nil
+}
+
+func (b *Bridge) Close() error {
+	b.cancel()
+	return nil
+}
+
+func (b *Bridge) loopTunnel() {
+	for {
+		err := b.serveTunnel()
+		if b.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			b.logger.ErrorContext(b.ctx, err)
+		}
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(bridgeRetryDelay):
+		}
+	}
+}
+
+// serveTunnel blocks until the tunnel is closed,
+// errors of established tunnels are logged by the multiplex service.
+func (b *Bridge) serveTunnel() error {
+	ctx := log.ContextWithNewID(b.ctx)
+	conn, err := b.detour.DialContext(ctx, N.NetworkTCP, M.Socksaddr{Fqdn: b.domain})
+	if err != nil {
+		return E.Cause(err, "open reverse tunnel to ", b.domain)
+	}
+	b.logger.InfoContext(ctx, "reverse tunnel to ", b.domain, " established")
+	stopClose := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stopClose()
+	var metadata adapter.InboundContext
+	metadata.Inbound = b.Tag()
+	metadata.InboundType = C.TypeBridge
+	metadata.Source = M.SocksaddrFromNet(conn.RemoteAddr())
+	b.service.NewConnectionEx(adapter.WithContext(ctx, &metadata), conn, metadata.Source, M.Socksaddr{}, nil)
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,275 @@
+package reverse
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-mux"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+func RegisterPortal(registry *outbound.Registry) {
+	outbound.Register[option.PortalOutboundOptions](registry, C.TypePortal, NewPortal)
+}
+
+var (
+	_ adapter.Outbound            = (*Portal)(nil)
+	_ adapter.ConnectionHandlerEx = (*Portal)(nil)
+)
+
+// Portal accepts tunnels from bridges, identified by the destination domain,
+// and sends other connections routed to it back through them.
+type Portal struct {
+	outbound.Adapter
+	logger     log.ContextLogger
+	connection adapter.ConnectionManager
+	domain     string
+	protocol   string
+	access     sync.Mutex
+	tunnels    []*portalTunnel
+	next       int
+}
+
+func NewPortal(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.PortalOutboundOptions) (adapter.Outbound, error) {
+	if options.Domain == "" {
+		return nil, E.New("missing domain")
+	}
+	// validate protocol
+	_, err := mux.NewClient(mux.Options{Protocol: options.Protocol})
+	if err != nil {
+		return nil, err
+	}
+	return &Portal{
+		Adapter:    outbound.NewAdapter(C.TypePortal, tag, []string{N.NetworkTCP, N.NetworkUDP}, nil),
+		logger:     logger,
+		connection: service.FromContext[adapter.ConnectionManager](ctx),
+		domain:     strings.ToLower(options.Domain),
+		protocol:   options.Protocol,
+	}, nil
+}
+
+func (p *Portal) NewConnectionEx(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	if strings.ToLower(metadata.Destination.Fqdn) != p.domain {
+		p.connection.NewConnection(ctx, p, conn, metadata, onClose)
+		return
+	}
+	tunnel, err := p.newTunnel(conn, onClose)
+	if err != nil {
+		N.CloseOnHandshakeFailure(conn, onClose, err)
+		p.logger.ErrorContext(ctx, err)
+		return
+	}
+	// complete lazy inbound handshakes before the connection is read ahead
+	err = N.ReportConnHandshakeSuccess(conn, conn)
+	if err != nil {
+		N.CloseOnHandshakeFailure(conn, onClose, E.Cause(err, "report handshake success"))
+		p.logger.ErrorContext(ctx, E.Cause(err, "report handshake success"))
+		return
+	}
+	p.access.Lock()
+	p.tunnels = append(p.tunnels, tunnel)
+	p.access.Unlock()
+	go tunnel.conn.readIdle()
+	p.logger.InfoContext(ctx, "reverse tunnel from ", metadata.Source, " registered")
+}
+
+func (p *Portal) NewPacketConnectionEx(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc) {
+	p.connection.NewPacketConnection(ctx, p, conn, metadata, onClose)
+}
+
+func (p *Portal) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	for {
+		tunnel := p.selectTunnel()
+		if tunnel == nil {
+			return nil, E.New("no available reverse tunnel for ", p.domain)
+		}
+		conn, err := tunnel.client.DialContext(ctx, network, destination)
+		if err == nil {
+			return conn, nil
+		}
+		p.removeTunnel(tunnel, err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Portal) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	for {
+		tunnel := p.selectTunnel()
+		if tunnel == nil {
+			return nil, E.New("no available reverse tunnel for ", p.domain)
+		}
+		conn, err := tunnel.client.ListenPacket(ctx, destination)
+		if err == nil {
+			return conn, nil
+		}
+		p.removeTunnel(tunnel, err)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Portal) Close() error {
+	p.access.Lock()
+	tunnels := p.tunnels
+	p.tunnels = nil
+	p.access.Unlock()
+	for _, tunnel := range tunnels {
+		tunnel.close(os.ErrClosed)
+	}
+	return nil
+}
+
+func (p *Portal) newTunnel(conn net.Conn, onClose N.CloseHandlerFunc) (*portalTunnel, error) {
+	tunnel := &portalTunnel{
+		onClose: N.OnceClose(func(it error) {
+			if onClose != nil {
+				onClose(it)
+			}
+		}),
+	}
+	tunnel.conn = newTunnelConn(conn, func(err error) {
+		go p.removeTunnel(tunnel, err)
+	})
+	client, err := mux.NewClient(mux.Options{
+		Dialer:         tunnel,
+		Logger:         p.logger,
+		Protocol:       p.protocol,
+		MaxConnections: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	tunnel.client = client
+	return tunnel, nil
+}
+
+// selectTunnel picks the registered tunnels in turn.
+func (p *Portal) selectTunnel() *portalTunnel {
+	p.access.Lock()
+	defer p.access.Unlock()
+	if len(p.tunnels) == 0 {
+		return nil
+	}
+	p.next = (p.next + 1) % len(p.tunnels)
+	return p.tunnels[p.next]
+}
+
+func (p *Portal) removeTunnel(tunnel *portalTunnel, err error) {
+	p.access.Lock()
+	registered := common.Contains(p.tunnels, tunnel)
+	p.tunnels = common.Filter(p.tunnels, func(it *portalTunnel) bool {
+		return it != tunnel
+	})
+	p.access.Unlock()
+	tunnel.close(err)
+	if registered {
+		p.logger.Debug("reverse tunnel removed: ", err)
+	}
+}
+
+// portalTunnel hands its connection to the multiplex client exactly once,
+// so the client fails instead of reconnecting after the tunnel is closed.
+type portalTunnel struct {
+	access    sync.Mutex
+	conn      *tunnelConn
+	used      bool
+	client    *mux.Client
+	onClose   N.CloseHandlerFunc
+	closeOnce sync.Once
+}
+
+func (t *portalTunnel) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	t.access.Lock()
+	defer t.access.Unlock()
+	if t.used {
+		return nil, E.New("reverse tunnel closed")
+	}
+	t.used = true
+	return t.conn, nil
+}
+
+func (t *portalTunnel) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	return nil, os.ErrInvalid
+}
+
+func (t *portalTunnel) close(err error) {
+	t.closeOnce.Do(func() {
+		t.client.Close()
+		t.conn.Close()
+		t.onClose(err)
+	})
+}
+
+// tunnelConn reports read and write errors of the tunnel connection.
+// The bridge sends nothing before the portal opens the first stream,
+// so the connection is read ahead while idle to notice tunnels closed before use.
+type tunnelConn struct {
+	net.Conn
+	onError func(error)
+	ready   chan struct{}
+	cached  []byte
+	err     error
+}
+
+func newTunnelConn(conn net.Conn, onError func(error)) *tunnelConn {
+	return &tunnelConn{
+		Conn:    conn,
+		onError: onError,
+		ready:   make(chan struct{}),
+	}
+}
+
+func (c *tunnelConn) readIdle() {
+	buffer := make([]byte, 4096)
+	n, err := c.Conn.Read(buffer)
+	c.cached = buffer[:n]
+	c.err = err
+	close(c.ready)
+	if err != nil {
+		c.onError(err)
+	}
+}
+
+func (c *tunnelConn) Read(p []byte) (n int, err error) {
+	<-c.ready
+	if len(c.cached) > 0 {
+		n = copy(p, c.cached)
+		c.cached = c.cached[n:]
+		return
+	}
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err = c.Conn.Read(p)
+	if err != nil {
+		c.onError(err)
+	}
+	return
+}
+
+func (c *tunnelConn) Write(p []byte) (n int, err error) {
+	n, err = c.Conn.Write(p)
+	if err != nil {
+		c.onError(err)
+	}
+	return
+}
+
+func (c *tunnelConn) Upstream() any {
+	return c.Conn
+}
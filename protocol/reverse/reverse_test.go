@@ -0,0 +1,130 @@
+package reverse_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/protocol/reverse"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/protocol/socks"
+
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func startEchoServer(t *testing.T) M.Socksaddr {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return M.SocksaddrFromNet(listener.Addr())
+}
+
+func TestBridgePortal(t *testing.T) {
+	t.Parallel()
+	service := startEchoServer(t)
+	tunnelPort := freePort(t)
+	userPort := freePort(t)
+	ctx, cancel := context.WithCancel(include.Context(context.Background()))
+	defer cancel()
+	// the bridge reaches the portal through a SOCKS inbound, users reach services behind the bridge through another one
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(`{
+  "log": {"disabled": true},
+  "inbounds": [
+    {"type": "socks", "tag": "tunnel-in", "listen": "127.0.0.1", "listen_port": `+strconv.Itoa(tunnelPort)+`},
+    {"type": "socks", "tag": "user-in", "listen": "127.0.0.1", "listen_port": `+strconv.Itoa(userPort)+`},
+    {"type": "bridge", "tag": "bridge-in", "domain": "reverse.example.com", "outbound": "to-portal"}
+  ],
+  "outbounds": [
+    {"type": "direct", "tag": "direct"},
+    {"type": "socks", "tag": "to-portal", "server": "127.0.0.1", "server_port": `+strconv.Itoa(tunnelPort)+`},
+    {"type": "portal", "tag": "portal", "domain": "reverse.example.com", "protocol": "smux"}
+  ],
+  "route": {
+    "rules": [
+      {"inbound": ["tunnel-in", "user-in"], "outbound": "portal"},
+      {"inbound": "bridge-in", "outbound": "direct"}
+    ]
+  }
+}`))
+	require.NoError(t, err)
+	instance, err := box.New(box.Options{Context: ctx, Options: options})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	client := socks.NewClient(N.SystemDialer, M.ParseSocksaddrHostPort("127.0.0.1", uint16(userPort)), socks.Version5, "", "")
+	var conn net.Conn
+	// the bridge registers its tunnel asynchronously after start
+	require.Eventually(t, func() bool {
+		conn, err = client.DialContext(ctx, N.NetworkTCP, service)
+		if err != nil {
+			return false
+		}
+		_, err = conn.Write([]byte("ping"))
+		if err != nil {
+			conn.Close()
+			return false
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+	defer conn.Close()
+	response := make([]byte, 4)
+	_, err = io.ReadFull(conn, response)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(response))
+}
+
+func TestPortalRemoveClosedTunnel(t *testing.T) {
+	t.Parallel()
+	ctx := include.Context(context.Background())
+	portal, err := reverse.NewPortal(ctx, nil, log.NewNOPFactory().Logger(), "portal", option.PortalOutboundOptions{
+		Domain: "reverse.example.com",
+	})
+	require.NoError(t, err)
+	defer portal.(io.Closer).Close()
+	tunnelConn, bridgeConn := net.Pipe()
+	closed := make(chan error, 1)
+	portal.(adapter.ConnectionHandlerEx).NewConnectionEx(ctx, tunnelConn, adapter.InboundContext{
+		Destination: M.Socksaddr{Fqdn: "reverse.example.com"},
+	}, func(it error) {
+		closed <- it
+	})
+	bridgeConn.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tunnel not removed after its connection was closed")
+	}
+	_, err = portal.DialContext(ctx, N.NetworkTCP, M.ParseSocksaddr("127.0.0.1:80"))
+	require.ErrorContains(t, err, "no available reverse tunnel")
+}
@@ -116,6 +116,13 @@ func (h *Outbound) InterfaceUpdated() {
 	}
 }
 
+func (h *Outbound) MultiplexClient() adapter.MultiplexClient {
+	if h.multiplexDialer == nil {
+		return nil
+	}
+	return h.multiplexDialer
+}
+
 func (h *Outbound) Close() error {
 	return common.Close(common.PtrOrNil(h.multiplexDialer), h.transport)
 }
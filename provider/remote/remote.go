@@ -301,6 +301,16 @@ func (s *Remote) updateOutbounds(content string) {
 		outboundsByTag[outbound.Tag()] = outbound
 	}
 	s.logger.Info(len(outbounds), " outbounds available")
+	for tag := range s.outboundsByTag {
+		if _, loaded := outboundsByTag[tag]; loaded {
+			continue
+		}
+		// closing a removed outbound drains its multiplex sessions instead of breaking them
+		err := s.outbound.Remove(tag)
+		if err != nil {
+			s.logger.Warn("remove outbound[", tag, "]: ", err)
+		}
+	}
 	s.outbounds = outbounds
 	s.outboundsByTag = outboundsByTag
 }
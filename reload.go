@@ -0,0 +1,499 @@
+package box
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/adapter/outbound"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/experimental"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service"
+)
+
+// referenceKeys are the names of options referencing outbounds by tag outside of inbounds, outbounds, endpoints and rules.
+var referenceKeys = []string{"detour", "download_detour"}
+
+// Reload applies new options to the started box in place of a restart.
+//
+// Inbounds and endpoints are only recreated if their options changed, outbounds are only recreated
+// if their options or the outbounds they depend on changed, and route and DNS rules are replaced atomically,
+// so that unchanged listeners and connections of unchanged outbounds are kept.
+// An error is returned if other options changed or the reload failed, in which case the box must be restarted.
+func (s *Box) Reload(options option.Options) error {
+	startTime := time.Now()
+	err := s.checkReload(options)
+	if err != nil {
+		return err
+	}
+	outboundRegistry := service.FromContext[adapter.OutboundRegistry](s.ctx)
+	changedEndpoints, removedEndpoints, err := diffOptions(s.ctx, s.options.Endpoints, options.Endpoints, func(it option.Endpoint) string { return it.Tag })
+	if err != nil {
+		return err
+	}
+	changedInbounds, removedInbounds, err := diffOptions(s.ctx, s.options.Inbounds, options.Inbounds, func(it option.Inbound) string { return it.Tag })
+	if err != nil {
+		return err
+	}
+	changedOutbounds, removedOutbounds, err := diffOptions(s.ctx, s.options.Outbounds, options.Outbounds, func(it option.Outbound) string { return it.Tag })
+	if err != nil {
+		return err
+	}
+	derivedParent := make(map[string]string)
+	derivedTags := make(map[string][]string)
+	for _, outboundOptions := range append(slices.Clone(s.options.Outbounds), options.Outbounds...) {
+		for _, derived := range outboundRegistry.DeriveOptions(outboundOptions.Type, outboundOptions.Tag, outboundOptions.Options) {
+			derivedParent[derived.Tag] = outboundOptions.Tag
+			derivedTags[outboundOptions.Tag] = append(derivedTags[outboundOptions.Tag], derived.Tag)
+		}
+	}
+	affected := make(map[string]bool)
+	markOutbound := func(tag string) {
+		affected[tag] = true
+		for _, derivedTag := range derivedTags[tag] {
+			affected[derivedTag] = true
+		}
+	}
+	for _, tags := range [][]string{changedEndpoints, removedEndpoints, changedOutbounds, removedOutbounds} {
+		for _, tag := range tags {
+			markOutbound(tag)
+		}
+	}
+	newOutbounds := make(map[string]bool)
+	for index, outboundOptions := range options.Outbounds {
+		newOutbounds[optionsTag(outboundOptions.Tag, index)] = true
+	}
+	for {
+		var updated bool
+		for _, dependent := range s.outbound.Outbounds() {
+			tag := dependent.Tag()
+			if affected[tag] || !common.Any(dependent.Dependencies(), func(it string) bool { return affected[it] }) {
+				continue
+			}
+			if parentTag, isDerived := derivedParent[tag]; isDerived {
+				tag = parentTag
+			}
+			if !newOutbounds[tag] {
+				return E.New("outbound[", dependent.Tag(), "] depends on changed outbounds")
+			}
+			changedOutbounds = append(changedOutbounds, tag)
+			markOutbound(tag)
+			updated = true
+		}
+		if !updated {
+			break
+		}
+	}
+	for _, dependent := range s.endpoint.Endpoints() {
+		if !affected[dependent.Tag()] && common.Any(dependent.Dependencies(), func(it string) bool { return affected[it] }) {
+			return E.New("endpoint[", dependent.Tag(), "] depends on changed outbounds")
+		}
+	}
+	references, err := s.staticReferences(options)
+	if err != nil {
+		return err
+	}
+	for tag := range affected {
+		if references[tag] {
+			return E.New("outbound[", tag, "] is referenced by options that can not be reloaded")
+		}
+	}
+	// rules are built and checked against the new outbounds before anything is changed,
+	// so that invalid rules leave the box running with the old options
+	outboundTags := make(map[string]bool)
+	for _, current := range s.outbound.Outbounds() {
+		if !affected[current.Tag()] {
+			outboundTags[current.Tag()] = true
+		}
+	}
+	for index, outboundOptions := range options.Outbounds {
+		outboundTags[optionsTag(outboundOptions.Tag, index)] = true
+		for _, derived := range outboundRegistry.DeriveOptions(outboundOptions.Type, outboundOptions.Tag, outboundOptions.Options) {
+			outboundTags[derived.Tag] = true
+		}
+	}
+	for index, endpointOptions := range options.Endpoints {
+		outboundTags[optionsTag(endpointOptions.Tag, index)] = true
+	}
+	routeRuleOptions := common.PtrValueOrDefault(options.Route).Rules
+	for index, rule := range routeRuleOptions {
+		err = checkRuleOutbounds(rule, outboundTags)
+		if err != nil {
+			return E.Cause(err, "update route rules: rule[", index, "]")
+		}
+	}
+	routeRules, err := s.router.NewRules(routeRuleOptions)
+	if err != nil {
+		return E.Cause(err, "update route rules")
+	}
+	dnsRules, err := s.dnsRouter.NewRules(common.PtrValueOrDefault(options.DNS).Rules)
+	if err != nil {
+		closeRules(routeRules)
+		return E.Cause(err, "update DNS rules")
+	}
+	ruleSwapped := false
+	defer func() {
+		if !ruleSwapped {
+			closeRules(routeRules)
+			closeRules(dnsRules)
+		}
+	}()
+
+	for _, tag := range removedEndpoints {
+		err = s.endpoint.Remove(tag)
+		if err != nil {
+			return E.Cause(err, "remove endpoint[", tag, "]")
+		}
+	}
+	for index, endpointOptions := range options.Endpoints {
+		tag := optionsTag(endpointOptions.Tag, index)
+		if !common.Contains(changedEndpoints, tag) {
+			continue
+		}
+		if _, loaded := s.endpoint.Get(tag); loaded {
+			err = s.endpoint.Remove(tag)
+			if err != nil {
+				return E.Cause(err, "remove endpoint[", tag, "]")
+			}
+		}
+		err = s.endpoint.Create(
+			adapter.WithContext(s.ctx, &adapter.InboundContext{Outbound: tag}),
+			s.router,
+			s.logFactory.NewLogger(F.ToString("endpoint/", endpointOptions.Type, "[", tag, "]")),
+			tag,
+			endpointOptions.Type,
+			endpointOptions.Options,
+		)
+		if err != nil {
+			return E.Cause(err, "initialize endpoint[", index, "]")
+		}
+	}
+	var entries []outbound.Entry
+	for index, outboundOptions := range options.Outbounds {
+		tag := optionsTag(outboundOptions.Tag, index)
+		if !common.Contains(changedOutbounds, tag) {
+			continue
+		}
+		outboundCtx := adapter.WithContext(s.ctx, &adapter.InboundContext{Outbound: tag})
+		entries = append(entries, outbound.Entry{
+			Context: outboundCtx,
+			Logger:  s.logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
+			Tag:     tag,
+			Type:    outboundOptions.Type,
+			Options: outboundOptions.Options,
+		})
+		for _, derived := range outboundRegistry.DeriveOptions(outboundOptions.Type, outboundOptions.Tag, outboundOptions.Options) {
+			entries = append(entries, outbound.Entry{
+				Context: outboundCtx,
+				Logger:  s.logFactory.NewLogger(F.ToString("outbound/", derived.Type, "[", derived.Tag, "]")),
+				Tag:     derived.Tag,
+				Type:    derived.Type,
+				Options: derived.Options,
+			})
+		}
+	}
+	if len(entries) > 0 {
+		err = s.outbound.Recreate(s.router, entries)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.removeOutbounds(affected, entries)
+	if err != nil {
+		return err
+	}
+	// new rules are started before the swap, so rule-sets used by both old and new rules stay loaded
+	for index, rule := range routeRules {
+		err = rule.Start()
+		if err != nil {
+			return E.Cause(err, "update route rules: initialize rule[", index, "]")
+		}
+	}
+	for index, rule := range dnsRules {
+		err = rule.Start()
+		if err != nil {
+			return E.Cause(err, "update DNS rules: initialize DNS rule[", index, "]")
+		}
+	}
+	s.router.SwapRules(routeRules, func() {
+		s.dnsRouter.SwapRules(dnsRules, nil)
+	})
+	ruleSwapped = true
+	for _, tag := range removedInbounds {
+		err = s.inbound.Remove(tag)
+		if err != nil {
+			return E.Cause(err, "remove inbound[", tag, "]")
+		}
+	}
+	for index, inboundOptions := range options.Inbounds {
+		tag := optionsTag(inboundOptions.Tag, index)
+		if !common.Contains(changedInbounds, tag) {
+			continue
+		}
+		if _, loaded := s.inbound.Get(tag); loaded {
+			err = s.inbound.Remove(tag)
+			if err != nil {
+				return E.Cause(err, "remove inbound[", tag, "]")
+			}
+		}
+		err = s.inbound.Create(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
+			tag,
+			inboundOptions.Type,
+			inboundOptions.Options,
+		)
+		if err != nil {
+			return E.Cause(err, "initialize inbound[", index, "]")
+		}
+	}
+	s.options = options
+	s.logger.Info(
+		"sing-box reloaded (", F.Seconds(time.Since(startTime).Seconds()), "s): ",
+		len(changedInbounds), " inbound(s), ", len(changedEndpoints), " endpoint(s) and ", len(entries), " outbound(s) recreated, ",
+		len(removedInbounds)+len(removedEndpoints)+len(removedOutbounds), " removed",
+	)
+	return nil
+}
+
+// checkReload returns an error if options other than inbounds, outbounds, endpoints and rules changed,
+// or if new rules require resources not loaded for old rules.
+func (s *Box) checkReload(options option.Options) error {
+	oldRoute, newRoute := common.PtrValueOrDefault(s.options.Route), common.PtrValueOrDefault(options.Route)
+	oldDNS, newDNS := common.PtrValueOrDefault(s.options.DNS), common.PtrValueOrDefault(options.DNS)
+	oldStatic, newStatic := staticOptions(s.options), staticOptions(options)
+	for _, name := range []string{"log", "dns", "ntp", "certificate", "route", "providers", "services", "experimental"} {
+		oldContent, err := json.MarshalContext(s.ctx, oldStatic[name])
+		if err != nil {
+			return E.Cause(err, "marshal ", name, " options")
+		}
+		newContent, err := json.MarshalContext(s.ctx, newStatic[name])
+		if err != nil {
+			return E.Cause(err, "marshal ", name, " options")
+		}
+		if !bytes.Equal(oldContent, newContent) {
+			return E.New(name, " options changed")
+		}
+	}
+	if newRoute.Final == "" && firstTag(s.options.Outbounds) != firstTag(options.Outbounds) {
+		return E.New("default outbound changed")
+	}
+	if !slices.Equal(experimental.CalculateClashModeList(s.options), experimental.CalculateClashModeList(options)) {
+		return E.New("clash modes changed")
+	}
+	if route.NeedFindProcess(newRoute, newDNS) && !s.router.NeedFindProcess() {
+		return E.New("process rules require searching processes")
+	}
+	loadedRuleSets := make(map[string]bool)
+	for _, tag := range ruleSetTags(oldRoute.Rules, oldDNS.Rules) {
+		loadedRuleSets[tag] = true
+	}
+	for _, tag := range ruleSetTags(newRoute.Rules, newDNS.Rules) {
+		if !loadedRuleSets[tag] {
+			return E.New("rule-set ", tag, " is not loaded for rules")
+		}
+	}
+	return nil
+}
+
+// staticOptions returns the options that can not be reloaded by name.
+func staticOptions(options option.Options) map[string]any {
+	var routeOptions *option.RouteOptions
+	if options.Route != nil {
+		routeOptions = common.Ptr(*options.Route)
+		routeOptions.Rules = nil
+	}
+	var dnsOptions *option.DNSOptions
+	if options.DNS != nil {
+		dnsOptions = common.Ptr(*options.DNS)
+		dnsOptions.Rules = nil
+	}
+	return map[string]any{
+		"log":          options.Log,
+		"dns":          dnsOptions,
+		"ntp":          options.NTP,
+		"certificate":  options.Certificate,
+		"route":        routeOptions,
+		"providers":    options.Providers,
+		"services":     options.Services,
+		"experimental": options.Experimental,
+	}
+}
+
+// staticReferences returns tags referenced by options that can not be reloaded.
+func (s *Box) staticReferences(options option.Options) (map[string]bool, error) {
+	content, err := json.MarshalContext(s.ctx, staticOptions(options))
+	if err != nil {
+		return nil, E.Cause(err, "marshal options")
+	}
+	var value any
+	err = json.Unmarshal(content, &value)
+	if err != nil {
+		return nil, err
+	}
+	references := make(map[string]bool)
+	var walk func(value any)
+	walk = func(value any) {
+		switch typedValue := value.(type) {
+		case map[string]any:
+			for key, item := range typedValue {
+				if tag, isString := item.(string); isString && common.Contains(referenceKeys, key) {
+					references[tag] = true
+				}
+				walk(item)
+			}
+		case []any:
+			for _, item := range typedValue {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+	return references, nil
+}
+
+// checkRuleOutbounds returns an error if the rule references outbounds which will not exist after the reload.
+func checkRuleOutbounds(rule option.Rule, outboundTags map[string]bool) error {
+	var (
+		action      option.RuleAction
+		preferredBy []string
+	)
+	switch rule.Type {
+	case C.RuleTypeDefault:
+		action = rule.DefaultOptions.RuleAction
+		preferredBy = rule.DefaultOptions.PreferredBy
+	case C.RuleTypeLogical:
+		action = rule.LogicalOptions.RuleAction
+		for _, subRule := range rule.LogicalOptions.Rules {
+			err := checkRuleOutbounds(subRule, outboundTags)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	var references []string
+	switch action.Action {
+	case "", C.RuleActionTypeRoute:
+		references = append(references, action.RouteOptions.Outbound)
+	case C.RuleActionTypeBypass:
+		references = append(references, action.BypassOptions.Outbound)
+	}
+	for _, tag := range append(references, preferredBy...) {
+		if tag != "" && !outboundTags[tag] {
+			return E.New("outbound not found: ", tag)
+		}
+	}
+	return nil
+}
+
+func closeRules[T adapter.Rule](rules []T) {
+	for _, rule := range rules {
+		rule.Close()
+	}
+}
+
+// removeOutbounds removes affected outbounds which are not recreated, dependents first.
+func (s *Box) removeOutbounds(affected map[string]bool, entries []outbound.Entry) error {
+	var removed []string
+	for tag := range affected {
+		if _, loaded := s.outbound.Outbound(tag); !loaded {
+			continue
+		}
+		if _, isEndpoint := s.endpoint.Get(tag); isEndpoint {
+			continue
+		}
+		if common.Any(entries, func(it outbound.Entry) bool { return it.Tag == tag }) {
+			continue
+		}
+		removed = append(removed, tag)
+	}
+	for len(removed) > 0 {
+		var (
+			remaining []string
+			lastErr   error
+		)
+		for _, tag := range removed {
+			err := s.outbound.Remove(tag)
+			if err != nil {
+				remaining = append(remaining, tag)
+				lastErr = err
+			}
+		}
+		if len(remaining) == len(removed) {
+			return E.Cause(lastErr, "remove outbound")
+		}
+		removed = remaining
+	}
+	return nil
+}
+
+func diffOptions[T any](ctx context.Context, oldOptions []T, newOptions []T, tagOf func(T) string) (changed []string, removed []string, err error) {
+	oldContent := make(map[string][]byte)
+	for index, options := range oldOptions {
+		oldContent[optionsTag(tagOf(options), index)], err = json.MarshalContext(ctx, &options)
+		if err != nil {
+			return
+		}
+	}
+	for index, options := range newOptions {
+		tag := optionsTag(tagOf(options), index)
+		var content []byte
+		content, err = json.MarshalContext(ctx, &options)
+		if err != nil {
+			return
+		}
+		existing, loaded := oldContent[tag]
+		if !loaded || !bytes.Equal(existing, content) {
+			changed = append(changed, tag)
+		}
+		delete(oldContent, tag)
+	}
+	for tag := range oldContent {
+		removed = append(removed, tag)
+	}
+	return
+}
+
+func optionsTag(tag string, index int) string {
+	if tag != "" {
+		return tag
+	}
+	return F.ToString(index)
+}
+
+func firstTag(outbounds []option.Outbound) string {
+	if len(outbounds) == 0 {
+		return ""
+	}
+	return optionsTag(outbounds[0].Tag, 0)
+}
+
+func ruleSetTags(rules []option.Rule, dnsRules []option.DNSRule) []string {
+	var tags []string
+	for _, rule := range rules {
+		switch rule.Type {
+		case C.RuleTypeDefault:
+			tags = append(tags, rule.DefaultOptions.RuleSet...)
+		case C.RuleTypeLogical:
+			tags = append(tags, ruleSetTags(rule.LogicalOptions.Rules, nil)...)
+		}
+	}
+	for _, rule := range dnsRules {
+		switch rule.Type {
+		case C.RuleTypeDefault:
+			tags = append(tags, rule.DefaultOptions.RuleSet...)
+		case C.RuleTypeLogical:
+			tags = append(tags, ruleSetTags(nil, rule.LogicalOptions.Rules)...)
+		}
+	}
+	return tags
+}
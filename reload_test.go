@@ -0,0 +1,215 @@
+package box_test
+
+import (
+	"context"
+	"testing"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/stretchr/testify/require"
+)
+
+const reloadBaseConfig = `{
+  "log": {"disabled": true},
+  "inbounds": [
+    {"type": "mixed", "tag": "mixed-a", "listen": "127.0.0.1"},
+    {"type": "mixed", "tag": "mixed-b", "listen": "127.0.0.1"},
+    {"type": "mixed", "tag": "mixed-c", "listen": "127.0.0.1"}
+  ],
+  "outbounds": [
+    {"type": "direct", "tag": "direct-a"},
+    {"type": "direct", "tag": "direct-b"},
+    {"type": "selector", "tag": "select", "outbounds": ["direct-b"]},
+    {"type": "selector", "tag": "select-nested", "outbounds": ["select"]}
+  ],
+  "route": {
+    "rules": [
+      {"rule_set": "set-a", "outbound": "direct-a"},
+      {"rule_set": "set-b", "outbound": "select"}
+    ],
+    "rule_set": [
+      {"tag": "set-a", "rules": [{"domain": "a.example.com"}]},
+      {"tag": "set-b", "rules": [{"domain": "b.example.com"}]},
+      {"tag": "set-c", "rules": [{"domain": "c.example.com"}]}
+    ]
+  }
+}`
+
+func parseReloadOptions(t *testing.T, ctx context.Context, content string) option.Options {
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(content))
+	require.NoError(t, err)
+	return options
+}
+
+func startReloadBox(t *testing.T) (context.Context, *box.Box) {
+	ctx, cancel := context.WithCancel(include.Context(context.Background()))
+	instance, err := box.New(box.Options{
+		Context: ctx,
+		Options: parseReloadOptions(t, ctx, reloadBaseConfig),
+	})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	t.Cleanup(func() {
+		instance.Close()
+		cancel()
+	})
+	return ctx, instance
+}
+
+func loadInbounds(instance *box.Box, tags ...string) map[string]adapter.Inbound {
+	inbounds := make(map[string]adapter.Inbound)
+	for _, tag := range tags {
+		inbounds[tag], _ = instance.Inbound().Get(tag)
+	}
+	return inbounds
+}
+
+func loadOutbounds(instance *box.Box, tags ...string) map[string]adapter.Outbound {
+	outbounds := make(map[string]adapter.Outbound)
+	for _, tag := range tags {
+		outbounds[tag], _ = instance.Outbound().Outbound(tag)
+	}
+	return outbounds
+}
+
+func ruleDescriptions(instance *box.Box) []string {
+	var descriptions []string
+	for _, rule := range instance.Router().Rules() {
+		descriptions = append(descriptions, rule.String())
+	}
+	return descriptions
+}
+
+func TestReloadInbounds(t *testing.T) {
+	t.Parallel()
+	ctx, instance := startReloadBox(t)
+	oldInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	oldOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	options := parseReloadOptions(t, ctx, reloadBaseConfig)
+	options.Inbounds = parseReloadOptions(t, ctx, `{
+  "inbounds": [
+    {"type": "mixed", "tag": "mixed-a", "listen": "127.0.0.1"},
+    {"type": "mixed", "tag": "mixed-b", "listen": "127.0.0.1", "users": [{"username": "user", "password": "pass"}]}
+  ]
+}`).Inbounds
+	require.NoError(t, instance.Reload(options))
+	newInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	require.Same(t, oldInbounds["mixed-a"], newInbounds["mixed-a"])
+	require.NotNil(t, newInbounds["mixed-b"])
+	require.NotSame(t, oldInbounds["mixed-b"], newInbounds["mixed-b"])
+	require.Nil(t, newInbounds["mixed-c"])
+	newOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	for tag, outbound := range oldOutbounds {
+		require.Same(t, outbound, newOutbounds[tag], tag)
+	}
+}
+
+func TestReloadDependentOutbounds(t *testing.T) {
+	t.Parallel()
+	ctx, instance := startReloadBox(t)
+	oldInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	oldOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	options := parseReloadOptions(t, ctx, reloadBaseConfig)
+	options.Outbounds = parseReloadOptions(t, ctx, `{
+  "outbounds": [
+    {"type": "direct", "tag": "direct-a"},
+    {"type": "direct", "tag": "direct-b", "connect_timeout": "5s"},
+    {"type": "selector", "tag": "select", "outbounds": ["direct-b"]},
+    {"type": "selector", "tag": "select-nested", "outbounds": ["select"]}
+  ]
+}`).Outbounds
+	require.NoError(t, instance.Reload(options))
+	newOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	require.Same(t, oldOutbounds["direct-a"], newOutbounds["direct-a"])
+	// outbounds depending on the changed outbound are recreated, including transitive dependents
+	for _, tag := range []string{"direct-b", "select", "select-nested"} {
+		require.NotNil(t, newOutbounds[tag], tag)
+		require.NotSame(t, oldOutbounds[tag], newOutbounds[tag], tag)
+	}
+	newInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	for tag, inbound := range oldInbounds {
+		require.Same(t, inbound, newInbounds[tag], tag)
+	}
+}
+
+func TestReloadRuleSetReference(t *testing.T) {
+	t.Parallel()
+	ctx, instance := startReloadBox(t)
+	oldInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	oldOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	oldRules := ruleDescriptions(instance)
+	options := parseReloadOptions(t, ctx, reloadBaseConfig)
+	options.Route.Rules = parseReloadOptions(t, ctx, `{
+  "route": {"rules": [{"rule_set": "set-b", "outbound": "direct-a"}]}
+}`).Route.Rules
+	require.NoError(t, instance.Reload(options))
+	newRules := ruleDescriptions(instance)
+	require.Len(t, newRules, 1)
+	require.NotEqual(t, oldRules, newRules)
+	require.Contains(t, newRules[0], "set-b")
+	newInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	for tag, inbound := range oldInbounds {
+		require.Same(t, inbound, newInbounds[tag], tag)
+	}
+	newOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	for tag, outbound := range oldOutbounds {
+		require.Same(t, outbound, newOutbounds[tag], tag)
+	}
+
+	// rule-sets not referenced by the running rules are not loaded
+	options = parseReloadOptions(t, ctx, reloadBaseConfig)
+	options.Route.Rules = parseReloadOptions(t, ctx, `{
+  "route": {"rules": [{"rule_set": "set-c", "outbound": "direct-a"}]}
+}`).Route.Rules
+	require.ErrorContains(t, instance.Reload(options), "rule-set set-c is not loaded")
+	require.Equal(t, newRules, ruleDescriptions(instance))
+}
+
+func TestReloadInvalidRules(t *testing.T) {
+	t.Parallel()
+	ctx, instance := startReloadBox(t)
+	oldInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	oldOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	oldRules := ruleDescriptions(instance)
+	options := parseReloadOptions(t, ctx, reloadBaseConfig)
+	options.Inbounds = options.Inbounds[:1]
+	options.Outbounds = parseReloadOptions(t, ctx, `{
+  "outbounds": [
+    {"type": "direct", "tag": "direct-a", "connect_timeout": "5s"},
+    {"type": "direct", "tag": "direct-b"},
+    {"type": "selector", "tag": "select", "outbounds": ["direct-b"]},
+    {"type": "selector", "tag": "select-nested", "outbounds": ["select"]}
+  ]
+}`).Outbounds
+	// a rule referencing an unknown outbound fails the reload before anything is changed
+	options.Route.Rules = parseReloadOptions(t, ctx, `{
+  "route": {"rules": [{"rule_set": "set-a", "outbound": "missing"}]}
+}`).Route.Rules
+	require.ErrorContains(t, instance.Reload(options), "outbound not found: missing")
+	require.Equal(t, oldRules, ruleDescriptions(instance))
+	newInbounds := loadInbounds(instance, "mixed-a", "mixed-b", "mixed-c")
+	for tag, inbound := range oldInbounds {
+		require.Same(t, inbound, newInbounds[tag], tag)
+	}
+	newOutbounds := loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	for tag, outbound := range oldOutbounds {
+		require.Same(t, outbound, newOutbounds[tag], tag)
+	}
+
+	// so does a rule referencing an outbound removed by the same reload
+	options = parseReloadOptions(t, ctx, reloadBaseConfig)
+	options.Outbounds = options.Outbounds[:3]
+	options.Route.Rules = parseReloadOptions(t, ctx, `{
+  "route": {"rules": [{"rule_set": "set-a", "outbound": "select-nested"}]}
+}`).Route.Rules
+	require.ErrorContains(t, instance.Reload(options), "outbound not found: select-nested")
+	require.Equal(t, oldRules, ruleDescriptions(instance))
+	newOutbounds = loadOutbounds(instance, "direct-a", "direct-b", "select", "select-nested")
+	for tag, outbound := range oldOutbounds {
+		require.Same(t, outbound, newOutbounds[tag], tag)
+	}
+}
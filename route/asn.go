@@ -0,0 +1,45 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/geoip"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+var _ adapter.ASNDatabase = (*ASNDatabase)(nil)
+
+type ASNDatabase struct {
+	reader *geoip.ASNReader
+}
+
+func NewASNDatabase(ctx context.Context, options option.ASNOptions) (*ASNDatabase, error) {
+	if options.Path == "" {
+		return nil, E.New("missing path")
+	}
+	reader, err := geoip.OpenASN(filemanager.BasePath(ctx, options.Path))
+	if err != nil {
+		return nil, E.Cause(err, "open ASN database")
+	}
+	return &ASNDatabase{reader: reader}, nil
+}
+
+func (d *ASNDatabase) Name() string {
+	return "asn"
+}
+
+func (d *ASNDatabase) Start(stage adapter.StartStage) error {
+	return nil
+}
+
+func (d *ASNDatabase) LookupASN(addr netip.Addr) uint32 {
+	return d.reader.LookupASN(addr)
+}
+
+func (d *ASNDatabase) Close() error {
+	return d.reader.Close()
+}
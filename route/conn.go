@@ -13,6 +13,7 @@ import (
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/proxyproto"
 	"github.com/sagernet/sing-box/common/tlsfragment"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
@@ -125,6 +126,15 @@ func (m *ConnectionManager) NewConnection(ctx context.Context, this N.Dialer, co
 		m.logger.ErrorContext(ctx, err)
 		return
 	}
+	if metadata.ProxyProtocol != 0 && isDirectDialer(this) {
+		err = writeProxyProtocolHeader(remoteConn, metadata)
+		if err != nil {
+			remoteConn.Close()
+			N.CloseOnHandshakeFailure(conn, onClose, err)
+			m.logger.ErrorContext(ctx, err)
+			return
+		}
+	}
 	if metadata.TLSFragment || metadata.TLSRecordFragment {
 		remoteConn = tf.NewConn(remoteConn, ctx, metadata.TLSFragment, metadata.TLSRecordFragment, metadata.TLSFragmentFallbackDelay)
 	}
@@ -211,6 +221,16 @@ func (m *ConnectionManager) NewPacketConnection(ctx context.Context, this N.Dial
 		m.logger.ErrorContext(ctx, "report handshake success: ", err)
 		return
 	}
+	if metadata.ProxyProtocol != 0 && isDirectDialer(this) {
+		header, err := proxyproto.Header(int(metadata.ProxyProtocol), N.NetworkUDP, metadata.Source, metadata.OriginDestination)
+		if err != nil {
+			conn.Close()
+			remotePacketConn.Close()
+			m.logger.ErrorContext(ctx, err)
+			return
+		}
+		remotePacketConn = proxyproto.NewPacketConn(remotePacketConn, header)
+	}
 	if destinationAddress.IsValid() {
 		var originDestination M.Socksaddr
 		if metadata.RouteOriginalDestination.IsValid() {
@@ -256,6 +276,25 @@ func (m *ConnectionManager) NewPacketConnection(ctx context.Context, this N.Dial
 	go m.packetConnectionCopy(ctx, destination, conn, true, &done, onClose)
 }
 
+// isDirectDialer reports whether this connects to the destination itself,
+// the PROXY protocol header is not sent through proxies.
+func isDirectDialer(this N.Dialer) bool {
+	_, isDirect := this.(dialer.DirectDialer)
+	return isDirect
+}
+
+func writeProxyProtocolHeader(conn net.Conn, metadata adapter.InboundContext) error {
+	header, err := proxyproto.Header(int(metadata.ProxyProtocol), N.NetworkTCP, metadata.Source, metadata.OriginDestination)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(header)
+	if err != nil {
+		return E.Cause(err, "write PROXY protocol header")
+	}
+	return nil
+}
+
 func (m *ConnectionManager) connectionCopy(ctx context.Context, source net.Conn, destination net.Conn, direction bool, done *atomic.Bool, onClose N.CloseHandlerFunc) {
 	_, err := bufio.CopyWithIncreateBuffer(destination, source, bufio.DefaultIncreaseBufferAfter, bufio.DefaultBatchSize)
 	if err != nil {
@@ -0,0 +1,45 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/geoip"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+var _ adapter.CountryDatabase = (*CountryDatabase)(nil)
+
+type CountryDatabase struct {
+	reader *geoip.Reader
+}
+
+func NewCountryDatabase(ctx context.Context, options option.CountryOptions) (*CountryDatabase, error) {
+	if options.Path == "" {
+		return nil, E.New("missing path")
+	}
+	reader, _, err := geoip.Open(filemanager.BasePath(ctx, options.Path))
+	if err != nil {
+		return nil, E.Cause(err, "open country database")
+	}
+	return &CountryDatabase{reader: reader}, nil
+}
+
+func (d *CountryDatabase) Name() string {
+	return "country"
+}
+
+func (d *CountryDatabase) Start(stage adapter.StartStage) error {
+	return nil
+}
+
+func (d *CountryDatabase) LookupCountry(addr netip.Addr) string {
+	return d.reader.Lookup(addr)
+}
+
+func (d *CountryDatabase) Close() error {
+	return d.reader.Close()
+}
@@ -0,0 +1,77 @@
+package route_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/include"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPActionKeepAlive(t *testing.T) {
+	t.Parallel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(request.URL.Path))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+	inboundListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	inboundPort := inboundListener.Addr().(*net.TCPAddr).Port
+	inboundListener.Close()
+	ctx, cancel := context.WithCancel(include.Context(context.Background()))
+	defer cancel()
+	options, err := json.UnmarshalExtendedContext[option.Options](ctx, []byte(`{
+  "log": {"disabled": true},
+  "inbounds": [
+    {
+      "type": "direct",
+      "listen": "127.0.0.1",
+      "listen_port": `+strconv.Itoa(inboundPort)+`,
+      "override_address": "127.0.0.1",
+      "override_port": `+strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)+`
+    }
+  ],
+  "route": {
+    "rules": [
+      {"action": "sniff"},
+      {"action": "http-redirect", "url_regex": "/second$", "location": "http://example.org/"}
+    ]
+  }
+}`))
+	require.NoError(t, err)
+	instance, err := box.New(box.Options{Context: ctx, Options: options})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	defer instance.Close()
+	client := &http.Client{
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+	// both requests would share one connection if it was kept alive after the first exchange
+	baseURL := "http://127.0.0.1:" + strconv.Itoa(inboundPort)
+	response, err := client.Get(baseURL + "/first")
+	require.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "/first", string(body))
+	response, err = client.Get(baseURL + "/second")
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusFound, response.StatusCode)
+	require.Equal(t, "http://example.org/", response.Header.Get("Location"))
+}
@@ -91,7 +91,7 @@ func (r *Router) routeConnection(ctx context.Context, conn net.Conn, metadata ad
 	if deadline.NeedAdditionalReadDeadline(conn) {
 		conn = deadline.NewConn(conn)
 	}
-	selectedRule, _, buffers, _, err := r.matchRule(ctx, &metadata, false, false, conn, nil)
+	selectedRule, _, conn, buffers, _, err := r.matchRule(ctx, &metadata, false, false, conn, nil)
 	if err != nil {
 		return err
 	}
@@ -135,6 +135,16 @@ func (r *Router) routeConnection(ctx context.Context, conn net.Conn, metadata ad
 			}
 			N.CloseOnHandshakeFailure(conn, onClose, r.hijackDNSStream(ctx, conn, metadata))
 			return nil
+		case R.RuleActionHTTPResponse:
+			for _, buffer := range buffers {
+				conn = bufio.NewCachedConn(conn, buffer)
+			}
+			err = r.actionHTTPResponse(ctx, conn, metadata, action)
+			if err != nil {
+				return err
+			}
+			N.CloseOnHandshakeFailure(conn, onClose, conn.Close())
+			return nil
 		}
 	}
 	if selectedRule == nil {
@@ -149,6 +159,12 @@ func (r *Router) routeConnection(ctx context.Context, conn net.Conn, metadata ad
 	for _, buffer := range buffers {
 		conn = bufio.NewCachedConn(conn, buffer)
 	}
+	if metadata.HTTPMethod != "" && hasHTTPAction(r.Rules()) {
+		conn, onClose = r.newHTTPHeaderRewrite(ctx, conn, metadata, onClose)
+	}
+	if metadata.MITM {
+		conn, onClose = r.newMITMUpstream(ctx, conn, metadata, *metadata.MITMState, onClose)
+	}
 	for _, tracker := range r.trackers {
 		conn = tracker.RoutedConnection(ctx, conn, metadata, selectedRule, selectedOutbound)
 	}
@@ -220,7 +236,7 @@ func (r *Router) routePacketConnection(ctx context.Context, conn N.PacketConn, m
 		conn = deadline.NewPacketConn(bufio.NewNetPacketConn(conn))
 	}*/
 
-	selectedRule, _, _, packetBuffers, err := r.matchRule(ctx, &metadata, false, false, nil, conn)
+	selectedRule, _, _, _, packetBuffers, err := r.matchRule(ctx, &metadata, false, false, nil, conn)
 	if err != nil {
 		return err
 	}
@@ -290,7 +306,7 @@ func (r *Router) routePacketConnection(ctx context.Context, conn N.PacketConn, m
 }
 
 func (r *Router) PreMatch(metadata adapter.InboundContext, routeContext tun.DirectRouteContext, timeout time.Duration, supportBypass bool) (tun.DirectRouteDestination, error) {
-	selectedRule, _, _, _, err := r.matchRule(r.ctx, &metadata, true, supportBypass, nil, nil)
+	selectedRule, _, _, _, _, err := r.matchRule(r.ctx, &metadata, true, supportBypass, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -404,9 +420,10 @@ func (r *Router) matchRule(
 	ctx context.Context, metadata *adapter.InboundContext, preMatch bool, supportBypass bool,
 	inputConn net.Conn, inputPacketConn N.PacketConn,
 ) (
-	selectedRule adapter.Rule, selectedRuleIndex int,
+	selectedRule adapter.Rule, selectedRuleIndex int, outputConn net.Conn,
 	buffers []*buf.Buffer, packetBuffers []*N.PacketBuffer, fatalErr error,
 ) {
+	outputConn = inputConn
 	r.searchProcessInfo(ctx, metadata)
 	if metadata.Destination.Addr.IsValid() && r.dnsTransport.FakeIP() != nil && r.dnsTransport.FakeIP().Store().Contains(metadata.Destination.Addr) {
 		domain, loaded := r.dnsTransport.FakeIP().Store().Lookup(metadata.Destination.Addr)
@@ -437,7 +454,7 @@ func (r *Router) matchRule(
 	}
 
 match:
-	for currentRuleIndex, currentRule := range r.rules {
+	for currentRuleIndex, currentRule := range r.Rules() {
 		metadata.ResetRuleCache()
 		if !currentRule.Match(metadata) {
 			continue
@@ -519,7 +536,10 @@ match:
 		switch action := currentRule.Action().(type) {
 		case *R.RuleActionSniff:
 			if !preMatch {
-				newBuffer, newPacketBuffers, newErr := r.actionSniff(ctx, metadata, action, inputConn, inputPacketConn, buffers, packetBuffers)
+				newConn, newBuffer, newPacketBuffers, newErr := r.actionSniff(ctx, metadata, action, outputConn, inputPacketConn, buffers, packetBuffers)
+				if newConn != nil {
+					outputConn = newConn
+				}
 				if newBuffer != nil {
 					buffers = append(buffers, newBuffer)
 				} else if len(newPacketBuffers) > 0 {
@@ -539,11 +559,32 @@ match:
 			if fatalErr != nil {
 				return
 			}
+		case *R.RuleActionMITM:
+			if !preMatch && outputConn != nil {
+				outputConn, buffers, fatalErr = r.actionMITM(ctx, metadata, outputConn, buffers)
+				if fatalErr != nil {
+					return
+				}
+			}
+		case *R.RuleActionHTTPHeader:
+			if metadata.HTTPMethod != "" {
+				metadata.HTTPHeaderRewriters = append(metadata.HTTPHeaderRewriters, action)
+			}
+		case R.RuleActionHTTPResponse:
+			if metadata.HTTPMethod == "" {
+				continue match
+			}
+			if redirectAction, isRedirect := action.(*R.RuleActionHTTPRedirect); isRedirect && !redirectAction.MatchURL(metadata) {
+				continue match
+			}
 		}
 		actionType := currentRule.Action().Type()
 		if actionType == C.RuleActionTypeRoute ||
 			actionType == C.RuleActionTypeReject ||
-			actionType == C.RuleActionTypeHijackDNS {
+			actionType == C.RuleActionTypeHijackDNS ||
+			actionType == C.RuleActionTypeHTTPRedirect ||
+			actionType == C.RuleActionTypeHTTPReject ||
+			actionType == C.RuleActionTypeHTTPMock {
 			selectedRule = currentRule
 			selectedRuleIndex = currentRuleIndex
 			break match
@@ -564,16 +605,33 @@ match:
 func (r *Router) actionSniff(
 	ctx context.Context, metadata *adapter.InboundContext, action *R.RuleActionSniff,
 	inputConn net.Conn, inputPacketConn N.PacketConn, inputBuffers []*buf.Buffer, inputPacketBuffers []*N.PacketBuffer,
-) (buffer *buf.Buffer, packetBuffers []*N.PacketBuffer, fatalErr error) {
-	if sniff.Skip(metadata) {
-		r.logger.DebugContext(ctx, "sniff skipped due to port considered as server-first")
-		return
-	} else if metadata.Protocol != "" {
+) (conn net.Conn, buffer *buf.Buffer, packetBuffers []*N.PacketBuffer, fatalErr error) {
+	if metadata.Protocol != "" {
 		r.logger.DebugContext(ctx, "duplicate sniff skipped")
 		return
+	} else if sniff.Skip(metadata) {
+		if inputConn == nil || len(action.ServerFirstSniffers) == 0 || len(inputBuffers) > 0 {
+			r.logger.DebugContext(ctx, "sniff skipped due to port considered as server-first")
+			return
+		} else if slices.Equal(metadata.SnifferNames, action.SnifferNames) && metadata.SniffError != nil {
+			r.logger.DebugContext(ctx, "sniff skipped due to previous error: ", metadata.SniffError)
+			return
+		}
+		var err error
+		conn, err = sniff.PeekServerFirst(ctx, metadata, inputConn, action.Timeout, action.ServerFirstSniffers...)
+		metadata.SnifferNames = action.SnifferNames
+		metadata.SniffError = err
+		if err == nil {
+			if metadata.Domain != "" {
+				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol, ", domain: ", metadata.Domain)
+			} else {
+				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol)
+			}
+		}
+		return
 	}
 	if inputConn != nil {
-		if len(action.StreamSniffers) == 0 && len(action.PacketSniffers) > 0 {
+		if len(action.StreamSniffers) == 0 && (len(action.PacketSniffers) > 0 || len(action.ServerFirstSniffers) > 0) {
 			return
 		} else if slices.Equal(metadata.SnifferNames, action.SnifferNames) && metadata.SniffError != nil && !errors.Is(metadata.SniffError, sniff.ErrNeedMoreData) {
 			r.logger.DebugContext(ctx, "packet sniff skipped due to previous error: ", metadata.SniffError)
@@ -590,6 +648,7 @@ func (r *Router) actionSniff(
 				sniff.BitTorrent,
 				sniff.SSH,
 				sniff.RDP,
+				sniff.MQTT,
 			}
 		}
 		sniffBuffer := buf.NewPacket()
@@ -616,6 +675,10 @@ func (r *Router) actionSniff(
 				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol, ", domain: ", metadata.Domain, ", client: ", metadata.Client)
 			} else if metadata.Domain != "" {
 				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol, ", domain: ", metadata.Domain)
+			} else if metadata.MQTTClientID != "" {
+				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol, ", client id: ", metadata.MQTTClientID, ", topic: ", metadata.Topic)
+			} else if metadata.Client != "" {
+				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol, ", client: ", metadata.Client)
 			} else {
 				r.logger.DebugContext(ctx, "sniffed protocol: ", metadata.Protocol)
 			}
@@ -626,7 +689,7 @@ func (r *Router) actionSniff(
 			sniffBuffer.Release()
 		}
 	} else if inputPacketConn != nil {
-		if len(action.PacketSniffers) == 0 && len(action.StreamSniffers) > 0 {
+		if len(action.PacketSniffers) == 0 && (len(action.StreamSniffers) > 0 || len(action.ServerFirstSniffers) > 0) {
 			return
 		} else if slices.Equal(metadata.SnifferNames, action.SnifferNames) && metadata.SniffError != nil && !errors.Is(metadata.SniffError, sniff.ErrNeedMoreData) {
 			r.logger.DebugContext(ctx, "packet sniff skipped due to previous error: ", metadata.SniffError)
@@ -647,6 +710,7 @@ func (r *Router) actionSniff(
 				sniff.UDPTracker,
 				sniff.DTLSRecord,
 				sniff.NTP,
+				sniff.WireGuard,
 			}
 		}
 		var err error
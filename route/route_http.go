@@ -0,0 +1,139 @@
+package route
+
+import (
+	std_bufio "bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+	R "github.com/sagernet/sing-box/route/rule"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/pipe"
+)
+
+func (r *Router) actionHTTPResponse(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, action R.RuleActionHTTPResponse) error {
+	request, err := http.ReadRequest(std_bufio.NewReader(conn))
+	if err != nil {
+		return E.Cause(err, "read HTTP request")
+	}
+	response := action.Response(request, metadata)
+	err = response.Write(conn)
+	if err != nil {
+		return E.Cause(err, "write HTTP response")
+	}
+	r.logger.DebugContext(ctx, "HTTP response ", response.StatusCode, " for ", request.Method, " ", request.Host, request.URL.RequestURI())
+	return nil
+}
+
+func (r *Router) newHTTPHeaderRewrite(
+	ctx context.Context, conn net.Conn, metadata adapter.InboundContext, onClose N.CloseHandlerFunc,
+) (net.Conn, N.CloseHandlerFunc) {
+	serverConn, clientConn := pipe.Pipe()
+	closeHandler := N.OnceClose(func(it error) {
+		if onClose != nil {
+			onClose(it)
+		}
+	})
+	go func() {
+		err := rewriteHTTP(ctx, conn, serverConn, metadata.HTTPHeaderRewriters)
+		common.Close(conn, serverConn)
+		if err != nil && !E.IsClosedOrCanceled(err) {
+			r.logger.ErrorContext(ctx, E.Cause(err, "rewrite HTTP"))
+		}
+		closeHandler(err)
+	}()
+	return clientConn, func(it error) {
+		if it != nil {
+			common.Close(conn, serverConn)
+			closeHandler(it)
+		}
+	}
+}
+
+// hasHTTPAction reports whether any rule answers or rewrites HTTP requests.
+func hasHTTPAction(rules []adapter.Rule) bool {
+	for _, rule := range rules {
+		switch rule.Action().(type) {
+		case *R.RuleActionHTTPHeader, R.RuleActionHTTPResponse:
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteHTTP relays one HTTP/1.x exchange from conn to upstream, rewriting request and response headers,
+// and falls back to raw copying after a protocol upgrade.
+// HTTP actions are matched against the first request only, so the connection is closed after the exchange,
+// and the next request of the client is routed again on a new connection.
+func rewriteHTTP(ctx context.Context, conn net.Conn, upstream net.Conn, rewriters []adapter.HTTPHeaderRewriter) error {
+	reader := std_bufio.NewReader(conn)
+	upstreamReader := std_bufio.NewReader(upstream)
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return E.Cause(err, "read request")
+	}
+	// Host is not written from the header map by net/http
+	request.Header.Set("Host", request.Host)
+	for _, rewriter := range rewriters {
+		rewriter.RewriteRequestHeader(request.Header)
+	}
+	request.Host = request.Header.Get("Host")
+	request.Header.Del("Host")
+	if _, loaded := request.Header["User-Agent"]; !loaded {
+		// prevent net/http from adding its default User-Agent
+		request.Header["User-Agent"] = []string{""}
+	}
+	err = request.Write(upstream)
+	if err != nil {
+		return E.Cause(err, "write request")
+	}
+	for {
+		response, err := http.ReadResponse(upstreamReader, request)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return E.Cause(err, "read response")
+		}
+		for _, rewriter := range rewriters {
+			rewriter.RewriteResponseHeader(response.Header)
+		}
+		isFinal := response.StatusCode < 100 || response.StatusCode >= 200
+		if isFinal {
+			response.Header.Set("Connection", "close")
+			response.Close = true
+		}
+		err = response.Write(conn)
+		response.Body.Close()
+		if err != nil {
+			return E.Cause(err, "write response")
+		}
+		if response.StatusCode == http.StatusSwitchingProtocols {
+			return bufio.CopyConn(ctx, newBufferedConn(conn, reader), newBufferedConn(upstream, upstreamReader))
+		}
+		if isFinal {
+			return nil
+		}
+	}
+}
+
+func newBufferedConn(conn net.Conn, reader *std_bufio.Reader) net.Conn {
+	buffered := reader.Buffered()
+	if buffered == 0 {
+		return conn
+	}
+	content, _ := reader.Peek(buffered)
+	buffer := buf.NewSize(buffered)
+	common.Must1(buffer.Write(content))
+	return bufio.NewCachedConn(conn, buffer)
+}
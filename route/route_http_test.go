@@ -0,0 +1,78 @@
+package route
+
+import (
+	std_bufio "bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	R "github.com/sagernet/sing-box/route/rule"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteHTTP(t *testing.T) {
+	t.Parallel()
+	conn, clientConn := net.Pipe()
+	upstream, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	rewriter := &R.RuleActionHTTPHeader{
+		Request: &R.HTTPHeaderRewrite{
+			Add:     http.Header{"X-Added": {"1"}},
+			Replace: http.Header{"Host": {"example.org"}},
+			Remove:  []string{"Cookie"},
+		},
+		Response: &R.HTTPHeaderRewrite{
+			Remove: []string{"Set-Cookie"},
+		},
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- rewriteHTTP(context.Background(), conn, upstream, []adapter.HTTPHeaderRewriter{rewriter})
+		conn.Close()
+		upstream.Close()
+	}()
+	go func() {
+		reader := std_bufio.NewReader(serverConn)
+		for {
+			request, err := http.ReadRequest(reader)
+			if err != nil {
+				return
+			}
+			response := &http.Response{
+				StatusCode: http.StatusOK,
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header: http.Header{
+					"Set-Cookie": {"a=b"},
+					"X-Request":  {request.Host + "|" + request.Header.Get("X-Added") + "|" + request.Header.Get("Cookie") + "|" + request.UserAgent()},
+				},
+				Body:          io.NopCloser(strings.NewReader(request.URL.Path)),
+				ContentLength: int64(len(request.URL.Path)),
+				Close:         request.Close,
+			}
+			err = response.Write(serverConn)
+			if err != nil {
+				return
+			}
+		}
+	}()
+	reader := std_bufio.NewReader(clientConn)
+	go clientConn.Write([]byte("GET /first HTTP/1.1\r\nHost: example.com\r\nCookie: c=d\r\n\r\n"))
+	response, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Empty(t, response.Header.Get("Set-Cookie"))
+	// the upstream sees the replaced host, the added header, no cookie and no default user agent
+	require.Equal(t, "example.org|1||", response.Header.Get("X-Request"))
+	// the connection is closed after the first exchange, so that the next request is routed again
+	require.True(t, response.Close)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.Equal(t, "/first", string(body))
+	require.NoError(t, <-done)
+}
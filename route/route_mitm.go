@@ -0,0 +1,112 @@
+package route
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/sniff"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/pipe"
+)
+
+func (r *Router) actionMITM(
+	ctx context.Context, metadata *adapter.InboundContext, inputConn net.Conn, inputBuffers []*buf.Buffer,
+) (conn net.Conn, buffers []*buf.Buffer, fatalErr error) {
+	conn = inputConn
+	buffers = inputBuffers
+	if metadata.MITM {
+		r.logger.DebugContext(ctx, "duplicate MITM skipped")
+		return
+	}
+	if metadata.Protocol == "" {
+		buffer := buf.NewPacket()
+		err := sniff.PeekStream(ctx, metadata, conn, buffers, buffer, 0, sniff.TLSClientHello)
+		if buffer.IsEmpty() {
+			buffer.Release()
+		} else {
+			buffers = append(buffers, buffer)
+		}
+		if err != nil {
+			r.logger.DebugContext(ctx, "MITM skipped: ", err)
+			return
+		}
+	}
+	if metadata.Protocol != C.ProtocolTLS {
+		r.logger.DebugContext(ctx, "MITM skipped for non-TLS protocol: ", metadata.Protocol)
+		return
+	}
+	serverName := metadata.Domain
+	if serverName == "" {
+		serverName = metadata.Destination.AddrString()
+	}
+	for _, buffer := range buffers {
+		conn = bufio.NewCachedConn(conn, buffer)
+	}
+	buffers = nil
+	tlsConn := r.mitm.Server(conn, serverName)
+	handshakeCtx, cancel := context.WithTimeout(ctx, C.TCPTimeout)
+	err := tlsConn.HandshakeContext(handshakeCtx)
+	cancel()
+	if err != nil {
+		return nil, nil, E.Cause(err, "MITM handshake for ", serverName)
+	}
+	// later actions may wrap the connection, so keep the client handshake state for the upstream
+	state := tlsConn.ConnectionState()
+	metadata.MITM = true
+	metadata.MITMState = &state
+	conn = tlsConn
+	buffer := buf.NewPacket()
+	err = sniff.PeekStream(ctx, metadata, conn, nil, buffer, 0, sniff.HTTPRequest)
+	if buffer.IsEmpty() {
+		buffer.Release()
+	} else {
+		buffers = append(buffers, buffer)
+	}
+	if err == nil {
+		r.logger.DebugContext(ctx, "MITM intercepted ", serverName, ": ", metadata.HTTPMethod, " ", metadata.HTTPPath)
+	} else {
+		r.logger.DebugContext(ctx, "MITM intercepted ", serverName)
+	}
+	return
+}
+
+func (r *Router) newMITMUpstream(
+	ctx context.Context, conn net.Conn, metadata adapter.InboundContext, state tls.ConnectionState, onClose N.CloseHandlerFunc,
+) (net.Conn, N.CloseHandlerFunc) {
+	serverName := state.ServerName
+	if serverName == "" {
+		serverName = metadata.Destination.AddrString()
+	}
+	serverConn, clientConn := pipe.Pipe()
+	upstreamConn := r.mitm.Client(serverConn, serverName, state.NegotiatedProtocol)
+	closeHandler := N.OnceClose(func(it error) {
+		if onClose != nil {
+			onClose(it)
+		}
+	})
+	go func() {
+		err := upstreamConn.HandshakeContext(ctx)
+		if err != nil {
+			common.Close(conn, upstreamConn)
+			if !E.IsClosedOrCanceled(err) {
+				r.logger.ErrorContext(ctx, E.Cause(err, "MITM upstream handshake for ", serverName))
+			}
+			closeHandler(err)
+			return
+		}
+		closeHandler(bufio.CopyConn(ctx, conn, upstreamConn))
+	}()
+	return clientConn, func(it error) {
+		if it != nil {
+			common.Close(conn, serverConn)
+			closeHandler(it)
+		}
+	}
+}
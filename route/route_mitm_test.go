@@ -0,0 +1,63 @@
+package route
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/mitm"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/bufio"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionMITMState(t *testing.T) {
+	t.Parallel()
+	directory := t.TempDir()
+	engine, err := mitm.NewEngine(context.Background(), log.NewNOPFactory().Logger(), option.MITMOptions{
+		CertificatePath: filepath.Join(directory, "ca.crt"),
+		KeyPath:         filepath.Join(directory, "ca.key"),
+	})
+	require.NoError(t, err)
+	router := &Router{
+		logger: log.NewNOPFactory().Logger(),
+		mitm:   engine,
+	}
+	conn, clientConn := net.Pipe()
+	defer conn.Close()
+	defer clientConn.Close()
+	go func() {
+		client := tls.Client(clientConn, &tls.Config{
+			ServerName:         "example.com",
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"http/1.1"},
+		})
+		if client.Handshake() == nil {
+			client.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+		}
+	}()
+	metadata := adapter.InboundContext{
+		Destination: M.ParseSocksaddrHostPort("example.com", 443),
+	}
+	outputConn, buffers, err := router.actionMITM(context.Background(), &metadata, conn, nil)
+	require.NoError(t, err)
+	require.True(t, metadata.MITM)
+	require.Equal(t, C.ProtocolTLS, metadata.Protocol)
+	require.Equal(t, "GET", metadata.HTTPMethod)
+	// the state stays available after later actions wrap the connection
+	for _, buffer := range buffers {
+		outputConn = bufio.NewCachedConn(outputConn, buffer)
+	}
+	_, isTLS := outputConn.(*tls.Conn)
+	require.False(t, isTLS)
+	require.NotNil(t, metadata.MITMState)
+	require.Equal(t, "example.com", metadata.MITMState.ServerName)
+	require.Equal(t, "http/1.1", metadata.MITMState.NegotiatedProtocol)
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/mitm"
 	"github.com/sagernet/sing-box/common/process"
 	"github.com/sagernet/sing-box/common/taskmonitor"
 	C "github.com/sagernet/sing-box/constant"
@@ -34,6 +36,7 @@ type Router struct {
 	dnsTransport      adapter.DNSTransportManager
 	connection        adapter.ConnectionManager
 	network           adapter.NetworkManager
+	ruleAccess        sync.RWMutex
 	rules             []adapter.Rule
 	needFindProcess   bool
 	ruleSets          []adapter.RuleSet
@@ -43,6 +46,8 @@ type Router struct {
 	pauseManager      pause.Manager
 	trackers          []adapter.ConnectionTracker
 	platformInterface adapter.PlatformInterface
+	mitmOptions       *option.MITMOptions
+	mitm              *mitm.Engine
 	started           bool
 	reloadChan        chan<- struct{}
 }
@@ -60,19 +65,30 @@ func NewRouter(ctx context.Context, logFactory log.Factory, options option.Route
 		network:           service.FromContext[adapter.NetworkManager](ctx),
 		rules:             make([]adapter.Rule, 0, len(options.Rules)),
 		ruleSetMap:        make(map[string]adapter.RuleSet),
-		needFindProcess:   hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess,
+		needFindProcess:   NeedFindProcess(options, dnsOptions),
 		pauseManager:      service.FromContext[pause.Manager](ctx),
 		platformInterface: service.FromContext[adapter.PlatformInterface](ctx),
+		mitmOptions:       options.MITM,
 		reloadChan:        reloadChan,
 	}
 }
 
 func (r *Router) Initialize(rules []option.Rule, ruleSets []option.RuleSet) error {
+	if r.mitmOptions != nil {
+		engine, err := mitm.NewEngine(r.ctx, r.logger, common.PtrValueOrDefault(r.mitmOptions))
+		if err != nil {
+			return E.Cause(err, "initialize MITM")
+		}
+		r.mitm = engine
+	}
 	for i, options := range rules {
 		rule, err := R.NewRule(r.ctx, r.logger, options, false)
 		if err != nil {
 			return E.Cause(err, "parse rule[", i, "]")
 		}
+		if rule.Action().Type() == C.RuleActionTypeMITM && r.mitm == nil {
+			return E.New("parse rule[", i, "]: missing `route.mitm` for MITM action")
+		}
 		r.rules = append(r.rules, rule)
 	}
 	for i, options := range ruleSets {
@@ -216,9 +232,49 @@ func (r *Router) RuleSet(tag string) (adapter.RuleSet, bool) {
 }
 
 func (r *Router) Rules() []adapter.Rule {
+	r.ruleAccess.RLock()
+	defer r.ruleAccess.RUnlock()
 	return r.rules
 }
 
+// NewRules parses route rules to replace current rules with SwapRules.
+// The rules are not started, so that they can be checked before other changes are applied.
+func (r *Router) NewRules(rules []option.Rule) ([]adapter.Rule, error) {
+	newRules := make([]adapter.Rule, 0, len(rules))
+	for i, options := range rules {
+		rule, err := R.NewRule(r.ctx, r.logger, options, false)
+		if err != nil {
+			closeRules(newRules)
+			return nil, E.Cause(err, "parse rule[", i, "]")
+		}
+		newRules = append(newRules, rule)
+		if rule.Action().Type() == C.RuleActionTypeMITM && r.mitm == nil {
+			closeRules(newRules)
+			return nil, E.New("parse rule[", i, "]: missing `route.mitm` for MITM action")
+		}
+	}
+	return newRules, nil
+}
+
+// SwapRules replaces route rules with started rules from NewRules in place of a restart.
+// swap is called with the rule lock held, so that DNS rules can be replaced in the same step.
+func (r *Router) SwapRules(rules []adapter.Rule, swap func()) {
+	r.ruleAccess.Lock()
+	oldRules := r.rules
+	r.rules = rules
+	if swap != nil {
+		swap()
+	}
+	r.ruleAccess.Unlock()
+	closeRules(oldRules)
+}
+
+func closeRules(rules []adapter.Rule) {
+	for _, rule := range rules {
+		rule.Close()
+	}
+}
+
 func (r *Router) AppendTracker(tracker adapter.ConnectionTracker) {
 	r.trackers = append(r.trackers, tracker)
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 )
 
@@ -38,7 +39,7 @@ func (r *abstractDefaultRule) Start() error {
 			}
 		}
 	}
-	return nil
+	return startAction(r.action)
 }
 
 func (r *abstractDefaultRule) Close() error {
@@ -210,6 +211,18 @@ func (r *abstractLogicalRule) Start() error {
 			return err
 		}
 	}
+	return startAction(r.action)
+}
+
+func startAction(action adapter.RuleAction) error {
+	if starter, isStarter := action.(interface {
+		Start() error
+	}); isStarter {
+		err := starter.Start()
+		if err != nil {
+			return E.Cause(err, "action")
+		}
+	}
 	return nil
 }
 
@@ -20,6 +20,7 @@ import (
 	"github.com/sagernet/sing/common/logger"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
 
 	"github.com/miekg/dns"
 )
@@ -99,6 +100,8 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 		}, nil
 	case C.RuleActionTypeHijackDNS:
 		return &RuleActionHijackDNS{}, nil
+	case C.RuleActionTypeMITM:
+		return &RuleActionMITM{}, nil
 	case C.RuleActionTypeSniff:
 		sniffAction := &RuleActionSniff{
 			SnifferNames: action.SniffOptions.Sniffer,
@@ -113,15 +116,28 @@ func NewRuleAction(ctx context.Context, logger logger.ContextLogger, action opti
 			RewriteTTL:   action.ResolveOptions.RewriteTTL,
 			ClientSubnet: action.ResolveOptions.ClientSubnet.Build(netip.Prefix{}),
 		}, nil
+	case C.RuleActionTypeHTTPRedirect:
+		return newHTTPRedirectAction(action.HTTPRedirectOptions)
+	case C.RuleActionTypeHTTPReject:
+		return &RuleActionHTTPReject{
+			Method: action.HTTPRejectOptions.Method,
+		}, nil
+	case C.RuleActionTypeHTTPMock:
+		return newHTTPMockAction(ctx, action.HTTPMockOptions)
+	case C.RuleActionTypeHTTPHeader:
+		return &RuleActionHTTPHeader{
+			Request:  newHTTPHeaderRewrite(action.HTTPHeaderOptions.RequestHeader),
+			Response: newHTTPHeaderRewrite(action.HTTPHeaderOptions.ResponseHeader),
+		}, nil
 	default:
 		panic(F.ToString("unknown rule action: ", action.Action))
 	}
 }
 
-func NewDNSRuleAction(logger logger.ContextLogger, action option.DNSRuleAction) adapter.RuleAction {
+func NewDNSRuleAction(ctx context.Context, logger logger.ContextLogger, action option.DNSRuleAction) (adapter.RuleAction, error) {
 	switch action.Action {
 	case "":
-		return nil
+		return nil, nil
 	case C.RuleActionTypeRoute:
 		return &RuleActionDNSRoute{
 			Server: action.RouteOptions.Server,
@@ -131,27 +147,29 @@ func NewDNSRuleAction(logger logger.ContextLogger, action option.DNSRuleAction)
 				RewriteTTL:   action.RouteOptions.RewriteTTL,
 				ClientSubnet: netip.Prefix(common.PtrValueOrDefault(action.RouteOptions.ClientSubnet)),
 			},
-		}
+		}, nil
 	case C.RuleActionTypeRouteOptions:
 		return &RuleActionDNSRouteOptions{
 			Strategy:     C.DomainStrategy(action.RouteOptionsOptions.Strategy),
 			DisableCache: action.RouteOptionsOptions.DisableCache,
 			RewriteTTL:   action.RouteOptionsOptions.RewriteTTL,
 			ClientSubnet: netip.Prefix(common.PtrValueOrDefault(action.RouteOptionsOptions.ClientSubnet)),
-		}
+		}, nil
 	case C.RuleActionTypeReject:
 		return &RuleActionReject{
 			Method: action.RejectOptions.Method,
 			NoDrop: action.RejectOptions.NoDrop,
 			logger: logger,
-		}
+		}, nil
 	case C.RuleActionTypePredefined:
 		return &RuleActionPredefined{
 			Rcode:  action.PredefinedOptions.Rcode.Build(),
 			Answer: common.Map(action.PredefinedOptions.Answer, option.DNSRecordOptions.Build),
 			Ns:     common.Map(action.PredefinedOptions.Ns, option.DNSRecordOptions.Build),
 			Extra:  common.Map(action.PredefinedOptions.Extra, option.DNSRecordOptions.Build),
-		}
+		}, nil
+	case C.RuleActionTypeRewrite:
+		return NewRuleActionDNSRewrite(ctx, action.RewriteOptions)
 	default:
 		panic(F.ToString("unknown rule action: ", action.Action))
 	}
@@ -412,11 +430,22 @@ func (r *RuleActionHijackDNS) String() string {
 	return "hijack-dns"
 }
 
+type RuleActionMITM struct{}
+
+func (r *RuleActionMITM) Type() string {
+	return C.RuleActionTypeMITM
+}
+
+func (r *RuleActionMITM) String() string {
+	return "mitm"
+}
+
 type RuleActionSniff struct {
-	SnifferNames   []string
-	StreamSniffers []sniff.StreamSniffer
-	PacketSniffers []sniff.PacketSniffer
-	Timeout        time.Duration
+	SnifferNames        []string
+	StreamSniffers      []sniff.StreamSniffer
+	PacketSniffers      []sniff.PacketSniffer
+	ServerFirstSniffers []sniff.ServerFirstSniffer
+	Timeout             time.Duration
 	// Deprecated
 	OverrideDestination bool
 }
@@ -451,6 +480,20 @@ func (r *RuleActionSniff) build() error {
 			r.StreamSniffers = append(r.StreamSniffers, sniff.RDP)
 		case C.ProtocolNTP:
 			r.PacketSniffers = append(r.PacketSniffers, sniff.NTP)
+		case C.ProtocolSMTP:
+			r.ServerFirstSniffers = append(r.ServerFirstSniffers, sniff.SMTP)
+		case C.ProtocolIMAP:
+			r.ServerFirstSniffers = append(r.ServerFirstSniffers, sniff.IMAP)
+		case C.ProtocolPOP3:
+			r.ServerFirstSniffers = append(r.ServerFirstSniffers, sniff.POP3)
+		case C.ProtocolMQTT:
+			r.StreamSniffers = append(r.StreamSniffers, sniff.MQTT)
+		case C.ProtocolWireGuard:
+			r.PacketSniffers = append(r.PacketSniffers, sniff.WireGuard)
+		case C.ProtocolSOCKS:
+			r.StreamSniffers = append(r.StreamSniffers, sniff.SOCKS)
+		case C.ProtocolHTTPConnect:
+			r.StreamSniffers = append(r.StreamSniffers, sniff.HTTPConnect)
 		default:
 			return E.New("unknown sniffer: ", name)
 		}
@@ -554,3 +597,143 @@ func rewriteRecords(records []dns.RR, question dns.Question) []dns.RR {
 		return it
 	})
 }
+
+type RuleActionDNSRewrite struct {
+	StripType    []uint16
+	StripIPCIDR  *IPCIDRItem
+	StripRuleSet *RuleSetItem
+	MinTTL       uint32
+	MaxTTL       uint32
+	Answer       []dns.RR
+	Ns           []dns.RR
+	Extra        []dns.RR
+}
+
+func NewRuleActionDNSRewrite(ctx context.Context, options option.DNSRewriteActionOptions) (*RuleActionDNSRewrite, error) {
+	if options.MaxTTL > 0 && options.MinTTL > options.MaxTTL {
+		return nil, E.New("min_ttl must not be greater than max_ttl")
+	}
+	action := &RuleActionDNSRewrite{
+		StripType: common.Map(options.StripType, func(it option.DNSQueryType) uint16 {
+			return uint16(it)
+		}),
+		MinTTL: options.MinTTL,
+		MaxTTL: options.MaxTTL,
+		Answer: common.Map(options.Answer, option.DNSRecordOptions.Build),
+		Ns:     common.Map(options.Ns, option.DNSRecordOptions.Build),
+		Extra:  common.Map(options.Extra, option.DNSRecordOptions.Build),
+	}
+	if len(options.StripIPCIDR) > 0 {
+		item, err := NewIPCIDRItem(false, options.StripIPCIDR)
+		if err != nil {
+			return nil, E.Cause(err, "strip_ip_cidr")
+		}
+		action.StripIPCIDR = item
+	}
+	if len(options.StripRuleSet) > 0 {
+		action.StripRuleSet = NewRuleSetItem(service.FromContext[adapter.Router](ctx), options.StripRuleSet, false, false)
+	}
+	return action, nil
+}
+
+func (r *RuleActionDNSRewrite) Type() string {
+	return C.RuleActionTypeRewrite
+}
+
+func (r *RuleActionDNSRewrite) String() string {
+	var descriptions []string
+	if len(r.StripType) > 0 {
+		descriptions = append(descriptions, "strip-type=["+strings.Join(common.Map(r.StripType, func(it uint16) string {
+			return dns.Type(it).String()
+		}), " ")+"]")
+	}
+	if r.StripIPCIDR != nil {
+		descriptions = append(descriptions, "strip-"+r.StripIPCIDR.String())
+	}
+	if r.StripRuleSet != nil {
+		descriptions = append(descriptions, "strip-"+r.StripRuleSet.String())
+	}
+	if r.MinTTL > 0 {
+		descriptions = append(descriptions, F.ToString("min-ttl=", r.MinTTL))
+	}
+	if r.MaxTTL > 0 {
+		descriptions = append(descriptions, F.ToString("max-ttl=", r.MaxTTL))
+	}
+	for _, recordList := range [][]dns.RR{r.Answer, r.Ns, r.Extra} {
+		descriptions = append(descriptions, common.Map(recordList, dns.RR.String)...)
+	}
+	return F.ToString("rewrite(", strings.Join(descriptions, ","), ")")
+}
+
+func (r *RuleActionDNSRewrite) Start() error {
+	if r.StripRuleSet != nil {
+		return r.StripRuleSet.Start()
+	}
+	return nil
+}
+
+func (r *RuleActionDNSRewrite) RewriteResponse(ctx context.Context, response *dns.Msg) {
+	if len(r.StripType) > 0 || r.StripIPCIDR != nil || r.StripRuleSet != nil {
+		response.Answer = common.Filter(response.Answer, func(it dns.RR) bool {
+			return !r.stripRecord(it)
+		})
+	}
+	if response.Rcode == dns.RcodeSuccess && len(response.Question) > 0 {
+		question := response.Question[0]
+		response.Answer = append(response.Answer, copyRecords(rewriteRecords(r.Answer, question))...)
+		response.Ns = append(response.Ns, copyRecords(rewriteRecords(r.Ns, question))...)
+		response.Extra = append(response.Extra, copyRecords(rewriteRecords(r.Extra, question))...)
+	}
+	if r.MinTTL > 0 || r.MaxTTL > 0 {
+		for _, recordList := range [][]dns.RR{response.Answer, response.Ns, response.Extra} {
+			for _, record := range recordList {
+				if record.Header().Rrtype == dns.TypeOPT {
+					continue
+				}
+				record.Header().Ttl = r.clampTTL(record.Header().Ttl)
+				if soa, isSOA := record.(*dns.SOA); isSOA {
+					soa.Minttl = r.clampTTL(soa.Minttl)
+				}
+			}
+		}
+	}
+}
+
+func (r *RuleActionDNSRewrite) stripRecord(record dns.RR) bool {
+	if common.Contains(r.StripType, record.Header().Rrtype) {
+		return true
+	}
+	if r.StripIPCIDR == nil && r.StripRuleSet == nil {
+		return false
+	}
+	var address netip.Addr
+	switch answer := record.(type) {
+	case *dns.A:
+		address = M.AddrFromIP(answer.A).Unmap()
+	case *dns.AAAA:
+		address = M.AddrFromIP(answer.AAAA)
+	default:
+		return false
+	}
+	metadata := adapter.InboundContext{
+		DestinationAddresses: []netip.Addr{address},
+	}
+	if r.StripIPCIDR != nil && r.StripIPCIDR.Match(&metadata) {
+		return true
+	}
+	return r.StripRuleSet != nil && r.StripRuleSet.Match(&metadata)
+}
+
+func (r *RuleActionDNSRewrite) clampTTL(timeToLive uint32) uint32 {
+	if timeToLive < r.MinTTL {
+		return r.MinTTL
+	}
+	if r.MaxTTL > 0 && timeToLive > r.MaxTTL {
+		return r.MaxTTL
+	}
+	return timeToLive
+}
+
+func copyRecords(records []dns.RR) []dns.RR {
+	return common.Map(records, dns.Copy)
+}
@@ -0,0 +1,219 @@
+package rule
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+// 1x1 transparent GIF
+var transparentGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// RuleActionHTTPResponse is implemented by final actions that answer
+// plain HTTP requests locally instead of routing them.
+type RuleActionHTTPResponse interface {
+	adapter.RuleAction
+	Response(request *http.Request, metadata adapter.InboundContext) *http.Response
+}
+
+func newHTTPRedirectAction(action option.HTTPRedirectActionOptions) (*RuleActionHTTPRedirect, error) {
+	redirectAction := &RuleActionHTTPRedirect{
+		Location:   action.Location,
+		StatusCode: action.StatusCode,
+	}
+	if redirectAction.StatusCode == 0 {
+		redirectAction.StatusCode = http.StatusFound
+	}
+	if action.URLRegex != "" {
+		urlRegex, err := regexp.Compile(action.URLRegex)
+		if err != nil {
+			return nil, E.Cause(err, "url_regex")
+		}
+		redirectAction.URLRegex = urlRegex
+	}
+	return redirectAction, nil
+}
+
+func newHTTPMockAction(ctx context.Context, action option.HTTPMockActionOptions) (*RuleActionHTTPMock, error) {
+	mockAction := &RuleActionHTTPMock{
+		StatusCode: action.StatusCode,
+		Header:     action.Headers.Build(),
+		Body:       []byte(action.Body),
+	}
+	if mockAction.StatusCode == 0 {
+		mockAction.StatusCode = http.StatusOK
+	}
+	if action.BodyPath != "" {
+		body, err := os.ReadFile(filemanager.BasePath(ctx, action.BodyPath))
+		if err != nil {
+			return nil, E.Cause(err, "read mock body")
+		}
+		mockAction.Body = body
+	}
+	return mockAction, nil
+}
+
+type RuleActionHTTPRedirect struct {
+	URLRegex   *regexp.Regexp
+	Location   string
+	StatusCode int
+}
+
+func (r *RuleActionHTTPRedirect) Type() string {
+	return C.RuleActionTypeHTTPRedirect
+}
+
+func (r *RuleActionHTTPRedirect) String() string {
+	return F.ToString("http-redirect(", r.StatusCode, " ", r.Location, ")")
+}
+
+// MatchURL reports whether the redirect applies to the recorded request URL,
+// the rule is skipped if url_regex is set and does not match.
+func (r *RuleActionHTTPRedirect) MatchURL(metadata *adapter.InboundContext) bool {
+	return r.URLRegex == nil || r.URLRegex.MatchString(metadata.HTTPURL)
+}
+
+func (r *RuleActionHTTPRedirect) Response(request *http.Request, metadata adapter.InboundContext) *http.Response {
+	location := r.Location
+	if r.URLRegex != nil {
+		match := r.URLRegex.FindStringSubmatchIndex(metadata.HTTPURL)
+		if match != nil {
+			location = string(r.URLRegex.ExpandString(nil, r.Location, metadata.HTTPURL, match))
+		}
+	}
+	header := make(http.Header)
+	header.Set("Location", location)
+	return newHTTPResponse(request, r.StatusCode, header, nil)
+}
+
+type RuleActionHTTPReject struct {
+	Method string
+}
+
+func (r *RuleActionHTTPReject) Type() string {
+	return C.RuleActionTypeHTTPReject
+}
+
+func (r *RuleActionHTTPReject) String() string {
+	return F.ToString("http-reject(", r.Method, ")")
+}
+
+func (r *RuleActionHTTPReject) Response(request *http.Request, metadata adapter.InboundContext) *http.Response {
+	switch r.Method {
+	case C.RuleActionHTTPRejectMethodEmpty:
+		return newHTTPResponse(request, http.StatusOK, nil, nil)
+	case C.RuleActionHTTPRejectMethodGIF:
+		header := make(http.Header)
+		header.Set("Content-Type", "image/gif")
+		return newHTTPResponse(request, http.StatusOK, header, transparentGIF)
+	default:
+		return newHTTPResponse(request, http.StatusNotFound, nil, nil)
+	}
+}
+
+type RuleActionHTTPMock struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (r *RuleActionHTTPMock) Type() string {
+	return C.RuleActionTypeHTTPMock
+}
+
+func (r *RuleActionHTTPMock) String() string {
+	return F.ToString("http-mock(", r.StatusCode, ")")
+}
+
+func (r *RuleActionHTTPMock) Response(request *http.Request, metadata adapter.InboundContext) *http.Response {
+	return newHTTPResponse(request, r.StatusCode, r.Header.Clone(), r.Body)
+}
+
+func newHTTPResponse(request *http.Request, statusCode int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode:    statusCode,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Close:         true,
+		Request:       request,
+	}
+}
+
+type RuleActionHTTPHeader struct {
+	Request  *HTTPHeaderRewrite
+	Response *HTTPHeaderRewrite
+}
+
+func (r *RuleActionHTTPHeader) Type() string {
+	return C.RuleActionTypeHTTPHeader
+}
+
+func (r *RuleActionHTTPHeader) String() string {
+	return "http-header"
+}
+
+func (r *RuleActionHTTPHeader) RewriteRequestHeader(header http.Header) {
+	r.Request.Rewrite(header)
+}
+
+func (r *RuleActionHTTPHeader) RewriteResponseHeader(header http.Header) {
+	r.Response.Rewrite(header)
+}
+
+type HTTPHeaderRewrite struct {
+	Add     http.Header
+	Replace http.Header
+	Remove  []string
+}
+
+func newHTTPHeaderRewrite(options *option.HTTPHeaderRewriteOptions) *HTTPHeaderRewrite {
+	if options == nil {
+		return nil
+	}
+	return &HTTPHeaderRewrite{
+		Add:     options.Add.Build(),
+		Replace: options.Replace.Build(),
+		Remove:  options.Remove,
+	}
+}
+
+// Rewrite removes headers first, then replaces the values of existing headers,
+// and adds new values last.
+func (r *HTTPHeaderRewrite) Rewrite(header http.Header) {
+	if r == nil {
+		return
+	}
+	for _, name := range r.Remove {
+		header.Del(name)
+	}
+	for name, values := range r.Replace {
+		if _, loaded := header[name]; loaded {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	for name, values := range r.Add {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+}
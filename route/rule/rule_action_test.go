@@ -0,0 +1,113 @@
+package rule
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json/badoption"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSRewriteAction(t *testing.T) {
+	t.Parallel()
+	var (
+		answer option.DNSRecordOptions
+		err    error
+	)
+	err = answer.UnmarshalJSON([]byte(`"cdn.example.net. IN A 10.0.0.1"`))
+	require.NoError(t, err)
+	action, err := NewRuleActionDNSRewrite(context.Background(), option.DNSRewriteActionOptions{
+		StripType:   []option.DNSQueryType{option.DNSQueryType(dns.TypeAAAA)},
+		StripIPCIDR: []string{"198.18.0.0/15"},
+		MinTTL:      60,
+		MaxTTL:      600,
+		Answer:      []option.DNSRecordOptions{answer},
+	})
+	require.NoError(t, err)
+	response := new(dns.Msg)
+	response.SetQuestion("example.com.", dns.TypeA)
+	response.Response = true
+	for _, record := range []string{
+		"example.com. 10 IN CNAME cdn.example.net.",
+		"cdn.example.net. 10 IN A 1.1.1.1",
+		"cdn.example.net. 86400 IN A 198.18.0.1",
+		"cdn.example.net. 10 IN AAAA ::1",
+	} {
+		rr, err := dns.NewRR(record)
+		require.NoError(t, err)
+		response.Answer = append(response.Answer, rr)
+	}
+	action.RewriteResponse(context.Background(), response)
+	require.Len(t, response.Answer, 3)
+	require.Equal(t, dns.TypeCNAME, response.Answer[0].Header().Rrtype)
+	require.Equal(t, "1.1.1.1", response.Answer[1].(*dns.A).A.String())
+	require.Equal(t, "10.0.0.1", response.Answer[2].(*dns.A).A.String())
+	for _, record := range response.Answer {
+		require.GreaterOrEqual(t, record.Header().Ttl, uint32(60))
+		require.LessOrEqual(t, record.Header().Ttl, uint32(600))
+	}
+	_, err = NewRuleActionDNSRewrite(context.Background(), option.DNSRewriteActionOptions{MinTTL: 600, MaxTTL: 60})
+	require.Error(t, err)
+}
+
+func TestHTTPRedirectAction(t *testing.T) {
+	t.Parallel()
+	action, err := newHTTPRedirectAction(option.HTTPRedirectActionOptions{
+		URLRegex: `^https?://example\.com/old/(.*)$`,
+		Location: "https://example.org/new/$1",
+	})
+	require.NoError(t, err)
+	request, err := http.NewRequest("GET", "http://example.com/old/a?b=c", nil)
+	require.NoError(t, err)
+	metadata := adapter.InboundContext{MITM: true, HTTPURL: "https://example.com/old/a?b=c"}
+	require.True(t, action.MatchURL(&metadata))
+	response := action.Response(request, metadata)
+	require.Equal(t, http.StatusFound, response.StatusCode)
+	require.Equal(t, "https://example.org/new/a?b=c", response.Header.Get("Location"))
+	require.False(t, action.MatchURL(&adapter.InboundContext{HTTPURL: "http://example.net/"}))
+	action, err = newHTTPRedirectAction(option.HTTPRedirectActionOptions{Location: "https://example.org/"})
+	require.NoError(t, err)
+	require.True(t, action.MatchURL(&adapter.InboundContext{HTTPURL: "http://example.net/"}))
+}
+
+func TestHTTPRejectAction(t *testing.T) {
+	t.Parallel()
+	request, err := http.NewRequest("GET", "http://example.com/ad.gif", nil)
+	require.NoError(t, err)
+	response := (&RuleActionHTTPReject{Method: C.RuleActionHTTPRejectMethodGIF}).Response(request, adapter.InboundContext{})
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.Equal(t, "image/gif", response.Header.Get("Content-Type"))
+	require.Equal(t, int64(len(transparentGIF)), response.ContentLength)
+	response = (&RuleActionHTTPReject{Method: C.RuleActionHTTPRejectMethodNotFound}).Response(request, adapter.InboundContext{})
+	require.Equal(t, http.StatusNotFound, response.StatusCode)
+	require.Zero(t, response.ContentLength)
+}
+
+func TestHTTPHeaderAction(t *testing.T) {
+	t.Parallel()
+	action := &RuleActionHTTPHeader{
+		Request: newHTTPHeaderRewrite(&option.HTTPHeaderRewriteOptions{
+			Add:     map[string]badoption.Listable[string]{"X-Added": {"1"}},
+			Replace: map[string]badoption.Listable[string]{"Accept": {"text/html"}, "Referer": {"none"}},
+			Remove:  []string{"user-agent"},
+		}),
+	}
+	header := http.Header{
+		"Accept":     {"*/*"},
+		"User-Agent": {"curl"},
+	}
+	action.RewriteRequestHeader(header)
+	require.Equal(t, http.Header{
+		"Accept":  {"text/html"},
+		"X-Added": {"1"},
+	}, header)
+	header = http.Header{"Server": {"test"}}
+	action.RewriteResponseHeader(header)
+	require.Equal(t, http.Header{"Server": {"test"}}, header)
+}
@@ -104,6 +104,52 @@ func NewDefaultRule(ctx context.Context, logger log.ContextLogger, options optio
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.JA3) > 0 {
+		item := NewJA3Item(options.JA3)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.JA4) > 0 {
+		item := NewJA4Item(options.JA4)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPMethod) > 0 {
+		item := NewHTTPMethodItem(options.HTTPMethod)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPHost) > 0 {
+		item := NewHTTPHostItem(options.HTTPHost)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPPathPrefix) > 0 {
+		item := NewHTTPPathPrefixItem(options.HTTPPathPrefix)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPPathRegex) > 0 {
+		item, err := NewHTTPPathRegexItem(options.HTTPPathRegex)
+		if err != nil {
+			return nil, E.Cause(err, "http_path_regex")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPUserAgentKeyword) > 0 {
+		item := NewHTTPUserAgentKeywordItem(options.HTTPUserAgentKeyword)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPUserAgentRegex) > 0 {
+		item, err := NewHTTPUserAgentRegexItem(options.HTTPUserAgentRegex)
+		if err != nil {
+			return nil, E.Cause(err, "http_user_agent_regex")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(options.Domain) > 0 || len(options.DomainSuffix) > 0 {
 		item, err := NewDomainItem(options.Domain, options.DomainSuffix)
 		if err != nil {
@@ -147,6 +193,22 @@ func NewDefaultRule(ctx context.Context, logger log.ContextLogger, options optio
 		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.SourceIPASN) > 0 {
+		item, err := NewIPASNItem(ctx, true, options.SourceIPASN)
+		if err != nil {
+			return nil, E.Cause(err, "source_ip_asn")
+		}
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.SourceIPCountry) > 0 {
+		item, err := NewIPCountryItem(ctx, true, options.SourceIPCountry)
+		if err != nil {
+			return nil, E.Cause(err, "source_ip_country")
+		}
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(options.IPCIDR) > 0 {
 		item, err := NewIPCIDRItem(false, options.IPCIDR)
 		if err != nil {
@@ -160,6 +222,22 @@ func NewDefaultRule(ctx context.Context, logger log.ContextLogger, options optio
 		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.IPASN) > 0 {
+		item, err := NewIPASNItem(ctx, false, options.IPASN)
+		if err != nil {
+			return nil, E.Cause(err, "ip_asn")
+		}
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.IPCountry) > 0 {
+		item, err := NewIPCountryItem(ctx, false, options.IPCountry)
+		if err != nil {
+			return nil, E.Cause(err, "ip_country")
+		}
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(options.SourcePort) > 0 {
 		item := NewPortItem(true, options.SourcePort)
 		rule.sourcePortItems = append(rule.sourcePortItems, item)
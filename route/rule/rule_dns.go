@@ -52,10 +52,14 @@ func (r *DefaultDNSRule) matchStates(metadata *adapter.InboundContext) ruleMatch
 }
 
 func NewDefaultDNSRule(ctx context.Context, logger log.ContextLogger, options option.DefaultDNSRule) (*DefaultDNSRule, error) {
+	action, err := NewDNSRuleAction(ctx, logger, options.DNSRuleAction)
+	if err != nil {
+		return nil, E.Cause(err, "action")
+	}
 	rule := &DefaultDNSRule{
 		abstractDefaultRule: abstractDefaultRule{
 			invert: options.Invert,
-			action: NewDNSRuleAction(logger, options.DNSRuleAction),
+			action: action,
 		},
 	}
 	if len(options.Inbound) > 0 {
@@ -146,11 +150,43 @@ func NewDefaultDNSRule(ctx context.Context, logger log.ContextLogger, options op
 		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.SourceIPASN) > 0 {
+		item, err := NewIPASNItem(ctx, true, options.SourceIPASN)
+		if err != nil {
+			return nil, E.Cause(err, "source_ip_asn")
+		}
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.SourceIPCountry) > 0 {
+		item, err := NewIPCountryItem(ctx, true, options.SourceIPCountry)
+		if err != nil {
+			return nil, E.Cause(err, "source_ip_country")
+		}
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if options.IPIsPrivate {
 		item := NewIPIsPrivateItem(false)
 		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.IPASN) > 0 {
+		item, err := NewIPASNItem(ctx, false, options.IPASN)
+		if err != nil {
+			return nil, E.Cause(err, "ip_asn")
+		}
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.IPCountry) > 0 {
+		item, err := NewIPCountryItem(ctx, false, options.IPCountry)
+		if err != nil {
+			return nil, E.Cause(err, "ip_country")
+		}
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if options.IPAcceptAny {
 		item := NewIPAcceptAnyItem()
 		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
@@ -321,11 +357,15 @@ func (r *LogicalDNSRule) matchStates(metadata *adapter.InboundContext) ruleMatch
 }
 
 func NewLogicalDNSRule(ctx context.Context, logger log.ContextLogger, options option.LogicalDNSRule) (*LogicalDNSRule, error) {
+	action, err := NewDNSRuleAction(ctx, logger, options.DNSRuleAction)
+	if err != nil {
+		return nil, E.Cause(err, "action")
+	}
 	r := &LogicalDNSRule{
 		abstractLogicalRule: abstractLogicalRule{
 			rules:  make([]adapter.HeadlessRule, len(options.Rules)),
 			invert: options.Invert,
-			action: NewDNSRuleAction(logger, options.DNSRuleAction),
+			action: action,
 		},
 	}
 	switch options.Mode {
@@ -66,6 +66,10 @@ func NewDefaultHeadlessRule(ctx context.Context, options option.DefaultHeadlessR
 		item := NewRawDomainItem(options.DomainMatcher)
 		rule.destinationAddressItems = append(rule.destinationAddressItems, item)
 		rule.allItems = append(rule.allItems, item)
+	} else if options.MappedDomainMatcher != nil {
+		item := NewMappedDomainItem(options.MappedDomainMatcher)
+		rule.destinationAddressItems = append(rule.destinationAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
 	}
 	if len(options.DomainKeyword) > 0 {
 		item := NewDomainKeywordItem(options.DomainKeyword)
@@ -91,6 +95,26 @@ func NewDefaultHeadlessRule(ctx context.Context, options option.DefaultHeadlessR
 		item := NewRawIPCIDRItem(true, options.SourceIPSet)
 		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
 		rule.allItems = append(rule.allItems, item)
+	} else if options.MappedSourceIPSet != nil {
+		item := NewMappedIPCIDRItem(true, options.MappedSourceIPSet)
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.SourceIPASN) > 0 {
+		item, err := NewIPASNItem(ctx, true, options.SourceIPASN)
+		if err != nil {
+			return nil, E.Cause(err, "source_ip_asn")
+		}
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.SourceIPCountry) > 0 {
+		item, err := NewIPCountryItem(ctx, true, options.SourceIPCountry)
+		if err != nil {
+			return nil, E.Cause(err, "source_ip_country")
+		}
+		rule.sourceAddressItems = append(rule.sourceAddressItems, item)
+		rule.allItems = append(rule.allItems, item)
 	}
 	if len(options.IPCIDR) > 0 {
 		item, err := NewIPCIDRItem(false, options.IPCIDR)
@@ -103,6 +127,26 @@ func NewDefaultHeadlessRule(ctx context.Context, options option.DefaultHeadlessR
 		item := NewRawIPCIDRItem(false, options.IPSet)
 		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
 		rule.allItems = append(rule.allItems, item)
+	} else if options.MappedIPSet != nil {
+		item := NewMappedIPCIDRItem(false, options.MappedIPSet)
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.IPASN) > 0 {
+		item, err := NewIPASNItem(ctx, false, options.IPASN)
+		if err != nil {
+			return nil, E.Cause(err, "ip_asn")
+		}
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.IPCountry) > 0 {
+		item, err := NewIPCountryItem(ctx, false, options.IPCountry)
+		if err != nil {
+			return nil, E.Cause(err, "ip_country")
+		}
+		rule.destinationIPCIDRItems = append(rule.destinationIPCIDRItems, item)
+		rule.allItems = append(rule.allItems, item)
 	}
 	if len(options.SourcePort) > 0 {
 		item := NewPortItem(true, options.SourcePort)
@@ -153,6 +197,52 @@ func NewDefaultHeadlessRule(ctx context.Context, options option.DefaultHeadlessR
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if len(options.JA3) > 0 {
+		item := NewJA3Item(options.JA3)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.JA4) > 0 {
+		item := NewJA4Item(options.JA4)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPMethod) > 0 {
+		item := NewHTTPMethodItem(options.HTTPMethod)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPHost) > 0 {
+		item := NewHTTPHostItem(options.HTTPHost)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPPathPrefix) > 0 {
+		item := NewHTTPPathPrefixItem(options.HTTPPathPrefix)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPPathRegex) > 0 {
+		item, err := NewHTTPPathRegexItem(options.HTTPPathRegex)
+		if err != nil {
+			return nil, E.Cause(err, "http_path_regex")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPUserAgentKeyword) > 0 {
+		item := NewHTTPUserAgentKeywordItem(options.HTTPUserAgentKeyword)
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.HTTPUserAgentRegex) > 0 {
+		item, err := NewHTTPUserAgentRegexItem(options.HTTPUserAgentRegex)
+		if err != nil {
+			return nil, E.Cause(err, "http_user_agent_regex")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if networkManager != nil {
 		if len(options.NetworkType) > 0 {
 			item := NewNetworkTypeItem(networkManager, common.Map(options.NetworkType, option.InterfaceType.Build))
@@ -0,0 +1,40 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+)
+
+var _ RuleItem = (*HTTPHostItem)(nil)
+
+type HTTPHostItem struct {
+	hosts   []string
+	hostMap map[string]bool
+}
+
+func NewHTTPHostItem(hosts []string) *HTTPHostItem {
+	hostMap := make(map[string]bool)
+	for _, host := range hosts {
+		hostMap[strings.ToLower(host)] = true
+	}
+	return &HTTPHostItem{
+		hosts:   hosts,
+		hostMap: hostMap,
+	}
+}
+
+func (r *HTTPHostItem) Match(metadata *adapter.InboundContext) bool {
+	return metadata.HTTPHost != "" && r.hostMap[metadata.HTTPHost]
+}
+
+func (r *HTTPHostItem) String() string {
+	hLen := len(r.hosts)
+	if hLen == 1 {
+		return "http_host=" + r.hosts[0]
+	} else if hLen > 3 {
+		return "http_host=[" + strings.Join(r.hosts[:3], " ") + "...]"
+	} else {
+		return "http_host=[" + strings.Join(r.hosts, " ") + "]"
+	}
+}
@@ -0,0 +1,40 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+)
+
+var _ RuleItem = (*HTTPPathPrefixItem)(nil)
+
+type HTTPPathPrefixItem struct {
+	prefixes []string
+}
+
+func NewHTTPPathPrefixItem(prefixes []string) *HTTPPathPrefixItem {
+	return &HTTPPathPrefixItem{prefixes}
+}
+
+func (r *HTTPPathPrefixItem) Match(metadata *adapter.InboundContext) bool {
+	if metadata.HTTPPath == "" {
+		return false
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(metadata.HTTPPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *HTTPPathPrefixItem) String() string {
+	pLen := len(r.prefixes)
+	if pLen == 1 {
+		return "http_path_prefix=" + r.prefixes[0]
+	} else if pLen > 3 {
+		return "http_path_prefix=[" + strings.Join(r.prefixes[:3], " ") + "...]"
+	} else {
+		return "http_path_prefix=[" + strings.Join(r.prefixes, " ") + "]"
+	}
+}
@@ -0,0 +1,42 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common"
+)
+
+var _ RuleItem = (*HTTPUserAgentKeywordItem)(nil)
+
+type HTTPUserAgentKeywordItem struct {
+	keywords []string
+}
+
+func NewHTTPUserAgentKeywordItem(keywords []string) *HTTPUserAgentKeywordItem {
+	return &HTTPUserAgentKeywordItem{common.Map(keywords, strings.ToLower)}
+}
+
+func (r *HTTPUserAgentKeywordItem) Match(metadata *adapter.InboundContext) bool {
+	if metadata.HTTPUserAgent == "" {
+		return false
+	}
+	userAgent := strings.ToLower(metadata.HTTPUserAgent)
+	for _, keyword := range r.keywords {
+		if strings.Contains(userAgent, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *HTTPUserAgentKeywordItem) String() string {
+	kLen := len(r.keywords)
+	if kLen == 1 {
+		return "http_user_agent_keyword=" + r.keywords[0]
+	} else if kLen > 3 {
+		return "http_user_agent_keyword=[" + strings.Join(r.keywords[:3], " ") + "...]"
+	} else {
+		return "http_user_agent_keyword=[" + strings.Join(r.keywords, " ") + "]"
+	}
+}
@@ -0,0 +1,80 @@
+package rule
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+var _ RuleItem = (*IPASNItem)(nil)
+
+type IPASNItem struct {
+	database    adapter.ASNDatabase
+	numberMap   map[uint32]bool
+	isSource    bool
+	description string
+}
+
+func NewIPASNItem(ctx context.Context, isSource bool, numbers []option.ASN) (*IPASNItem, error) {
+	database := service.FromContext[adapter.ASNDatabase](ctx)
+	if database == nil {
+		return nil, E.New("missing `route.asn` database")
+	}
+	numberMap := make(map[uint32]bool)
+	for _, number := range numbers {
+		numberMap[uint32(number)] = true
+	}
+	var description string
+	if isSource {
+		description = "source_ip_asn="
+	} else {
+		description = "ip_asn="
+	}
+	if len(numbers) == 1 {
+		description += numbers[0].String()
+	} else {
+		description += "[" + strings.Join(common.Map(numbers, option.ASN.String), " ") + "]"
+	}
+	return &IPASNItem{
+		database:    database,
+		numberMap:   numberMap,
+		isSource:    isSource,
+		description: description,
+	}, nil
+}
+
+func (r *IPASNItem) Match(metadata *adapter.InboundContext) bool {
+	if r.isSource || metadata.IPCIDRMatchSource {
+		return r.match(metadata.Source.Addr)
+	}
+	if metadata.Destination.IsIP() {
+		return r.match(metadata.Destination.Addr)
+	}
+	if len(metadata.DestinationAddresses) > 0 {
+		for _, address := range metadata.DestinationAddresses {
+			if r.match(address) {
+				return true
+			}
+		}
+		return false
+	}
+	return metadata.IPCIDRAcceptEmpty
+}
+
+func (r *IPASNItem) match(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	number := r.database.LookupASN(addr)
+	return number != 0 && r.numberMap[number]
+}
+
+func (r *IPASNItem) String() string {
+	return r.description
+}
@@ -0,0 +1,72 @@
+package rule
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testASNDatabase map[netip.Addr]uint32
+
+func (d testASNDatabase) Name() string {
+	return "asn"
+}
+
+func (d testASNDatabase) Start(stage adapter.StartStage) error {
+	return nil
+}
+
+func (d testASNDatabase) LookupASN(addr netip.Addr) uint32 {
+	return d[addr]
+}
+
+func (d testASNDatabase) Close() error {
+	return nil
+}
+
+func TestIPASNItem(t *testing.T) {
+	t.Parallel()
+	_, err := NewIPASNItem(context.Background(), false, []option.ASN{13335})
+	require.Error(t, err)
+
+	ctx := service.ContextWith[adapter.ASNDatabase](context.Background(), testASNDatabase{
+		netip.MustParseAddr("1.1.1.1"):  13335,
+		netip.MustParseAddr("8.8.8.8"):  15169,
+		netip.MustParseAddr("10.0.0.1"): 0,
+	})
+	item, err := NewIPASNItem(ctx, false, []option.ASN{13335, 4134})
+	require.NoError(t, err)
+	require.Equal(t, "ip_asn=[AS13335 AS4134]", item.String())
+	require.True(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("1.1.1.1", 443)}))
+	require.False(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("8.8.8.8", 443)}))
+	require.True(t, item.Match(&adapter.InboundContext{
+		Destination:          M.ParseSocksaddrHostPort("example.com", 443),
+		DestinationAddresses: []netip.Addr{netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("1.1.1.1")},
+	}))
+	require.False(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("example.com", 443)}))
+
+	sourceItem, err := NewIPASNItem(ctx, true, []option.ASN{15169})
+	require.NoError(t, err)
+	require.Equal(t, "source_ip_asn=AS15169", sourceItem.String())
+	require.True(t, sourceItem.Match(&adapter.InboundContext{
+		Source:      M.ParseSocksaddrHostPort("8.8.8.8", 10000),
+		Destination: M.ParseSocksaddrHostPort("1.1.1.1", 443),
+	}))
+	require.False(t, sourceItem.Match(&adapter.InboundContext{Source: M.ParseSocksaddrHostPort("10.0.0.1", 10000)}))
+
+	rule, err := NewHeadlessRule(ctx, option.HeadlessRule{
+		Type:           C.RuleTypeDefault,
+		DefaultOptions: option.DefaultHeadlessRule{IPASN: []option.ASN{13335}},
+	})
+	require.NoError(t, err)
+	require.True(t, rule.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("1.1.1.1", 443)}))
+	require.False(t, rule.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("8.8.8.8", 443)}))
+}
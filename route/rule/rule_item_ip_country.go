@@ -0,0 +1,77 @@
+package rule
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+var _ RuleItem = (*IPCountryItem)(nil)
+
+type IPCountryItem struct {
+	database    adapter.CountryDatabase
+	codeMap     map[string]bool
+	isSource    bool
+	description string
+}
+
+func NewIPCountryItem(ctx context.Context, isSource bool, codes []string) (*IPCountryItem, error) {
+	database := service.FromContext[adapter.CountryDatabase](ctx)
+	if database == nil {
+		return nil, E.New("missing `route.country` database")
+	}
+	codeMap := make(map[string]bool)
+	for _, code := range codes {
+		codeMap[strings.ToLower(code)] = true
+	}
+	var description string
+	if isSource {
+		description = "source_ip_country="
+	} else {
+		description = "ip_country="
+	}
+	if len(codes) == 1 {
+		description += codes[0]
+	} else {
+		description += "[" + strings.Join(codes, " ") + "]"
+	}
+	return &IPCountryItem{
+		database:    database,
+		codeMap:     codeMap,
+		isSource:    isSource,
+		description: description,
+	}, nil
+}
+
+func (r *IPCountryItem) Match(metadata *adapter.InboundContext) bool {
+	if r.isSource || metadata.IPCIDRMatchSource {
+		return r.match(metadata.Source.Addr)
+	}
+	if metadata.Destination.IsIP() {
+		return r.match(metadata.Destination.Addr)
+	}
+	if len(metadata.DestinationAddresses) > 0 {
+		for _, address := range metadata.DestinationAddresses {
+			if r.match(address) {
+				return true
+			}
+		}
+		return false
+	}
+	return metadata.IPCIDRAcceptEmpty
+}
+
+func (r *IPCountryItem) match(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	return r.codeMap[r.database.LookupCountry(addr)]
+}
+
+func (r *IPCountryItem) String() string {
+	return r.description
+}
@@ -0,0 +1,77 @@
+package rule
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	"github.com/sagernet/sing/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testCountryDatabase map[netip.Addr]string
+
+func (d testCountryDatabase) Name() string {
+	return "country"
+}
+
+func (d testCountryDatabase) Start(stage adapter.StartStage) error {
+	return nil
+}
+
+func (d testCountryDatabase) LookupCountry(addr netip.Addr) string {
+	code, loaded := d[addr]
+	if !loaded {
+		return "unknown"
+	}
+	return code
+}
+
+func (d testCountryDatabase) Close() error {
+	return nil
+}
+
+func TestIPCountryItem(t *testing.T) {
+	t.Parallel()
+	_, err := NewIPCountryItem(context.Background(), false, []string{"jp"})
+	require.Error(t, err)
+
+	ctx := service.ContextWith[adapter.CountryDatabase](context.Background(), testCountryDatabase{
+		netip.MustParseAddr("1.1.1.1"):  "jp",
+		netip.MustParseAddr("8.8.8.8"):  "us",
+		netip.MustParseAddr("10.0.0.1"): "private",
+	})
+	item, err := NewIPCountryItem(ctx, false, []string{"JP", "cn"})
+	require.NoError(t, err)
+	require.Equal(t, "ip_country=[JP cn]", item.String())
+	require.True(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("1.1.1.1", 443)}))
+	require.False(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("8.8.8.8", 443)}))
+	require.False(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("9.9.9.9", 443)}))
+	require.True(t, item.Match(&adapter.InboundContext{
+		Destination:          M.ParseSocksaddrHostPort("example.com", 443),
+		DestinationAddresses: []netip.Addr{netip.MustParseAddr("8.8.8.8"), netip.MustParseAddr("1.1.1.1")},
+	}))
+	require.False(t, item.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("example.com", 443)}))
+
+	sourceItem, err := NewIPCountryItem(ctx, true, []string{"us"})
+	require.NoError(t, err)
+	require.Equal(t, "source_ip_country=us", sourceItem.String())
+	require.True(t, sourceItem.Match(&adapter.InboundContext{
+		Source:      M.ParseSocksaddrHostPort("8.8.8.8", 10000),
+		Destination: M.ParseSocksaddrHostPort("1.1.1.1", 443),
+	}))
+	require.False(t, sourceItem.Match(&adapter.InboundContext{Source: M.ParseSocksaddrHostPort("10.0.0.1", 10000)}))
+
+	rule, err := NewHeadlessRule(ctx, option.HeadlessRule{
+		Type:           C.RuleTypeDefault,
+		DefaultOptions: option.DefaultHeadlessRule{IPCountry: []string{"jp"}},
+	})
+	require.NoError(t, err)
+	require.True(t, rule.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("1.1.1.1", 443)}))
+	require.False(t, rule.Match(&adapter.InboundContext{Destination: M.ParseSocksaddrHostPort("8.8.8.8", 443)}))
+}
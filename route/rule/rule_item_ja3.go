@@ -0,0 +1,37 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*JA3Item)(nil)
+
+type JA3Item struct {
+	fingerprints   []string
+	fingerprintMap map[string]bool
+}
+
+func NewJA3Item(fingerprints []string) *JA3Item {
+	fingerprintMap := make(map[string]bool)
+	for _, fingerprint := range fingerprints {
+		fingerprintMap[strings.ToLower(fingerprint)] = true
+	}
+	return &JA3Item{
+		fingerprints:   fingerprints,
+		fingerprintMap: fingerprintMap,
+	}
+}
+
+func (r *JA3Item) Match(metadata *adapter.InboundContext) bool {
+	return metadata.JA3 != "" && r.fingerprintMap[metadata.JA3]
+}
+
+func (r *JA3Item) String() string {
+	if len(r.fingerprints) == 1 {
+		return F.ToString("ja3=", r.fingerprints[0])
+	}
+	return F.ToString("ja3=[", strings.Join(r.fingerprints, " "), "]")
+}
@@ -0,0 +1,37 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*JA4Item)(nil)
+
+type JA4Item struct {
+	fingerprints   []string
+	fingerprintMap map[string]bool
+}
+
+func NewJA4Item(fingerprints []string) *JA4Item {
+	fingerprintMap := make(map[string]bool)
+	for _, fingerprint := range fingerprints {
+		fingerprintMap[strings.ToLower(fingerprint)] = true
+	}
+	return &JA4Item{
+		fingerprints:   fingerprints,
+		fingerprintMap: fingerprintMap,
+	}
+}
+
+func (r *JA4Item) Match(metadata *adapter.InboundContext) bool {
+	return metadata.JA4 != "" && r.fingerprintMap[metadata.JA4]
+}
+
+func (r *JA4Item) String() string {
+	if len(r.fingerprints) == 1 {
+		return F.ToString("ja4=", r.fingerprints[0])
+	}
+	return F.ToString("ja4=[", strings.Join(r.fingerprints, " "), "]")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/srs/mapped"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common"
@@ -30,7 +31,19 @@ func extractIPSetFromRule(rawRule adapter.HeadlessRule) []*netipx.IPSet {
 		return common.FlatMap(rule.destinationIPCIDRItems, func(rawItem RuleItem) []*netipx.IPSet {
 			switch item := rawItem.(type) {
 			case *IPCIDRItem:
-				return []*netipx.IPSet{item.ipSet}
+				switch ipSet := item.ipSet.(type) {
+				case *netipx.IPSet:
+					return []*netipx.IPSet{ipSet}
+				case *mapped.IPSet:
+					// decoded on demand, as only route address sets need it
+					decodedSet, err := ipSet.IPSet()
+					if err != nil {
+						return nil
+					}
+					return []*netipx.IPSet{decodedSet}
+				default:
+					return nil
+				}
 			default:
 				return nil
 			}
@@ -67,5 +80,5 @@ func isWIFIHeadlessRule(rule option.DefaultHeadlessRule) bool {
 }
 
 func isIPCIDRHeadlessRule(rule option.DefaultHeadlessRule) bool {
-	return len(rule.IPCIDR) > 0 || rule.IPSet != nil
+	return len(rule.IPCIDR) > 0 || rule.IPSet != nil || rule.MappedIPSet != nil || len(rule.IPASN) > 0 || len(rule.IPCountry) > 0
 }
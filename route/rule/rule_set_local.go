@@ -10,6 +10,7 @@ import (
 
 	"github.com/sagernet/fswatch"
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
@@ -110,16 +111,25 @@ func (s *LocalRuleSet) reloadFile(path string) error {
 		}
 
 	case C.RuleSetFormatBinary:
-		setFile, err := os.Open(path)
+		var err error
+		ruleSet, err = srs.ReadFile(path, false)
 		if err != nil {
 			return err
 		}
-		ruleSet, err = srs.Read(setFile, false)
+	default:
+		if !convertor.IsForeignFormat(s.fileFormat) {
+			return E.New("unknown rule-set format: ", s.fileFormat)
+		}
+		setFile, err := os.Open(path)
 		if err != nil {
 			return err
 		}
-	default:
-		return E.New("unknown rule-set format: ", s.fileFormat)
+		defer setFile.Close()
+		rules, err := convertor.ToOptions(s.fileFormat, setFile, s.logger)
+		if err != nil {
+			return E.Cause(err, "convert ", s.fileFormat, " rule-set")
+		}
+		return s.reloadRules(rules)
 	}
 	plainRuleSet, err := ruleSet.Upgrade()
 	if err != nil {
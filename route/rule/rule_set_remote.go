@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/convertor"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
@@ -171,12 +172,19 @@ func (s *RemoteRuleSet) loadBytes(content []byte) error {
 			return err
 		}
 	case C.RuleSetFormatBinary:
-		ruleSet, err = srs.Read(bytes.NewReader(content), false)
+		ruleSet, err = srs.ReadBytes(content, false)
 		if err != nil {
 			return err
 		}
 	default:
-		return E.New("unknown rule-set format: ", s.options.Format)
+		if !convertor.IsForeignFormat(s.options.Format) {
+			return E.New("unknown rule-set format: ", s.options.Format)
+		}
+		// rule-sets in foreign formats are converted to binary when fetched
+		ruleSet, err = srs.ReadBytes(content, false)
+		if err != nil {
+			return err
+		}
 	}
 	plainRuleSet, err := ruleSet.Upgrade()
 	if err != nil {
@@ -287,6 +295,13 @@ func (s *RemoteRuleSet) fetch(ctx context.Context, startContext *adapter.HTTPSta
 		response.Body.Close()
 		return err
 	}
+	if convertor.IsForeignFormat(s.options.Format) {
+		content, err = compileRuleSet(s.options.Format, content, s.logger)
+		if err != nil {
+			response.Body.Close()
+			return E.Cause(err, "convert ", s.options.Format, " rule-set")
+		}
+	}
 	err = s.loadBytes(content)
 	if err != nil {
 		response.Body.Close()
@@ -312,6 +327,23 @@ func (s *RemoteRuleSet) fetch(ctx context.Context, startContext *adapter.HTTPSta
 	return nil
 }
 
+// compileRuleSet converts a rule-set in a foreign format to binary,
+// so that the cache file stores the converted rule-set.
+// The cache file is only read by the running version, so the mapped layout is used
+// to match the cached rule-set in place instead of decoding it.
+func compileRuleSet(format string, content []byte, logger logger.Logger) ([]byte, error) {
+	rules, err := convertor.ToOptions(format, bytes.NewReader(content), logger)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	err = srs.Write(&buffer, option.PlainRuleSet{Rules: rules}, C.RuleSetVersionCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
 func (s *RemoteRuleSet) Close() error {
 	s.rules = nil
 	s.cancel()
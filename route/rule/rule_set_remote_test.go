@@ -0,0 +1,38 @@
+package rule
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteRuleSetForeignFormat(t *testing.T) {
+	t.Parallel()
+	content, err := compileRuleSet(C.RuleSetFormatClashClassical, []byte(`payload:
+  - DOMAIN-SUFFIX,example.com
+  - IP-CIDR,10.0.0.0/8
+`), logger.NOP())
+	require.NoError(t, err)
+	ruleSet := &RemoteRuleSet{
+		ctx:     context.Background(),
+		options: option.RuleSet{Tag: "test", Format: C.RuleSetFormatClashClassical},
+	}
+	// the cache file stores the compiled binary
+	require.NoError(t, ruleSet.loadBytes(content))
+	require.True(t, ruleSet.Metadata().ContainsIPCIDRRule)
+	metadata := testMetadata("www.example.com")
+	require.True(t, ruleSet.Match(&metadata))
+	metadata = testMetadata("example.org")
+	require.False(t, ruleSet.Match(&metadata))
+	metadata = adapter.InboundContext{Destination: M.ParseSocksaddr("10.0.0.1:443")}
+	require.True(t, ruleSet.Match(&metadata))
+	_, err = compileRuleSet(C.RuleSetFormatHosts, []byte("127.0.0.1 localhost\n"), logger.NOP())
+	require.Error(t, err)
+}
@@ -5,6 +5,11 @@ import (
 	"github.com/sagernet/sing-box/option"
 )
 
+// NeedFindProcess reports whether the options require searching the process of connections.
+func NeedFindProcess(options option.RouteOptions, dnsOptions option.DNSOptions) bool {
+	return hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess
+}
+
 func hasRule(rules []option.Rule, cond func(rule option.DefaultRule) bool) bool {
 	for _, rule := range rules {
 		switch rule.Type {
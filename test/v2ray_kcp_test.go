@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+func TestV2RayKCP(t *testing.T) {
+	t.Run("self", func(t *testing.T) {
+		testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeKCP,
+		})
+	})
+	t.Run("header", func(t *testing.T) {
+		testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeKCP,
+			KCPOptions: option.V2RayKCPOptions{
+				HeaderType: C.KCPHeaderWechatVideo,
+				Seed:       "sing-box",
+			},
+		})
+	})
+}
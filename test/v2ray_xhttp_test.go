@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+func TestV2RayXHTTP(t *testing.T) {
+	t.Run("packet-up", func(t *testing.T) {
+		testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeXHTTP,
+			XHTTPOptions: option.V2RayXHTTPOptions{
+				Mode: C.XHTTPModePacketUp,
+			},
+		})
+	})
+	t.Run("stream-up", func(t *testing.T) {
+		testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeXHTTP,
+			XHTTPOptions: option.V2RayXHTTPOptions{
+				Mode: C.XHTTPModeStreamUp,
+			},
+		})
+	})
+	t.Run("stream-one", func(t *testing.T) {
+		testV2RayTransportSelf(t, &option.V2RayTransportOptions{
+			Type: C.V2RayTransportTypeXHTTP,
+			XHTTPOptions: option.V2RayXHTTPOptions{
+				Mode: C.XHTTPModeStreamOne,
+			},
+		})
+	})
+}
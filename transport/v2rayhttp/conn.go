@@ -157,7 +157,7 @@ func (c *HTTP2Conn) Setup(reader io.Reader, err error) {
 }
 
 func (c *HTTP2Conn) Read(b []byte) (n int, err error) {
-	if c.reader == nil {
+	if c.create != nil {
 		<-c.create
 		if c.err != nil {
 			return 0, c.err
@@ -0,0 +1,84 @@
+package v2raykcp
+
+import (
+	"context"
+	"crypto/cipher"
+	"math/rand"
+	"net"
+	"sync/atomic"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.V2RayClientTransport = (*Client)(nil)
+
+var globalConversation atomic.Uint32
+
+func init() {
+	globalConversation.Store(rand.Uint32() & 0xFFFF)
+}
+
+type Client struct {
+	dialer     N.Dialer
+	serverAddr M.Socksaddr
+	config     *config
+	security   cipher.AEAD
+	tlsConfig  tls.Config
+}
+
+func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, options option.V2RayKCPOptions, tlsConfig tls.Config) (adapter.V2RayClientTransport, error) {
+	config, err := newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		dialer:     dialer,
+		serverAddr: serverAddr,
+		config:     config,
+		security:   newSecurity(config.seed),
+		tlsConfig:  tlsConfig,
+	}, nil
+}
+
+func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
+	udpConn, err := c.dialer.DialContext(ctx, N.NetworkUDP, c.serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	header, _ := newPacketHeader(c.config.headerType)
+	conversation := uint16(globalConversation.Add(1))
+	conn := newConnection(conversation, udpConn.LocalAddr(), udpConn.RemoteAddr(), newPacketWriter(header, c.security, udpConn), udpConn, c.config)
+	go fetchInput(udpConn, header, c.security, conn)
+	if c.tlsConfig != nil {
+		tlsConn, err := tls.ClientHandshake(ctx, conn, c.tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, E.Cause(err, "TLS handshake")
+		}
+		return tlsConn, nil
+	}
+	return conn, nil
+}
+
+func fetchInput(udpConn net.Conn, header packetHeader, security cipher.AEAD, conn *Connection) {
+	buffer := make([]byte, 65535)
+	for {
+		n, err := udpConn.Read(buffer)
+		if err != nil {
+			return
+		}
+		segments := readPacket(header, security, buffer[:n])
+		if len(segments) > 0 {
+			conn.input(segments)
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	return nil
+}
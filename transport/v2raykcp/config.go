@@ -0,0 +1,79 @@
+package v2raykcp
+
+import (
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	defaultMTU              = 1350
+	defaultTTI              = 50
+	defaultUplinkCapacity   = 5
+	defaultDownlinkCapacity = 20
+	defaultBufferSize       = 2
+)
+
+type config struct {
+	mtu              uint32
+	tti              uint32
+	uplinkCapacity   uint32
+	downlinkCapacity uint32
+	congestion       bool
+	readBufferSize   uint32
+	writeBufferSize  uint32
+	headerType       string
+	seed             string
+}
+
+func newConfig(options option.V2RayKCPOptions) (*config, error) {
+	c := &config{
+		mtu:              options.MTU,
+		tti:              options.TTI,
+		uplinkCapacity:   options.UplinkCapacity,
+		downlinkCapacity: options.DownlinkCapacity,
+		congestion:       options.Congestion,
+		readBufferSize:   options.ReadBufferSize,
+		writeBufferSize:  options.WriteBufferSize,
+		headerType:       options.HeaderType,
+		seed:             options.Seed,
+	}
+	if c.mtu == 0 {
+		c.mtu = defaultMTU
+	} else if c.mtu < 576 || c.mtu > 1460 {
+		return nil, E.New("invalid mtu: ", c.mtu, ", must be between 576 and 1460")
+	}
+	if c.tti == 0 {
+		c.tti = defaultTTI
+	} else if c.tti < 10 || c.tti > 100 {
+		return nil, E.New("invalid tti: ", c.tti, ", must be between 10 and 100")
+	}
+	if c.uplinkCapacity == 0 {
+		c.uplinkCapacity = defaultUplinkCapacity
+	}
+	if c.downlinkCapacity == 0 {
+		c.downlinkCapacity = defaultDownlinkCapacity
+	}
+	if c.readBufferSize == 0 {
+		c.readBufferSize = defaultBufferSize
+	}
+	if c.writeBufferSize == 0 {
+		c.writeBufferSize = defaultBufferSize
+	}
+	_, err := newPacketHeader(c.headerType)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *config) sendingInFlightSize() uint32 {
+	return max(c.uplinkCapacity*1024*1024/c.mtu/(1000/c.tti), 8)
+}
+
+func (c *config) sendingBufferSize() uint32 {
+	return c.writeBufferSize * 1024 * 1024 / c.mtu
+}
+
+func (c *config) receivingInFlightSize() uint32 {
+	return max(c.downlinkCapacity*1024*1024/c.mtu/(1000/c.tti), 8)
+}
@@ -0,0 +1,465 @@
+package v2raykcp
+
+import (
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type connectionState int32
+
+const (
+	stateActive connectionState = iota
+	stateReadyToClose
+	statePeerClosed
+	stateTerminating
+	statePeerTerminating
+	stateTerminated
+)
+
+type roundTripInfo struct {
+	access           sync.RWMutex
+	variation        uint32
+	srtt             uint32
+	rto              uint32
+	minRTT           uint32
+	updatedTimestamp uint32
+}
+
+func (i *roundTripInfo) updatePeerRTO(rto uint32, current uint32) {
+	i.access.Lock()
+	defer i.access.Unlock()
+	if current-i.updatedTimestamp < 3000 {
+		return
+	}
+	i.updatedTimestamp = current
+	i.rto = rto
+}
+
+// update follows RFC 6298.
+func (i *roundTripInfo) update(rtt uint32, current uint32) {
+	if rtt > 0x7FFFFFFF {
+		return
+	}
+	i.access.Lock()
+	defer i.access.Unlock()
+	if i.srtt == 0 {
+		i.srtt = rtt
+		i.variation = rtt / 2
+	} else {
+		delta := rtt - i.srtt
+		if i.srtt > rtt {
+			delta = i.srtt - rtt
+		}
+		i.variation = (3*i.variation + delta) / 4
+		i.srtt = max((7*i.srtt+rtt)/8, i.minRTT)
+	}
+	var rto uint32
+	if i.minRTT < 4*i.variation {
+		rto = i.srtt + 4*i.variation
+	} else {
+		rto = i.srtt + i.variation
+	}
+	rto = min(rto, 10000)
+	i.rto = rto * 5 / 4
+	i.updatedTimestamp = current
+}
+
+func (i *roundTripInfo) timeout() uint32 {
+	i.access.RLock()
+	defer i.access.RUnlock()
+	return i.rto
+}
+
+type updater struct {
+	interval        atomic.Int64
+	running         atomic.Bool
+	shouldContinue  func() bool
+	shouldTerminate func() bool
+	update          func()
+}
+
+func newUpdater(interval time.Duration, shouldContinue func() bool, shouldTerminate func() bool, update func()) *updater {
+	u := &updater{
+		shouldContinue:  shouldContinue,
+		shouldTerminate: shouldTerminate,
+		update:          update,
+	}
+	u.interval.Store(int64(interval))
+	return u
+}
+
+func (u *updater) setInterval(interval time.Duration) {
+	u.interval.Store(int64(interval))
+}
+
+func (u *updater) wakeUp() {
+	if u.running.CompareAndSwap(false, true) {
+		go u.run()
+	}
+}
+
+func (u *updater) run() {
+	for !u.shouldTerminate() && u.shouldContinue() {
+		u.update()
+		time.Sleep(time.Duration(u.interval.Load()))
+	}
+	u.running.Store(false)
+	if !u.shouldTerminate() && u.shouldContinue() {
+		u.wakeUp()
+	}
+}
+
+var _ net.Conn = (*Connection)(nil)
+
+// Connection is a reliable stream over mKCP, compatible with the v2ray wire format.
+type Connection struct {
+	conv       uint16
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	writer     *packetWriter
+	closer     io.Closer
+	config     *config
+	since      time.Time
+
+	readDeadline  atomic.Pointer[time.Time]
+	writeDeadline atomic.Pointer[time.Time]
+	dataInput     chan struct{}
+	dataOutput    chan struct{}
+
+	currentState     atomic.Int32
+	stateBeginTime   atomic.Uint32
+	lastIncomingTime atomic.Uint32
+	lastPingTime     atomic.Uint32
+
+	mss             uint32
+	roundTrip       *roundTripInfo
+	receivingWorker *receivingWorker
+	sendingWorker   *sendingWorker
+	dataUpdater     *updater
+	pingUpdater     *updater
+	terminateOnce   sync.Once
+}
+
+func newConnection(conv uint16, localAddr net.Addr, remoteAddr net.Addr, writer *packetWriter, closer io.Closer, config *config) *Connection {
+	conn := &Connection{
+		conv:       conv,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		writer:     writer,
+		closer:     closer,
+		config:     config,
+		since:      time.Now(),
+		dataInput:  make(chan struct{}, 1),
+		dataOutput: make(chan struct{}, 1),
+		mss:        config.mtu - uint32(writer.overhead()) - dataSegmentOverhead,
+		roundTrip: &roundTripInfo{
+			rto:    100,
+			minRTT: config.tti,
+		},
+	}
+	conn.receivingWorker = newReceivingWorker(conn)
+	conn.sendingWorker = newSendingWorker(conn)
+	isTerminating := func() bool {
+		state := conn.state()
+		return state == stateTerminating || state == stateTerminated
+	}
+	isTerminated := func() bool {
+		return conn.state() == stateTerminated
+	}
+	conn.dataUpdater = newUpdater(time.Duration(config.tti)*time.Millisecond, func() bool {
+		return conn.sendingWorker.updateNecessary() || conn.receivingWorker.updateNecessary()
+	}, isTerminating, conn.flush)
+	conn.pingUpdater = newUpdater(5*time.Second, func() bool {
+		return true
+	}, isTerminated, conn.flush)
+	conn.pingUpdater.wakeUp()
+	return conn
+}
+
+func (c *Connection) elapsed() uint32 {
+	return uint32(time.Since(c.since).Milliseconds())
+}
+
+func (c *Connection) state() connectionState {
+	return connectionState(c.currentState.Load())
+}
+
+func (c *Connection) setState(state connectionState) {
+	c.currentState.Store(int32(state))
+	c.stateBeginTime.Store(c.elapsed())
+	switch state {
+	case statePeerClosed:
+		c.sendingWorker.closeWrite()
+	case stateTerminating:
+		c.sendingWorker.closeWrite()
+		c.pingUpdater.setInterval(time.Second)
+	case statePeerTerminating:
+		c.sendingWorker.closeWrite()
+		c.pingUpdater.setInterval(time.Second)
+	case stateTerminated:
+		c.sendingWorker.closeWrite()
+		c.pingUpdater.setInterval(time.Second)
+		c.dataUpdater.wakeUp()
+		c.pingUpdater.wakeUp()
+		go c.terminate()
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Connection) Read(b []byte) (int, error) {
+	for {
+		switch c.state() {
+		case stateReadyToClose, stateTerminating, stateTerminated:
+			return 0, io.EOF
+		}
+		n := c.receivingWorker.read(b)
+		if n > 0 {
+			c.dataUpdater.wakeUp()
+			return n, nil
+		}
+		if c.state() == statePeerTerminating {
+			return 0, io.EOF
+		}
+		err := c.waitFor(c.dataInput, &c.readDeadline)
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (c *Connection) Write(b []byte) (int, error) {
+	var (
+		n             int
+		updatePending bool
+	)
+	defer func() {
+		if updatePending {
+			c.dataUpdater.wakeUp()
+		}
+	}()
+	for n < len(b) {
+		for n < len(b) {
+			if c.state() != stateActive {
+				return n, io.ErrClosedPipe
+			}
+			size := min(len(b)-n, int(c.mss))
+			payload := make([]byte, size)
+			copy(payload, b[n:])
+			if !c.sendingWorker.push(payload) {
+				break
+			}
+			updatePending = true
+			n += size
+		}
+		if n == len(b) {
+			break
+		}
+		if updatePending {
+			c.dataUpdater.wakeUp()
+			updatePending = false
+		}
+		err := c.waitFor(c.dataOutput, &c.writeDeadline)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (c *Connection) waitFor(ch chan struct{}, deadline *atomic.Pointer[time.Time]) error {
+	for i := 0; i < 16; i++ {
+		select {
+		case <-ch:
+			return nil
+		default:
+			runtime.Gosched()
+		}
+	}
+	duration := 16 * time.Second
+	if current := deadline.Load(); current != nil && !current.IsZero() {
+		duration = time.Until(*current)
+		if duration <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-timer.C:
+		if current := deadline.Load(); current != nil && !current.IsZero() && current.Before(time.Now()) {
+			return os.ErrDeadlineExceeded
+		}
+	}
+	return nil
+}
+
+func (c *Connection) Close() error {
+	signal(c.dataInput)
+	signal(c.dataOutput)
+	switch c.state() {
+	case stateReadyToClose, stateTerminating, stateTerminated:
+		return net.ErrClosed
+	case stateActive:
+		c.setState(stateReadyToClose)
+	case statePeerClosed:
+		c.setState(stateTerminating)
+	case statePeerTerminating:
+		c.setState(stateTerminated)
+	}
+	c.pingUpdater.wakeUp()
+	return nil
+}
+
+func (c *Connection) terminate() {
+	c.terminateOnce.Do(func() {
+		signal(c.dataInput)
+		signal(c.dataOutput)
+		c.closer.Close()
+		c.sendingWorker.release()
+		c.receivingWorker.release()
+	})
+}
+
+func (c *Connection) handleOption(option segmentOption) {
+	if option&segmentOptionClose == segmentOptionClose {
+		switch c.state() {
+		case stateReadyToClose:
+			c.setState(stateTerminating)
+		case stateActive:
+			c.setState(statePeerClosed)
+		}
+	}
+}
+
+func (c *Connection) input(segments []segment) {
+	current := c.elapsed()
+	c.lastIncomingTime.Store(current)
+	for _, seg := range segments {
+		if seg.conversation() != c.conv {
+			break
+		}
+		switch seg := seg.(type) {
+		case *dataSegment:
+			c.handleOption(seg.option)
+			c.receivingWorker.processSegment(seg)
+			if c.receivingWorker.isDataAvailable() {
+				signal(c.dataInput)
+			}
+			c.dataUpdater.wakeUp()
+		case *ackSegment:
+			c.handleOption(seg.option)
+			c.sendingWorker.processSegment(current, seg, c.roundTrip.timeout())
+			signal(c.dataOutput)
+			c.dataUpdater.wakeUp()
+		case *cmdOnlySegment:
+			c.handleOption(seg.option)
+			if seg.cmd == commandTerminate {
+				switch c.state() {
+				case stateActive, statePeerClosed:
+					c.setState(statePeerTerminating)
+				case stateReadyToClose:
+					c.setState(stateTerminating)
+				case stateTerminating:
+					c.setState(stateTerminated)
+				}
+			}
+			if seg.option == segmentOptionClose || seg.cmd == commandTerminate {
+				signal(c.dataInput)
+				signal(c.dataOutput)
+			}
+			c.sendingWorker.processReceivingNext(seg.receivingNext)
+			c.receivingWorker.processSendingNext(seg.sendingNext)
+			c.roundTrip.updatePeerRTO(seg.peerRTO, current)
+		}
+	}
+}
+
+func (c *Connection) flush() {
+	current := c.elapsed()
+	if c.state() == stateTerminated {
+		return
+	}
+	if c.state() == stateActive && current-c.lastIncomingTime.Load() >= 30000 {
+		c.Close()
+	}
+	if c.state() == stateReadyToClose && c.sendingWorker.isEmpty() {
+		c.setState(stateTerminating)
+	}
+	if c.state() == stateTerminating {
+		c.ping(current, commandTerminate)
+		if current-c.stateBeginTime.Load() > 8000 {
+			c.setState(stateTerminated)
+		}
+		return
+	}
+	if c.state() == statePeerTerminating && current-c.stateBeginTime.Load() > 4000 {
+		c.setState(stateTerminating)
+	}
+	if c.state() == stateReadyToClose && current-c.stateBeginTime.Load() > 15000 {
+		c.setState(stateTerminating)
+	}
+	c.receivingWorker.flush(current)
+	c.sendingWorker.flush(current)
+	if current-c.lastPingTime.Load() >= 3000 {
+		c.ping(current, commandPing)
+	}
+}
+
+func (c *Connection) ping(current uint32, cmd command) {
+	seg := &cmdOnlySegment{
+		conv:          c.conv,
+		cmd:           cmd,
+		receivingNext: c.receivingWorker.nextNumberValue(),
+		sendingNext:   c.sendingWorker.firstUnacknowledgedNumber(),
+		peerRTO:       c.roundTrip.timeout(),
+	}
+	if c.state() == stateReadyToClose {
+		seg.option = segmentOptionClose
+	}
+	c.writeSegment(seg)
+	c.lastPingTime.Store(current)
+}
+
+func (c *Connection) writeSegment(seg segment) {
+	c.writer.writeSegment(seg)
+}
+
+func (c *Connection) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *Connection) SetDeadline(t time.Time) error {
+	c.readDeadline.Store(&t)
+	c.writeDeadline.Store(&t)
+	signal(c.dataInput)
+	signal(c.dataOutput)
+	return nil
+}
+
+func (c *Connection) SetReadDeadline(t time.Time) error {
+	c.readDeadline.Store(&t)
+	signal(c.dataInput)
+	return nil
+}
+
+func (c *Connection) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.Store(&t)
+	signal(c.dataOutput)
+	return nil
+}
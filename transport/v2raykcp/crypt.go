@@ -0,0 +1,71 @@
+package v2raykcp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/fnv"
+
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var _ cipher.AEAD = (*simpleAuthenticator)(nil)
+
+// simpleAuthenticator is the legacy mKCP packet obfuscation used when no seed is set:
+// a FNV-1a checksum and a length prefix, XORed forward in 4-byte words.
+type simpleAuthenticator struct{}
+
+func (*simpleAuthenticator) NonceSize() int {
+	return 0
+}
+
+func (*simpleAuthenticator) Overhead() int {
+	return 6
+}
+
+func (*simpleAuthenticator) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(dst[start+4:], uint16(len(plaintext)))
+	dst = append(dst, plaintext...)
+	sealed := dst[start:]
+	hash := fnv.New32a()
+	hash.Write(sealed[4:])
+	binary.BigEndian.PutUint32(sealed, hash.Sum32())
+	for i := 4; i < len(sealed); i++ {
+		sealed[i] ^= sealed[i-4]
+	}
+	return dst
+}
+
+func (*simpleAuthenticator) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, ciphertext...)
+	opened := dst[start:]
+	for i := len(opened) - 1; i >= 4; i-- {
+		opened[i] ^= opened[i-4]
+	}
+	if len(opened) < 6 {
+		return nil, E.New("invalid auth")
+	}
+	hash := fnv.New32a()
+	hash.Write(opened[4:])
+	if binary.BigEndian.Uint32(opened) != hash.Sum32() {
+		return nil, E.New("invalid auth")
+	}
+	if int(binary.BigEndian.Uint16(opened[4:])) != len(opened)-6 {
+		return nil, E.New("invalid auth")
+	}
+	return append(dst[:start], opened[6:]...), nil
+}
+
+func newSecurity(seed string) cipher.AEAD {
+	if seed == "" {
+		return &simpleAuthenticator{}
+	}
+	hashedSeed := sha256.Sum256([]byte(seed))
+	block := common.Must1(aes.NewCipher(hashedSeed[:16]))
+	return common.Must1(cipher.NewGCM(block))
+}
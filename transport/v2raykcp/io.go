@@ -0,0 +1,92 @@
+package v2raykcp
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+	"sync"
+	"time"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+type packetWriter struct {
+	access   sync.Mutex
+	header   packetHeader
+	security cipher.AEAD
+	writer   io.Writer
+	buffer   []byte
+}
+
+func newPacketWriter(header packetHeader, security cipher.AEAD, writer io.Writer) *packetWriter {
+	return &packetWriter{
+		header:   header,
+		security: security,
+		writer:   writer,
+	}
+}
+
+func (w *packetWriter) overhead() int {
+	overhead := w.security.NonceSize() + w.security.Overhead()
+	if w.header != nil {
+		overhead += w.header.Size()
+	}
+	return overhead
+}
+
+// writeSegment writes one segment per packet, retrying transient write failures like v2ray does.
+func (w *packetWriter) writeSegment(seg segment) error {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.buffer = w.buffer[:0]
+	if w.header != nil {
+		w.buffer = append(w.buffer, make([]byte, w.header.Size())...)
+		w.header.Serialize(w.buffer)
+	}
+	nonceSize := w.security.NonceSize()
+	if nonceSize > 0 {
+		start := len(w.buffer)
+		w.buffer = append(w.buffer, make([]byte, nonceSize)...)
+		rand.Read(w.buffer[start:])
+	}
+	nonce := w.buffer[len(w.buffer)-nonceSize:]
+	plaintext := make([]byte, seg.byteSize())
+	seg.serialize(plaintext)
+	w.buffer = w.security.Seal(w.buffer, nonce, plaintext, nil)
+	var err error
+	for i := 0; i < 5; i++ {
+		_, err = w.writer.Write(w.buffer)
+		if err == nil || E.IsClosed(err) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return err
+}
+
+func readPacket(header packetHeader, security cipher.AEAD, b []byte) []segment {
+	if header != nil {
+		if len(b) <= header.Size() {
+			return nil
+		}
+		b = b[header.Size():]
+	}
+	nonceSize := security.NonceSize()
+	if len(b) <= nonceSize+security.Overhead() {
+		return nil
+	}
+	b, err := security.Open(nil, b[:nonceSize], b[nonceSize:], nil)
+	if err != nil {
+		return nil
+	}
+	var segments []segment
+	for len(b) > 0 {
+		var seg segment
+		seg, b = readSegment(b)
+		if seg == nil {
+			break
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
@@ -0,0 +1,57 @@
+package v2raykcp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type echoHandler struct{}
+
+func (h *echoHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	io.Copy(conn, conn)
+	conn.Close()
+	onClose(nil)
+}
+
+func TestLoopback(t *testing.T) {
+	t.Parallel()
+	for _, options := range []option.V2RayKCPOptions{
+		{},
+		{HeaderType: C.KCPHeaderWechatVideo, Seed: "sing-box"},
+		{HeaderType: C.KCPHeaderDTLS, Congestion: true},
+	} {
+		t.Run(options.HeaderType, func(t *testing.T) {
+			t.Parallel()
+			server, err := NewServer(context.Background(), logger.NOP(), options, nil, &echoHandler{})
+			require.NoError(t, err)
+			packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err)
+			go server.ServePacket(packetConn)
+			defer server.Close()
+			client, err := NewClient(context.Background(), N.SystemDialer, M.SocksaddrFromNet(packetConn.LocalAddr()), options, nil)
+			require.NoError(t, err)
+			defer client.Close()
+			conn, err := client.DialContext(context.Background())
+			require.NoError(t, err)
+			defer conn.Close()
+			// larger than a single segment and the default window
+			content := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+			go conn.Write(content)
+			response := make([]byte, len(content))
+			_, err = io.ReadFull(conn, response)
+			require.NoError(t, err)
+			require.Equal(t, content, response)
+		})
+	}
+}
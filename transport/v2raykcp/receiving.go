@@ -0,0 +1,186 @@
+package v2raykcp
+
+import (
+	"sync"
+)
+
+type ackList struct {
+	writer          func(seg *ackSegment)
+	timestamps      []uint32
+	numbers         []uint32
+	nextFlush       []uint32
+	flushCandidates []uint32
+	dirty           bool
+}
+
+func newAckList(writer func(seg *ackSegment)) *ackList {
+	return &ackList{
+		writer:          writer,
+		timestamps:      make([]uint32, 0, ackNumberLimit),
+		numbers:         make([]uint32, 0, ackNumberLimit),
+		nextFlush:       make([]uint32, 0, ackNumberLimit),
+		flushCandidates: make([]uint32, 0, ackNumberLimit),
+	}
+}
+
+func (l *ackList) add(number uint32, timestamp uint32) {
+	l.timestamps = append(l.timestamps, timestamp)
+	l.numbers = append(l.numbers, number)
+	l.nextFlush = append(l.nextFlush, 0)
+	l.dirty = true
+}
+
+func (l *ackList) clear(una uint32) {
+	count := 0
+	for i := range l.numbers {
+		if l.numbers[i] < una {
+			continue
+		}
+		if i != count {
+			l.numbers[count] = l.numbers[i]
+			l.timestamps[count] = l.timestamps[i]
+			l.nextFlush[count] = l.nextFlush[i]
+		}
+		count++
+	}
+	if count < len(l.numbers) {
+		l.numbers = l.numbers[:count]
+		l.timestamps = l.timestamps[:count]
+		l.nextFlush = l.nextFlush[:count]
+		l.dirty = true
+	}
+}
+
+func (l *ackList) flush(current uint32, rto uint32) {
+	l.flushCandidates = l.flushCandidates[:0]
+	seg := &ackSegment{}
+	for i := range l.numbers {
+		if l.nextFlush[i] > current {
+			if len(l.flushCandidates) < cap(l.flushCandidates) {
+				l.flushCandidates = append(l.flushCandidates, l.numbers[i])
+			}
+			continue
+		}
+		seg.numbers = append(seg.numbers, l.numbers[i])
+		seg.putTimestamp(l.timestamps[i])
+		l.nextFlush[i] = current + max(rto/2, 20)
+		if seg.isFull() {
+			l.writer(seg)
+			seg = &ackSegment{}
+			l.dirty = false
+		}
+	}
+	if l.dirty || len(seg.numbers) > 0 {
+		for _, number := range l.flushCandidates {
+			if seg.isFull() {
+				break
+			}
+			seg.numbers = append(seg.numbers, number)
+		}
+		l.writer(seg)
+		l.dirty = false
+	}
+}
+
+type receivingWorker struct {
+	access     sync.RWMutex
+	conn       *Connection
+	leftOver   []byte
+	window     map[uint32]*dataSegment
+	ackList    *ackList
+	nextNumber uint32
+	windowSize uint32
+}
+
+func newReceivingWorker(conn *Connection) *receivingWorker {
+	worker := &receivingWorker{
+		conn:       conn,
+		window:     make(map[uint32]*dataSegment),
+		windowSize: conn.config.receivingInFlightSize(),
+	}
+	worker.ackList = newAckList(worker.writeSegment)
+	return worker
+}
+
+func (w *receivingWorker) processSendingNext(number uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.ackList.clear(number)
+}
+
+func (w *receivingWorker) processSegment(seg *dataSegment) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if seg.number-w.nextNumber >= w.windowSize {
+		return
+	}
+	w.ackList.clear(seg.sendingNext)
+	w.ackList.add(seg.number, seg.timestamp)
+	if _, loaded := w.window[seg.number]; !loaded {
+		w.window[seg.number] = seg
+	}
+}
+
+func (w *receivingWorker) read(b []byte) int {
+	w.access.Lock()
+	defer w.access.Unlock()
+	var n int
+	for n < len(b) {
+		if len(w.leftOver) == 0 {
+			seg, loaded := w.window[w.nextNumber]
+			if !loaded {
+				break
+			}
+			delete(w.window, w.nextNumber)
+			w.nextNumber++
+			w.leftOver = seg.payload
+		}
+		copied := copy(b[n:], w.leftOver)
+		w.leftOver = w.leftOver[copied:]
+		n += copied
+	}
+	return n
+}
+
+func (w *receivingWorker) isDataAvailable() bool {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	_, loaded := w.window[w.nextNumber]
+	return loaded
+}
+
+func (w *receivingWorker) nextNumberValue() uint32 {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return w.nextNumber
+}
+
+func (w *receivingWorker) flush(current uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.ackList.flush(current, w.conn.roundTrip.timeout())
+}
+
+func (w *receivingWorker) writeSegment(seg *ackSegment) {
+	seg.conv = w.conn.conv
+	seg.receivingNext = w.nextNumber
+	seg.receivingWindow = w.nextNumber + w.windowSize
+	seg.option = 0
+	if w.conn.state() == stateReadyToClose {
+		seg.option = segmentOptionClose
+	}
+	w.conn.writeSegment(seg)
+}
+
+func (w *receivingWorker) release() {
+	w.access.Lock()
+	defer w.access.Unlock()
+	clear(w.window)
+	w.leftOver = nil
+}
+
+func (w *receivingWorker) updateNecessary() bool {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return len(w.ackList.numbers) > 0
+}
@@ -0,0 +1,205 @@
+package v2raykcp
+
+import (
+	"encoding/binary"
+)
+
+type command byte
+
+const (
+	commandACK       command = 0
+	commandData      command = 1
+	commandTerminate command = 2
+	commandPing      command = 3
+)
+
+type segmentOption byte
+
+const (
+	segmentOptionClose segmentOption = 1
+)
+
+const (
+	dataSegmentOverhead = 18
+	ackNumberLimit      = 128
+)
+
+type segment interface {
+	conversation() uint16
+	command() command
+	byteSize() int
+	serialize(b []byte)
+}
+
+type dataSegment struct {
+	conv        uint16
+	option      segmentOption
+	timestamp   uint32
+	number      uint32
+	sendingNext uint32
+	payload     []byte
+
+	timeout  uint32
+	transmit uint32
+}
+
+func (s *dataSegment) conversation() uint16 {
+	return s.conv
+}
+
+func (s *dataSegment) command() command {
+	return commandData
+}
+
+func (s *dataSegment) byteSize() int {
+	return dataSegmentOverhead + len(s.payload)
+}
+
+func (s *dataSegment) serialize(b []byte) {
+	binary.BigEndian.PutUint16(b, s.conv)
+	b[2] = byte(commandData)
+	b[3] = byte(s.option)
+	binary.BigEndian.PutUint32(b[4:], s.timestamp)
+	binary.BigEndian.PutUint32(b[8:], s.number)
+	binary.BigEndian.PutUint32(b[12:], s.sendingNext)
+	binary.BigEndian.PutUint16(b[16:], uint16(len(s.payload)))
+	copy(b[18:], s.payload)
+}
+
+type ackSegment struct {
+	conv            uint16
+	option          segmentOption
+	receivingWindow uint32
+	receivingNext   uint32
+	timestamp       uint32
+	numbers         []uint32
+}
+
+func (s *ackSegment) conversation() uint16 {
+	return s.conv
+}
+
+func (s *ackSegment) command() command {
+	return commandACK
+}
+
+func (s *ackSegment) putTimestamp(timestamp uint32) {
+	if timestamp-s.timestamp < 0x7FFFFFFF {
+		s.timestamp = timestamp
+	}
+}
+
+func (s *ackSegment) isFull() bool {
+	return len(s.numbers) == ackNumberLimit
+}
+
+func (s *ackSegment) byteSize() int {
+	return 17 + len(s.numbers)*4
+}
+
+func (s *ackSegment) serialize(b []byte) {
+	binary.BigEndian.PutUint16(b, s.conv)
+	b[2] = byte(commandACK)
+	b[3] = byte(s.option)
+	binary.BigEndian.PutUint32(b[4:], s.receivingWindow)
+	binary.BigEndian.PutUint32(b[8:], s.receivingNext)
+	binary.BigEndian.PutUint32(b[12:], s.timestamp)
+	b[16] = byte(len(s.numbers))
+	for i, number := range s.numbers {
+		binary.BigEndian.PutUint32(b[17+i*4:], number)
+	}
+}
+
+type cmdOnlySegment struct {
+	conv          uint16
+	cmd           command
+	option        segmentOption
+	sendingNext   uint32
+	receivingNext uint32
+	peerRTO       uint32
+}
+
+func (s *cmdOnlySegment) conversation() uint16 {
+	return s.conv
+}
+
+func (s *cmdOnlySegment) command() command {
+	return s.cmd
+}
+
+func (s *cmdOnlySegment) byteSize() int {
+	return 16
+}
+
+func (s *cmdOnlySegment) serialize(b []byte) {
+	binary.BigEndian.PutUint16(b, s.conv)
+	b[2] = byte(s.cmd)
+	b[3] = byte(s.option)
+	binary.BigEndian.PutUint32(b[4:], s.sendingNext)
+	binary.BigEndian.PutUint32(b[8:], s.receivingNext)
+	binary.BigEndian.PutUint32(b[12:], s.peerRTO)
+}
+
+func readSegment(b []byte) (segment, []byte) {
+	if len(b) < 4 {
+		return nil, nil
+	}
+	conv := binary.BigEndian.Uint16(b)
+	cmd := command(b[2])
+	option := segmentOption(b[3])
+	b = b[4:]
+	switch cmd {
+	case commandData:
+		if len(b) < 14 {
+			return nil, nil
+		}
+		seg := &dataSegment{
+			conv:        conv,
+			option:      option,
+			timestamp:   binary.BigEndian.Uint32(b),
+			number:      binary.BigEndian.Uint32(b[4:]),
+			sendingNext: binary.BigEndian.Uint32(b[8:]),
+		}
+		length := int(binary.BigEndian.Uint16(b[12:]))
+		b = b[14:]
+		if len(b) < length {
+			return nil, nil
+		}
+		seg.payload = make([]byte, length)
+		copy(seg.payload, b)
+		return seg, b[length:]
+	case commandACK:
+		if len(b) < 13 {
+			return nil, nil
+		}
+		seg := &ackSegment{
+			conv:            conv,
+			option:          option,
+			receivingWindow: binary.BigEndian.Uint32(b),
+			receivingNext:   binary.BigEndian.Uint32(b[4:]),
+			timestamp:       binary.BigEndian.Uint32(b[8:]),
+		}
+		count := int(b[12])
+		b = b[13:]
+		if len(b) < count*4 {
+			return nil, nil
+		}
+		seg.numbers = make([]uint32, count)
+		for i := range seg.numbers {
+			seg.numbers[i] = binary.BigEndian.Uint32(b[i*4:])
+		}
+		return seg, b[count*4:]
+	default:
+		if len(b) < 12 {
+			return nil, nil
+		}
+		return &cmdOnlySegment{
+			conv:          conv,
+			cmd:           cmd,
+			option:        option,
+			sendingNext:   binary.BigEndian.Uint32(b),
+			receivingNext: binary.BigEndian.Uint32(b[4:]),
+			peerRTO:       binary.BigEndian.Uint32(b[8:]),
+		}, b[12:]
+	}
+}
@@ -0,0 +1,299 @@
+package v2raykcp
+
+import (
+	"container/list"
+	"sync"
+)
+
+type sendingWindow struct {
+	cache             *list.List
+	totalInFlightSize uint32
+	writer            func(seg *dataSegment)
+	onPacketLoss      func(lossRate uint32)
+}
+
+func newSendingWindow(writer func(seg *dataSegment), onPacketLoss func(lossRate uint32)) *sendingWindow {
+	return &sendingWindow{
+		cache:        list.New(),
+		writer:       writer,
+		onPacketLoss: onPacketLoss,
+	}
+}
+
+func (w *sendingWindow) release() {
+	w.cache.Init()
+}
+
+func (w *sendingWindow) len() uint32 {
+	return uint32(w.cache.Len())
+}
+
+func (w *sendingWindow) isEmpty() bool {
+	return w.cache.Len() == 0
+}
+
+func (w *sendingWindow) push(number uint32, payload []byte) {
+	w.cache.PushBack(&dataSegment{
+		number:  number,
+		payload: payload,
+	})
+}
+
+func (w *sendingWindow) firstNumber() uint32 {
+	return w.cache.Front().Value.(*dataSegment).number
+}
+
+func (w *sendingWindow) clear(una uint32) {
+	for !w.isEmpty() {
+		front := w.cache.Front()
+		if front.Value.(*dataSegment).number >= una {
+			break
+		}
+		w.cache.Remove(front)
+	}
+}
+
+func (w *sendingWindow) visit(visitor func(seg *dataSegment) bool) {
+	for element := w.cache.Front(); element != nil; element = element.Next() {
+		if !visitor(element.Value.(*dataSegment)) {
+			break
+		}
+	}
+}
+
+func (w *sendingWindow) handleFastAck(number uint32, rto uint32) {
+	w.visit(func(seg *dataSegment) bool {
+		if number == seg.number || number-seg.number > 0x7FFFFFFF {
+			return false
+		}
+		if seg.transmit > 0 && seg.timeout > rto/3 {
+			seg.timeout -= rto / 3
+		}
+		return true
+	})
+}
+
+func (w *sendingWindow) flush(current uint32, rto uint32, maxInFlightSize uint32) {
+	if w.isEmpty() {
+		return
+	}
+	var (
+		lost         uint32
+		inFlightSize uint32
+	)
+	w.visit(func(seg *dataSegment) bool {
+		if current-seg.timeout >= 0x7FFFFFFF {
+			return true
+		}
+		if seg.transmit == 0 {
+			w.totalInFlightSize++
+		} else {
+			lost++
+		}
+		seg.timeout = current + rto
+		seg.timestamp = current
+		seg.transmit++
+		w.writer(seg)
+		inFlightSize++
+		return inFlightSize < maxInFlightSize
+	})
+	if w.onPacketLoss != nil && inFlightSize > 0 && w.totalInFlightSize != 0 {
+		w.onPacketLoss(lost * 100 / w.totalInFlightSize)
+	}
+}
+
+func (w *sendingWindow) remove(number uint32) bool {
+	for element := w.cache.Front(); element != nil; element = element.Next() {
+		seg := element.Value.(*dataSegment)
+		if seg.number > number {
+			return false
+		} else if seg.number == number {
+			if w.totalInFlightSize > 0 {
+				w.totalInFlightSize--
+			}
+			w.cache.Remove(element)
+			return true
+		}
+	}
+	return false
+}
+
+type sendingWorker struct {
+	access                     sync.RWMutex
+	conn                       *Connection
+	window                     *sendingWindow
+	firstUnacknowledged        uint32
+	nextNumber                 uint32
+	remoteNextNumber           uint32
+	controlWindow              uint32
+	windowSize                 uint32
+	firstUnacknowledgedUpdated bool
+	closed                     bool
+}
+
+func newSendingWorker(conn *Connection) *sendingWorker {
+	worker := &sendingWorker{
+		conn:             conn,
+		remoteNextNumber: 32,
+		controlWindow:    conn.config.sendingInFlightSize(),
+		windowSize:       conn.config.sendingBufferSize(),
+	}
+	worker.window = newSendingWindow(worker.writeSegment, worker.onPacketLoss)
+	return worker
+}
+
+func (w *sendingWorker) processReceivingNext(nextNumber uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.processReceivingNextWithoutLock(nextNumber)
+}
+
+func (w *sendingWorker) processReceivingNextWithoutLock(nextNumber uint32) {
+	w.window.clear(nextNumber)
+	w.findFirstUnacknowledged()
+}
+
+func (w *sendingWorker) findFirstUnacknowledged() {
+	first := w.firstUnacknowledged
+	if !w.window.isEmpty() {
+		w.firstUnacknowledged = w.window.firstNumber()
+	} else {
+		w.firstUnacknowledged = w.nextNumber
+	}
+	if first != w.firstUnacknowledged {
+		w.firstUnacknowledgedUpdated = true
+	}
+}
+
+func (w *sendingWorker) processAck(number uint32) bool {
+	// number < firstUnacknowledged || number >= nextNumber
+	if number-w.firstUnacknowledged > 0x7FFFFFFF || number-w.nextNumber < 0x7FFFFFFF {
+		return false
+	}
+	removed := w.window.remove(number)
+	if removed {
+		w.findFirstUnacknowledged()
+	}
+	return removed
+}
+
+func (w *sendingWorker) processSegment(current uint32, seg *ackSegment, rto uint32) {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.closed {
+		return
+	}
+	if w.remoteNextNumber < seg.receivingWindow {
+		w.remoteNextNumber = seg.receivingWindow
+	}
+	w.processReceivingNextWithoutLock(seg.receivingNext)
+	if len(seg.numbers) == 0 {
+		return
+	}
+	var (
+		maxAck        uint32
+		maxAckRemoved bool
+	)
+	for _, number := range seg.numbers {
+		removed := w.processAck(number)
+		if maxAck < number {
+			maxAck = number
+			maxAckRemoved = removed
+		}
+	}
+	if maxAckRemoved {
+		w.window.handleFastAck(maxAck, rto)
+		if current-seg.timestamp < 10000 {
+			w.conn.roundTrip.update(current-seg.timestamp, current)
+		}
+	}
+}
+
+func (w *sendingWorker) push(payload []byte) bool {
+	w.access.Lock()
+	defer w.access.Unlock()
+	if w.closed || w.window.len() > w.windowSize {
+		return false
+	}
+	w.window.push(w.nextNumber, payload)
+	w.nextNumber++
+	return true
+}
+
+func (w *sendingWorker) writeSegment(seg *dataSegment) {
+	seg.conv = w.conn.conv
+	seg.sendingNext = w.firstUnacknowledged
+	seg.option = 0
+	if w.conn.state() == stateReadyToClose {
+		seg.option = segmentOptionClose
+	}
+	w.conn.writeSegment(seg)
+}
+
+func (w *sendingWorker) onPacketLoss(lossRate uint32) {
+	if !w.conn.config.congestion || w.conn.roundTrip.timeout() == 0 {
+		return
+	}
+	if lossRate >= 15 {
+		w.controlWindow = 3 * w.controlWindow / 4
+	} else if lossRate <= 5 {
+		w.controlWindow += w.controlWindow / 4
+	}
+	w.controlWindow = max(w.controlWindow, 16)
+	w.controlWindow = min(w.controlWindow, 2*w.conn.config.sendingInFlightSize())
+}
+
+func (w *sendingWorker) flush(current uint32) {
+	w.access.Lock()
+	if w.closed {
+		w.access.Unlock()
+		return
+	}
+	cwnd := w.conn.config.sendingInFlightSize()
+	if cwnd > w.remoteNextNumber-w.firstUnacknowledged {
+		cwnd = w.remoteNextNumber - w.firstUnacknowledged
+	}
+	if w.conn.config.congestion && cwnd > w.controlWindow {
+		cwnd = w.controlWindow
+	}
+	cwnd *= 20 // same magic factor as v2ray
+	if !w.window.isEmpty() {
+		w.window.flush(current, w.conn.roundTrip.timeout(), cwnd)
+		w.firstUnacknowledgedUpdated = false
+	}
+	updated := w.firstUnacknowledgedUpdated
+	w.firstUnacknowledgedUpdated = false
+	w.access.Unlock()
+	if updated {
+		w.conn.ping(current, commandPing)
+	}
+}
+
+func (w *sendingWorker) closeWrite() {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.window.clear(0xFFFFFFFF)
+}
+
+func (w *sendingWorker) release() {
+	w.access.Lock()
+	defer w.access.Unlock()
+	w.window.release()
+	w.closed = true
+}
+
+func (w *sendingWorker) isEmpty() bool {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return w.window.isEmpty()
+}
+
+func (w *sendingWorker) updateNecessary() bool {
+	return !w.isEmpty()
+}
+
+func (w *sendingWorker) firstUnacknowledgedNumber() uint32 {
+	w.access.RLock()
+	defer w.access.RUnlock()
+	return w.firstUnacknowledged
+}
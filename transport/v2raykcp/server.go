@@ -0,0 +1,155 @@
+package v2raykcp
+
+import (
+	"context"
+	"crypto/cipher"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var _ adapter.V2RayServerTransport = (*Server)(nil)
+
+type Server struct {
+	ctx        context.Context
+	logger     logger.ContextLogger
+	config     *config
+	header     packetHeader
+	security   cipher.AEAD
+	tlsConfig  tls.ServerConfig
+	handler    adapter.V2RayServerTransportHandler
+	access     sync.Mutex
+	packetConn net.PacketConn
+	sessions   map[sessionID]*Connection
+}
+
+type sessionID struct {
+	source       M.Socksaddr
+	conversation uint16
+}
+
+func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayKCPOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (adapter.V2RayServerTransport, error) {
+	config, err := newConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	header, _ := newPacketHeader(config.headerType)
+	return &Server{
+		ctx:       ctx,
+		logger:    logger,
+		config:    config,
+		header:    header,
+		security:  newSecurity(config.seed),
+		tlsConfig: tlsConfig,
+		handler:   handler,
+		sessions:  make(map[sessionID]*Connection),
+	}, nil
+}
+
+func (s *Server) Network() []string {
+	return []string{N.NetworkUDP}
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	return os.ErrInvalid
+}
+
+func (s *Server) ServePacket(listener net.PacketConn) error {
+	s.access.Lock()
+	s.packetConn = listener
+	s.access.Unlock()
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := listener.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		s.handlePacket(buffer[:n], addr)
+	}
+}
+
+func (s *Server) handlePacket(b []byte, addr net.Addr) {
+	segments := readPacket(s.header, s.security, b)
+	if len(segments) == 0 {
+		return
+	}
+	id := sessionID{
+		source:       M.SocksaddrFromNet(addr).Unwrap(),
+		conversation: segments[0].conversation(),
+	}
+	s.access.Lock()
+	conn, loaded := s.sessions[id]
+	if !loaded {
+		if segments[0].command() == commandTerminate {
+			s.access.Unlock()
+			return
+		}
+		header, _ := newPacketHeader(s.config.headerType)
+		writer := &sessionWriter{server: s, id: id, destination: addr}
+		conn = newConnection(id.conversation, s.packetConn.LocalAddr(), addr, newPacketWriter(header, s.security, writer), writer, s.config)
+		s.sessions[id] = conn
+		go s.newConnection(conn, id.source)
+	}
+	s.access.Unlock()
+	conn.input(segments)
+}
+
+func (s *Server) newConnection(conn *Connection, source M.Socksaddr) {
+	ctx := log.ContextWithNewID(s.ctx)
+	var netConn net.Conn = conn
+	if s.tlsConfig != nil {
+		tlsConn, err := tls.ServerHandshake(ctx, conn, s.tlsConfig)
+		if err != nil {
+			s.logger.ErrorContext(ctx, E.Cause(err, "process connection from ", source, ": TLS handshake"))
+			conn.Close()
+			return
+		}
+		netConn = tlsConn
+	}
+	s.handler.NewConnectionEx(ctx, netConn, source, M.Socksaddr{}, nil)
+}
+
+func (s *Server) removeSession(id sessionID) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	delete(s.sessions, id)
+}
+
+func (s *Server) Close() error {
+	s.access.Lock()
+	sessions := make([]*Connection, 0, len(s.sessions))
+	for _, conn := range s.sessions {
+		sessions = append(sessions, conn)
+	}
+	packetConn := s.packetConn
+	s.access.Unlock()
+	for _, conn := range sessions {
+		conn.terminate()
+	}
+	return common.Close(packetConn)
+}
+
+type sessionWriter struct {
+	server      *Server
+	id          sessionID
+	destination net.Addr
+}
+
+func (w *sessionWriter) Write(p []byte) (int, error) {
+	return w.server.packetConn.WriteTo(p, w.destination)
+}
+
+func (w *sessionWriter) Close() error {
+	w.server.removeSession(w.id)
+	return nil
+}
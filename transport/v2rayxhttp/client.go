@@ -0,0 +1,222 @@
+package v2rayxhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	sHTTP "github.com/sagernet/sing/protocol/http"
+
+	"github.com/gofrs/uuid/v5"
+	"golang.org/x/net/http2"
+)
+
+var _ adapter.V2RayClientTransport = (*Client)(nil)
+
+type Client struct {
+	transport        http.RoundTripper
+	requestURL       url.URL
+	host             string
+	headers          http.Header
+	mode             string
+	padding          paddingRange
+	maxEachPostBytes int
+	minPostsInterval time.Duration
+}
+
+func NewClient(ctx context.Context, dialer N.Dialer, serverAddr M.Socksaddr, options option.V2RayXHTTPOptions, tlsConfig tls.Config) (adapter.V2RayClientTransport, error) {
+	mode, err := checkMode(options.Mode)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := parsePaddingRange(options.XPaddingBytes)
+	if err != nil {
+		return nil, err
+	}
+	var transport http.RoundTripper
+	if tlsConfig == nil {
+		transport = &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+		}
+	} else {
+		if len(tlsConfig.NextProtos()) == 0 {
+			tlsConfig.SetNextProtos([]string{http2.NextProtoTLS})
+		}
+		tlsDialer := tls.NewDialer(dialer, tlsConfig)
+		transport = &http2.Transport{
+			ReadIdleTimeout: time.Duration(options.IdleTimeout),
+			PingTimeout:     time.Duration(options.PingTimeout),
+			DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.STDConfig) (net.Conn, error) {
+				return tlsDialer.DialTLSContext(ctx, M.ParseSocksaddr(addr))
+			},
+		}
+	}
+	if mode == C.XHTTPModeAuto {
+		mode = C.XHTTPModePacketUp
+	}
+	if mode == C.XHTTPModeStreamOne && tlsConfig == nil {
+		return nil, E.New("stream-one mode requires TLS")
+	}
+	var host string
+	if options.Host != "" {
+		host = options.Host
+	} else if tlsConfig != nil && tlsConfig.ServerName() != "" {
+		host = tlsConfig.ServerName()
+	} else {
+		host = serverAddr.String()
+	}
+	var requestURL url.URL
+	if tlsConfig == nil {
+		requestURL.Scheme = "http"
+	} else {
+		requestURL.Scheme = "https"
+	}
+	requestURL.Host = serverAddr.String()
+	err = sHTTP.URLSetPath(&requestURL, normalizePath(options.Path))
+	if err != nil {
+		return nil, E.Cause(err, "parse path")
+	}
+	maxEachPostBytes := int(options.MaxEachPostBytes)
+	if maxEachPostBytes == 0 {
+		maxEachPostBytes = defaultMaxEachPostBytes
+	}
+	minPostsInterval := time.Duration(options.MinPostsInterval)
+	if minPostsInterval == 0 {
+		minPostsInterval = defaultMinPostsInterval
+	}
+	return &Client{
+		transport:        transport,
+		requestURL:       requestURL,
+		host:             host,
+		headers:          options.Headers.Build(),
+		mode:             mode,
+		padding:          padding,
+		maxEachPostBytes: maxEachPostBytes,
+		minPostsInterval: minPostsInterval,
+	}, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, requestURL *url.URL, body io.Reader) *http.Request {
+	request, _ := http.NewRequestWithContext(ctx, method, requestURL.String(), body)
+	request.Header = c.headers.Clone()
+	request.Host = c.host
+	refererURL := *requestURL
+	refererURL.RawQuery = paddingQueryKey + "=" + c.padding.padding()
+	request.Header.Set("Referer", refererURL.String())
+	return request
+}
+
+func (c *Client) DialContext(ctx context.Context) (net.Conn, error) {
+	connCtx, cancel := context.WithCancel(v2rayhttp.DupContext(ctx))
+	if c.mode == C.XHTTPModeStreamOne {
+		pipeReader, pipeWriter := io.Pipe()
+		request := c.newRequest(connCtx, http.MethodPost, &c.requestURL, pipeReader)
+		conn := &clientConn{v2rayhttp.NewLateHTTPConn(pipeWriter), cancel}
+		go c.roundTripDownload(request, conn)
+		return conn, nil
+	}
+	sessionURL := c.requestURL
+	sessionURL.Path += uuid.Must(uuid.NewV4()).String()
+	var conn *clientConn
+	if c.mode == C.XHTTPModeStreamUp {
+		pipeReader, pipeWriter := io.Pipe()
+		conn = &clientConn{v2rayhttp.NewLateHTTPConn(pipeWriter), cancel}
+		request := c.newRequest(connCtx, http.MethodPost, &sessionURL, pipeReader)
+		go func() {
+			response, err := c.transport.RoundTrip(request)
+			if err == nil {
+				response.Body.Close()
+				if response.StatusCode != http.StatusOK {
+					err = E.New("v2ray-xhttp: unexpected upload status: ", response.Status)
+				}
+			}
+			if err != nil {
+				pipeReader.CloseWithError(err)
+				cancel()
+			}
+		}()
+	} else {
+		uploader := newUploadBuffer(c.maxEachPostBytes)
+		conn = &clientConn{v2rayhttp.NewLateHTTPConn(uploader), cancel}
+		go c.uploadLoop(connCtx, cancel, &sessionURL, uploader)
+	}
+	go c.roundTripDownload(c.newRequest(connCtx, http.MethodGet, &sessionURL, nil), conn)
+	return conn, nil
+}
+
+func (c *Client) roundTripDownload(request *http.Request, conn *clientConn) {
+	response, err := c.transport.RoundTrip(request)
+	if err != nil {
+		conn.Setup(nil, err)
+	} else if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		conn.Setup(nil, E.New("v2ray-xhttp: unexpected status: ", response.Status))
+	} else {
+		conn.Setup(response.Body, nil)
+	}
+}
+
+func (c *Client) uploadLoop(ctx context.Context, cancel context.CancelFunc, sessionURL *url.URL, uploader *uploadBuffer) {
+	var lastPost time.Time
+	for seq := uint64(0); ; seq++ {
+		payload, err := uploader.Take()
+		if err != nil {
+			return
+		}
+		if wait := c.minPostsInterval - time.Since(lastPost); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+		lastPost = time.Now()
+		postURL := *sessionURL
+		postURL.Path += "/" + strconv.FormatUint(seq, 10)
+		request := c.newRequest(ctx, http.MethodPost, &postURL, bytes.NewReader(payload))
+		request.ContentLength = int64(len(payload))
+		response, err := c.transport.RoundTrip(request)
+		if err == nil {
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+			if response.StatusCode != http.StatusOK {
+				err = E.New("v2ray-xhttp: unexpected upload status: ", response.Status)
+			}
+		}
+		if err != nil {
+			uploader.CloseWithError(err)
+			cancel()
+			return
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	c.transport = v2rayhttp.ResetTransport(c.transport)
+	return nil
+}
+
+type clientConn struct {
+	*v2rayhttp.HTTP2Conn
+	cancel context.CancelFunc
+}
+
+func (c *clientConn) Close() error {
+	c.cancel()
+	return c.HTTP2Conn.Close()
+}
@@ -0,0 +1,92 @@
+package v2rayxhttp
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+const (
+	defaultPaddingFrom      = 100
+	defaultPaddingTo        = 1000
+	defaultMaxEachPostBytes = 1000000
+	defaultMinPostsInterval = 30 * time.Millisecond
+	defaultMaxBufferedPosts = 30
+	defaultMaxBufferedBytes = 64 * 1024 * 1024
+	maxPendingSessions      = 1024
+	sessionTimeout          = 30 * time.Second
+	paddingQueryKey         = "x_padding"
+)
+
+type paddingRange struct {
+	from int
+	to   int
+}
+
+func parsePaddingRange(value string) (paddingRange, error) {
+	if value == "" {
+		return paddingRange{defaultPaddingFrom, defaultPaddingTo}, nil
+	}
+	fromString, toString, isRange := strings.Cut(value, "-")
+	from, err := strconv.Atoi(strings.TrimSpace(fromString))
+	if err != nil {
+		return paddingRange{}, E.Cause(err, "parse x_padding_bytes")
+	}
+	to := from
+	if isRange {
+		to, err = strconv.Atoi(strings.TrimSpace(toString))
+		if err != nil {
+			return paddingRange{}, E.Cause(err, "parse x_padding_bytes")
+		}
+	}
+	if from < 0 || to < from {
+		return paddingRange{}, E.New("invalid x_padding_bytes: ", value)
+	}
+	return paddingRange{from, to}, nil
+}
+
+func (r paddingRange) padding() string {
+	length := r.from
+	if r.to > r.from {
+		length += rand.Intn(r.to - r.from + 1)
+	}
+	return strings.Repeat("X", length)
+}
+
+func (r paddingRange) check(request *http.Request) bool {
+	padding := request.URL.Query().Get(paddingQueryKey)
+	if padding == "" {
+		referrer, err := url.Parse(request.Header.Get("Referer"))
+		if err == nil {
+			padding = referrer.Query().Get(paddingQueryKey)
+		}
+	}
+	return len(padding) >= r.from && len(padding) <= r.to
+}
+
+func normalizePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
+
+func checkMode(mode string) (string, error) {
+	switch mode {
+	case "", C.XHTTPModeAuto:
+		return C.XHTTPModeAuto, nil
+	case C.XHTTPModePacketUp, C.XHTTPModeStreamUp, C.XHTTPModeStreamOne:
+		return mode, nil
+	default:
+		return "", E.New("unknown xhttp mode: ", mode)
+	}
+}
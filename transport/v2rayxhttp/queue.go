@@ -0,0 +1,184 @@
+package v2rayxhttp
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+var (
+	errTooManyBufferedUploads = E.New("too many buffered uploads")
+	errTooManyPendingSessions = E.New("too many pending sessions")
+)
+
+// uploadBudget limits the total size of upload packets buffered by all sessions of a server.
+type uploadBudget struct {
+	used atomic.Int64
+	max  int64
+}
+
+func (b *uploadBudget) acquire(size int) bool {
+	if b.used.Add(int64(size)) > b.max {
+		b.used.Add(-int64(size))
+		return false
+	}
+	return true
+}
+
+func (b *uploadBudget) release(size int) {
+	b.used.Add(-int64(size))
+}
+
+// uploadQueue reorders upload packets by sequence number and exposes them as a stream.
+type uploadQueue struct {
+	access     sync.Mutex
+	cond       *sync.Cond
+	packets    map[uint64][]byte
+	nextSeq    uint64
+	current    []byte
+	maxPackets int
+	budget     *uploadBudget
+	closed     bool
+}
+
+func newUploadQueue(maxPackets int, budget *uploadBudget) *uploadQueue {
+	queue := &uploadQueue{
+		packets:    make(map[uint64][]byte),
+		maxPackets: maxPackets,
+		budget:     budget,
+	}
+	queue.cond = sync.NewCond(&queue.access)
+	return queue
+}
+
+func (q *uploadQueue) Push(seq uint64, payload []byte) error {
+	q.access.Lock()
+	defer q.access.Unlock()
+	for !q.closed && seq != q.nextSeq && len(q.packets) >= q.maxPackets {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return net.ErrClosed
+	}
+	if seq < q.nextSeq {
+		return E.New("duplicate packet: ", seq)
+	}
+	if _, loaded := q.packets[seq]; loaded {
+		return E.New("duplicate packet: ", seq)
+	}
+	if !q.budget.acquire(len(payload)) {
+		return errTooManyBufferedUploads
+	}
+	q.packets[seq] = payload
+	q.cond.Broadcast()
+	return nil
+}
+
+func (q *uploadQueue) Read(p []byte) (n int, err error) {
+	q.access.Lock()
+	defer q.access.Unlock()
+	for len(q.current) == 0 {
+		if payload, loaded := q.packets[q.nextSeq]; loaded {
+			delete(q.packets, q.nextSeq)
+			q.budget.release(len(payload))
+			q.nextSeq++
+			q.current = payload
+			q.cond.Broadcast()
+			continue
+		}
+		if q.closed {
+			return 0, io.EOF
+		}
+		q.cond.Wait()
+	}
+	n = copy(p, q.current)
+	q.current = q.current[n:]
+	return n, nil
+}
+
+func (q *uploadQueue) Close() error {
+	q.access.Lock()
+	defer q.access.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+	return nil
+}
+
+// Discard closes the queue and drops packets not read yet.
+func (q *uploadQueue) Discard() {
+	q.access.Lock()
+	defer q.access.Unlock()
+	q.closed = true
+	for _, payload := range q.packets {
+		q.budget.release(len(payload))
+	}
+	clear(q.packets)
+	q.current = nil
+	q.cond.Broadcast()
+}
+
+// uploadBuffer collects written data for packet-up uploads.
+type uploadBuffer struct {
+	access  sync.Mutex
+	cond    *sync.Cond
+	buffer  []byte
+	maxSize int
+	err     error
+}
+
+func newUploadBuffer(maxSize int) *uploadBuffer {
+	buffer := &uploadBuffer{
+		maxSize: maxSize,
+	}
+	buffer.cond = sync.NewCond(&buffer.access)
+	return buffer
+}
+
+func (b *uploadBuffer) Write(p []byte) (n int, err error) {
+	b.access.Lock()
+	defer b.access.Unlock()
+	for b.err == nil && len(b.buffer) >= b.maxSize {
+		b.cond.Wait()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.buffer = append(b.buffer, p...)
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+// Take returns the buffered data, at most maxSize bytes, blocking until data is available.
+func (b *uploadBuffer) Take() ([]byte, error) {
+	b.access.Lock()
+	defer b.access.Unlock()
+	for b.err == nil && len(b.buffer) == 0 {
+		b.cond.Wait()
+	}
+	if len(b.buffer) == 0 {
+		return nil, b.err
+	}
+	size := min(len(b.buffer), b.maxSize)
+	payload := make([]byte, size)
+	copy(payload, b.buffer)
+	b.buffer = append(b.buffer[:0], b.buffer[size:]...)
+	b.cond.Broadcast()
+	return payload, nil
+}
+
+func (b *uploadBuffer) CloseWithError(err error) {
+	b.access.Lock()
+	defer b.access.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.cond.Broadcast()
+}
+
+func (b *uploadBuffer) Close() error {
+	b.CloseWithError(net.ErrClosed)
+	return nil
+}
@@ -0,0 +1,372 @@
+package v2rayxhttp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/v2rayhttp"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	aTLS "github.com/sagernet/sing/common/tls"
+	sHttp "github.com/sagernet/sing/protocol/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var _ adapter.V2RayServerTransport = (*Server)(nil)
+
+type Server struct {
+	ctx              context.Context
+	logger           logger.ContextLogger
+	tlsConfig        tls.ServerConfig
+	handler          adapter.V2RayServerTransportHandler
+	httpServer       *http.Server
+	h2Server         *http2.Server
+	h2cHandler       http.Handler
+	host             string
+	path             string
+	mode             string
+	headers          http.Header
+	padding          paddingRange
+	maxEachPostBytes int
+	maxBufferedPosts int
+	uploadBudget     uploadBudget
+	sessionAccess    sync.Mutex
+	sessions         map[string]*session
+	pendingSessions  int
+}
+
+type session struct {
+	queue     *uploadQueue
+	connected bool
+	timer     *time.Timer
+}
+
+func NewServer(ctx context.Context, logger logger.ContextLogger, options option.V2RayXHTTPOptions, tlsConfig tls.ServerConfig, handler adapter.V2RayServerTransportHandler) (*Server, error) {
+	mode, err := checkMode(options.Mode)
+	if err != nil {
+		return nil, err
+	}
+	padding, err := parsePaddingRange(options.XPaddingBytes)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{
+		ctx:              ctx,
+		logger:           logger,
+		tlsConfig:        tlsConfig,
+		handler:          handler,
+		h2Server:         &http2.Server{IdleTimeout: time.Duration(options.IdleTimeout)},
+		host:             options.Host,
+		path:             normalizePath(options.Path),
+		mode:             mode,
+		headers:          options.Headers.Build(),
+		padding:          padding,
+		maxEachPostBytes: int(options.MaxEachPostBytes),
+		maxBufferedPosts: int(options.MaxBufferedPosts),
+		sessions:         make(map[string]*session),
+	}
+	if server.maxEachPostBytes == 0 {
+		server.maxEachPostBytes = defaultMaxEachPostBytes
+	}
+	if server.maxBufferedPosts == 0 {
+		server.maxBufferedPosts = defaultMaxBufferedPosts
+	}
+	// a single session must be able to fill its queue
+	server.uploadBudget.max = int64(max(defaultMaxBufferedBytes, server.maxEachPostBytes*server.maxBufferedPosts))
+	server.httpServer = &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: C.TCPTimeout,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return log.ContextWithNewID(ctx)
+		},
+	}
+	server.h2cHandler = h2c.NewHandler(server, server.h2Server)
+	return server, nil
+}
+
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.Method == "PRI" && len(request.Header) == 0 && request.URL.Path == "*" && request.Proto == "HTTP/2.0" {
+		s.h2cHandler.ServeHTTP(writer, request)
+		return
+	}
+	host := request.Host
+	if len(s.host) > 0 && host != s.host {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.New("bad host: ", host))
+		return
+	}
+	if !strings.HasPrefix(request.URL.Path, s.path) {
+		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad path: ", request.URL.Path))
+		return
+	}
+	if !s.padding.check(request) {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.New("invalid padding"))
+		return
+	}
+	for key, values := range s.headers {
+		for _, value := range values {
+			writer.Header().Set(key, value)
+		}
+	}
+	writer.Header().Set("X-Padding", s.padding.padding())
+	var sessionID, seq string
+	subPath := strings.Trim(strings.TrimPrefix(request.URL.Path, s.path), "/")
+	if subPath != "" {
+		sessionID, seq, _ = strings.Cut(subPath, "/")
+	}
+	switch {
+	case sessionID == "" && request.Method == http.MethodPost:
+		if !s.allowMode(C.XHTTPModeStreamOne) {
+			s.invalidRequest(writer, request, http.StatusNotFound, E.New("stream-one mode is disabled"))
+			return
+		}
+		s.serveStreamOne(writer, request)
+	case sessionID != "" && seq == "" && request.Method == http.MethodGet:
+		s.serveDownload(writer, request, sessionID)
+	case sessionID != "" && seq == "" && request.Method == http.MethodPost:
+		if !s.allowMode(C.XHTTPModeStreamUp) {
+			s.invalidRequest(writer, request, http.StatusNotFound, E.New("stream-up mode is disabled"))
+			return
+		}
+		s.serveStreamUp(writer, request, sessionID)
+	case sessionID != "" && seq != "" && request.Method == http.MethodPost:
+		if !s.allowMode(C.XHTTPModePacketUp) {
+			s.invalidRequest(writer, request, http.StatusNotFound, E.New("packet-up mode is disabled"))
+			return
+		}
+		s.servePacketUp(writer, request, sessionID, seq)
+	default:
+		s.invalidRequest(writer, request, http.StatusNotFound, E.New("bad request: ", request.Method, " ", request.URL.Path))
+	}
+}
+
+func (s *Server) allowMode(mode string) bool {
+	return s.mode == C.XHTTPModeAuto || s.mode == mode
+}
+
+func (s *Server) serveStreamOne(writer http.ResponseWriter, request *http.Request) {
+	http.NewResponseController(writer).EnableFullDuplex()
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusOK)
+	flusher := writer.(http.Flusher)
+	flusher.Flush()
+	s.serveConn(writer, request, request.Body, flusher)
+}
+
+func (s *Server) serveDownload(writer http.ResponseWriter, request *http.Request, sessionID string) {
+	s.sessionAccess.Lock()
+	currentSession, err := s.loadSessionLocked(sessionID, true)
+	if err != nil {
+		s.sessionAccess.Unlock()
+		s.invalidRequest(writer, request, http.StatusServiceUnavailable, err)
+		return
+	}
+	if currentSession.connected {
+		s.sessionAccess.Unlock()
+		s.invalidRequest(writer, request, http.StatusConflict, E.New("duplicate download request for session ", sessionID))
+		return
+	}
+	currentSession.connected = true
+	s.pendingSessions--
+	currentSession.timer.Stop()
+	s.sessionAccess.Unlock()
+	defer s.removeSession(sessionID, currentSession)
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.WriteHeader(http.StatusOK)
+	flusher := writer.(http.Flusher)
+	flusher.Flush()
+	s.serveConn(writer, request, currentSession.queue, flusher)
+}
+
+func (s *Server) serveConn(writer http.ResponseWriter, request *http.Request, reader io.Reader, flusher http.Flusher) {
+	done := make(chan struct{})
+	conn := v2rayhttp.NewHTTP2Wrapper(&v2rayhttp.ServerHTTPConn{
+		HTTP2Conn: v2rayhttp.NewHTTPConn(reader, writer),
+		Flusher:   flusher,
+	})
+	s.handler.NewConnectionEx(request.Context(), conn, sHttp.SourceAddress(request), M.Socksaddr{}, N.OnceClose(func(it error) {
+		close(done)
+	}))
+	select {
+	case <-done:
+	case <-request.Context().Done():
+	}
+	conn.CloseWrapper()
+}
+
+func (s *Server) serveStreamUp(writer http.ResponseWriter, request *http.Request, sessionID string) {
+	currentSession, err := s.loadSession(sessionID)
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusServiceUnavailable, err)
+		return
+	}
+	http.NewResponseController(writer).EnableFullDuplex()
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Set("Cache-Control", "no-store")
+	writer.WriteHeader(http.StatusOK)
+	writer.(http.Flusher).Flush()
+	var seq uint64
+	for {
+		payload := make([]byte, buf.BufferSize)
+		n, err := request.Body.Read(payload)
+		if n > 0 {
+			pushErr := currentSession.queue.Push(seq, payload[:n])
+			if pushErr != nil {
+				if pushErr != net.ErrClosed {
+					s.logger.DebugContext(request.Context(), E.Cause(pushErr, "push upload stream"))
+				}
+				return
+			}
+			seq++
+		}
+		if err != nil {
+			if err != io.EOF && !E.IsClosedOrCanceled(err) {
+				s.logger.DebugContext(request.Context(), E.Cause(err, "read upload stream"))
+			}
+			currentSession.queue.Close()
+			return
+		}
+	}
+}
+
+func (s *Server) servePacketUp(writer http.ResponseWriter, request *http.Request, sessionID string, seqString string) {
+	seq, err := strconv.ParseUint(seqString, 10, 64)
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.Cause(err, "parse seq"))
+		return
+	}
+	if request.ContentLength > int64(s.maxEachPostBytes) {
+		s.invalidRequest(writer, request, http.StatusRequestEntityTooLarge, E.New("too large upload: ", request.ContentLength))
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(request.Body, int64(s.maxEachPostBytes)+1))
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.Cause(err, "read upload"))
+		return
+	}
+	if len(payload) > s.maxEachPostBytes {
+		s.invalidRequest(writer, request, http.StatusRequestEntityTooLarge, E.New("too large upload"))
+		return
+	}
+	currentSession, err := s.loadSession(sessionID)
+	if err != nil {
+		s.invalidRequest(writer, request, http.StatusServiceUnavailable, err)
+		return
+	}
+	err = currentSession.queue.Push(seq, payload)
+	if err == errTooManyBufferedUploads {
+		s.invalidRequest(writer, request, http.StatusServiceUnavailable, E.Cause(err, "push upload"))
+		return
+	} else if err != nil {
+		s.invalidRequest(writer, request, http.StatusBadRequest, E.Cause(err, "push upload"))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) loadSession(sessionID string) (*session, error) {
+	s.sessionAccess.Lock()
+	defer s.sessionAccess.Unlock()
+	return s.loadSessionLocked(sessionID, false)
+}
+
+// loadSessionLocked returns the session or creates a pending one, which is not connected to a download request yet.
+// Uploads may create pending sessions without authentication, so their number is limited.
+func (s *Server) loadSessionLocked(sessionID string, download bool) (*session, error) {
+	currentSession, loaded := s.sessions[sessionID]
+	if loaded {
+		return currentSession, nil
+	}
+	if !download && s.pendingSessions >= maxPendingSessions {
+		return nil, errTooManyPendingSessions
+	}
+	currentSession = &session{
+		queue: newUploadQueue(s.maxBufferedPosts, &s.uploadBudget),
+	}
+	currentSession.timer = time.AfterFunc(sessionTimeout, func() {
+		s.sessionAccess.Lock()
+		connected := currentSession.connected
+		s.sessionAccess.Unlock()
+		if !connected {
+			s.removeSession(sessionID, currentSession)
+		}
+	})
+	s.sessions[sessionID] = currentSession
+	s.pendingSessions++
+	return currentSession, nil
+}
+
+func (s *Server) removeSession(sessionID string, currentSession *session) {
+	s.sessionAccess.Lock()
+	if s.sessions[sessionID] == currentSession {
+		delete(s.sessions, sessionID)
+		if !currentSession.connected {
+			s.pendingSessions--
+		}
+	}
+	s.sessionAccess.Unlock()
+	currentSession.queue.Discard()
+}
+
+func (s *Server) invalidRequest(writer http.ResponseWriter, request *http.Request, statusCode int, err error) {
+	if statusCode > 0 {
+		writer.WriteHeader(statusCode)
+	}
+	s.logger.ErrorContext(request.Context(), E.Cause(err, "process connection from ", request.RemoteAddr))
+}
+
+func (s *Server) Network() []string {
+	return []string{N.NetworkTCP}
+}
+
+func (s *Server) Serve(listener net.Listener) error {
+	if s.tlsConfig != nil {
+		if len(s.tlsConfig.NextProtos()) == 0 {
+			s.tlsConfig.SetNextProtos([]string{http2.NextProtoTLS, "http/1.1"})
+		} else if !common.Contains(s.tlsConfig.NextProtos(), http2.NextProtoTLS) {
+			s.tlsConfig.SetNextProtos(append([]string{http2.NextProtoTLS}, s.tlsConfig.NextProtos()...))
+		}
+		listener = aTLS.NewListener(listener, s.tlsConfig)
+	}
+	return s.httpServer.Serve(listener)
+}
+
+func (s *Server) ServePacket(listener net.PacketConn) error {
+	return os.ErrInvalid
+}
+
+func (s *Server) Close() error {
+	s.sessionAccess.Lock()
+	for _, currentSession := range s.sessions {
+		currentSession.timer.Stop()
+		currentSession.queue.Discard()
+	}
+	clear(s.sessions)
+	s.pendingSessions = 0
+	s.sessionAccess.Unlock()
+	return common.Close(common.PtrOrNil(s.httpServer))
+}
@@ -0,0 +1,116 @@
+package v2rayxhttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/logger"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+type echoHandler struct{}
+
+func (h *echoHandler) NewConnectionEx(ctx context.Context, conn net.Conn, source M.Socksaddr, destination M.Socksaddr, onClose N.CloseHandlerFunc) {
+	io.Copy(conn, conn)
+	conn.Close()
+	onClose(nil)
+}
+
+func startServer(t *testing.T, options option.V2RayXHTTPOptions) M.Socksaddr {
+	server, err := NewServer(context.Background(), logger.NOP(), options, nil, &echoHandler{})
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return M.SocksaddrFromNet(listener.Addr())
+}
+
+func TestLoopback(t *testing.T) {
+	t.Parallel()
+	for _, mode := range []string{C.XHTTPModePacketUp, C.XHTTPModeStreamUp} {
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+			serverAddr := startServer(t, option.V2RayXHTTPOptions{Path: "/xhttp"})
+			client, err := NewClient(context.Background(), N.SystemDialer, serverAddr, option.V2RayXHTTPOptions{
+				Path:             "/xhttp",
+				Mode:             mode,
+				MaxEachPostBytes: 4096,
+			}, nil)
+			require.NoError(t, err)
+			defer client.Close()
+			conn, err := client.DialContext(context.Background())
+			require.NoError(t, err)
+			defer conn.Close()
+			// larger than a single post, so that packet-up sends several ordered packets
+			content := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+			go conn.Write(content)
+			response := make([]byte, len(content))
+			_, err = io.ReadFull(conn, response)
+			require.NoError(t, err)
+			require.Equal(t, content, response)
+		})
+	}
+}
+
+func newTestServer(t *testing.T) *Server {
+	server, err := NewServer(context.Background(), logger.NOP(), option.V2RayXHTTPOptions{
+		Path:          "/xhttp",
+		XPaddingBytes: "0",
+	}, nil, &echoHandler{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return server
+}
+
+func postUpload(server *Server, sessionID string, seq uint64, payload []byte) int {
+	request := httptest.NewRequest(http.MethodPost, "/xhttp/"+sessionID+"/"+strconv.FormatUint(seq, 10), bytes.NewReader(payload))
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestPendingSessionLimit(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+	for i := 0; i < maxPendingSessions; i++ {
+		require.Equal(t, http.StatusOK, postUpload(server, "session-"+strconv.Itoa(i), 0, []byte("hello")))
+	}
+	require.Equal(t, http.StatusServiceUnavailable, postUpload(server, "session-overflow", 0, []byte("hello")))
+	// uploads to existing sessions are still accepted
+	require.Equal(t, http.StatusOK, postUpload(server, "session-0", 1, []byte("hello")))
+	server.sessionAccess.Lock()
+	currentSession := server.sessions["session-0"]
+	server.sessionAccess.Unlock()
+	server.removeSession("session-0", currentSession)
+	require.Equal(t, http.StatusOK, postUpload(server, "session-overflow", 0, []byte("hello")))
+}
+
+func TestBufferedBytesLimit(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(t)
+	server.uploadBudget.max = 10
+	require.Equal(t, http.StatusOK, postUpload(server, "session-a", 1, []byte("012345")))
+	require.Equal(t, http.StatusServiceUnavailable, postUpload(server, "session-b", 1, []byte("012345")))
+	server.sessionAccess.Lock()
+	currentSession := server.sessions["session-a"]
+	server.sessionAccess.Unlock()
+	server.removeSession("session-a", currentSession)
+	require.Zero(t, server.uploadBudget.used.Load())
+	require.Equal(t, http.StatusOK, postUpload(server, "session-b", 2, []byte("012345")))
+}